@@ -16,7 +16,6 @@ import (
 	"hash"
 	"io"
 
-	circlPki "github.com/cloudflare/circl/pki"
 	circlSign "github.com/cloudflare/circl/sign"
 )
 
@@ -57,6 +56,16 @@ func verifyHandshakeSignature(sigType uint8, pubkey crypto.PublicKey, hashFunc c
 		if err := rsa.VerifyPSS(pubKey, hashFunc, signed, sig, signOpts); err != nil {
 			return err
 		}
+	// [uTLS SECTION BEGIN]
+	case signatureSM2:
+		pubKey, ok := pubkey.(*sm2PublicKey)
+		if !ok {
+			return fmt.Errorf("expected an SM2 public key, got %T", pubkey)
+		}
+		if !sm2Verify(pubKey, signed, sig) {
+			return errors.New("SM2 verification failure")
+		}
+	// [uTLS SECTION END]
 	default:
 		// [UTLS SECTION BEGINS]
 		// Ported from cloudflare/go
@@ -121,10 +130,12 @@ func typeAndHashFromSignatureScheme(signatureAlgorithm SignatureScheme) (sigType
 		sigType = signatureECDSA
 	case Ed25519:
 		sigType = signatureEd25519
+	case SM2WithSM3: // [uTLS]
+		sigType = signatureSM2
 	default:
 		// [UTLS SECTION BEGINS]
 		// Ported from cloudflare/go
-		scheme := circlPki.SchemeByTLSID(uint(signatureAlgorithm))
+		scheme := circlSchemeByTLSID(signatureAlgorithm) // [uTLS]
 		if scheme == nil {
 			return 0, 0, fmt.Errorf("unsupported signature algorithm: %v", signatureAlgorithm)
 		}
@@ -144,12 +155,12 @@ func typeAndHashFromSignatureScheme(signatureAlgorithm SignatureScheme) (sigType
 		hash = crypto.SHA384
 	case PKCS1WithSHA512, PSSWithSHA512, ECDSAWithP521AndSHA512:
 		hash = crypto.SHA512
-	case Ed25519:
+	case Ed25519, SM2WithSM3: // [uTLS] SM2WithSM3
 		hash = directSigning
 	default:
 		// [UTLS SECTION BEGINS]
 		// Ported from cloudflare/go
-		scheme := circlPki.SchemeByTLSID(uint(signatureAlgorithm))
+		scheme := circlSchemeByTLSID(signatureAlgorithm) // [uTLS]
 		if scheme == nil {
 			return 0, 0, fmt.Errorf("unsupported signature algorithm: %v", signatureAlgorithm)
 		}
@@ -178,6 +189,8 @@ func legacyTypeAndHashFromPublicKey(pub crypto.PublicKey) (sigType uint8, hash c
 	// Ported from cloudflare/go
 	case circlSign.PublicKey:
 		return 0, 0, fmt.Errorf("tls: circl public keys are not supported before TLS 1.2")
+	case *sm2PublicKey:
+		return 0, 0, fmt.Errorf("tls: SM2 public keys are not supported before TLS 1.3")
 	// [UTLS SECTION ENDS]
 	default:
 		return 0, 0, fmt.Errorf("tls: unsupported public key: %T", pub)
@@ -252,12 +265,17 @@ func signatureSchemesForCertificate(version uint16, cert *Certificate) []Signatu
 	// [UTLS SECTION BEGINS]
 	// Ported from cloudflare/go
 	case circlSign.PublicKey:
-		scheme := pub.Scheme()
-		tlsScheme, ok := scheme.(circlPki.TLSScheme)
-		if !ok {
+		tlsID := tlsIDByCirclScheme(pub.Scheme(), version) // [uTLS]
+		if tlsID == 0 {
+			return nil
+		}
+		sigAlgs = []SignatureScheme{tlsID}
+	case *sm2PublicKey:
+		// RFC 8998 only defines sm2sig_sm3 for TLS 1.3.
+		if version != VersionTLS13 {
 			return nil
 		}
-		sigAlgs = []SignatureScheme{SignatureScheme(tlsScheme.TLSIdentifier())}
+		sigAlgs = []SignatureScheme{SM2WithSM3}
 	// [UTLS SECTION ENDS]
 	default:
 		return nil
@@ -108,6 +108,14 @@ func CipherSuiteName(id uint16) string {
 			return c.Name
 		}
 	}
+	// [uTLS SECTION BEGIN]
+	switch id {
+	case TLS_SM4_GCM_SM3:
+		return "TLS_SM4_GCM_SM3"
+	case TLS_SM4_CCM_SM3:
+		return "TLS_SM4_CCM_SM3"
+	}
+	// [uTLS SECTION END]
 	return fmt.Sprintf("0x%04X", id)
 }
 
@@ -128,6 +136,9 @@ const (
 	// suiteSHA384 indicates that the cipher suite uses SHA384 as the
 	// handshake hash.
 	suiteSHA384
+	// suiteDHE indicates that the cipher suite involves finite field
+	// Diffie-Hellman with an RSA signature, see dheKeyAgreement.
+	suiteDHE // [uTLS]
 )
 
 // A cipherSuite is a TLS 1.0–1.2 cipher suite, and defines the key exchange
@@ -195,7 +206,7 @@ type cipherSuiteTLS13 struct {
 	id     uint16
 	keyLen int
 	aead   func(key, fixedNonce []byte) aead
-	hash   crypto.Hash
+	hash   tls13Hash // [uTLS] was crypto.Hash, see hashSM3
 }
 
 // cipherSuitesTLS13 should be an internal detail,
@@ -209,9 +220,9 @@ type cipherSuiteTLS13 struct {
 //
 //go:linkname cipherSuitesTLS13
 var cipherSuitesTLS13 = []*cipherSuiteTLS13{ // TODO: replace with a map.
-	{TLS_AES_128_GCM_SHA256, 16, aeadAESGCMTLS13, crypto.SHA256},
-	{TLS_CHACHA20_POLY1305_SHA256, 32, aeadChaCha20Poly1305, crypto.SHA256},
-	{TLS_AES_256_GCM_SHA384, 32, aeadAESGCMTLS13, crypto.SHA384},
+	{TLS_AES_128_GCM_SHA256, 16, aeadAESGCMTLS13, tls13Hash(crypto.SHA256)},            // [uTLS]
+	{TLS_CHACHA20_POLY1305_SHA256, 32, aeadChaCha20Poly1305, tls13Hash(crypto.SHA256)}, // [uTLS]
+	{TLS_AES_256_GCM_SHA384, 32, aeadAESGCMTLS13, tls13Hash(crypto.SHA384)},            // [uTLS]
 }
 
 // cipherSuitesPreferenceOrder is the order in which we'll select (on the
@@ -658,6 +669,15 @@ func cipherSuiteByID(id uint16) *cipherSuite {
 			return cipherSuite
 		}
 	}
+	// [uTLS SECTION BEGIN]
+	if weakCiphersEnabled.Load() {
+		for _, cipherSuite := range globalWeakCipherSuites {
+			if cipherSuite.id == id {
+				return cipherSuite
+			}
+		}
+	}
+	// [uTLS SECTION END]
 	return nil
 }
 
@@ -676,6 +696,13 @@ func cipherSuiteTLS13ByID(id uint16) *cipherSuiteTLS13 {
 			return cipherSuite
 		}
 	}
+	// [uTLS SECTION BEGIN]
+	for _, cipherSuite := range shangMiCipherSuitesTLS13 {
+		if cipherSuite.id == id {
+			return cipherSuite
+		}
+	}
+	// [uTLS SECTION END]
 	return nil
 }
 
@@ -166,11 +166,11 @@ const (
 )
 
 func isTLS13OnlyKeyExchange(curve CurveID) bool {
-	return curve == X25519MLKEM768
+	return curve == X25519MLKEM768 || isMLKEMGroup(curve) || curve == CurveSM2 // [uTLS]
 }
 
 func isPQKeyExchange(curve CurveID) bool {
-	return curve == X25519MLKEM768
+	return curve == X25519MLKEM768 || isMLKEMGroup(curve) // [uTLS]
 }
 
 // TLS 1.3 Key Share. See RFC 8446, Section 4.2.8.
@@ -218,6 +218,10 @@ const (
 	signatureECDSA
 	signatureEd25519
 	signatureEdDilithium3
+	signatureMLDSA44 // [uTLS]
+	signatureMLDSA65 // [uTLS]
+	signatureMLDSA87 // [uTLS]
+	signatureSM2     // [uTLS]
 )
 
 // directSigning is a standard Hash value that signals that no pre-hashing
@@ -262,12 +266,14 @@ type ConnectionState struct {
 	// TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256, TLS_AES_128_GCM_SHA256).
 	CipherSuite uint16
 
-	// NegotiatedProtocol is the application protocol negotiated with ALPN.
+	// NegotiatedProtocol is the application protocol negotiated with ALPN,
+	// or with NPN in TLS 1.2 handshakes.
 	NegotiatedProtocol string
 
 	// NegotiatedProtocolIsMutual used to indicate a mutual NPN negotiation.
 	//
-	// Deprecated: this value is always true.
+	// Deprecated: this value is always true, except for clients which
+	// negotiated NPN without a protocol in common with the server.
 	NegotiatedProtocolIsMutual bool
 
 	// PeerApplicationSettings is the Application-Layer Protocol Settings (ALPS)
@@ -332,6 +338,21 @@ type ConnectionState struct {
 	// EncryptedExtensions message. It is only populated if the server sent the
 	// ech extension in EncryptedExtensions message.
 	ECHRetryConfigs []ECHConfig // [uTLS]
+
+	// PSKIdentity is the ExternalPSK.Identity of the external pre-shared key
+	// selected by the server, or nil if the connection was not authenticated
+	// with an external PSK.
+	PSKIdentity []byte // [uTLS]
+
+	// VerifiedSCTs are the Signed Certificate Timestamps of the leaf
+	// certificate of the server which were verified according to
+	// Config.CTPolicy, on full handshakes.
+	VerifiedSCTs []*SignedCertificateTimestamp // [uTLS]
+
+	// FetchedIntermediates are the intermediate certificates which the
+	// server didn't send, and which were fetched according to
+	// Config.AIAFetching to verify its certificate, on full handshakes.
+	FetchedIntermediates []*x509.Certificate // [uTLS]
 }
 
 // ExportKeyingMaterial returns length bytes of exported key material in a new
@@ -390,9 +411,9 @@ func requiresClientCert(c ClientAuthType) bool {
 // ClientSessionCache is a cache of ClientSessionState objects that can be used
 // by a client to resume a TLS session with a given server. ClientSessionCache
 // implementations should expect to be called concurrently from different
-// goroutines. Up to TLS 1.2, only ticket-based resumption is supported, not
-// SessionID-based resumption. In TLS 1.3 they were merged into PSK modes, which
-// are supported via this interface.
+// goroutines. Up to TLS 1.2, both ticket-based and SessionID-based resumption
+// are supported. In TLS 1.3 they were merged into PSK modes, which are
+// supported via this interface.
 type ClientSessionCache interface {
 	// Get searches for a ClientSessionState associated with the given key.
 	// On return, ok is true if one was found.
@@ -751,6 +772,102 @@ type Config struct {
 	// By default, utls throws an exception in such scenarios. Set this to true to skip the resumption and suppress the exception.
 	PreferSkipResumptionOnNilExtension bool // [uTLS]
 
+	// ExternalPSKs are TLS 1.3 pre-shared keys established out of band, see
+	// RFC 8446, Section 2.2.
+	//
+	// A client offers all of them, in order, after the resumption ticket if
+	// any. With a ClientHelloSpec, they are offered by an
+	// ExternalPreSharedKeyExtension. They can't be combined with Encrypted
+	// Client Hello: building the ClientHello fails if
+	// EncryptedClientHelloConfigList is set too.
+	//
+	// A server accepts any of them, and prefers them over session tickets.
+	// Connections authenticated with an external PSK don't use certificates.
+	ExternalPSKs []ExternalPSK // [uTLS]
+
+	// PSKKeyExchangeModes are the psk_key_exchange_modes (PskModePlain,
+	// PskModeDHE) allowed with ExternalPSKs, see RFC 8446, Section 4.2.9.
+	//
+	// A client advertises them when it offers external PSKs, unless the
+	// ClientHelloSpec carries its own PSKKeyExchangeModesExtension. A server
+	// picks the first one in this list that the client supports. If empty,
+	// only PskModeDHE is used. Session resumption always uses PskModeDHE.
+	PSKKeyExchangeModes []uint8 // [uTLS]
+
+	// KeySharePredictionCache, if not nil, remembers the group each server
+	// asked for in a HelloRetryRequest, keyed like ClientSessionCache. When
+	// a ClientHelloSpec is applied, a key share for the remembered group is
+	// sent upfront, provided the spec's supported_groups lists it, so that
+	// the next connection to that server doesn't need a HelloRetryRequest.
+	KeySharePredictionCache KeySharePredictionCache // [uTLS]
+
+	// CipherSuiteRegistry, if not nil, provides implementations of TLS
+	// 1.0–1.2 cipher suites which aren't built in, or are too weak to be
+	// negotiated by default, for the connections using this Config. The
+	// suites are negotiated when listed in CipherSuites or, for a client,
+	// in the ClientHelloSpec.
+	CipherSuiteRegistry *CipherSuiteRegistry // [uTLS]
+
+	// EncryptThenMAC controls whether a server negotiates encrypt_then_mac,
+	// RFC 7366, with clients which offer it and a CBC cipher suite. Clients
+	// offer it with EncryptThenMACExtension in their ClientHelloSpec.
+	EncryptThenMAC bool // [uTLS]
+
+	// ServerSessionCache, if not nil, is used by a server to cache TLS 1.0–1.2
+	// sessions under the session ID sent in the ServerHello, and to resume
+	// them when clients offer that session ID without a valid session ticket.
+	// It is used even if SessionTicketsDisabled is set.
+	ServerSessionCache ServerSessionCache // [uTLS]
+
+	// KeyUpdatePolicy, if not nil, makes TLS 1.3 connections update their
+	// traffic keys automatically, like long-lived browser connections do.
+	// Keys can also be updated with Conn.SendKeyUpdate.
+	KeyUpdatePolicy *KeyUpdatePolicy // [uTLS]
+
+	// RevocationCheck, if not nil, makes a client check the revocation status
+	// of the leaf certificate of servers, with the OCSP response they stapled
+	// or, optionally, with OCSP responders and CRLs. Clients ask for stapled
+	// responses with StatusRequestExtension in their ClientHelloSpec.
+	//
+	// Like VerifyPeerCertificate, it isn't called on resumed sessions, of
+	// which the certificates were checked by the original connection. Clear
+	// ClientSessionCache to check every connection.
+	RevocationCheck *RevocationCheck // [uTLS]
+
+	// CTPolicy, if not nil, makes a client verify the Signed Certificate
+	// Timestamps of the leaf certificate of servers, and optionally require
+	// them. Clients ask for SCTs with SCTExtension, and for stapled OCSP
+	// responses, which may carry SCTs, with StatusRequestExtension. As
+	// RevocationCheck, it isn't enforced on resumed sessions.
+	CTPolicy *CTPolicy // [uTLS]
+
+	// CertificatePins, if not nil, makes a client check the public keys of
+	// the certificate chains of servers against per-host pins, after they
+	// were verified. As RevocationCheck, they aren't checked on resumed
+	// sessions, so sessions cached before the pins changed stay usable.
+	CertificatePins *CertificatePins // [uTLS]
+
+	// AIAFetching, if not nil, makes a client fetch the intermediate
+	// certificates which servers didn't send, as browsers do, when it
+	// verifies their certificates.
+	AIAFetching *AIAFetching // [uTLS]
+
+	// RootStore, if not nil, is the root store of a browser with which a
+	// client verifies the certificates of servers, rather than RootCAs. See
+	// also BindRootStore.
+	RootStore *RootStore // [uTLS]
+
+	// HandshakeTracer, if not nil, is called for every event of the
+	// handshakes of connections, with timestamps, for logging or metrics.
+	HandshakeTracer HandshakeTracer // [uTLS]
+
+	// InsecureShangMi enables the ShangMi cipher suites, key exchange group
+	// and signature scheme of RFC 8998, see TLS_SM4_GCM_SM3. Their SM2 and
+	// SM4 implementations are not constant time, and may leak keys through
+	// timing side channels: they are experimental, and should only be
+	// enabled to interoperate with servers which require them.
+	InsecureShangMi bool // [uTLS]
+
 	// CipherSuites is a list of enabled TLS 1.0–1.2 cipher suites. The order of
 	// the list is ignored. Note that TLS 1.3 ciphersuites are not configurable.
 	//
@@ -775,6 +892,10 @@ type Config struct {
 	// SessionTicketsDisabled may be set to true to disable session ticket and
 	// PSK (resumption) support. Note that on clients, session ticket support is
 	// also disabled if ClientSessionCache is nil.
+	//
+	// [uTLS] On clients, it disables TLS 1.0–1.2 session ID resumption as well:
+	// no session is saved to or loaded from ClientSessionCache. On servers,
+	// ServerSessionCache is still used.
 	SessionTicketsDisabled bool
 
 	// SessionTicketKey is used by TLS servers to provide session resumption.
@@ -847,8 +968,11 @@ type Config struct {
 	CurvePreferences []CurveID
 
 	// PQSignatureSchemesEnabled controls whether additional post-quantum
-	// signature schemes are supported for peer certificates. For available
-	// signature schemes, see tls_cf.go.
+	// signature schemes, such as [MLDSA65], are advertised and supported for
+	// peer certificates. For available signature schemes, see tls_cf.go.
+	//
+	// Certificates and PKCS #8 private keys for these schemes are decoded to
+	// circl keys regardless of this setting.
 	PQSignatureSchemesEnabled bool // [UTLS] ported from cloudflare/go
 
 	// DynamicRecordSizingDisabled disables adaptive sizing of TLS records.
@@ -1051,6 +1175,20 @@ func (c *Config) Clone() *Config {
 
 		PreferSkipResumptionOnNilExtension: c.PreferSkipResumptionOnNilExtension, // [UTLS]
 		ECHConfigs:                         c.ECHConfigs,                         // [uTLS]
+		ExternalPSKs:                       c.ExternalPSKs,                       // [uTLS]
+		PSKKeyExchangeModes:                c.PSKKeyExchangeModes,                // [uTLS]
+		KeySharePredictionCache:            c.KeySharePredictionCache,            // [uTLS]
+		CipherSuiteRegistry:                c.CipherSuiteRegistry,                // [uTLS]
+		EncryptThenMAC:                     c.EncryptThenMAC,                     // [uTLS]
+		ServerSessionCache:                 c.ServerSessionCache,                 // [uTLS]
+		KeyUpdatePolicy:                    c.KeyUpdatePolicy,                    // [uTLS]
+		RevocationCheck:                    c.RevocationCheck,                    // [uTLS]
+		CTPolicy:                           c.CTPolicy,                           // [uTLS]
+		CertificatePins:                    c.CertificatePins,                    // [uTLS]
+		AIAFetching:                        c.AIAFetching,                        // [uTLS]
+		RootStore:                          c.RootStore,                          // [uTLS]
+		HandshakeTracer:                    c.HandshakeTracer,                    // [uTLS]
+		InsecureShangMi:                    c.InsecureShangMi,                    // [uTLS]
 	}
 }
 
@@ -1295,12 +1433,17 @@ func (c *Config) curvePreferences(version uint16) []CurveID {
 	// }
 	// [uTLS] SECTION END
 	if c != nil && len(c.CurvePreferences) != 0 {
+		curvePreferences = withOptInCurvePreferences(curvePreferences) // [uTLS]
 		curvePreferences = slices.DeleteFunc(curvePreferences, func(x CurveID) bool {
-			return !slices.Contains(c.CurvePreferences, x)
+			return !slices.Contains(c.CurvePreferences, x) ||
+				x == CurveSM2 && !c.shangMiEnabled() // [uTLS]
 		})
 	}
 	if version < VersionTLS13 {
 		curvePreferences = slices.DeleteFunc(curvePreferences, isTLS13OnlyKeyExchange)
+		// [uTLS] TLS 1.2 uses FFDHE groups through the DHE cipher suites
+		// only, see selectDHEGroup.
+		curvePreferences = slices.DeleteFunc(curvePreferences, isFFDHEGroup)
 	}
 	return curvePreferences
 }
@@ -1563,7 +1706,7 @@ func (cri *CertificateRequestInfo) SupportsCertificate(c *Certificate) error {
 		// chain.Leaf was nil.
 		if j != 0 || x509Cert == nil {
 			var err error
-			if x509Cert, err = x509.ParseCertificate(cert); err != nil {
+			if x509Cert, err = parseCertificate(cert); err != nil { // [uTLS]
 				return fmt.Errorf("failed to parse certificate #%d in the chain: %w", j, err)
 			}
 		}
@@ -1684,7 +1827,7 @@ func (c *Certificate) leaf() (*x509.Certificate, error) {
 	if c.Leaf != nil {
 		return c.Leaf, nil
 	}
-	return x509.ParseCertificate(c.Certificate[0])
+	return parseCertificate(c.Certificate[0]) // [uTLS]
 }
 
 type handshakeMessage interface {
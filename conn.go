@@ -9,6 +9,7 @@ package tls
 import (
 	"bytes"
 	"context"
+	"crypto"
 	"crypto/cipher"
 	"crypto/subtle"
 	"crypto/x509"
@@ -56,6 +57,9 @@ type Conn struct {
 	// activeCertHandles contains the cache handles to certificates in
 	// peerCertificates that are used to track active references.
 	activeCertHandles []*activeCert
+	// peerPublicKey is the public key of peerCertificates[0], decoded by
+	// certificatePublicKey.
+	peerPublicKey crypto.PublicKey // [uTLS]
 	// verifiedChains contains the certificate chains that we built, as
 	// opposed to the ones presented by the server.
 	verifiedChains [][]*x509.Certificate
@@ -183,8 +187,17 @@ type halfConn struct {
 	nextCipher any       // next encryption state
 	nextMac    hash.Hash // next MAC algorithm
 
+	// [uTLS] encrypt_then_mac, see RFC 7366 and prepareEncryptThenMAC
+	encryptThenMAC     bool
+	nextEncryptThenMAC bool
+
 	level         QUICEncryptionLevel // current QUIC encryption level
 	trafficSecret []byte              // current TLS 1.3 traffic secret
+
+	// [uTLS] bytes protected with the current TLS 1.3 traffic secret, and
+	// whether the peer was asked to update it, see KeyUpdatePolicy
+	keyUpdateBytes     uint64
+	keyUpdateRequested bool
 }
 
 type permanentError struct {
@@ -221,8 +234,10 @@ func (hc *halfConn) changeCipherSpec() error {
 	}
 	hc.cipher = hc.nextCipher
 	hc.mac = hc.nextMac
+	hc.encryptThenMAC = hc.nextEncryptThenMAC // [uTLS]
 	hc.nextCipher = nil
 	hc.nextMac = nil
+	hc.nextEncryptThenMAC = false // [uTLS]
 	for i := range hc.seq {
 		hc.seq[i] = 0
 	}
@@ -237,6 +252,7 @@ func (hc *halfConn) setTrafficSecret(suite *cipherSuiteTLS13, level QUICEncrypti
 	for i := range hc.seq {
 		hc.seq[i] = 0
 	}
+	hc.keyUpdateBytes, hc.keyUpdateRequested = 0, false // [uTLS]
 }
 
 // incSeq increments the sequence number.
@@ -386,6 +402,15 @@ func (hc *halfConn) decrypt(record []byte) ([]byte, recordType, error) {
 				return nil, 0, alertBadRecordMAC
 			}
 		case cbcMode:
+			// [uTLS SECTION BEGIN]
+			if hc.encryptThenMAC {
+				var err error
+				if plaintext, err = hc.decryptEncryptThenMAC(c, record, explicitNonceLen); err != nil {
+					return nil, 0, err
+				}
+				break
+			}
+			// [uTLS SECTION END]
 			blockSize := c.BlockSize()
 			minPayload := explicitNonceLen + roundUp(hc.mac.Size()+1, blockSize)
 			if len(payload)%blockSize != 0 || len(payload) < minPayload {
@@ -432,7 +457,7 @@ func (hc *halfConn) decrypt(record []byte) ([]byte, recordType, error) {
 		plaintext = payload
 	}
 
-	if hc.mac != nil {
+	if hc.mac != nil && !hc.encryptThenMAC { // [uTLS]
 		macSize := hc.mac.Size()
 		if len(payload) < macSize {
 			return nil, 0, alertBadRecordMAC
@@ -538,6 +563,12 @@ func (hc *halfConn) encrypt(record, payload []byte, rand io.Reader) ([]byte, err
 			record = c.Seal(record, nonce, payload, additionalData)
 		}
 	case cbcMode:
+		// [uTLS SECTION BEGIN]
+		if hc.encryptThenMAC {
+			record = hc.encryptEncryptThenMAC(c, record, payload, explicitNonce)
+			break
+		}
+		// [uTLS SECTION END]
 		mac := tls10MAC(hc.mac, hc.scratchBuf[:0], hc.seq[:], record[:recordHeaderLen], payload, nil)
 		blockSize := c.BlockSize()
 		plaintextLen := len(payload) + len(mac)
@@ -614,6 +645,11 @@ func (c *Conn) readRecordOrCCS(expectChangeCipherSpec bool) error {
 	if c.in.err != nil {
 		return c.in.err
 	}
+	// [uTLS SECTION BEGIN]
+	if err := c.finishFalseStart(); err != nil {
+		return err
+	}
+	// [uTLS SECTION END]
 	handshakeComplete := c.isHandshakeComplete.Load()
 
 	// This function modifies c.rawInput, which owns the c.input memory.
@@ -773,6 +809,8 @@ func (c *Conn) readRecordOrCCS(expectChangeCipherSpec bool) error {
 		// to avoid copying the plaintext. This is safe because c.rawInput is
 		// not read from or written to until c.input is drained.
 		c.input.Reset(data)
+		c.in.keyUpdateBytes += uint64(len(data)) // [uTLS]
+		c.maybeRequestKeyUpdate()                // [uTLS]
 
 	case recordTypeHandshake:
 		if len(data) == 0 || expectChangeCipherSpec {
@@ -829,6 +867,11 @@ func (c *Conn) readFromUntil(r io.Reader, n int) error {
 	// attempt to fetch it so that it can be used in (*Conn).Read to
 	// "predict" closeNotify alerts.
 	c.rawInput.Grow(needs + bytes.MinRead)
+	// [uTLS SECTION BEGIN]
+	if c.utls.pcapngRecorder != nil {
+		r = &pcapngReader{r: r, recorder: c.utls.pcapngRecorder}
+	}
+	// [uTLS SECTION END]
 	_, err := c.rawInput.ReadFrom(&atLeastReader{r, int64(needs)})
 	return err
 }
@@ -912,6 +955,13 @@ func (c *Conn) maxPayloadSizeForWrite(typ recordType) int {
 			payloadBytes -= ciph.Overhead()
 		case cbcMode:
 			blockSize := ciph.BlockSize()
+			// [uTLS SECTION BEGIN]
+			if c.out.encryptThenMAC {
+				// The MAC follows the padded ciphertext.
+				payloadBytes = ((payloadBytes - c.out.mac.Size()) & ^(blockSize - 1)) - 1
+				break
+			}
+			// [uTLS SECTION END]
 			// The payload must fit in a multiple of blockSize, with
 			// room for at least one padding byte.
 			payloadBytes = (payloadBytes & ^(blockSize - 1)) - 1
@@ -948,6 +998,7 @@ func (c *Conn) write(data []byte) (int, error) {
 
 	n, err := c.conn.Write(data)
 	c.bytesSent += int64(n)
+	c.recordPcapngSegment(data[:n]) // [uTLS]
 	return n, err
 }
 
@@ -958,6 +1009,7 @@ func (c *Conn) flush() (int, error) {
 
 	n, err := c.conn.Write(c.sendBuf)
 	c.bytesSent += int64(n)
+	c.recordPcapngSegment(c.sendBuf[:n]) // [uTLS]
 	c.sendBuf = nil
 	c.buffering = false
 	return n, err
@@ -1000,6 +1052,13 @@ func (c *Conn) writeRecordLocked(typ recordType, data []byte) (int, error) {
 
 	var n int
 	for len(data) > 0 {
+		// [uTLS SECTION BEGIN]
+		if typ == recordTypeApplicationData {
+			if err := c.maybeUpdateSendingKeyLocked(); err != nil {
+				return n, err
+			}
+		}
+		// [uTLS SECTION END]
 		m := len(data)
 		if maxPayload := c.maxPayloadSizeForWrite(typ); m > maxPayload {
 			m = maxPayload
@@ -1030,6 +1089,7 @@ func (c *Conn) writeRecordLocked(typ recordType, data []byte) (int, error) {
 		if _, err := c.write(outBuf); err != nil {
 			return n, err
 		}
+		c.out.keyUpdateBytes += uint64(m) // [uTLS]
 		n += m
 		data = data[m:]
 	}
@@ -1058,6 +1118,14 @@ func (c *Conn) writeHandshakeRecord(msg handshakeMessage, transcript transcriptH
 		transcript.Write(data)
 	}
 
+	// [uTLS SECTION BEGIN]
+	c.traceHandshakeMessage(msg, data, true)
+	c.recordHandshakeMessage(data, true)
+	if _, ok := msg.(*clientHelloMsg); ok && c.utls.clientHelloFragmentation != nil && c.quic == nil {
+		return c.writeFragmentedClientHelloLocked(data)
+	}
+	// [uTLS SECTION END]
+
 	return c.writeRecordLocked(recordTypeHandshake, data)
 }
 
@@ -1116,6 +1184,8 @@ func (c *Conn) readHandshake(transcript transcriptHash) (any, error) {
 }
 
 func (c *Conn) unmarshalHandshakeMessage(data []byte, transcript transcriptHash) (handshakeMessage, error) {
+	c.recordHandshakeMessage(data, false) // [uTLS]
+
 	var m handshakeMessage
 	switch data[0] {
 	case typeHelloRequest:
@@ -1185,6 +1255,7 @@ func (c *Conn) unmarshalHandshakeMessage(data []byte, transcript transcriptHash)
 	if !m.unmarshal(data) {
 		return nil, c.in.setErrorLocked(c.sendAlert(alertUnexpectedMessage))
 	}
+	c.traceHandshakeMessage(m, data, false) // [uTLS]
 
 	if transcript != nil {
 		transcript.Write(data)
@@ -1326,6 +1397,10 @@ func (c *Conn) handlePostHandshakeMessage() error {
 		return c.handleNewSessionTicket(msg)
 	case *keyUpdateMsg:
 		return c.handleKeyUpdate(msg)
+	case *certificateRequestMsgTLS13: // [uTLS]
+		return c.handlePostHandshakeCertificateRequest(msg)
+	case *certificateMsgTLS13: // [uTLS]
+		return c.handlePostHandshakeCertificate(msg)
 	}
 	// The QUIC layer is supposed to treat an unexpected post-handshake CertificateRequest
 	// as a QUIC-level PROTOCOL_VIOLATION error (RFC 9001, Section 4.4). Returning an
@@ -1575,7 +1650,9 @@ func (c *Conn) handshakeContext(ctx context.Context) (ret error) {
 	c.in.Lock()
 	defer c.in.Unlock()
 
+	c.traceHandshake(HandshakeEventStart, nil, nil) // [uTLS]
 	c.handshakeErr = c.handshakeFn(handshakeCtx)
+	c.traceHandshake(HandshakeEventDone, nil, c.handshakeErr) // [uTLS]
 	if c.handshakeErr == nil {
 		c.handshakes++
 	} else {
@@ -50,6 +50,12 @@ const (
 	SupportedGroups_ffdhe4096                       uint16 = 258
 	SupportedGroups_ffdhe6144                       uint16 = 259
 	SupportedGroups_ffdhe8192                       uint16 = 260
+	SupportedGroups_MLKEM512                        uint16 = 512
+	SupportedGroups_MLKEM768                        uint16 = 513
+	SupportedGroups_MLKEM1024                       uint16 = 514
+	SupportedGroups_SecP256r1MLKEM768               uint16 = 4587
+	SupportedGroups_X25519MLKEM768                  uint16 = 4588
+	SupportedGroups_SecP384r1MLKEM1024              uint16 = 4589
 	SupportedGroups_arbitrary_explicit_prime_curves uint16 = 65281
 	SupportedGroups_arbitrary_explicit_char2_curves uint16 = 65282
 )
@@ -101,6 +107,12 @@ var DictSupportedGroupsValueIndexed = map[uint16]string{
 	258:   "ffdhe4096",
 	259:   "ffdhe6144",
 	260:   "ffdhe8192",
+	512:   "MLKEM512",
+	513:   "MLKEM768",
+	514:   "MLKEM1024",
+	4587:  "SecP256r1MLKEM768",
+	4588:  "X25519MLKEM768",
+	4589:  "SecP384r1MLKEM1024",
 	65281: "arbitrary_explicit_prime_curves",
 	65282: "arbitrary_explicit_char2_curves",
 }
@@ -152,6 +164,12 @@ var DictSupportedGroupsNameIndexed = map[string]uint16{
 	"ffdhe4096":                       258,
 	"ffdhe6144":                       259,
 	"ffdhe8192":                       260,
+	"MLKEM512":                        512,
+	"MLKEM768":                        513,
+	"MLKEM1024":                       514,
+	"SecP256r1MLKEM768":               4587,
+	"X25519MLKEM768":                  4588,
+	"SecP384r1MLKEM1024":              4589,
 	"arbitrary_explicit_prime_curves": 65281,
 	"arbitrary_explicit_char2_curves": 65282,
 }
@@ -108,7 +108,7 @@ func (c *Conn) makeClientHello() (*clientHelloMsg, *keySharePrivateKeys, *echCli
 	hello.cipherSuites = make([]uint16, 0, len(configCipherSuites))
 
 	for _, suiteId := range preferenceOrder {
-		suite := mutualCipherSuite(configCipherSuites, suiteId)
+		suite := config.mutualCipherSuite(configCipherSuites, suiteId) // [uTLS]
 		if suite == nil {
 			continue
 		}
@@ -119,6 +119,7 @@ func (c *Conn) makeClientHello() (*clientHelloMsg, *keySharePrivateKeys, *echCli
 		}
 		hello.cipherSuites = append(hello.cipherSuites, suiteId)
 	}
+	hello.cipherSuites = config.appendUTLSCipherSuites(hello.cipherSuites, configCipherSuites, maxVersion) // [uTLS]
 
 	_, err := io.ReadFull(config.rand(), hello.random)
 	if err != nil {
@@ -138,7 +139,7 @@ func (c *Conn) makeClientHello() (*clientHelloMsg, *keySharePrivateKeys, *echCli
 	}
 
 	if maxVersion >= VersionTLS12 {
-		hello.supportedSignatureAlgorithms = supportedSignatureAlgorithms()
+		hello.supportedSignatureAlgorithms = signatureSchemesForVersion(config.supportedSignatureAlgorithms(), maxVersion) // [uTLS]
 	}
 	if testingOnlyForceClientHelloSignatureAlgorithms != nil {
 		hello.supportedSignatureAlgorithms = testingOnlyForceClientHelloSignatureAlgorithms
@@ -157,6 +158,9 @@ func (c *Conn) makeClientHello() (*clientHelloMsg, *keySharePrivateKeys, *echCli
 		} else {
 			hello.cipherSuites = append(hello.cipherSuites, defaultCipherSuitesTLS13NoAES...)
 		}
+		if !fips140tls.Required() {
+			hello.cipherSuites = append(hello.cipherSuites, config.shangMiCipherSuites()...) // [uTLS]
+		}
 
 		if len(hello.supportedCurves) == 0 {
 			return nil, nil, nil, errors.New("tls: no supported elliptic curves for ECDHE")
@@ -189,6 +193,15 @@ func (c *Conn) makeClientHello() (*clientHelloMsg, *keySharePrivateKeys, *echCli
 			if slices.Contains(hello.supportedCurves, X25519) {
 				hello.keyShares = append(hello.keyShares, keyShare{group: X25519, data: x25519EphemeralKey})
 			}
+			// [uTLS SECTION BEGIN]
+		} else if isExtraGroup(curveID) {
+			key, data, err := generateExtraKeyShare(config, curveID)
+			if err != nil {
+				return nil, nil, nil, err
+			}
+			keyShareKeys.extraKeyShares = []extraKeyShare{key}
+			hello.keyShares = []keyShare{{group: curveID, data: data}}
+			// [uTLS SECTION END]
 		} else {
 			if _, ok := curveForCurveID(curveID); !ok {
 				return nil, nil, nil, errors.New("tls: CurvePreferences includes unsupported curve")
@@ -287,6 +300,11 @@ func (c *Conn) clientHandshake(ctx context.Context) (err error) {
 	if err != nil {
 		return err
 	}
+	// [uTLS SECTION BEGIN]
+	if err := c.offerExternalPSKs(hello, session, binderKey); err != nil {
+		return err
+	}
+	// [uTLS SECTION END]
 	if session != nil {
 		defer func() {
 			// If we got a handshake failure when resuming a session, throw away
@@ -297,7 +315,7 @@ func (c *Conn) clientHandshake(ctx context.Context) (err error) {
 			// delete tickets to recover from a corrupted PSK.
 			if err != nil {
 				if cacheKey := c.clientSessionCacheKey(); cacheKey != "" {
-					c.config.ClientSessionCache.Put(cacheKey, nil)
+					c.clientSessionCache().Put(cacheKey, nil) // [uTLS]
 				}
 			}
 		}()
@@ -328,6 +346,8 @@ func (c *Conn) clientHandshake(ctx context.Context) (err error) {
 
 	c.serverName = hello.serverName
 
+	c.traceHandshakeMsg(HandshakeEventClientHelloBuilt, hello) // [uTLS]
+	c.recordECHInnerHello(ech)                                 // [uTLS]
 	if _, err := c.writeHandshakeRecord(hello, nil); err != nil {
 		return err
 	}
@@ -431,7 +451,7 @@ func (c *Conn) loadSession(hello *clientHelloMsg) (
 	if cacheKey == "" {
 		return nil, nil, nil, nil
 	}
-	cs, ok := c.config.ClientSessionCache.Get(cacheKey)
+	cs, ok := c.clientSessionCache().Get(cacheKey) // [uTLS]
 	if !ok || cs == nil {
 		return nil, nil, nil, nil
 	}
@@ -456,7 +476,7 @@ func (c *Conn) loadSession(hello *clientHelloMsg) (
 	if !c.config.InsecureSkipTimeVerify {
 		if c.config.time().After(session.peerCertificates[0].NotAfter) {
 			// Expired certificate, delete the entry.
-			c.config.ClientSessionCache.Put(cacheKey, nil)
+			c.clientSessionCache().Put(cacheKey, nil) // [uTLS]
 			return nil, nil, nil, nil
 		}
 	}
@@ -484,17 +504,23 @@ func (c *Conn) loadSession(hello *clientHelloMsg) (
 	if session.version != VersionTLS13 {
 		// In TLS 1.2 the cipher suite must match the resumed session. Ensure we
 		// are still offering it.
-		if mutualCipherSuite(hello.cipherSuites, session.cipherSuite) == nil {
+		if c.config.mutualCipherSuite(hello.cipherSuites, session.cipherSuite) == nil { // [uTLS]
 			return nil, nil, nil, nil
 		}
 
 		hello.sessionTicket = session.ticket
+		// [uTLS SECTION BEGIN]
+		if session.ticket == nil && c.quic == nil {
+			// Offer the session ID the server cached the session under.
+			hello.sessionId = session.sessionID
+		}
+		// [uTLS SECTION END]
 		return
 	}
 
 	// Check that the session ticket is not expired.
 	if c.config.time().After(time.Unix(int64(session.useBy), 0)) {
-		c.config.ClientSessionCache.Put(cacheKey, nil)
+		c.clientSessionCache().Put(cacheKey, nil) // [uTLS]
 		return nil, nil, nil, nil
 	}
 
@@ -647,6 +673,12 @@ func (hs *clientHandshakeState) handshake() error {
 			return err
 		}
 		c.clientFinishedIsFirst = true
+		// [uTLS SECTION BEGIN]
+		if hs.falseStart() {
+			hs.startFalseStart()
+			return nil
+		}
+		// [uTLS SECTION END]
 		if err := hs.readSessionTicket(); err != nil {
 			return err
 		}
@@ -665,7 +697,7 @@ func (hs *clientHandshakeState) handshake() error {
 }
 
 func (hs *clientHandshakeState) pickCipherSuite() error {
-	if hs.suite = mutualCipherSuite(hs.hello.cipherSuites, hs.serverHello.cipherSuite); hs.suite == nil {
+	if hs.suite = hs.c.config.mutualCipherSuite(hs.hello.cipherSuites, hs.serverHello.cipherSuite); hs.suite == nil { // [uTLS]
 		hs.c.sendAlert(alertHandshakeFailure)
 		return errors.New("tls: server chose an unconfigured cipher suite")
 	}
@@ -728,7 +760,7 @@ func (hs *clientHandshakeState) doFullHandshake() error {
 	if c.handshakes == 0 {
 		// If this is the first handshake on a connection, process and
 		// (optionally) verify the server's certificates.
-		if err := c.verifyServerCertificate(certMsg.certificates); err != nil {
+		if err := c.verifyServerCertificate(hs.ctx, certMsg.certificates); err != nil { // [uTLS] ctx
 			return err
 		}
 	} else {
@@ -753,7 +785,9 @@ func (hs *clientHandshakeState) doFullHandshake() error {
 			c.sendAlert(alertIllegalParameter)
 			return err
 		}
-		if len(skx.key) >= 3 && skx.key[0] == 3 /* named curve */ {
+		if dhe, ok := keyAgreement.(*dheKeyAgreement); ok { // [uTLS]
+			c.curveID = dhe.group
+		} else if len(skx.key) >= 3 && skx.key[0] == 3 /* named curve */ {
 			c.curveID = CurveID(byteorder.BEUint16(skx.key[1:]))
 		}
 
@@ -817,7 +851,7 @@ func (hs *clientHandshakeState) doFullHandshake() error {
 		hs.masterSecret = masterFromPreMasterSecret(c.vers, hs.suite, preMasterSecret,
 			hs.hello.random, hs.serverHello.random)
 	}
-	if err := c.config.writeKeyLog(keyLogLabelTLS12, hs.hello.random, hs.masterSecret); err != nil {
+	if err := c.writeKeyLog(keyLogLabelTLS12, hs.hello.random, hs.masterSecret); err != nil { // [uTLS]
 		c.sendAlert(alertInternalError)
 		return errors.New("tls: failed to write to key log: " + err.Error())
 	}
@@ -893,6 +927,8 @@ func (hs *clientHandshakeState) establishKeys() error {
 
 	c.in.prepareCipherSpec(c.vers, serverCipher, serverHash)
 	c.out.prepareCipherSpec(c.vers, clientCipher, clientHash)
+	c.in.prepareEncryptThenMAC(hs.serverHello.encryptThenMAC)  // [uTLS]
+	c.out.prepareEncryptThenMAC(hs.serverHello.encryptThenMAC) // [uTLS]
 	return nil
 }
 
@@ -915,6 +951,13 @@ func (hs *clientHandshakeState) processServerHello() (bool, error) {
 		return false, errors.New("tls: server selected unsupported compression format")
 	}
 
+	// [uTLS SECTION BEGIN]
+	if hs.serverHello.encryptThenMAC && !hs.hello.encryptThenMAC {
+		c.sendAlert(alertUnsupportedExtension)
+		return false, errors.New("tls: server sent an unsolicited encrypt_then_mac extension")
+	}
+	// [uTLS SECTION END]
+
 	if c.handshakes == 0 && hs.serverHello.secureRenegotiationSupported {
 		c.secureRenegotiation = true
 		if len(hs.serverHello.secureRenegotiation) != 0 {
@@ -938,6 +981,9 @@ func (hs *clientHandshakeState) processServerHello() (bool, error) {
 		return false, err
 	}
 	c.clientProtocol = hs.serverHello.alpnProtocol
+	if err := hs.processServerNPN(); err != nil { // [uTLS]
+		return false, err
+	}
 
 	c.scts = hs.serverHello.scts
 
@@ -1059,7 +1105,8 @@ func (hs *clientHandshakeState) readSessionTicket() error {
 }
 
 func (hs *clientHandshakeState) saveSessionTicket() error {
-	if hs.ticket == nil {
+	sessionID := hs.sessionIDToSave() // [uTLS]
+	if hs.ticket == nil && sessionID == nil {
 		return nil
 	}
 	c := hs.c
@@ -1072,11 +1119,12 @@ func (hs *clientHandshakeState) saveSessionTicket() error {
 	session := c.sessionState()
 	session.secret = hs.masterSecret
 	session.ticket = hs.ticket
+	session.sessionID = sessionID // [uTLS]
 
 	cs := &ClientSessionState{session: session}
 	// [UTLS BEGIN]
 	if c.config.ClientSessionCache != nil { // skip saving session if cache is nil
-		c.config.ClientSessionCache.Put(cacheKey, cs)
+		c.clientSessionCache().Put(cacheKey, cs) // [uTLS]
 	}
 	// [UTLS END]
 	return nil
@@ -1088,6 +1136,9 @@ func (hs *clientHandshakeState) sendFinished(out []byte) error {
 	if err := c.writeChangeCipherRecord(); err != nil {
 		return err
 	}
+	if err := hs.sendNextProtocol(); err != nil { // [uTLS]
+		return err
+	}
 
 	finished := new(finishedMsg)
 	finished.verifyData = hs.finishedHash.clientSum(hs.masterSecret)
@@ -1121,7 +1172,10 @@ func checkKeySize(n int) (max int, ok bool) {
 
 // verifyServerCertificate parses and verifies the provided chain, setting
 // c.verifiedChains and c.peerCertificates or sending the appropriate alert.
-func (c *Conn) verifyServerCertificate(certificates [][]byte) error {
+//
+// [uTLS] ctx is the handshake context, which bounds the HTTP requests of
+// Config.AIAFetching and Config.RevocationCheck.
+func (c *Conn) verifyServerCertificate(ctx context.Context, certificates [][]byte) error {
 	activeHandles := make([]*activeCert, len(certificates))
 	certs := make([]*x509.Certificate, len(certificates))
 	for i, asn1Data := range certificates {
@@ -1171,7 +1225,7 @@ func (c *Conn) verifyServerCertificate(certificates [][]byte) error {
 			for _, cert := range certs[1:] {
 				opts.Intermediates.AddCert(cert)
 			}
-			chains, err := certs[0].Verify(opts)
+			chains, err := c.verifyWithAIA(ctx, certs, opts) // [uTLS]
 			if err != nil {
 				c.sendAlert(alertBadCertificate)
 				return &CertificateVerificationError{UnverifiedCertificates: certs, Err: err}
@@ -1206,7 +1260,7 @@ func (c *Conn) verifyServerCertificate(certificates [][]byte) error {
 		for _, cert := range certs[1:] {
 			opts.Intermediates.AddCert(cert)
 		}
-		chains, err := certs[0].Verify(opts)
+		chains, err := c.verifyWithAIA(ctx, certs, opts) // [uTLS]
 		if err != nil {
 			c.sendAlert(alertBadCertificate)
 			return &CertificateVerificationError{UnverifiedCertificates: certs, Err: err}
@@ -1219,16 +1273,39 @@ func (c *Conn) verifyServerCertificate(certificates [][]byte) error {
 		}
 	}
 
-	switch certs[0].PublicKey.(type) {
-	case *rsa.PublicKey, *ecdsa.PublicKey, ed25519.PublicKey, circlSign.PublicKey: // [UTLS] ported from cloudflare/go
+	// [uTLS SECTION BEGINS]
+	publicKey, err := certificatePublicKey(certs[0])
+	if err != nil {
+		c.sendAlert(alertBadCertificate)
+		return err
+	}
+	// [uTLS SECTION ENDS]
+
+	switch publicKey.(type) {
+	case *rsa.PublicKey, *ecdsa.PublicKey, ed25519.PublicKey, circlSign.PublicKey, *sm2PublicKey: // [UTLS] ported from cloudflare/go, and SM2
 		break
 	default:
 		c.sendAlert(alertUnsupportedCertificate)
-		return fmt.Errorf("tls: server's certificate contains an unsupported type of public key: %T", certs[0].PublicKey)
+		return fmt.Errorf("tls: server's certificate contains an unsupported type of public key: %T", publicKey)
 	}
 
 	c.activeCertHandles = activeHandles
 	c.peerCertificates = certs
+	c.peerPublicKey = publicKey // [uTLS]
+
+	// [uTLS SECTION BEGIN]
+	if !echRejected {
+		if err := c.checkRevocation(ctx, certs); err != nil {
+			return err
+		}
+		if err := c.checkCertificateTransparency(certs); err != nil {
+			return err
+		}
+		if err := c.checkPins(certs); err != nil {
+			return err
+		}
+	}
+	// [uTLS SECTION END]
 
 	if c.config.VerifyPeerCertificate != nil && !echRejected {
 		if err := c.config.VerifyPeerCertificate(certificates, c.verifiedChains); err != nil {
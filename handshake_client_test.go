@@ -2798,7 +2798,7 @@ func TestHandshakeRSATooBig(t *testing.T) {
 	c := &Conn{conn: &discardConn{}, config: testConfig.Clone()}
 
 	expectedErr := "tls: server sent certificate containing RSA key larger than 8192 bits"
-	err := c.verifyServerCertificate([][]byte{testCert.Bytes})
+	err := c.verifyServerCertificate(context.Background(), [][]byte{testCert.Bytes})
 	if err == nil || err.Error() != expectedErr {
 		t.Errorf("Conn.verifyServerCertificate unexpected error: want %q, got %q", expectedErr, err)
 	}
@@ -14,7 +14,6 @@ import (
 	"crypto/rsa"
 	"crypto/subtle"
 	"errors"
-	"fmt"
 	"hash"
 	"slices"
 	"time"
@@ -60,7 +59,8 @@ func (hs *clientHandshakeStateTLS13) handshake() error {
 	}
 
 	// Consistency check on the presence of a keyShare and its parameters.
-	if hs.keyShareKeys == nil || hs.keyShareKeys.ecdhe == nil || len(hs.hello.keyShares) == 0 {
+	// [uTLS] A ClientHello offering PSKs in psk_ke mode may have none.
+	if !hs.hello.pskKEWithoutKeyShares() && (hs.keyShareKeys == nil || (hs.keyShareKeys.ecdhe == nil && len(hs.keyShareKeys.extraKeyShares) == 0) || len(hs.hello.keyShares) == 0) { // [uTLS]
 		return c.sendAlert(alertInternalError)
 	}
 
@@ -127,6 +127,7 @@ func (hs *clientHandshakeStateTLS13) handshake() error {
 		} else {
 			hs.echContext.echRejected = true
 		}
+		c.traceECH() // [uTLS]
 	}
 
 	if err := transcriptMsg(hs.serverHello, hs.transcript); err != nil {
@@ -166,6 +167,9 @@ func (hs *clientHandshakeStateTLS13) handshake() error {
 	if _, err := c.flush(); err != nil {
 		return err
 	}
+	if hs.hello.postHandshakeAuth { // [uTLS]
+		c.enablePostHandshakeAuth(hs.suite, hs.transcript)
+	}
 
 	if hs.echContext != nil && hs.echContext.echRejected {
 		c.sendAlert(alertECHRequired)
@@ -227,6 +231,12 @@ func (hs *clientHandshakeStateTLS13) checkServerHelloOrHRR() error {
 		c.sendAlert(alertIllegalParameter)
 		return errors.New("tls: server chose an unconfigured cipher suite")
 	}
+	// [uTLS SECTION BEGIN]
+	if isShangMiCipherSuite(selectedSuite.id) && !c.config.shangMiEnabled() {
+		c.sendAlert(alertHandshakeFailure)
+		return errShangMiDisabled
+	}
+	// [uTLS SECTION END]
 	hs.suite = selectedSuite
 	c.cipherSuite = hs.suite.id
 
@@ -336,25 +346,46 @@ func (hs *clientHandshakeStateTLS13) processHelloRetryRequest() error {
 			c.sendAlert(alertIllegalParameter)
 			return errors.New("tls: server sent an unnecessary HelloRetryRequest key_share")
 		}
-		// Note: we don't support selecting X25519MLKEM768 in a HRR, because it
-		// is currently first in preference order, so if it's enabled we'll
-		// always send a key share for it.
-		//
-		// This will have to change once we support multiple hybrid KEMs.
-		if _, ok := curveForCurveID(curveID); !ok {
-			c.sendAlert(alertInternalError)
-			return errors.New("tls: CurvePreferences includes unsupported curve")
-		}
-		key, err := generateECDHEKey(c.config.rand(), curveID)
-		if err != nil {
-			c.sendAlert(alertInternalError)
-			return err
+		// [uTLS SECTION BEGIN]
+		if isExtraGroup(curveID) {
+			key, data, err := generateExtraKeyShare(c.config, curveID)
+			if err != nil {
+				c.sendAlert(alertInternalError)
+				return err
+			}
+			hs.keyShareKeys = &keySharePrivateKeys{curveID: curveID, extraKeyShares: []extraKeyShare{key}}
+			hello.keyShares = []keyShare{{group: curveID, data: data}}
+		} else {
+			// [uTLS SECTION END]
+			if _, ok := curveForCurveID(curveID); !ok {
+				c.sendAlert(alertInternalError)
+				return errors.New("tls: CurvePreferences includes unsupported curve")
+			}
+			key, err := generateECDHEKey(c.config.rand(), curveID)
+			if err != nil {
+				c.sendAlert(alertInternalError)
+				return err
+			}
+			hs.keyShareKeys = &keySharePrivateKeys{curveID: curveID, ecdhe: key}
+			hello.keyShares = []keyShare{{group: curveID, data: key.PublicKey().Bytes()}}
+		} // [uTLS]
+
+		// [uTLS SECTION BEGIN]
+		if cache := c.config.KeySharePredictionCache; cache != nil && hs.uconn != nil {
+			if cacheKey := c.clientSessionCacheKey(); cacheKey != "" {
+				cache.Put(cacheKey, curveID)
+			}
 		}
-		hs.keyShareKeys = &keySharePrivateKeys{curveID: curveID, ecdhe: key}
-		hello.keyShares = []keyShare{{group: curveID, data: key.PublicKey().Bytes()}}
+		// [uTLS SECTION END]
 	}
 
-	if len(hello.pskIdentities) > 0 {
+	// [uTLS SECTION BEGIN]
+	if len(hello.pskIdentities) > 0 && len(c.utls.externalPSKs) > 0 {
+		if err := hs.updateExternalPSKsAfterHRR(chHash); err != nil {
+			return err
+		}
+	} else if len(hello.pskIdentities) > 0 {
+		// [uTLS SECTION END]
 		pskSuite := cipherSuiteTLS13ByID(hs.session.cipherSuite)
 		if pskSuite == nil {
 			return c.sendAlert(alertInternalError)
@@ -389,56 +420,9 @@ func (hs *clientHandshakeStateTLS13) processHelloRetryRequest() error {
 	// and utlsExtensionPadding are supposed to change
 	if hs.uconn != nil {
 		if hs.uconn.ClientHelloID.Str() != HelloGolang.Str() {
-			if len(hs.hello.pskIdentities) > 0 {
-				// TODO: wait for someone who cares about PSK to implement
-				return errors.New("uTLS does not support reprocessing of PSK key triggered by HelloRetryRequest")
-			}
-
-			keyShareExtFound := false
-			for _, ext := range hs.uconn.Extensions {
-				// new ks seems to be generated either way
-				if ks, ok := ext.(*KeyShareExtension); ok {
-					ks.KeyShares = keyShares(hs.hello.keyShares).ToPublic()
-					keyShareExtFound = true
-				}
-			}
-			if !keyShareExtFound {
-				return errors.New("uTLS: received HelloRetryRequest, but keyshare not found among client's " +
-					"uconn.Extensions")
-			}
-
-			if len(hs.serverHello.cookie) > 0 {
-				// serverHello specified a cookie, let's echo it
-				cookieFound := false
-				for _, ext := range hs.uconn.Extensions {
-					if ks, ok := ext.(*CookieExtension); ok {
-						ks.Cookie = hs.serverHello.cookie
-						cookieFound = true
-					}
-				}
-
-				if !cookieFound {
-					// pick a random index where to add cookieExtension
-					// -2 instead of -1 is a lazy way to ensure that PSK is still a last extension
-					p, err := newPRNG()
-					if err != nil {
-						return err
-					}
-					cookieIndex := p.Intn(len(hs.uconn.Extensions) - 2)
-					if cookieIndex >= len(hs.uconn.Extensions) {
-						// this check is for empty hs.uconn.Extensions
-						return fmt.Errorf("cookieIndex >= len(hs.uconn.Extensions): %v >= %v",
-							cookieIndex, len(hs.uconn.Extensions))
-					}
-					hs.uconn.Extensions = append(hs.uconn.Extensions[:cookieIndex],
-						append([]TLSExtension{&CookieExtension{Cookie: hs.serverHello.cookie}},
-							hs.uconn.Extensions[cookieIndex:]...)...)
-				}
-			}
-			if err := hs.uconn.MarshalClientHelloNoECH(); err != nil {
+			if err := hs.applyHelloRetryRequest(); err != nil {
 				return err
 			}
-			hs.hello.original = hs.uconn.HandshakeState.Hello.Raw
 		}
 	}
 	// [uTLS SECTION ENDS]
@@ -478,6 +462,9 @@ func (hs *clientHandshakeStateTLS13) processHelloRetryRequest() error {
 		hs.hello = hello
 	}
 
+	if isInnerHello {
+		hs.c.recordECHInnerHello(hs.echContext) // [uTLS]
+	}
 	if _, err := hs.c.writeHandshakeRecord(hs.hello, hs.transcript); err != nil {
 		return err
 	}
@@ -521,11 +508,16 @@ func (hs *clientHandshakeStateTLS13) processServerHello() error {
 		return errors.New("tls: malformed key_share extension")
 	}
 
-	if hs.serverHello.serverShare.group == 0 {
+	// [uTLS SECTION BEGIN]
+	// In psk_ke mode, the server sends no key share. See RFC 8446, Section 4.2.9.
+	pskKE := hs.serverHello.serverShare.group == 0 && hs.serverHello.selectedIdentityPresent &&
+		slices.Contains(hs.hello.pskModes, pskModePlain)
+	// [uTLS SECTION END]
+	if hs.serverHello.serverShare.group == 0 && !pskKE {
 		c.sendAlert(alertIllegalParameter)
 		return errors.New("tls: server did not send a key share")
 	}
-	if !slices.ContainsFunc(hs.hello.keyShares, func(ks keyShare) bool {
+	if !pskKE && !slices.ContainsFunc(hs.hello.keyShares, func(ks keyShare) bool {
 		return ks.group == hs.serverHello.serverShare.group
 	}) {
 		c.sendAlert(alertIllegalParameter)
@@ -541,9 +533,15 @@ func (hs *clientHandshakeStateTLS13) processServerHello() error {
 		return errors.New("tls: server selected an invalid PSK")
 	}
 
-	if len(hs.hello.pskIdentities) != 1 || hs.session == nil {
+	// [uTLS SECTION BEGIN]
+	if offer := hs.selectedExternalPSK(); offer != nil {
+		return hs.useExternalPSK(offer)
+	}
+	// The resumption identity, if any, comes before the external ones.
+	if hs.serverHello.selectedIdentity != 0 || hs.session == nil {
 		return c.sendAlert(alertInternalError)
 	}
+	// [uTLS SECTION END]
 	pskSuite := cipherSuiteTLS13ByID(hs.session.cipherSuite)
 	if pskSuite == nil {
 		return c.sendAlert(alertInternalError)
@@ -582,6 +580,23 @@ func getSharedKey(peerData []byte, key *ecdh.PrivateKey) ([]byte, error) {
 func (hs *clientHandshakeStateTLS13) establishHandshakeKeys() error {
 	c := hs.c
 
+	// [uTLS SECTION BEGIN]
+	if hs.serverHello.serverShare.group == 0 {
+		// psk_ke, checked by processServerHello: no (EC)DHE input.
+		c.curveID = 0
+		return hs.establishHandshakeKeysWithSharedKey(nil)
+	}
+	if key := hs.keyShareKeys.extraKeyShare(hs.serverHello.serverShare.group); key != nil {
+		sharedKey, err := key.sharedKey(hs.serverHello.serverShare.data)
+		if err != nil {
+			c.sendAlert(alertIllegalParameter)
+			return err
+		}
+		c.curveID = hs.serverHello.serverShare.group
+		return hs.establishHandshakeKeysWithSharedKey(sharedKey)
+	}
+	// [uTLS SECTION END]
+
 	ecdhePeerData := hs.serverHello.serverShare.data
 	if hs.serverHello.serverShare.group == X25519MLKEM768 {
 		if len(ecdhePeerData) != mlkem.CiphertextSize768+x25519PublicKeySize {
@@ -598,7 +613,13 @@ func (hs *clientHandshakeStateTLS13) establishHandshakeKeys() error {
 		}
 		ecdhePeerData = hs.serverHello.serverShare.data[:x25519PublicKeySize]
 	}
-	sharedKey, err := getSharedKey(ecdhePeerData, hs.keyShareKeys.ecdhe)
+	// With a ClientHelloSpec, the hybrid share has its own X25519 key, and
+	// the classical share may be for another group.
+	ecdheKey := hs.keyShareKeys.ecdhe
+	if hs.keyShareKeys.mlkemEcdhe != nil && isPQGroup(hs.serverHello.serverShare.group) {
+		ecdheKey = hs.keyShareKeys.mlkemEcdhe
+	}
+	sharedKey, err := getSharedKey(ecdhePeerData, ecdheKey)
 	// [uTLS] SECTION END
 	if err != nil {
 		c.sendAlert(alertIllegalParameter)
@@ -646,6 +667,14 @@ func (hs *clientHandshakeStateTLS13) establishHandshakeKeys() error {
 	// [uTLS] SECTION END
 	c.curveID = hs.serverHello.serverShare.group
 
+	// [uTLS SECTION BEGIN]
+	return hs.establishHandshakeKeysWithSharedKey(sharedKey)
+}
+
+func (hs *clientHandshakeStateTLS13) establishHandshakeKeysWithSharedKey(sharedKey []byte) error {
+	c := hs.c
+	// [uTLS SECTION END]
+
 	earlySecret := hs.earlySecret
 	if !hs.usingPSK {
 		earlySecret = tls13.NewEarlySecret(hs.suite.hash.New, nil)
@@ -666,12 +695,12 @@ func (hs *clientHandshakeStateTLS13) establishHandshakeKeys() error {
 		c.quicSetReadSecret(QUICEncryptionLevelHandshake, hs.suite.id, serverSecret)
 	}
 
-	err = c.config.writeKeyLog(keyLogLabelClientHandshake, hs.hello.random, clientSecret)
+	err := c.writeKeyLog(keyLogLabelClientHandshake, hs.hello.random, clientSecret) // [uTLS]
 	if err != nil {
 		c.sendAlert(alertInternalError)
 		return err
 	}
-	err = c.config.writeKeyLog(keyLogLabelServerHandshake, hs.hello.random, serverSecret)
+	err = c.writeKeyLog(keyLogLabelServerHandshake, hs.hello.random, serverSecret) // [uTLS]
 	if err != nil {
 		c.sendAlert(alertInternalError)
 		return err
@@ -787,6 +816,12 @@ func (hs *clientHandshakeStateTLS13) readServerCertificate() error {
 
 	certReq, ok := msg.(*certificateRequestMsgTLS13)
 	if ok {
+		// [uTLS SECTION BEGIN]
+		if len(certReq.context) != 0 {
+			c.sendAlert(alertIllegalParameter)
+			return errors.New("tls: received certificate request with a context during the handshake")
+		}
+		// [uTLS SECTION END]
 		hs.certReq = certReq
 		transcriptMsg(certReq, hs.transcript) // [UTLS] if it is certReq (not compressedCert), write to transcript
 
@@ -820,6 +855,12 @@ func (hs *clientHandshakeStateTLS13) readServerCertificate() error {
 		c.sendAlert(alertDecodeError)
 		return errors.New("tls: received empty certificates message")
 	}
+	// [uTLS SECTION BEGIN]
+	if len(certMsg.context) != 0 {
+		c.sendAlert(alertIllegalParameter)
+		return errors.New("tls: received server certificate with a request context")
+	}
+	// [uTLS SECTION END]
 	// [UTLS SECTION BEGINS]
 	if !skipWritingCertToTranscript { // write to transcript only if it is not compressedCert (i.e. if not processed by extension)
 		if err = transcriptMsg(certMsg, hs.transcript); err != nil {
@@ -831,7 +872,7 @@ func (hs *clientHandshakeStateTLS13) readServerCertificate() error {
 	c.scts = certMsg.certificate.SignedCertificateTimestamps
 	c.ocspResponse = certMsg.certificate.OCSPStaple
 
-	if err := c.verifyServerCertificate(certMsg.certificate.Certificate); err != nil {
+	if err := c.verifyServerCertificate(hs.ctx, certMsg.certificate.Certificate); err != nil { // [uTLS] ctx
 		return err
 	}
 
@@ -850,7 +891,7 @@ func (hs *clientHandshakeStateTLS13) readServerCertificate() error {
 	}
 
 	// See RFC 8446, Section 4.4.3.
-	if !isSupportedSignatureAlgorithm(certVerify.signatureAlgorithm, c.config.supportedSignatureAlgorithms()) { // [UTLS] ported from cloudflare/go
+	if !isSupportedSignatureAlgorithm(certVerify.signatureAlgorithm, hs.hello.supportedSignatureAlgorithms) { // [uTLS] as advertised by the ClientHelloSpec
 		c.sendAlert(alertIllegalParameter)
 		return errors.New("tls: certificate used with invalid signature algorithm")
 	}
@@ -863,7 +904,7 @@ func (hs *clientHandshakeStateTLS13) readServerCertificate() error {
 		return errors.New("tls: certificate used with invalid signature algorithm")
 	}
 	signed := signedMessage(sigHash, serverSignatureContext, hs.transcript)
-	if err := verifyHandshakeSignature(sigType, c.peerCertificates[0].PublicKey,
+	if err := verifyHandshakeSignature(sigType, c.peerPublicKey, // [uTLS]
 		sigHash, signed, certVerify.signature); err != nil {
 		c.sendAlert(alertDecryptError)
 		return errors.New("tls: invalid signature by the server certificate: " + err.Error())
@@ -909,12 +950,12 @@ func (hs *clientHandshakeStateTLS13) readServerFinished() error {
 	serverSecret := hs.masterSecret.ServerApplicationTrafficSecret(hs.transcript)
 	c.in.setTrafficSecret(hs.suite, QUICEncryptionLevelApplication, serverSecret)
 
-	err = c.config.writeKeyLog(keyLogLabelClientTraffic, hs.hello.random, hs.trafficSecret)
+	err = c.writeKeyLog(keyLogLabelClientTraffic, hs.hello.random, hs.trafficSecret) // [uTLS]
 	if err != nil {
 		c.sendAlert(alertInternalError)
 		return err
 	}
-	err = c.config.writeKeyLog(keyLogLabelServerTraffic, hs.hello.random, serverSecret)
+	err = c.writeKeyLog(keyLogLabelServerTraffic, hs.hello.random, serverSecret) // [uTLS]
 	if err != nil {
 		c.sendAlert(alertInternalError)
 		return err
@@ -968,6 +1009,9 @@ func (hs *clientHandshakeStateTLS13) sendClientCertificate() error {
 	certVerifyMsg.hasSignatureAlgorithm = true
 
 	certVerifyMsg.signatureAlgorithm, err = selectSignatureScheme(c.vers, cert, hs.certReq.supportedSignatureAlgorithms)
+	if err == nil {
+		err = c.config.checkShangMiSignature(certVerifyMsg.signatureAlgorithm) // [uTLS]
+	}
 	if err != nil {
 		// getClientCertificate returned a certificate incompatible with the
 		// CertificateRequestInfo supported signature algorithms.
@@ -1036,6 +1080,14 @@ func (c *Conn) handleNewSessionTicket(msg *newSessionTicketMsgTLS13) error {
 		return nil
 	}
 
+	// [uTLS SECTION BEGIN]
+	// Sessions are resumed based on the certificates they carry, which
+	// connections authenticated with an external PSK don't have.
+	if c.utls.externalPSKIdentity != nil {
+		return nil
+	}
+	// [uTLS SECTION END]
+
 	// See RFC 8446, Section 4.6.1.
 	if msg.lifetime == 0 {
 		return nil
@@ -1072,7 +1124,7 @@ func (c *Conn) handleNewSessionTicket(msg *newSessionTicketMsgTLS13) error {
 	}
 	cs := &ClientSessionState{session: session}
 	if cacheKey := c.clientSessionCacheKey(); cacheKey != "" {
-		c.config.ClientSessionCache.Put(cacheKey, cs)
+		c.clientSessionCache().Put(cacheKey, cs) // [uTLS]
 	}
 
 	return nil
@@ -102,7 +102,9 @@ type clientHelloMsg struct {
 	extensions []uint16
 
 	// [uTLS]
-	nextProtoNeg bool
+	nextProtoNeg      bool
+	encryptThenMAC    bool
+	postHandshakeAuth bool
 }
 
 func (m *clientHelloMsg) marshalMsg(echInner bool) ([]byte, error) {
@@ -160,6 +162,16 @@ func (m *clientHelloMsg) marshalMsgReorderOuterExts(echInner bool, outerExts []u
 		exts.AddUint16(ExtensionExtendedMasterSecret)
 		exts.AddUint16(0) // empty extension_data
 	}
+	if m.encryptThenMAC && !echInner { // [uTLS]
+		// RFC 7366
+		exts.AddUint16(extensionEncryptThenMAC)
+		exts.AddUint16(0) // empty extension_data
+	}
+	if m.postHandshakeAuth { // [uTLS]
+		// RFC 8446, Section 4.2.6
+		exts.AddUint16(utlsExtensionPostHandshakeAuth)
+		exts.AddUint16(0) // empty extension_data
+	}
 	if m.scts {
 		// RFC 6962, Section 3.3.1
 		exts.AddUint16(ExtensionSCT)
@@ -608,6 +620,12 @@ func (m *clientHelloMsg) unmarshal(data []byte) bool {
 		case ExtensionExtendedMasterSecret:
 			// RFC 7627
 			m.extendedMasterSecret = true
+		case extensionEncryptThenMAC: // [uTLS]
+			// RFC 7366
+			m.encryptThenMAC = true
+		case utlsExtensionPostHandshakeAuth: // [uTLS]
+			// RFC 8446, Section 4.2.6
+			m.postHandshakeAuth = true
 		case ExtensionALPN:
 			// RFC 7301, Section 3.1
 			var protoList cryptobyte.String
@@ -752,6 +770,8 @@ func (m *clientHelloMsg) clone() *clientHelloMsg {
 		pskBinders:                       slices.Clone(m.pskBinders),
 		quicTransportParameters:          slices.Clone(m.quicTransportParameters),
 		encryptedClientHello:             slices.Clone(m.encryptedClientHello),
+		encryptThenMAC:                   m.encryptThenMAC,    // [uTLS]
+		postHandshakeAuth:                m.postHandshakeAuth, // [uTLS]
 	}
 }
 
@@ -782,8 +802,9 @@ type serverHelloMsg struct {
 	selectedGroup CurveID
 
 	// [uTLS]
-	nextProtoNeg bool
-	nextProtos   []string
+	nextProtoNeg   bool
+	nextProtos     []string
+	encryptThenMAC bool
 }
 
 func (m *serverHelloMsg) marshal() ([]byte, error) {
@@ -818,6 +839,10 @@ func (m *serverHelloMsg) marshal() ([]byte, error) {
 		exts.AddUint16(ExtensionExtendedMasterSecret)
 		exts.AddUint16(0) // empty extension_data
 	}
+	if m.encryptThenMAC { // [uTLS]
+		exts.AddUint16(extensionEncryptThenMAC)
+		exts.AddUint16(0) // empty extension_data
+	}
 	if len(m.alpnProtocol) > 0 {
 		exts.AddUint16(ExtensionALPN)
 		exts.AddUint16LengthPrefixed(func(exts *cryptobyte.Builder) {
@@ -986,6 +1011,8 @@ func (m *serverHelloMsg) unmarshal(data []byte) bool {
 			m.secureRenegotiationSupported = true
 		case ExtensionExtendedMasterSecret:
 			m.extendedMasterSecret = true
+		case extensionEncryptThenMAC: // [uTLS]
+			m.encryptThenMAC = true
 		case ExtensionALPN:
 			var protoList cryptobyte.String
 			if !extData.ReadUint16LengthPrefixed(&protoList) || protoList.Empty() {
@@ -1309,6 +1336,7 @@ type certificateRequestMsgTLS13 struct {
 	supportedSignatureAlgorithms     []SignatureScheme
 	supportedSignatureAlgorithmsCert []SignatureScheme
 	certificateAuthorities           [][]byte
+	context                          []byte // [uTLS] for post-handshake authentication
 }
 
 func (m *certificateRequestMsgTLS13) marshal() ([]byte, error) {
@@ -1317,7 +1345,9 @@ func (m *certificateRequestMsgTLS13) marshal() ([]byte, error) {
 	b.AddUint24LengthPrefixed(func(b *cryptobyte.Builder) {
 		// certificate_request_context (SHALL be zero length unless used for
 		// post-handshake authentication)
-		b.AddUint8(0)
+		b.AddUint8LengthPrefixed(func(b *cryptobyte.Builder) { // [uTLS]
+			b.AddBytes(m.context)
+		})
 
 		b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
 			if m.ocspStapling {
@@ -1375,13 +1405,16 @@ func (m *certificateRequestMsgTLS13) unmarshal(data []byte) bool {
 	*m = certificateRequestMsgTLS13{}
 	s := cryptobyte.String(data)
 
-	var context, extensions cryptobyte.String
+	var extensions cryptobyte.String
 	if !s.Skip(4) || // message type and uint24 length field
-		!s.ReadUint8LengthPrefixed(&context) || !context.Empty() ||
+		!readUint8LengthPrefixed(&s, &m.context) || // [uTLS]
 		!s.ReadUint16LengthPrefixed(&extensions) ||
 		!s.Empty() {
 		return false
 	}
+	if len(m.context) == 0 { // [uTLS]
+		m.context = nil
+	}
 
 	for !extensions.Empty() {
 		var extension uint16
@@ -1521,13 +1554,16 @@ type certificateMsgTLS13 struct {
 	certificate  Certificate
 	ocspStapling bool
 	scts         bool
+	context      []byte // [uTLS] for post-handshake authentication
 }
 
 func (m *certificateMsgTLS13) marshal() ([]byte, error) {
 	var b cryptobyte.Builder
 	b.AddUint8(typeCertificate)
 	b.AddUint24LengthPrefixed(func(b *cryptobyte.Builder) {
-		b.AddUint8(0) // certificate_request_context
+		b.AddUint8LengthPrefixed(func(b *cryptobyte.Builder) { // [uTLS]
+			b.AddBytes(m.context) // certificate_request_context
+		})
 
 		certificate := m.certificate
 		if !m.ocspStapling {
@@ -1583,13 +1619,15 @@ func (m *certificateMsgTLS13) unmarshal(data []byte) bool {
 	*m = certificateMsgTLS13{}
 	s := cryptobyte.String(data)
 
-	var context cryptobyte.String
 	if !s.Skip(4) || // message type and uint24 length field
-		!s.ReadUint8LengthPrefixed(&context) || !context.Empty() ||
+		!readUint8LengthPrefixed(&s, &m.context) || // [uTLS]
 		!unmarshalCertificate(&s, &m.certificate) ||
 		!s.Empty() {
 		return false
 	}
+	if len(m.context) == 0 { // [uTLS]
+		m.context = nil
+	}
 
 	m.scts = m.certificate.SignedCertificateTimestamps != nil
 	m.ocspStapling = m.certificate.OCSPStaple != nil
@@ -486,6 +486,9 @@ func (*certificateRequestMsgTLS13) Generate(rand *rand.Rand, size int) reflect.V
 			m.certificateAuthorities[i] = randomBytes(rand.Intn(10)+1, rand)
 		}
 	}
+	if rand.Intn(10) > 5 { // [uTLS]
+		m.context = randomBytes(rand.Intn(32)+1, rand)
+	}
 	return reflect.ValueOf(m)
 }
 
@@ -506,6 +509,9 @@ func (*certificateMsgTLS13) Generate(rand *rand.Rand, size int) reflect.Value {
 				m.certificate.SignedCertificateTimestamps, randomBytes(rand.Intn(500)+1, rand))
 		}
 	}
+	if rand.Intn(10) > 5 { // [uTLS]
+		m.context = randomBytes(rand.Intn(32)+1, rand)
+	}
 	return reflect.ValueOf(m)
 }
 
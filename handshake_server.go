@@ -32,6 +32,7 @@ type serverHandshakeState struct {
 	hello        *serverHelloMsg
 	suite        *cipherSuite
 	ecdheOk      bool
+	dheOk        bool // [uTLS]
 	ecSignOk     bool
 	ellipticOk   bool
 	rsaDecryptOk bool
@@ -40,6 +41,8 @@ type serverHandshakeState struct {
 	finishedHash finishedHash
 	masterSecret []byte
 	cert         *Certificate
+
+	resumedSessionID bool // [uTLS] sessionState is from Config.ServerSessionCache
 }
 
 // serverHandshake performs a TLS handshake as a server.
@@ -127,6 +130,7 @@ func (hs *serverHandshakeState) handshake() error {
 		if _, err := c.flush(); err != nil {
 			return err
 		}
+		hs.cacheSessionState() // [uTLS]
 	}
 
 	c.ekm = ekmFromMasterSecret(c.vers, hs.suite, hs.masterSecret, hs.clientHello.random, hs.hello.random)
@@ -265,6 +269,7 @@ func (hs *serverHandshakeState) processClientHello() error {
 	}
 	hs.hello.alpnProtocol = selectedProto
 	c.clientProtocol = selectedProto
+	hs.negotiateNPN() // [uTLS]
 
 	hs.cert, err = c.config.getCertificate(clientHelloInfo(hs.ctx, c, hs.clientHello))
 	if err != nil {
@@ -280,6 +285,7 @@ func (hs *serverHandshakeState) processClientHello() error {
 	}
 
 	hs.ecdheOk = supportsECDHE(c.config, c.vers, hs.clientHello.supportedCurves, hs.clientHello.supportedPoints)
+	_, hs.dheOk = selectDHEGroup(c.config, hs.clientHello.supportedCurves) // [uTLS]
 
 	if hs.ecdheOk && len(hs.clientHello.supportedPoints) > 0 {
 		// Although omitting the ec_point_formats extension is permitted, some
@@ -395,8 +401,9 @@ func (hs *serverHandshakeState) pickCipherSuite() error {
 			}
 		}
 	}
+	preferenceList = c.config.appendUTLSCipherSuites(preferenceList, configCipherSuites, c.vers) // [uTLS]
 
-	hs.suite = selectCipherSuite(preferenceList, hs.clientHello.cipherSuites, hs.cipherSuiteOk)
+	hs.suite = c.config.selectCipherSuite(preferenceList, hs.clientHello.cipherSuites, hs.cipherSuiteOk) // [uTLS]
 	if hs.suite == nil {
 		c.sendAlert(alertHandshakeFailure)
 		return errors.New("tls: no cipher suite supported by both client and server")
@@ -430,7 +437,11 @@ func (hs *serverHandshakeState) pickCipherSuite() error {
 }
 
 func (hs *serverHandshakeState) cipherSuiteOk(c *cipherSuite) bool {
-	if c.flags&suiteECDHE != 0 {
+	if c.flags&suiteDHE != 0 { // [uTLS]
+		if !hs.dheOk || !hs.rsaSignOk {
+			return false
+		}
+	} else if c.flags&suiteECDHE != 0 {
 		if !hs.ecdheOk {
 			return false
 		}
@@ -454,31 +465,19 @@ func (hs *serverHandshakeState) cipherSuiteOk(c *cipherSuite) bool {
 func (hs *serverHandshakeState) checkForResumption() error {
 	c := hs.c
 
-	if c.config.SessionTicketsDisabled {
-		return nil
+	// [uTLS SECTION BEGIN]
+	sessionState, err := hs.sessionStateFromTicket()
+	if err != nil {
+		return err
 	}
-
-	var sessionState *SessionState
-	if c.config.UnwrapSession != nil {
-		ss, err := c.config.UnwrapSession(hs.clientHello.sessionTicket, c.connectionStateLocked())
-		if err != nil {
-			return err
-		}
-		if ss == nil {
-			return nil
-		}
-		sessionState = ss
-	} else {
-		plaintext := c.config.decryptTicket(hs.clientHello.sessionTicket, c.ticketKeys)
-		if plaintext == nil {
-			return nil
-		}
-		ss, err := ParseSessionState(plaintext)
-		if err != nil {
-			return nil
-		}
-		sessionState = ss
+	if sessionState == nil {
+		sessionState = hs.sessionStateFromCache()
+		hs.resumedSessionID = sessionState != nil
+	}
+	if sessionState == nil {
+		return nil
 	}
+	// [uTLS SECTION END]
 
 	// TLS 1.2 tickets don't natively have a lifetime, but we want to avoid
 	// re-wrapping the same master secret in different tickets over and over for
@@ -506,8 +505,8 @@ func (hs *serverHandshakeState) checkForResumption() error {
 	}
 
 	// Check that we also support the ciphersuite from the session.
-	suite := selectCipherSuite([]uint16{sessionState.cipherSuite},
-		c.config.cipherSuites(), hs.cipherSuiteOk)
+	suite := c.config.selectCipherSuite([]uint16{sessionState.cipherSuite},
+		c.config.cipherSuites(), hs.cipherSuiteOk) // [uTLS]
 	if suite == nil {
 		return nil
 	}
@@ -549,10 +548,48 @@ func (hs *serverHandshakeState) checkForResumption() error {
 	return nil
 }
 
+// [uTLS SECTION BEGIN]
+
+// sessionStateFromTicket returns the session of the client's session ticket,
+// or nil if it can't be resumed.
+func (hs *serverHandshakeState) sessionStateFromTicket() (*SessionState, error) {
+	c := hs.c
+
+	if c.config.SessionTicketsDisabled {
+		return nil, nil
+	}
+
+	var sessionState *SessionState
+	if c.config.UnwrapSession != nil {
+		ss, err := c.config.UnwrapSession(hs.clientHello.sessionTicket, c.connectionStateLocked())
+		if err != nil {
+			return nil, err
+		}
+		if ss == nil {
+			return nil, nil
+		}
+		sessionState = ss
+	} else {
+		plaintext := c.config.decryptTicket(hs.clientHello.sessionTicket, c.ticketKeys)
+		if plaintext == nil {
+			return nil, nil
+		}
+		ss, err := ParseSessionState(plaintext)
+		if err != nil {
+			return nil, nil
+		}
+		sessionState = ss
+	}
+	return sessionState, nil
+}
+
+// [uTLS SECTION END]
+
 func (hs *serverHandshakeState) doResumeHandshake() error {
 	c := hs.c
 
 	hs.hello.cipherSuite = hs.suite.id
+	hs.hello.encryptThenMAC = c.config.EncryptThenMAC && hs.clientHello.encryptThenMAC && hs.suite.isCBC() // [uTLS]
 	c.cipherSuite = hs.suite.id
 	// We echo the client's session ID in the ServerHello to let it know
 	// that we're doing a resumption.
@@ -561,6 +598,9 @@ func (hs *serverHandshakeState) doResumeHandshake() error {
 	// secret and it's potentially encrypted with the same key, to help the
 	// client avoid cross-connection tracking from a network observer.
 	hs.hello.ticketSupported = true
+	if hs.resumedSessionID { // [uTLS] the client might not support tickets
+		hs.hello.ticketSupported = hs.clientHello.ticketSupported && !c.config.SessionTicketsDisabled
+	}
 	hs.finishedHash = newFinishedHash(c.vers, hs.suite)
 	hs.finishedHash.discardHandshakeBuffer()
 	if err := transcriptMsg(hs.clientHello, &hs.finishedHash); err != nil {
@@ -591,6 +631,10 @@ func (hs *serverHandshakeState) doFullHandshake() error {
 
 	hs.hello.ticketSupported = hs.clientHello.ticketSupported && !c.config.SessionTicketsDisabled
 	hs.hello.cipherSuite = hs.suite.id
+	if err := hs.newSessionID(); err != nil { // [uTLS]
+		return err
+	}
+	hs.hello.encryptThenMAC = c.config.EncryptThenMAC && hs.clientHello.encryptThenMAC && hs.suite.isCBC() // [uTLS]
 
 	hs.finishedHash = newFinishedHash(hs.c.vers, hs.suite)
 	if c.config.ClientAuth == NoClientCert {
@@ -626,7 +670,9 @@ func (hs *serverHandshakeState) doFullHandshake() error {
 		return err
 	}
 	if skx != nil {
-		if len(skx.key) >= 3 && skx.key[0] == 3 /* named curve */ {
+		if dhe, ok := keyAgreement.(*dheKeyAgreement); ok { // [uTLS]
+			c.curveID = dhe.group
+		} else if len(skx.key) >= 3 && skx.key[0] == 3 /* named curve */ {
 			c.curveID = CurveID(byteorder.BEUint16(skx.key[1:]))
 		}
 		if _, err := hs.c.writeHandshakeRecord(skx, &hs.finishedHash); err != nil {
@@ -644,7 +690,7 @@ func (hs *serverHandshakeState) doFullHandshake() error {
 		}
 		if c.vers >= VersionTLS12 {
 			certReq.hasSignatureAlgorithm = true
-			certReq.supportedSignatureAlgorithms = c.config.supportedSignatureAlgorithms() // [UTLS] ported from cloudflare/go
+			certReq.supportedSignatureAlgorithms = signatureSchemesForVersion(c.config.supportedSignatureAlgorithms(), c.vers) // [UTLS] ported from cloudflare/go
 		}
 
 		// An empty list of certificateAuthorities signals to
@@ -691,7 +737,7 @@ func (hs *serverHandshakeState) doFullHandshake() error {
 			return err
 		}
 		if len(certMsg.certificates) != 0 {
-			pub = c.peerCertificates[0].PublicKey
+			pub = c.peerPublicKey // [uTLS]
 		}
 
 		msg, err = c.readHandshake(&hs.finishedHash)
@@ -754,7 +800,8 @@ func (hs *serverHandshakeState) doFullHandshake() error {
 		var sigType uint8
 		var sigHash crypto.Hash
 		if c.vers >= VersionTLS12 {
-			if !isSupportedSignatureAlgorithm(certVerify.signatureAlgorithm, certReq.supportedSignatureAlgorithms) {
+			if !isSupportedSignatureAlgorithm(certVerify.signatureAlgorithm, certReq.supportedSignatureAlgorithms) ||
+				isTLS13OnlySignatureScheme(certVerify.signatureAlgorithm) { // [uTLS]
 				c.sendAlert(alertIllegalParameter)
 				return errors.New("tls: client certificate used with invalid signature algorithm")
 			}
@@ -807,6 +854,8 @@ func (hs *serverHandshakeState) establishKeys() error {
 
 	c.in.prepareCipherSpec(c.vers, clientCipher, clientHash)
 	c.out.prepareCipherSpec(c.vers, serverCipher, serverHash)
+	c.in.prepareEncryptThenMAC(hs.hello.encryptThenMAC)  // [uTLS]
+	c.out.prepareEncryptThenMAC(hs.hello.encryptThenMAC) // [uTLS]
 
 	return nil
 }
@@ -817,6 +866,9 @@ func (hs *serverHandshakeState) readFinished(out []byte) error {
 	if err := c.readChangeCipherSpec(); err != nil {
 		return err
 	}
+	if err := hs.readNextProtocol(); err != nil { // [uTLS]
+		return err
+	}
 
 	// finishedMsg is included in the transcript, but not until after we
 	// check the client version, since the state before this message was
@@ -913,7 +965,7 @@ func (c *Conn) processCertsFromClient(certificate Certificate) error {
 	certs := make([]*x509.Certificate, len(certificates))
 	var err error
 	for i, asn1Data := range certificates {
-		if certs[i], err = x509.ParseCertificate(asn1Data); err != nil {
+		if certs[i], err = parseCertificate(asn1Data); err != nil { // [uTLS]
 			c.sendAlert(alertBadCertificate)
 			return errors.New("tls: failed to parse client certificate: " + err.Error())
 		}
@@ -972,11 +1024,19 @@ func (c *Conn) processCertsFromClient(certificate Certificate) error {
 	c.scts = certificate.SignedCertificateTimestamps
 
 	if len(certs) > 0 {
-		switch certs[0].PublicKey.(type) {
-		case *ecdsa.PublicKey, *rsa.PublicKey, ed25519.PublicKey, circlSign.PublicKey: // [UTLS] ported from cloudflare/go
+		// [uTLS SECTION BEGINS]
+		publicKey, err := certificatePublicKey(certs[0])
+		if err != nil {
+			c.sendAlert(alertBadCertificate)
+			return err
+		}
+		c.peerPublicKey = publicKey
+		// [uTLS SECTION ENDS]
+		switch publicKey.(type) {
+		case *ecdsa.PublicKey, *rsa.PublicKey, ed25519.PublicKey, circlSign.PublicKey, *sm2PublicKey: // [UTLS] ported from cloudflare/go, and SM2
 		default:
 			c.sendAlert(alertUnsupportedCertificate)
-			return fmt.Errorf("tls: client certificate contains an unsupported public key of type %T", certs[0].PublicKey)
+			return fmt.Errorf("tls: client certificate contains an unsupported public key of type %T", publicKey)
 		}
 	}
 
@@ -182,6 +182,8 @@ func (hs *serverHandshakeStateTLS13) processClientHello() error {
 	}
 	if fips140tls.Required() {
 		preferenceList = defaultCipherSuitesTLS13FIPS
+	} else if shangMi := c.config.shangMiCipherSuites(); len(shangMi) > 0 { // [uTLS]
+		preferenceList = append(shangMi, preferenceList...)
 	}
 	for _, suiteID := range preferenceList {
 		hs.suite = mutualCipherSuiteTLS13(hs.clientHello.cipherSuites, suiteID)
@@ -197,103 +199,127 @@ func (hs *serverHandshakeStateTLS13) processClientHello() error {
 	hs.hello.cipherSuite = hs.suite.id
 	hs.transcript = hs.suite.hash.New()
 
-	// First, if a post-quantum key exchange is available, use one. See
-	// draft-ietf-tls-key-share-prediction-01, Section 4 for why this must be
-	// first.
-	//
-	// Second, if the client sent a key share for a group we support, use that,
-	// to avoid a HelloRetryRequest round-trip.
-	//
-	// Finally, pick in our fixed preference order.
-	preferredGroups := c.config.curvePreferences(c.vers)
-	preferredGroups = slices.DeleteFunc(preferredGroups, func(group CurveID) bool {
-		return !slices.Contains(hs.clientHello.supportedCurves, group)
-	})
-	if len(preferredGroups) == 0 {
-		c.sendAlert(alertHandshakeFailure)
-		return errors.New("tls: no key exchanges supported by both client and server")
+	// [uTLS SECTION BEGIN]
+	// With psk_ke, an external PSK replaces the (EC)DHE key exchange, for
+	// which the client may not have sent supported_groups and key_share.
+	// See RFC 8446, Section 9.2.
+	plainPSK, err := hs.checkForExternalPSK(pskModePlain)
+	if err != nil {
+		return err
 	}
-	hasKeyShare := func(group CurveID) bool {
-		for _, ks := range hs.clientHello.keyShares {
-			if ks.group == group {
-				return true
+	// [uTLS SECTION END]
+
+	if !plainPSK { // [uTLS]
+		// First, if a post-quantum key exchange is available, use one. See
+		// draft-ietf-tls-key-share-prediction-01, Section 4 for why this must be
+		// first.
+		//
+		// Second, if the client sent a key share for a group we support, use that,
+		// to avoid a HelloRetryRequest round-trip.
+		//
+		// Finally, pick in our fixed preference order.
+		preferredGroups := c.config.curvePreferences(c.vers)
+		preferredGroups = slices.DeleteFunc(preferredGroups, func(group CurveID) bool {
+			return !slices.Contains(hs.clientHello.supportedCurves, group)
+		})
+		if len(preferredGroups) == 0 {
+			c.sendAlert(alertHandshakeFailure)
+			return errors.New("tls: no key exchanges supported by both client and server")
+		}
+		hasKeyShare := func(group CurveID) bool {
+			for _, ks := range hs.clientHello.keyShares {
+				if ks.group == group {
+					return true
+				}
 			}
+			return false
 		}
-		return false
-	}
-	sort.SliceStable(preferredGroups, func(i, j int) bool {
-		return hasKeyShare(preferredGroups[i]) && !hasKeyShare(preferredGroups[j])
-	})
-	sort.SliceStable(preferredGroups, func(i, j int) bool {
-		return isPQKeyExchange(preferredGroups[i]) && !isPQKeyExchange(preferredGroups[j])
-	})
-	selectedGroup := preferredGroups[0]
-
-	var clientKeyShare *keyShare
-	for _, ks := range hs.clientHello.keyShares {
-		if ks.group == selectedGroup {
-			clientKeyShare = &ks
-			break
+		sort.SliceStable(preferredGroups, func(i, j int) bool {
+			return hasKeyShare(preferredGroups[i]) && !hasKeyShare(preferredGroups[j])
+		})
+		sort.SliceStable(preferredGroups, func(i, j int) bool {
+			return isPQKeyExchange(preferredGroups[i]) && !isPQKeyExchange(preferredGroups[j])
+		})
+		selectedGroup := preferredGroups[0]
+
+		var clientKeyShare *keyShare
+		for _, ks := range hs.clientHello.keyShares {
+			if ks.group == selectedGroup {
+				clientKeyShare = &ks
+				break
+			}
 		}
-	}
-	if clientKeyShare == nil {
-		ks, err := hs.doHelloRetryRequest(selectedGroup)
-		if err != nil {
-			return err
+		if clientKeyShare == nil {
+			ks, err := hs.doHelloRetryRequest(selectedGroup)
+			if err != nil {
+				return err
+			}
+			clientKeyShare = ks
 		}
-		clientKeyShare = ks
-	}
-	c.curveID = selectedGroup
+		c.curveID = selectedGroup
 
-	ecdhGroup := selectedGroup
-	ecdhData := clientKeyShare.data
-	if selectedGroup == X25519MLKEM768 {
-		ecdhGroup = X25519
-		if len(ecdhData) != mlkem.EncapsulationKeySize768+x25519PublicKeySize {
-			c.sendAlert(alertIllegalParameter)
-			return errors.New("tls: invalid X25519MLKEM768 client key share")
-		}
-		ecdhData = ecdhData[mlkem.EncapsulationKeySize768:]
-	}
-	if _, ok := curveForCurveID(ecdhGroup); !ok {
-		c.sendAlert(alertInternalError)
-		return errors.New("tls: CurvePreferences includes unsupported curve")
-	}
-	key, err := generateECDHEKey(c.config.rand(), ecdhGroup)
-	if err != nil {
-		c.sendAlert(alertInternalError)
-		return err
-	}
-	hs.hello.serverShare = keyShare{group: selectedGroup, data: key.PublicKey().Bytes()}
-	peerKey, err := key.Curve().NewPublicKey(ecdhData)
-	if err != nil {
-		c.sendAlert(alertIllegalParameter)
-		return errors.New("tls: invalid client key share")
-	}
-	hs.sharedKey, err = key.ECDH(peerKey)
-	if err != nil {
-		c.sendAlert(alertIllegalParameter)
-		return errors.New("tls: invalid client key share")
-	}
-	if selectedGroup == X25519MLKEM768 {
-		k, err := mlkem.NewEncapsulationKey768(clientKeyShare.data[:mlkem.EncapsulationKeySize768])
-		if err != nil {
-			c.sendAlert(alertIllegalParameter)
-			return errors.New("tls: invalid X25519MLKEM768 client key share")
-		}
-		mlkemSharedSecret, ciphertext := k.Encapsulate()
-		// draft-kwiatkowski-tls-ecdhe-mlkem-02, Section 3.1.3: "For
-		// X25519MLKEM768, the shared secret is the concatenation of the ML-KEM
-		// shared secret and the X25519 shared secret. The shared secret is 64
-		// bytes (32 bytes for each part)."
-		hs.sharedKey = append(mlkemSharedSecret, hs.sharedKey...)
-		// draft-kwiatkowski-tls-ecdhe-mlkem-02, Section 3.1.2: "When the
-		// X25519MLKEM768 group is negotiated, the server's key exchange value
-		// is the concatenation of an ML-KEM ciphertext returned from
-		// encapsulation to the client's encapsulation key, and the server's
-		// ephemeral X25519 share."
-		hs.hello.serverShare.data = append(ciphertext, hs.hello.serverShare.data...)
-	}
+		// [uTLS SECTION BEGIN]
+		if selectedGroup != X25519MLKEM768 && isExtraGroup(selectedGroup) {
+			serverShare, sharedKey, err := extraServerShare(c.config.rand(), selectedGroup, clientKeyShare.data)
+			if err != nil {
+				c.sendAlert(alertIllegalParameter)
+				return err
+			}
+			hs.hello.serverShare = keyShare{group: selectedGroup, data: serverShare}
+			hs.sharedKey = sharedKey
+		} else {
+			// [uTLS SECTION END]
+			ecdhGroup := selectedGroup
+			ecdhData := clientKeyShare.data
+			if selectedGroup == X25519MLKEM768 {
+				ecdhGroup = X25519
+				if len(ecdhData) != mlkem.EncapsulationKeySize768+x25519PublicKeySize {
+					c.sendAlert(alertIllegalParameter)
+					return errors.New("tls: invalid X25519MLKEM768 client key share")
+				}
+				ecdhData = ecdhData[mlkem.EncapsulationKeySize768:]
+			}
+			if _, ok := curveForCurveID(ecdhGroup); !ok {
+				c.sendAlert(alertInternalError)
+				return errors.New("tls: CurvePreferences includes unsupported curve")
+			}
+			key, err := generateECDHEKey(c.config.rand(), ecdhGroup)
+			if err != nil {
+				c.sendAlert(alertInternalError)
+				return err
+			}
+			hs.hello.serverShare = keyShare{group: selectedGroup, data: key.PublicKey().Bytes()}
+			peerKey, err := key.Curve().NewPublicKey(ecdhData)
+			if err != nil {
+				c.sendAlert(alertIllegalParameter)
+				return errors.New("tls: invalid client key share")
+			}
+			hs.sharedKey, err = key.ECDH(peerKey)
+			if err != nil {
+				c.sendAlert(alertIllegalParameter)
+				return errors.New("tls: invalid client key share")
+			}
+			if selectedGroup == X25519MLKEM768 {
+				k, err := mlkem.NewEncapsulationKey768(clientKeyShare.data[:mlkem.EncapsulationKeySize768])
+				if err != nil {
+					c.sendAlert(alertIllegalParameter)
+					return errors.New("tls: invalid X25519MLKEM768 client key share")
+				}
+				mlkemSharedSecret, ciphertext := k.Encapsulate()
+				// draft-kwiatkowski-tls-ecdhe-mlkem-02, Section 3.1.3: "For
+				// X25519MLKEM768, the shared secret is the concatenation of the ML-KEM
+				// shared secret and the X25519 shared secret. The shared secret is 64
+				// bytes (32 bytes for each part)."
+				hs.sharedKey = append(mlkemSharedSecret, hs.sharedKey...)
+				// draft-kwiatkowski-tls-ecdhe-mlkem-02, Section 3.1.2: "When the
+				// X25519MLKEM768 group is negotiated, the server's key exchange value
+				// is the concatenation of an ML-KEM ciphertext returned from
+				// encapsulation to the client's encapsulation key, and the server's
+				// ephemeral X25519 share."
+				hs.hello.serverShare.data = append(ciphertext, hs.hello.serverShare.data...)
+			}
+		} // [uTLS]
+	} // [uTLS]
 
 	selectedProto, err := negotiateALPN(c.config.NextProtos, hs.clientHello.alpnProtocols, c.quic != nil)
 	if err != nil {
@@ -330,6 +356,16 @@ func (hs *serverHandshakeStateTLS13) processClientHello() error {
 func (hs *serverHandshakeStateTLS13) checkForResumption() error {
 	c := hs.c
 
+	// [uTLS SECTION BEGIN]
+	if hs.usingPSK {
+		// An external PSK was accepted with psk_ke by processClientHello.
+		return nil
+	}
+	if accepted, err := hs.checkForExternalPSK(pskModeDHE); err != nil || accepted {
+		return err
+	}
+	// [uTLS SECTION END]
+
 	if c.config.SessionTicketsDisabled {
 		return nil
 	}
@@ -470,7 +506,7 @@ func (hs *serverHandshakeStateTLS13) checkForResumption() error {
 // cloneHash uses the encoding.BinaryMarshaler and encoding.BinaryUnmarshaler
 // interfaces implemented by standard library hashes to clone the state of in
 // to a new instance of h. It returns nil if the operation fails.
-func cloneHash(in hash.Hash, h crypto.Hash) hash.Hash {
+func cloneHash(in hash.Hash, h interface{ New() hash.Hash }) hash.Hash { // [uTLS] was crypto.Hash, see tls13Hash
 	// Recreate the interface to avoid importing encoding.
 	type binaryMarshaler interface {
 		MarshalBinary() (data []byte, err error)
@@ -518,6 +554,9 @@ func (hs *serverHandshakeStateTLS13) pickCertificate() error {
 		return err
 	}
 	hs.sigAlg, err = selectSignatureScheme(c.vers, certificate, hs.clientHello.supportedSignatureAlgorithms)
+	if err == nil {
+		err = c.config.checkShangMiSignature(hs.sigAlg) // [uTLS]
+	}
 	if err != nil {
 		// getCertificate returned a certificate that is unsupported or
 		// incompatible with the client's signature algorithms.
@@ -1061,6 +1100,12 @@ func (hs *serverHandshakeStateTLS13) readClientCertificate() error {
 		c.sendAlert(alertUnexpectedMessage)
 		return unexpectedMessageError(certMsg, msg)
 	}
+	// [uTLS SECTION BEGIN]
+	if len(certMsg.context) != 0 {
+		c.sendAlert(alertIllegalParameter)
+		return errors.New("tls: received client certificate with a request context during the handshake")
+	}
+	// [uTLS SECTION END]
 
 	if err := c.processCertsFromClient(certMsg.certificate); err != nil {
 		return err
@@ -1102,7 +1147,7 @@ func (hs *serverHandshakeStateTLS13) readClientCertificate() error {
 			return errors.New("tls: client certificate used with invalid signature algorithm")
 		}
 		signed := signedMessage(sigHash, clientSignatureContext, hs.transcript)
-		if err := verifyHandshakeSignature(sigType, c.peerCertificates[0].PublicKey,
+		if err := verifyHandshakeSignature(sigType, c.peerPublicKey, // [uTLS]
 			sigHash, signed, certVerify.signature); err != nil {
 			c.sendAlert(alertDecryptError)
 			return errors.New("tls: invalid signature by the client certificate: " + err.Error())
@@ -1142,6 +1187,10 @@ func (hs *serverHandshakeStateTLS13) readClientFinished() error {
 		return errors.New("tls: invalid client finished hash")
 	}
 
+	if hs.clientHello.postHandshakeAuth { // [uTLS] the transcript includes the client Finished, see sendSessionTickets
+		c.enablePostHandshakeAuth(hs.suite, hs.transcript)
+	}
+
 	c.in.setTrafficSecret(hs.suite, QUICEncryptionLevelApplication, hs.trafficSecret)
 
 	return nil
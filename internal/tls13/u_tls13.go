@@ -29,3 +29,20 @@ func (s *MasterSecret) Secret() []byte {
 	}
 	return nil
 }
+
+const (
+	externalBinderLabel = "ext binder"
+	importedBinderLabel = "imp binder"
+)
+
+// ExternalBinderKey derives the binder_key for an external PSK, see RFC 8446,
+// Section 7.1.
+func (s *EarlySecret) ExternalBinderKey() []byte {
+	return deriveSecret(s.hash, s.secret, externalBinderLabel, nil)
+}
+
+// ImportedBinderKey derives the binder_key for an imported PSK, see RFC 9258,
+// Section 5.1.
+func (s *EarlySecret) ImportedBinderKey() []byte {
+	return deriveSecret(s.hash, s.secret, importedBinderLabel, nil)
+}
@@ -329,7 +329,7 @@ func (q *QUICConn) StoreSession(session *SessionState) error {
 		return nil
 	}
 	cs := &ClientSessionState{session: session}
-	c.config.ClientSessionCache.Put(cacheKey, cs)
+	c.clientSessionCache().Put(cacheKey, cs) // [uTLS]
 	return nil
 }
 
@@ -97,6 +97,10 @@ type SessionState struct {
 	useBy  uint64 // seconds since UNIX epoch
 	ageAdd uint32
 	ticket []byte
+
+	// Client-side TLS 1.0–1.2 session ID, under which the server cached the
+	// session if it didn't issue a ticket.
+	sessionID []byte // [uTLS]
 }
 
 // Bytes encodes the session, including any private fields, so that it can be
@@ -302,7 +302,7 @@ func X509KeyPair(certPEMBlock, keyPEMBlock []byte) (Certificate, error) {
 
 	// We don't need to parse the public key for TLS, but we so do anyway
 	// to check that it looks sane and matches the private key.
-	x509Cert, err := x509.ParseCertificate(cert.Certificate[0])
+	x509Cert, err := parseCertificate(cert.Certificate[0]) // [uTLS]
 	if err != nil {
 		return fail(err)
 	}
@@ -320,7 +320,14 @@ func X509KeyPair(certPEMBlock, keyPEMBlock []byte) (Certificate, error) {
 		return fail(err)
 	}
 
-	switch pub := x509Cert.PublicKey.(type) {
+	// [uTLS SECTION BEGINS]
+	publicKey, err := certificatePublicKey(x509Cert)
+	if err != nil {
+		return fail(err)
+	}
+	// [uTLS SECTION ENDS]
+
+	switch pub := publicKey.(type) {
 	case *rsa.PublicKey:
 		priv, ok := cert.PrivateKey.(*rsa.PrivateKey)
 		if !ok {
@@ -358,6 +365,14 @@ func X509KeyPair(certPEMBlock, keyPEMBlock []byte) (Certificate, error) {
 		if err != nil || err2 != nil || !bytes.Equal(pkBytes, pkBytes2) {
 			return fail(errors.New("tls: private key does not match public key"))
 		}
+	case *sm2PublicKey:
+		priv, ok := cert.PrivateKey.(*sm2PrivateKey)
+		if !ok {
+			return fail(errors.New("tls: private key type does not match public key type"))
+		}
+		if !pub.Equal(priv.Public()) {
+			return fail(errors.New("tls: private key does not match public key"))
+		}
 	// [UTLS SECTION ENDS]
 	default:
 		return fail(errors.New("tls: unknown public key algorithm"))
@@ -373,6 +388,12 @@ func parsePrivateKey(der []byte) (crypto.PrivateKey, error) {
 	if key, err := x509.ParsePKCS1PrivateKey(der); err == nil {
 		return key, nil
 	}
+	if key, err := parseCirclPrivateKey(der); err == nil { // [uTLS]
+		return key, nil
+	}
+	if key, err := parseSM2PrivateKey(der); err == nil { // [uTLS]
+		return key, nil
+	}
 	if key, err := x509.ParsePKCS8PrivateKey(der); err == nil {
 		switch key := key.(type) {
 		case *rsa.PrivateKey, *ecdsa.PrivateKey, ed25519.PrivateKey, circlSign.PrivateKey: // [uTLS] ported from cloudflare/go
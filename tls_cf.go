@@ -4,9 +4,21 @@
 package tls
 
 import (
+	"crypto"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"slices"
+
 	circlPki "github.com/cloudflare/circl/pki"
 	circlSign "github.com/cloudflare/circl/sign"
 	"github.com/cloudflare/circl/sign/eddilithium3"
+	"github.com/cloudflare/circl/sign/mldsa/mldsa44"
+	"github.com/cloudflare/circl/sign/mldsa/mldsa65"
+	"github.com/cloudflare/circl/sign/mldsa/mldsa87"
+	"golang.org/x/crypto/cryptobyte"
+	cryptobyte_asn1 "golang.org/x/crypto/cryptobyte/asn1"
 )
 
 // To add a signature scheme from Circl
@@ -15,12 +27,23 @@ import (
 //   2. follow the instructions in crypto/x509/x509_cf.go
 //   3. add a signature<NameOfAlg> to the iota in common.go
 //   4. add row in the circlSchemes lists below
+//
+// Schemes which don't implement TLSScheme and CertificateScheme, like ML-DSA,
+// set tlsID and oid in their row instead.
 
 var circlSchemes = [...]struct {
 	sigType uint8
 	scheme  circlSign.Scheme
+	tlsID   SignatureScheme       // [uTLS]
+	oid     asn1.ObjectIdentifier // [uTLS]
+	tls13   bool                  // [uTLS] not usable in TLS 1.2
 }{
-	{signatureEdDilithium3, eddilithium3.Scheme()},
+	{sigType: signatureEdDilithium3, scheme: eddilithium3.Scheme()},
+	// [uTLS SECTION BEGIN]
+	{signatureMLDSA44, mldsa44.Scheme(), MLDSA44, asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 3, 17}, true},
+	{signatureMLDSA65, mldsa65.Scheme(), MLDSA65, asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 3, 18}, true},
+	{signatureMLDSA87, mldsa87.Scheme(), MLDSA87, asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 3, 19}, true},
+	// [uTLS SECTION END]
 }
 
 func circlSchemeBySigType(sigType uint8) circlSign.Scheme {
@@ -41,22 +64,173 @@ func sigTypeByCirclScheme(scheme circlSign.Scheme) uint8 {
 	return 0
 }
 
+// [uTLS SECTION BEGIN]
+
+// circlSchemeByTLSID returns the scheme of circlSchemes for a TLS
+// SignatureScheme, or nil.
+func circlSchemeByTLSID(id SignatureScheme) circlSign.Scheme {
+	for _, cs := range circlSchemes {
+		if cs.tlsID == id {
+			return cs.scheme
+		}
+	}
+	return nil
+}
+
+// tlsIDByCirclScheme returns the TLS SignatureScheme of a scheme of
+// circlSchemes, or zero if it is not available in the given TLS version.
+func tlsIDByCirclScheme(scheme circlSign.Scheme, version uint16) SignatureScheme {
+	for _, cs := range circlSchemes {
+		if cs.scheme == scheme && (!cs.tls13 || version >= VersionTLS13) {
+			return cs.tlsID
+		}
+	}
+	return 0
+}
+
+// isTLS13OnlySignatureScheme reports whether id is the TLS SignatureScheme of
+// a scheme of circlSchemes which is not usable in TLS 1.2, like ML-DSA.
+func isTLS13OnlySignatureScheme(id SignatureScheme) bool {
+	for _, cs := range circlSchemes {
+		if cs.tlsID == id {
+			return cs.tls13
+		}
+	}
+	return false
+}
+
+// signatureSchemesForVersion returns algs without the schemes which are not
+// usable in the given TLS version. algs is not modified.
+func signatureSchemesForVersion(algs []SignatureScheme, version uint16) []SignatureScheme {
+	if version >= VersionTLS13 || !slices.ContainsFunc(algs, isTLS13OnlySignatureScheme) {
+		return algs
+	}
+	return slices.DeleteFunc(slices.Clone(algs), isTLS13OnlySignatureScheme)
+}
+
+func circlSchemeByOid(oid asn1.ObjectIdentifier) circlSign.Scheme {
+	for _, cs := range circlSchemes {
+		if cs.oid != nil && cs.oid.Equal(oid) {
+			return cs.scheme
+		}
+	}
+	return nil
+}
+
+// certificatePublicKey returns the public key of cert. For the schemes in
+// circlSchemes, which crypto/x509 either doesn't know or decodes to its own
+// types, it is a circlSign.PublicKey decoded from the SubjectPublicKeyInfo.
+// For SM2 keys, it is an *sm2PublicKey. Otherwise it is cert.PublicKey.
+func certificatePublicKey(cert *x509.Certificate) (crypto.PublicKey, error) {
+	if pub, ok, err := sm2PublicKeyFromSPKI(cert.RawSubjectPublicKeyInfo); ok {
+		return pub, err
+	}
+	var spki struct {
+		Algorithm pkix.AlgorithmIdentifier
+		PublicKey asn1.BitString
+	}
+	if rest, err := asn1.Unmarshal(cert.RawSubjectPublicKeyInfo, &spki); err != nil || len(rest) != 0 {
+		return cert.PublicKey, nil
+	}
+	scheme := circlSchemeByOid(spki.Algorithm.Algorithm)
+	if scheme == nil {
+		return cert.PublicKey, nil
+	}
+	pub, err := scheme.UnmarshalBinaryPublicKey(spki.PublicKey.RightAlign())
+	if err != nil {
+		return nil, errors.New("tls: failed to parse " + scheme.Name() + " public key: " + err.Error())
+	}
+	return pub, nil
+}
+
+// parseCirclPrivateKey parses a PKCS #8 private key for the schemes in
+// circlSchemes. ML-DSA keys use the seed, expandedKey or both encodings of
+// RFC 9881, other schemes the raw private key in an OCTET STRING.
+func parseCirclPrivateKey(der []byte) (circlSign.PrivateKey, error) {
+	var pkcs8 struct {
+		Version    int
+		Algorithm  pkix.AlgorithmIdentifier
+		PrivateKey []byte
+	}
+	if _, err := asn1.Unmarshal(der, &pkcs8); err != nil {
+		return nil, err
+	}
+	scheme := circlSchemeByOid(pkcs8.Algorithm.Algorithm)
+	if scheme == nil {
+		return nil, errors.New("tls: unknown private key algorithm")
+	}
+
+	input := cryptobyte.String(pkcs8.PrivateKey)
+	var seed, expanded cryptobyte.String
+	switch {
+	case input.PeekASN1Tag(cryptobyte_asn1.Tag(0).ContextSpecific()):
+		if !input.ReadASN1(&seed, cryptobyte_asn1.Tag(0).ContextSpecific()) {
+			return nil, errors.New("tls: invalid private key seed")
+		}
+	case input.PeekASN1Tag(cryptobyte_asn1.SEQUENCE):
+		var both cryptobyte.String
+		if !input.ReadASN1(&both, cryptobyte_asn1.SEQUENCE) ||
+			!both.ReadASN1(&seed, cryptobyte_asn1.OCTET_STRING) ||
+			!both.ReadASN1(&expanded, cryptobyte_asn1.OCTET_STRING) || !both.Empty() {
+			return nil, errors.New("tls: invalid private key")
+		}
+	default:
+		if !input.ReadASN1(&expanded, cryptobyte_asn1.OCTET_STRING) {
+			return nil, errors.New("tls: invalid private key")
+		}
+	}
+	if !input.Empty() {
+		return nil, errors.New("tls: trailing data after private key")
+	}
+
+	if seed != nil {
+		if len(seed) != scheme.SeedSize() {
+			return nil, errors.New("tls: invalid private key seed")
+		}
+		_, priv := scheme.DeriveKey(seed)
+		if expanded != nil {
+			if packed, err := priv.MarshalBinary(); err != nil || string(packed) != string(expanded) {
+				return nil, errors.New("tls: private key seed does not match expanded key")
+			}
+		}
+		return priv, nil
+	}
+	return scheme.UnmarshalBinaryPrivateKey(expanded)
+}
+
+// [uTLS SECTION END]
+
 var supportedSignatureAlgorithmsWithCircl []SignatureScheme
 
 // supportedSignatureAlgorithms returns enabled signature schemes. PQ signature
 // schemes are only included when tls.Config#PQSignatureSchemesEnabled is set
-// and FIPS-only mode is not enabled.
+// and FIPS-only mode is not enabled, and SM2WithSM3 when
+// tls.Config#CipherSuites lists a ShangMi suite.
 func (c *Config) supportedSignatureAlgorithms() []SignatureScheme {
+	algs := defaultSupportedSignatureAlgorithms
 	if c != nil && c.PQSignatureSchemesEnabled {
-		return supportedSignatureAlgorithmsWithCircl
+		algs = supportedSignatureAlgorithmsWithCircl
+	}
+	if len(c.shangMiCipherSuites()) > 0 {
+		algs = append(slices.Clip(algs), SM2WithSM3)
 	}
-	return defaultSupportedSignatureAlgorithms
+	return algs
 }
 
 func init() {
+	// [uTLS SECTION BEGIN]
+	for i, cs := range circlSchemes {
+		if tlsScheme, ok := cs.scheme.(circlPki.TLSScheme); ok && cs.tlsID == 0 {
+			circlSchemes[i].tlsID = SignatureScheme(tlsScheme.TLSIdentifier())
+		}
+		if certScheme, ok := cs.scheme.(circlPki.CertificateScheme); ok && cs.oid == nil {
+			circlSchemes[i].oid = certScheme.Oid()
+		}
+	}
+	// [uTLS SECTION END]
 	supportedSignatureAlgorithmsWithCircl = append([]SignatureScheme{}, defaultSupportedSignatureAlgorithms...)
 	for _, cs := range circlSchemes {
 		supportedSignatureAlgorithmsWithCircl = append(supportedSignatureAlgorithmsWithCircl,
-			SignatureScheme(cs.scheme.(circlPki.TLSScheme).TLSIdentifier()))
+			cs.tlsID) // [uTLS]
 	}
 }
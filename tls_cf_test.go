@@ -0,0 +1,288 @@
+// Copyright 2026 uTLS Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tls
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/pem"
+	"math/big"
+	"slices"
+	"testing"
+	"time"
+
+	circlSign "github.com/cloudflare/circl/sign"
+	"golang.org/x/crypto/cryptobyte"
+	cryptobyte_asn1 "golang.org/x/crypto/cryptobyte/asn1"
+)
+
+// testCirclCertificate returns a certificate for example.golang with the
+// circl private key priv, issued by a new ECDSA root, and the pool holding
+// that root.
+func testCirclCertificate(t *testing.T, priv circlSign.PrivateKey) (Certificate, *x509.CertPool) {
+	t.Helper()
+	pubBytes, err := priv.Public().(circlSign.PublicKey).MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var oid asn1.ObjectIdentifier
+	for _, cs := range circlSchemes {
+		if cs.scheme == priv.Scheme() {
+			oid = cs.oid
+		}
+	}
+
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Circl Test Root"},
+		NotBefore:             time.Unix(0, 0),
+		NotAfter:              time.Unix(0, 0).Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	caCert, err := x509.ParseCertificate(caDER)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// crypto/x509 can't issue certificates for circl keys, so issue one for
+	// a placeholder key and swap its SubjectPublicKeyInfo before signing
+	// the TBSCertificate again.
+	placeholder, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "example.golang"},
+		DNSNames:     []string{"example.golang"},
+		NotBefore:    time.Unix(0, 0),
+		NotAfter:     time.Unix(0, 0).Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, caCert, &placeholder.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	spki, err := asn1.Marshal(struct {
+		Algorithm pkix.AlgorithmIdentifier
+		PublicKey asn1.BitString
+	}{pkix.AlgorithmIdentifier{Algorithm: oid}, asn1.BitString{Bytes: pubBytes, BitLength: 8 * len(pubBytes)}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	input := cryptobyte.String(der)
+	var certificate, tbs, sigAlgID cryptobyte.String
+	if !input.ReadASN1(&certificate, cryptobyte_asn1.SEQUENCE) ||
+		!certificate.ReadASN1(&tbs, cryptobyte_asn1.SEQUENCE) ||
+		!certificate.ReadASN1Element(&sigAlgID, cryptobyte_asn1.SEQUENCE) {
+		t.Fatal("failed to parse the placeholder certificate")
+	}
+	var b cryptobyte.Builder
+	b.AddASN1(cryptobyte_asn1.SEQUENCE, func(b *cryptobyte.Builder) {
+		// version, serialNumber, signature, issuer, validity, subject
+		for range 6 {
+			var field cryptobyte.String
+			var tag cryptobyte_asn1.Tag
+			if !tbs.ReadAnyASN1Element(&field, &tag) {
+				t.Fatal("failed to parse the placeholder TBSCertificate")
+			}
+			b.AddBytes(field)
+		}
+		if !tbs.SkipASN1(cryptobyte_asn1.SEQUENCE) {
+			t.Fatal("failed to parse the placeholder SubjectPublicKeyInfo")
+		}
+		b.AddBytes(spki)
+		b.AddBytes(tbs)
+	})
+	newTBS := b.BytesOrPanic()
+	digest := sha256.Sum256(newTBS)
+	sig, err := ecdsa.SignASN1(rand.Reader, caKey, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	b = cryptobyte.Builder{}
+	b.AddASN1(cryptobyte_asn1.SEQUENCE, func(b *cryptobyte.Builder) {
+		b.AddBytes(newTBS)
+		b.AddBytes(sigAlgID)
+		b.AddASN1BitString(sig)
+	})
+
+	roots := x509.NewCertPool()
+	roots.AddCert(caCert)
+	return Certificate{Certificate: [][]byte{b.BytesOrPanic()}, PrivateKey: priv}, roots
+}
+
+func testCirclPrivateKey(t *testing.T, sigAlg SignatureScheme) circlSign.PrivateKey {
+	_, priv, err := circlSchemeByTLSID(sigAlg).GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return priv
+}
+
+func TestMLDSAHandshake(t *testing.T) {
+	for _, sigAlg := range []SignatureScheme{MLDSA44, MLDSA65, MLDSA87} {
+		t.Run(circlSchemeByTLSID(sigAlg).Name(), func(t *testing.T) {
+			serverCert, roots := testCirclCertificate(t, testCirclPrivateKey(t, sigAlg))
+			clientCert, clientRoots := testCirclCertificate(t, testCirclPrivateKey(t, sigAlg))
+
+			clientConfig := testConfig.Clone()
+			clientConfig.InsecureSkipVerify = false
+			clientConfig.ServerName = "example.golang"
+			clientConfig.RootCAs = roots
+			clientConfig.PQSignatureSchemesEnabled = true
+			clientConfig.Certificates = []Certificate{clientCert}
+			serverConfig := testConfig.Clone()
+			serverConfig.Certificates = []Certificate{serverCert}
+			serverConfig.PQSignatureSchemesEnabled = true
+			serverConfig.ClientAuth = RequireAndVerifyClientCert
+			serverConfig.ClientCAs = clientRoots
+
+			serverState, clientState, err := testHandshake(t, clientConfig, serverConfig)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(clientState.VerifiedChains) != 1 || len(serverState.VerifiedChains) != 1 {
+				t.Errorf("certificate chains were not verified")
+			}
+
+			// ML-DSA is not defined for TLS 1.2.
+			clientConfig.MaxVersion = VersionTLS12
+			if _, _, err := testHandshake(t, clientConfig, serverConfig); err == nil {
+				t.Error("TLS 1.2 handshake with an ML-DSA certificate succeeded")
+			}
+		})
+	}
+}
+
+func TestMLDSAHandshakeUTLS(t *testing.T) {
+	serverCert, roots := testCirclCertificate(t, testCirclPrivateKey(t, MLDSA65))
+	clientConfig := testConfig.Clone()
+	clientConfig.InsecureSkipVerify = false
+	clientConfig.ServerName = "example.golang"
+	clientConfig.RootCAs = roots
+	serverConfig := testConfig.Clone()
+	serverConfig.Certificates = []Certificate{serverCert}
+
+	spec, err := utlsIdToSpec(HelloChrome_Auto)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, ext := range spec.Extensions {
+		if sigAlgs, ok := ext.(*SignatureAlgorithmsExtension); ok {
+			sigAlgs.SupportedSignatureAlgorithms = append([]SignatureScheme{MLDSA65}, sigAlgs.SupportedSignatureAlgorithms...)
+		}
+	}
+	_, clientState, err := testUtlsHandshake(t, clientConfig, serverConfig, &spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(clientState.VerifiedChains) != 1 {
+		t.Errorf("certificate chain was not verified")
+	}
+}
+
+func TestMLDSAX509KeyPair(t *testing.T) {
+	seed := make([]byte, 32)
+	if _, err := rand.Read(seed); err != nil {
+		t.Fatal(err)
+	}
+	_, priv := circlSchemeByTLSID(MLDSA44).DeriveKey(seed)
+	cert, _ := testCirclCertificate(t, priv)
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Certificate[0]})
+
+	keyPair, err := X509KeyPair(certPEM, testMLDSAPrivateKeyPEM(t, seed))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !priv.Equal(keyPair.PrivateKey) {
+		t.Error("parsed private key doesn't match the seed")
+	}
+
+	seed[0] ^= 0xff
+	if _, err := X509KeyPair(certPEM, testMLDSAPrivateKeyPEM(t, seed)); err == nil {
+		t.Error("X509KeyPair accepted a private key not matching the certificate")
+	}
+}
+
+// testMLDSAPrivateKeyPEM returns an ML-DSA-44 PKCS #8 private key in the seed
+// form of RFC 9881.
+func testMLDSAPrivateKeyPEM(t *testing.T, seed []byte) []byte {
+	var b cryptobyte.Builder
+	b.AddASN1(cryptobyte_asn1.SEQUENCE, func(b *cryptobyte.Builder) {
+		b.AddASN1Int64(0)
+		b.AddASN1(cryptobyte_asn1.SEQUENCE, func(b *cryptobyte.Builder) {
+			b.AddASN1ObjectIdentifier(asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 3, 17})
+		})
+		b.AddASN1(cryptobyte_asn1.OCTET_STRING, func(b *cryptobyte.Builder) {
+			b.AddASN1(cryptobyte_asn1.Tag(0).ContextSpecific(), func(b *cryptobyte.Builder) {
+				b.AddBytes(seed)
+			})
+		})
+	})
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: b.BytesOrPanic()})
+}
+
+// TestCirclSignatureAlgorithms checks that all
+// supportedSignatureAlgorithmsWithCircl have valid type information, and that
+// the schemes of circlSchemes which are only defined for TLS 1.3 are never
+// offered in TLS 1.2.
+func TestCirclSignatureAlgorithms(t *testing.T) {
+	for _, sigAlg := range supportedSignatureAlgorithmsWithCircl {
+		sigType, hash, err := typeAndHashFromSignatureScheme(sigAlg)
+		if err != nil {
+			t.Errorf("%v: unexpected error: %v", sigAlg, err)
+		}
+		if sigType == 0 {
+			t.Errorf("%v: missing signature type", sigAlg)
+		}
+		if hash == 0 && sigAlg != Ed25519 && circlSchemeByTLSID(sigAlg) == nil {
+			t.Errorf("%v: missing hash", sigAlg)
+		}
+	}
+
+	for _, sigAlg := range []SignatureScheme{MLDSA44, MLDSA65, MLDSA87} {
+		if !isTLS13OnlySignatureScheme(sigAlg) {
+			t.Errorf("%v: usable in TLS 1.2", sigAlg)
+		}
+		if !slices.Contains(signatureSchemesForVersion(supportedSignatureAlgorithmsWithCircl, VersionTLS13), sigAlg) {
+			t.Errorf("%v: missing in TLS 1.3", sigAlg)
+		}
+		if slices.Contains(signatureSchemesForVersion(supportedSignatureAlgorithmsWithCircl, VersionTLS12), sigAlg) {
+			t.Errorf("%v: offered in TLS 1.2", sigAlg)
+		}
+	}
+	if isTLS13OnlySignatureScheme(ECDSAWithP256AndSHA256) {
+		t.Error("ECDSAWithP256AndSHA256 is not usable in TLS 1.2")
+	}
+
+	config := testConfig.Clone()
+	config.PQSignatureSchemesEnabled = true
+	config.MaxVersion = VersionTLS12
+	hello, _, _, err := Client(nil, config).makeClientHello()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if slices.ContainsFunc(hello.supportedSignatureAlgorithms, isTLS13OnlySignatureScheme) {
+		t.Errorf("TLS 1.2 ClientHello offers %v", hello.supportedSignatureAlgorithms)
+	}
+}
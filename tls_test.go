@@ -905,6 +905,34 @@ func TestCloneNonFuncFields(t *testing.T) {
 			f.Set(reflect.ValueOf(map[string][]byte{"a": {1}}))
 		case "ECHConfigs": // [UTLS] ECH (Encrypted Client Hello) Configs
 			f.Set(reflect.ValueOf([]ECHConfig{{Version: 1}}))
+		case "ExternalPSKs": // [UTLS] External PSKs
+			f.Set(reflect.ValueOf([]ExternalPSK{{Identity: []byte{1}, Key: []byte{2}}}))
+		case "PSKKeyExchangeModes": // [UTLS] External PSKs
+			f.Set(reflect.ValueOf([]uint8{PskModePlain}))
+		case "KeySharePredictionCache": // [UTLS] Key share prediction
+			f.Set(reflect.ValueOf(NewLRUKeySharePredictionCache(1)))
+		case "CipherSuiteRegistry": // [UTLS] Cipher suite registry
+			f.Set(reflect.ValueOf(&CipherSuiteRegistry{}))
+		case "EncryptThenMAC": // [UTLS] Encrypt-then-MAC
+			f.Set(reflect.ValueOf(true))
+		case "ServerSessionCache": // [UTLS] Session ID resumption
+			f.Set(reflect.ValueOf(NewLRUServerSessionCache(1)))
+		case "KeyUpdatePolicy": // [UTLS] Automatic KeyUpdate
+			f.Set(reflect.ValueOf(&KeyUpdatePolicy{MaxRecords: 1}))
+		case "RevocationCheck": // [UTLS] OCSP and CRL checks
+			f.Set(reflect.ValueOf(&RevocationCheck{Policy: RevocationHardFail}))
+		case "CTPolicy": // [UTLS] Certificate Transparency
+			f.Set(reflect.ValueOf(&CTPolicy{Enforce: true}))
+		case "CertificatePins": // [UTLS] SPKI pinning
+			f.Set(reflect.ValueOf(&CertificatePins{ReportOnly: true}))
+		case "AIAFetching": // [UTLS] AIA intermediate fetching
+			f.Set(reflect.ValueOf(&AIAFetching{MaxDepth: 1}))
+		case "RootStore": // [UTLS] browser root stores
+			f.Set(reflect.ValueOf(NewRootStore("test")))
+		case "HandshakeTracer": // [UTLS] handshake tracing
+			f.Set(reflect.ValueOf(&testHandshakeTracer{}))
+		case "InsecureShangMi": // [UTLS] ShangMi opt-in
+			f.Set(reflect.ValueOf(true))
 		default:
 			t.Errorf("all fields must be accounted for, but saw unknown field %q", fn)
 		}
@@ -2072,6 +2100,46 @@ func TestECH(t *testing.T) {
 }
 
 func testECHSpec(t *testing.T, spec *ClientHelloSpec, expectSuccess bool) {
+	clientConfig, serverConfig, secretCert := testECHConfigs(t) // [uTLS]
+
+	// [uTLS SECTION BEGIN]
+	ss, cs, err := testUtlsHandshake(t, clientConfig, serverConfig, spec)
+	if expectSuccess {
+		if err != nil {
+			t.Fatalf("unexpected failure: %s", err)
+		}
+		if !ss.ECHAccepted {
+			t.Fatal("server ConnectionState shows ECH not accepted")
+		}
+		if !cs.ECHAccepted {
+			t.Fatal("client ConnectionState shows ECH not accepted")
+		}
+		if cs.ServerName != "secret.example" || ss.ServerName != "secret.example" {
+			t.Fatalf("unexpected ConnectionState.ServerName, want %q, got server:%q, client: %q", "secret.example", ss.ServerName, cs.ServerName)
+		}
+		if len(cs.VerifiedChains) != 1 {
+			t.Fatal("unexpect number of certificate chains")
+		}
+		if len(cs.VerifiedChains[0]) != 1 {
+			t.Fatal("unexpect number of certificates")
+		}
+		if !cs.VerifiedChains[0][0].Equal(secretCert) {
+			t.Fatal("unexpected certificate")
+		}
+	} else {
+		if err == nil {
+			t.Fatalf("unexpected handshake success, expected failure")
+		}
+	}
+	// [uTLS SECTION END]
+}
+
+// [uTLS SECTION BEGIN]
+
+// testECHConfigs returns the configs of a client and a server which accepts
+// its Encrypted Client Hello, and the certificate of the inner server name.
+func testECHConfigs(t *testing.T) (clientConfig, serverConfig *Config, secretCert *x509.Certificate) {
+	// [uTLS SECTION END]
 	k, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
 	if err != nil {
 		t.Fatal(err)
@@ -2095,7 +2163,7 @@ func testECHSpec(t *testing.T, spec *ClientHelloSpec, expectSuccess bool) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	secretCert, err := x509.ParseCertificate(secretCertDER)
+	secretCert, err = x509.ParseCertificate(secretCertDER)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -2138,7 +2206,7 @@ func testECHSpec(t *testing.T, spec *ClientHelloSpec, expectSuccess bool) {
 	})
 	echConfigList := builder.BytesOrPanic()
 
-	clientConfig, serverConfig := testConfig.Clone(), testConfig.Clone()
+	clientConfig, serverConfig = testConfig.Clone(), testConfig.Clone()
 	clientConfig.InsecureSkipVerify = false
 	clientConfig.Rand = rand.Reader
 	clientConfig.Time = nil
@@ -2160,35 +2228,5 @@ func testECHSpec(t *testing.T, spec *ClientHelloSpec, expectSuccess bool) {
 	serverConfig.EncryptedClientHelloKeys = []EncryptedClientHelloKey{
 		{Config: echConfig, PrivateKey: echKey.Bytes(), SendAsRetry: true},
 	}
-
-	// [uTLS SECTION BEGIN]
-	ss, cs, err := testUtlsHandshake(t, clientConfig, serverConfig, spec)
-	if expectSuccess {
-		if err != nil {
-			t.Fatalf("unexpected failure: %s", err)
-		}
-		if !ss.ECHAccepted {
-			t.Fatal("server ConnectionState shows ECH not accepted")
-		}
-		if !cs.ECHAccepted {
-			t.Fatal("client ConnectionState shows ECH not accepted")
-		}
-		if cs.ServerName != "secret.example" || ss.ServerName != "secret.example" {
-			t.Fatalf("unexpected ConnectionState.ServerName, want %q, got server:%q, client: %q", "secret.example", ss.ServerName, cs.ServerName)
-		}
-		if len(cs.VerifiedChains) != 1 {
-			t.Fatal("unexpect number of certificate chains")
-		}
-		if len(cs.VerifiedChains[0]) != 1 {
-			t.Fatal("unexpect number of certificates")
-		}
-		if !cs.VerifiedChains[0][0].Equal(secretCert) {
-			t.Fatal("unexpected certificate")
-		}
-	} else {
-		if err == nil {
-			t.Fatalf("unexpected handshake success, expected failure")
-		}
-	}
-	// [uTLS SECTION END]
+	return clientConfig, serverConfig, secretCert // [uTLS]
 }
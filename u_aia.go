@@ -0,0 +1,232 @@
+// Copyright 2026 uTLS Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tls
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/pem"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/cryptobyte"
+	cryptobyte_asn1 "golang.org/x/crypto/cryptobyte/asn1"
+)
+
+// AIAFetching configures the fetching of the intermediate certificates which
+// servers failed to send, from the caIssuers URLs of the Authority Information
+// Access extension of certificates, as browsers do. See Config.AIAFetching.
+type AIAFetching struct {
+	// Fetcher fetches the certificates, with requests canceled along with the
+	// handshake context. If nil, an HTTP client with a timeout of 10 seconds
+	// is used.
+	Fetcher HTTPFetcher
+
+	// Cache, if not nil, keeps the fetched certificates across connections.
+	Cache *AIACache
+
+	// MaxDepth is the maximum number of certificates fetched to complete a
+	// chain. If zero, 4 is used.
+	MaxDepth int
+
+	// MaxSize is the maximum size of a fetched certificate, in bytes. If
+	// zero, 64 KiB is used.
+	MaxSize int64
+}
+
+// defaultAIAFetcher is the Fetcher of AIAFetching if nil. Unlike
+// http.DefaultClient, it doesn't let a slow server stall the handshake.
+var defaultAIAFetcher HTTPFetcher = &http.Client{Timeout: 10 * time.Second}
+
+func (a *AIAFetching) fetcher() HTTPFetcher {
+	if a.Fetcher == nil {
+		return defaultAIAFetcher
+	}
+	return a.Fetcher
+}
+
+func (a *AIAFetching) maxDepth() int {
+	if a.MaxDepth <= 0 {
+		return 4
+	}
+	return a.MaxDepth
+}
+
+func (a *AIAFetching) maxSize() int64 {
+	if a.MaxSize <= 0 {
+		return 64 << 10
+	}
+	return a.MaxSize
+}
+
+// AIACache is a cache of certificates fetched by URL, safe for concurrent use.
+// Its zero value is an empty cache which keeps up to 256 certificates.
+type AIACache struct {
+	// MaxEntries is the maximum number of certificates kept. If zero, 256
+	// is used.
+	MaxEntries int
+
+	mu    sync.Mutex
+	certs map[string]*x509.Certificate
+	urls  []string // in insertion order, for eviction
+}
+
+func (c *AIACache) get(url string) *x509.Certificate {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.certs[url]
+}
+
+func (c *AIACache) put(url string, cert *x509.Certificate) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.certs == nil {
+		c.certs = make(map[string]*x509.Certificate)
+	}
+	if _, ok := c.certs[url]; ok {
+		return
+	}
+	maxEntries := c.MaxEntries
+	if maxEntries <= 0 {
+		maxEntries = 256
+	}
+	for len(c.urls) >= maxEntries {
+		delete(c.certs, c.urls[0])
+		c.urls = c.urls[1:]
+	}
+	c.certs[url] = cert
+	c.urls = append(c.urls, url)
+}
+
+// verifyWithAIA verifies the certificates of the server with opts, the
+// intermediates of which are certs[1:], using verifyChains. If the issuer of
+// a certificate is missing, it is fetched according to Config.AIAFetching,
+// and the fetched certificates are reported in
+// ConnectionState.FetchedIntermediates.
+func (c *Conn) verifyWithAIA(ctx context.Context, certs []*x509.Certificate, opts x509.VerifyOptions) ([][]*x509.Certificate, error) {
+	chains, err := c.verifyChains(certs[0], opts)
+	aia := c.config.AIAFetching
+	if err == nil || aia == nil || !errors.As(err, new(x509.UnknownAuthorityError)) {
+		return chains, err
+	}
+
+	// Follow the certificates sent by the server from the leaf, to the one of
+	// which the issuer is missing.
+	cert := certs[0]
+walk:
+	for range certs { // bounded, in case the certificates form a loop
+		for _, issuer := range certs[1:] {
+			if issuer != cert && cert.CheckSignatureFrom(issuer) == nil {
+				cert = issuer
+				continue walk
+			}
+		}
+		break
+	}
+
+	var fetched []*x509.Certificate
+	for range aia.maxDepth() {
+		issuer := aia.fetchIssuer(ctx, cert)
+		if issuer == nil {
+			break
+		}
+		fetched = append(fetched, issuer)
+		opts.Intermediates.AddCert(issuer)
+		if chains, err = c.verifyChains(certs[0], opts); err == nil {
+			c.utls.fetchedIntermediates = fetched
+			return chains, nil
+		}
+		if !errors.As(err, new(x509.UnknownAuthorityError)) {
+			break
+		}
+		cert = issuer
+	}
+	return nil, err
+}
+
+// fetchIssuer fetches the issuer of cert from its caIssuers URLs, or returns
+// nil if none of them serves it.
+func (aia *AIAFetching) fetchIssuer(ctx context.Context, cert *x509.Certificate) *x509.Certificate {
+	for _, url := range cert.IssuingCertificateURL {
+		if aia.Cache != nil {
+			if issuer := aia.Cache.get(url); issuer != nil && cert.CheckSignatureFrom(issuer) == nil {
+				return issuer
+			}
+		}
+		data, err := httpFetch(ctx, aia.fetcher(), http.MethodGet, url, "", nil, aia.maxSize())
+		if err != nil {
+			continue
+		}
+		issuer := issuerFromAIAResponse(cert, data)
+		if issuer == nil {
+			continue
+		}
+		if aia.Cache != nil {
+			aia.Cache.put(url, issuer)
+		}
+		return issuer
+	}
+	return nil
+}
+
+// issuerFromAIAResponse returns the certificate of data which issued cert, or
+// nil. RFC 5280, Section 4.2.2.1 mandates a DER certificate or a "certs-only"
+// PKCS #7 bundle, usually served with a .p7c extension, but PEM is common.
+func issuerFromAIAResponse(cert *x509.Certificate, data []byte) *x509.Certificate {
+	if block, _ := pem.Decode(data); block != nil && (block.Type == "CERTIFICATE" || block.Type == "PKCS7") {
+		data = block.Bytes
+	}
+	candidates, err := parsePKCS7Certificates(data)
+	if err != nil {
+		issuer, err := x509.ParseCertificate(data)
+		if err != nil {
+			return nil
+		}
+		candidates = []*x509.Certificate{issuer}
+	}
+	for _, issuer := range candidates {
+		if cert.CheckSignatureFrom(issuer) == nil {
+			return issuer
+		}
+	}
+	return nil
+}
+
+var oidPKCS7SignedData = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 2}
+
+// parsePKCS7Certificates parses the certificates of a DER PKCS #7 SignedData,
+// RFC 2315, Section 9.1. BER encodings with indefinite lengths, which some
+// tools produce, are not supported.
+func parsePKCS7Certificates(der []byte) ([]*x509.Certificate, error) {
+	input := cryptobyte.String(der)
+	var contentInfo, content, signedData, certificates cryptobyte.String
+	var contentType asn1.ObjectIdentifier
+	if !input.ReadASN1(&contentInfo, cryptobyte_asn1.SEQUENCE) || !input.Empty() ||
+		!contentInfo.ReadASN1ObjectIdentifier(&contentType) || !contentType.Equal(oidPKCS7SignedData) ||
+		!contentInfo.ReadASN1(&content, cryptobyte_asn1.Tag(0).Constructed().ContextSpecific()) ||
+		!content.ReadASN1(&signedData, cryptobyte_asn1.SEQUENCE) ||
+		!signedData.SkipASN1(cryptobyte_asn1.INTEGER) || // version
+		!signedData.SkipASN1(cryptobyte_asn1.SET) || // digestAlgorithms
+		!signedData.SkipASN1(cryptobyte_asn1.SEQUENCE) || // contentInfo
+		!signedData.ReadASN1(&certificates, cryptobyte_asn1.Tag(0).Constructed().ContextSpecific()) {
+		return nil, errors.New("tls: invalid PKCS #7 certificates")
+	}
+	var certs []*x509.Certificate
+	for !certificates.Empty() {
+		var der cryptobyte.String
+		if !certificates.ReadASN1Element(&der, cryptobyte_asn1.SEQUENCE) {
+			return nil, errors.New("tls: invalid PKCS #7 certificates")
+		}
+		cert, err := x509.ParseCertificate(der)
+		if err != nil {
+			return nil, err
+		}
+		certs = append(certs, cert)
+	}
+	return certs, nil
+}
@@ -0,0 +1,269 @@
+// Copyright 2026 uTLS Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tls
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/cryptobyte"
+	cryptobyte_asn1 "golang.org/x/crypto/cryptobyte/asn1"
+)
+
+// newAIATestChain returns a root and a chain of certificates it issued, from
+// the leaf to the intermediates, each of which points to its issuer with an
+// AIA caIssuers URL served by the returned fetcher.
+func newAIATestChain(t *testing.T, intermediates int) (*x509.Certificate, Certificate, *testHTTPFetcher) {
+	t.Helper()
+	fetcher := &testHTTPFetcher{bodies: make(map[string][]byte)}
+	newKey := func() *ecdsa.PrivateKey {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return key
+	}
+	issue := func(template, parent *x509.Certificate, key, parentKey *ecdsa.PrivateKey) *x509.Certificate {
+		template.NotBefore = revocationTestTime.Add(-time.Hour)
+		template.NotAfter = revocationTestTime.Add(time.Hour)
+		der, err := x509.CreateCertificate(rand.Reader, template, parent, key.Public(), parentKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cert, _ := x509.ParseCertificate(der)
+		return cert
+	}
+
+	rootKey := newKey()
+	rootTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "AIA Test Root"},
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	root := issue(rootTemplate, rootTemplate, rootKey, rootKey)
+
+	issuer, issuerKey, issuerURL := root, rootKey, "http://aia.example/root.cer"
+	fetcher.bodies[issuerURL] = root.Raw
+	for i := range intermediates {
+		key := newKey()
+		cert := issue(&x509.Certificate{
+			SerialNumber:          big.NewInt(int64(i + 2)),
+			Subject:               pkix.Name{CommonName: "AIA Test Intermediate " + string(rune('A'+i))},
+			KeyUsage:              x509.KeyUsageCertSign,
+			BasicConstraintsValid: true,
+			IsCA:                  true,
+			IssuingCertificateURL: []string{issuerURL},
+		}, issuer, key, issuerKey)
+		issuer, issuerKey = cert, key
+		issuerURL = "http://aia.example/" + string(rune('a'+i)) + ".cer"
+		fetcher.bodies[issuerURL] = cert.Raw
+	}
+
+	leafKey := newKey()
+	leaf := issue(&x509.Certificate{
+		SerialNumber:          big.NewInt(100),
+		Subject:               pkix.Name{CommonName: "example.golang"},
+		DNSNames:              []string{"example.golang"},
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		IssuingCertificateURL: []string{issuerURL},
+	}, issuer, leafKey, issuerKey)
+	return root, Certificate{Certificate: [][]byte{leaf.Raw}, PrivateKey: leafKey}, fetcher
+}
+
+func testAIAHandshake(t *testing.T, root *x509.Certificate, cert Certificate, aia *AIAFetching) (ConnectionState, error) {
+	t.Helper()
+	serverConfig := testConfig.Clone()
+	serverConfig.Time = func() time.Time { return revocationTestTime }
+	serverConfig.Certificates = []Certificate{cert}
+
+	clientConfig := testConfig.Clone()
+	clientConfig.Time = func() time.Time { return revocationTestTime }
+	clientConfig.InsecureSkipVerify = false
+	clientConfig.ServerName = "example.golang"
+	clientConfig.RootCAs = x509.NewCertPool()
+	clientConfig.RootCAs.AddCert(root)
+	clientConfig.AIAFetching = aia
+
+	_, state, err := testHandshake(t, clientConfig, serverConfig)
+	return state, err
+}
+
+func TestAIAFetching(t *testing.T) {
+	root, cert, fetcher := newAIATestChain(t, 2)
+
+	if _, err := testAIAHandshake(t, root, cert, nil); err == nil {
+		t.Fatal("an incomplete chain was accepted without AIA fetching")
+	}
+
+	state, err := testAIAHandshake(t, root, cert, &AIAFetching{Fetcher: fetcher})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(state.FetchedIntermediates) != 2 || state.FetchedIntermediates[0].Subject.CommonName != "AIA Test Intermediate B" {
+		t.Errorf("unexpected fetched intermediates %v", state.FetchedIntermediates)
+	}
+	if len(state.VerifiedChains) != 1 || len(state.VerifiedChains[0]) != 4 {
+		t.Errorf("unexpected verified chains %v", state.VerifiedChains)
+	}
+
+	if _, err := testAIAHandshake(t, root, cert, &AIAFetching{Fetcher: fetcher, MaxDepth: 1}); err == nil {
+		t.Error("a chain was completed beyond MaxDepth")
+	}
+	if _, err := testAIAHandshake(t, root, cert, &AIAFetching{Fetcher: fetcher, MaxSize: 64}); err == nil {
+		t.Error("a certificate larger than MaxSize was used")
+	}
+
+	// A partial chain is completed from its last intermediate.
+	leaf, _ := x509.ParseCertificate(cert.Certificate[0])
+	partial := cert
+	partial.Certificate = [][]byte{cert.Certificate[0], fetcher.bodies[leaf.IssuingCertificateURL[0]]}
+	fetcher.requests = nil
+	state, err = testAIAHandshake(t, root, partial, &AIAFetching{Fetcher: fetcher})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(state.FetchedIntermediates) != 1 || len(fetcher.requests) != 1 {
+		t.Errorf("fetched %v with requests %q", state.FetchedIntermediates, fetcher.requests)
+	}
+
+	// A complete chain isn't fetched.
+	intermediate, _ := x509.ParseCertificate(partial.Certificate[1])
+	complete := cert
+	complete.Certificate = append(partial.Certificate, fetcher.bodies[intermediate.IssuingCertificateURL[0]])
+	fetcher.requests = nil
+	state, err = testAIAHandshake(t, root, complete, &AIAFetching{Fetcher: fetcher})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(state.FetchedIntermediates) != 0 || len(fetcher.requests) != 0 {
+		t.Errorf("fetched %v with requests %q", state.FetchedIntermediates, fetcher.requests)
+	}
+}
+
+func TestAIAFetchingCache(t *testing.T) {
+	root, cert, fetcher := newAIATestChain(t, 1)
+	leaf, _ := x509.ParseCertificate(cert.Certificate[0])
+	url := leaf.IssuingCertificateURL[0]
+	// PEM is accepted too.
+	fetcher.bodies[url] = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: fetcher.bodies[url]})
+
+	aia := &AIAFetching{Fetcher: fetcher, Cache: &AIACache{}}
+	for i := 0; i < 2; i++ {
+		state, err := testAIAHandshake(t, root, cert, aia)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(state.FetchedIntermediates) != 1 {
+			t.Errorf("unexpected fetched intermediates %v", state.FetchedIntermediates)
+		}
+	}
+	if len(fetcher.requests) != 1 {
+		t.Errorf("the cache wasn't used: %q", fetcher.requests)
+	}
+
+	cache := &AIACache{MaxEntries: 1}
+	cache.put("a", leaf)
+	cache.put("b", leaf)
+	if cache.get("a") != nil || cache.get("b") != leaf {
+		t.Error("the cache doesn't evict its oldest entry")
+	}
+}
+
+// newPKCS7Certificates returns a "certs-only" PKCS #7 SignedData of certs.
+func newPKCS7Certificates(certs ...*x509.Certificate) []byte {
+	var b cryptobyte.Builder
+	b.AddASN1(cryptobyte_asn1.SEQUENCE, func(b *cryptobyte.Builder) {
+		b.AddASN1ObjectIdentifier(oidPKCS7SignedData)
+		b.AddASN1(cryptobyte_asn1.Tag(0).Constructed().ContextSpecific(), func(b *cryptobyte.Builder) {
+			b.AddASN1(cryptobyte_asn1.SEQUENCE, func(b *cryptobyte.Builder) {
+				b.AddASN1Int64(1)
+				b.AddASN1(cryptobyte_asn1.SET, func(b *cryptobyte.Builder) {})
+				b.AddASN1(cryptobyte_asn1.SEQUENCE, func(b *cryptobyte.Builder) {
+					b.AddASN1ObjectIdentifier(asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 1}) // data
+				})
+				b.AddASN1(cryptobyte_asn1.Tag(0).Constructed().ContextSpecific(), func(b *cryptobyte.Builder) {
+					for _, cert := range certs {
+						b.AddBytes(cert.Raw)
+					}
+				})
+				b.AddASN1(cryptobyte_asn1.SET, func(b *cryptobyte.Builder) {}) // signerInfos
+			})
+		})
+	})
+	return b.BytesOrPanic()
+}
+
+func TestAIAFetchingPKCS7(t *testing.T) {
+	root, cert, fetcher := newAIATestChain(t, 1)
+	leaf, _ := x509.ParseCertificate(cert.Certificate[0])
+	url := leaf.IssuingCertificateURL[0]
+	intermediate, _ := x509.ParseCertificate(fetcher.bodies[url])
+	fetcher.bodies[url] = newPKCS7Certificates(root, intermediate)
+
+	state, err := testAIAHandshake(t, root, cert, &AIAFetching{Fetcher: fetcher})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(state.FetchedIntermediates) != 1 || !state.FetchedIntermediates[0].Equal(intermediate) {
+		t.Errorf("unexpected fetched intermediates %v", state.FetchedIntermediates)
+	}
+}
+
+// contextHTTPFetcher records the contexts of the requests it passes to fetcher.
+type contextHTTPFetcher struct {
+	fetcher  HTTPFetcher
+	contexts []context.Context
+}
+
+func (f *contextHTTPFetcher) Do(req *http.Request) (*http.Response, error) {
+	f.contexts = append(f.contexts, req.Context())
+	return f.fetcher.Do(req)
+}
+
+func TestAIAFetchingContext(t *testing.T) {
+	root, cert, fetcher := newAIATestChain(t, 1)
+	serverConfig := testConfig.Clone()
+	serverConfig.Time = func() time.Time { return revocationTestTime }
+	serverConfig.Certificates = []Certificate{cert}
+
+	f := &contextHTTPFetcher{fetcher: fetcher}
+	clientConfig := testConfig.Clone()
+	clientConfig.Time = func() time.Time { return revocationTestTime }
+	clientConfig.InsecureSkipVerify = false
+	clientConfig.ServerName = "example.golang"
+	clientConfig.RootCAs = x509.NewCertPool()
+	clientConfig.RootCAs.AddCert(root)
+	clientConfig.AIAFetching = &AIAFetching{Fetcher: f}
+
+	c, s := localPipe(t)
+	go func() {
+		server := Server(s, serverConfig)
+		server.Handshake()
+		server.Close()
+	}()
+	type contextKey struct{}
+	ctx := context.WithValue(context.Background(), contextKey{}, "handshake")
+	client := Client(c, clientConfig)
+	defer client.Close()
+	if err := client.HandshakeContext(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if len(f.contexts) != 1 || f.contexts[0].Value(contextKey{}) != "handshake" {
+		t.Error("the request wasn't made with the handshake context")
+	}
+}
@@ -0,0 +1,295 @@
+// Copyright 2026 uTLS Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tls
+
+import (
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/md5"
+	"errors"
+	"fmt"
+	"hash"
+	"slices"
+	"sync"
+	"sync/atomic"
+)
+
+// weakCipherSuites implement cipher suites which parrots advertise, but which
+// aren't negotiated unless enabled with CipherSuiteRegistry.EnableWeak, or,
+// for the CBC ones, globally with EnableWeakCiphers.
+var weakCipherSuites = []*cipherSuite{
+	{DISABLED_TLS_RSA_WITH_AES_256_CBC_SHA256, 32, 32, 16, rsaKA,
+		suiteTLS12, cipherAES, macSHA256, nil},
+	{DISABLED_TLS_ECDHE_ECDSA_WITH_AES_256_CBC_SHA384, 32, 48, 16, ecdheECDSAKA,
+		suiteECDHE | suiteECSign | suiteTLS12 | suiteSHA384, cipherAES, utlsMacSHA384, nil},
+	{DISABLED_TLS_ECDHE_RSA_WITH_AES_256_CBC_SHA384, 32, 48, 16, ecdheRSAKA,
+		suiteECDHE | suiteTLS12 | suiteSHA384, cipherAES, utlsMacSHA384, nil},
+	{FAKE_TLS_ECDHE_ECDSA_WITH_3DES_EDE_CBC_SHA, 24, 20, 8, ecdheECDSAKA,
+		suiteECDHE | suiteECSign, cipher3DES, macSHA1, nil},
+	{FAKE_TLS_RSA_WITH_RC4_128_MD5, 16, 16, 0, rsaKA,
+		0, cipherRC4, macMD5, nil},
+}
+
+// globalWeakCipherSuites are the weakCipherSuites which EnableWeakCiphers has
+// always enabled. RC4 and 3DES are only available with
+// CipherSuiteRegistry.EnableWeak.
+var globalWeakCipherSuites = weakCipherSuites[:3]
+
+// weakCiphersEnabled is set by EnableWeakCiphers.
+var weakCiphersEnabled atomic.Bool
+
+// macMD5 returns an MD5 based MAC, only used by weakCipherSuites.
+func macMD5(key []byte) hash.Hash {
+	return hmac.New(md5.New, key)
+}
+
+// CipherSuiteKeyExchange is the key exchange of a TLS 1.0–1.2 cipher suite.
+type CipherSuiteKeyExchange uint8
+
+const (
+	// KeyExchangeRSA is the static RSA key exchange.
+	KeyExchangeRSA CipherSuiteKeyExchange = iota
+	// KeyExchangeECDHERSA is ECDHE signed with an RSA certificate.
+	KeyExchangeECDHERSA
+	// KeyExchangeECDHEECDSA is ECDHE signed with an ECDSA or Ed25519
+	// certificate.
+	KeyExchangeECDHEECDSA
+	// KeyExchangeDHERSA is finite field DHE signed with an RSA certificate,
+	// using the groups of RFC 7919.
+	KeyExchangeDHERSA
+)
+
+// CipherSuiteImpl describes the implementation of a TLS 1.0–1.2 cipher suite
+// which isn't built in, such as the ARIA, Camellia or CCM ones. It is
+// registered with CipherSuiteRegistry.Register.
+//
+// A suite either has an AEAD, with NewAEAD, or is a CBC one, with NewBlock
+// and MAC.
+type CipherSuiteImpl struct {
+	// ID is the cipher suite value sent on the wire.
+	ID uint16
+	// KeyExchange is the key exchange of the suite.
+	KeyExchange CipherSuiteKeyExchange
+	// KeyLen is the size of the cipher key, in bytes.
+	KeyLen int
+	// TLS12Only restricts the suite to TLS 1.2. AEAD suites are always
+	// restricted to TLS 1.2.
+	TLS12Only bool
+	// SHA384 selects SHA-384 as the hash of the TLS 1.2 PRF, instead of
+	// SHA-256.
+	SHA384 bool
+
+	// NewAEAD returns the AEAD for key. The AEAD must take 12 byte nonces.
+	NewAEAD func(key []byte) (cipher.AEAD, error)
+	// XORNonce reports whether the nonce of an AEAD suite is a 12 byte IV
+	// XORed with the sequence number, as for ChaCha20-Poly1305 (RFC 7905).
+	// Otherwise, it is a 4 byte implicit IV followed by an 8 byte explicit
+	// nonce sent in each record, as for AES-GCM (RFC 5288) or AES-CCM
+	// (RFC 6655).
+	XORNonce bool
+
+	// NewBlock returns the block cipher for key, used in CBC mode.
+	NewBlock func(key []byte) (cipher.Block, error)
+	// MAC returns the hash used in the HMAC of a CBC suite.
+	MAC func() hash.Hash
+}
+
+// CipherSuiteRegistry holds the implementations of TLS 1.0–1.2 cipher suites
+// beyond the built-in ones, for the connections whose Config refers to it,
+// see Config.CipherSuiteRegistry. Suites still need to be listed in
+// Config.CipherSuites, or in a ClientHelloSpec, to be negotiated.
+//
+// The zero value is an empty registry. A CipherSuiteRegistry is safe for
+// concurrent use, but suites should be added before it's used by
+// connections.
+type CipherSuiteRegistry struct {
+	mu     sync.RWMutex
+	suites map[uint16]*cipherSuite
+}
+
+func (r *CipherSuiteRegistry) add(suite *cipherSuite) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.suites == nil {
+		r.suites = make(map[uint16]*cipherSuite)
+	}
+	r.suites[suite.id] = suite
+}
+
+// EnableWeak enables the built-in implementations of suites which parrots
+// advertise but which are too weak to be negotiated by default:
+//
+//   - DISABLED_TLS_RSA_WITH_AES_256_CBC_SHA256
+//   - DISABLED_TLS_ECDHE_ECDSA_WITH_AES_256_CBC_SHA384
+//   - DISABLED_TLS_ECDHE_RSA_WITH_AES_256_CBC_SHA384
+//   - FAKE_TLS_ECDHE_ECDSA_WITH_3DES_EDE_CBC_SHA
+//   - FAKE_TLS_RSA_WITH_RC4_128_MD5
+//
+// If ids is empty, all of them are enabled.
+func (r *CipherSuiteRegistry) EnableWeak(ids ...uint16) error {
+	if len(ids) == 0 {
+		for _, suite := range weakCipherSuites {
+			r.add(suite)
+		}
+		return nil
+	}
+	for _, id := range ids {
+		i := slices.IndexFunc(weakCipherSuites, func(suite *cipherSuite) bool { return suite.id == id })
+		if i < 0 {
+			return fmt.Errorf("tls: no built-in implementation of cipher suite %#04x", id)
+		}
+		r.add(weakCipherSuites[i])
+	}
+	return nil
+}
+
+// Register adds the implementation of a cipher suite. It takes precedence
+// over a built-in implementation of the same suite.
+func (r *CipherSuiteRegistry) Register(impl CipherSuiteImpl) error {
+	if cipherSuiteTLS13ByID(impl.ID) != nil {
+		return fmt.Errorf("tls: cipher suite %#04x is a TLS 1.3 one", impl.ID)
+	}
+	if impl.KeyLen <= 0 {
+		return errors.New("tls: invalid cipher suite key length")
+	}
+	suite := &cipherSuite{id: impl.ID, keyLen: impl.KeyLen}
+	switch impl.KeyExchange {
+	case KeyExchangeRSA:
+		suite.ka = rsaKA
+	case KeyExchangeECDHERSA:
+		suite.ka, suite.flags = ecdheRSAKA, suiteECDHE
+	case KeyExchangeECDHEECDSA:
+		suite.ka, suite.flags = ecdheECDSAKA, suiteECDHE|suiteECSign
+	case KeyExchangeDHERSA:
+		suite.ka, suite.flags = dheRSAKA, suiteDHE
+	default:
+		return errors.New("tls: unknown cipher suite key exchange")
+	}
+	if impl.TLS12Only {
+		suite.flags |= suiteTLS12
+	}
+	if impl.SHA384 {
+		suite.flags |= suiteSHA384
+	}
+
+	key := make([]byte, impl.KeyLen)
+	switch {
+	case impl.NewAEAD != nil && impl.NewBlock == nil && impl.MAC == nil:
+		a, err := impl.NewAEAD(key)
+		if err != nil {
+			return fmt.Errorf("tls: cipher suite %#04x: %w", impl.ID, err)
+		}
+		if a.NonceSize() != aeadNonceLength {
+			return fmt.Errorf("tls: cipher suite %#04x: AEAD takes %d byte nonces", impl.ID, a.NonceSize())
+		}
+		suite.flags |= suiteTLS12
+		suite.ivLen = noncePrefixLength
+		if impl.XORNonce {
+			suite.ivLen = aeadNonceLength
+		}
+		newAEAD, xorNonce := impl.NewAEAD, impl.XORNonce
+		suite.aead = func(key, fixedNonce []byte) aead {
+			a, err := newAEAD(key)
+			if err != nil {
+				panic(err)
+			}
+			if xorNonce {
+				ret := &xorNonceAEAD{aead: a}
+				copy(ret.nonceMask[:], fixedNonce)
+				return ret
+			}
+			ret := &prefixNonceAEAD{aead: a}
+			copy(ret.nonce[:], fixedNonce)
+			return ret
+		}
+	case impl.NewAEAD == nil && impl.NewBlock != nil && impl.MAC != nil:
+		block, err := impl.NewBlock(key)
+		if err != nil {
+			return fmt.Errorf("tls: cipher suite %#04x: %w", impl.ID, err)
+		}
+		suite.ivLen = block.BlockSize()
+		suite.macLen = impl.MAC().Size()
+		newBlock, mac := impl.NewBlock, impl.MAC
+		suite.cipher = func(key, iv []byte, isRead bool) any {
+			block, err := newBlock(key)
+			if err != nil {
+				panic(err)
+			}
+			if isRead {
+				return cipher.NewCBCDecrypter(block, iv)
+			}
+			return cipher.NewCBCEncrypter(block, iv)
+		}
+		suite.mac = func(key []byte) hash.Hash {
+			return hmac.New(mac, key)
+		}
+	default:
+		return errors.New("tls: a cipher suite needs either NewAEAD, or NewBlock and MAC")
+	}
+
+	r.add(suite)
+	return nil
+}
+
+func (r *CipherSuiteRegistry) cipherSuiteByID(id uint16) *cipherSuite {
+	if r == nil {
+		return nil
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.suites[id]
+}
+
+// cipherSuiteByID is like the package function, but also knows the suites of
+// c.CipherSuiteRegistry.
+func (c *Config) cipherSuiteByID(id uint16) *cipherSuite {
+	if c != nil {
+		if suite := c.CipherSuiteRegistry.cipherSuiteByID(id); suite != nil {
+			return suite
+		}
+	}
+	return cipherSuiteByID(id)
+}
+
+// mutualCipherSuite is like the package function, but also knows the suites
+// of c.CipherSuiteRegistry.
+func (c *Config) mutualCipherSuite(have []uint16, want uint16) *cipherSuite {
+	if !slices.Contains(have, want) {
+		return nil
+	}
+	return c.cipherSuiteByID(want)
+}
+
+// selectCipherSuite is like the package function, but also knows the suites
+// of c.CipherSuiteRegistry.
+func (c *Config) selectCipherSuite(ids, supportedIDs []uint16, ok func(*cipherSuite) bool) *cipherSuite {
+	for _, id := range ids {
+		candidate := c.cipherSuiteByID(id)
+		if candidate == nil || !ok(candidate) {
+			continue
+		}
+		if slices.Contains(supportedIDs, id) {
+			return candidate
+		}
+	}
+	return nil
+}
+
+// appendUTLSCipherSuites appends the suites of configCipherSuites which
+// aren't in the preference order of crypto/tls, such as the DHE ones or those
+// of Config.CipherSuiteRegistry, to list.
+func (c *Config) appendUTLSCipherSuites(list, configCipherSuites []uint16, version uint16) []uint16 {
+	for _, id := range configCipherSuites {
+		suite := c.cipherSuiteByID(id)
+		if suite == nil || slices.Contains(list, id) {
+			continue
+		}
+		if version < VersionTLS12 && suite.flags&suiteTLS12 != 0 {
+			continue
+		}
+		list = append(list, id)
+	}
+	return list
+}
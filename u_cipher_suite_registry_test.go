@@ -0,0 +1,144 @@
+// Copyright 2026 uTLS Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tls
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha256"
+	"testing"
+
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+// Private use cipher suite values, see RFC 8447, Section 8.
+const (
+	testSuiteAESGCM   uint16 = 0xff01
+	testSuiteChaCha20 uint16 = 0xff02
+	testSuiteAESCBC   uint16 = 0xff03
+)
+
+func testCipherSuiteRegistry(t *testing.T) *CipherSuiteRegistry {
+	newGCM := func(key []byte) (cipher.AEAD, error) {
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, err
+		}
+		return cipher.NewGCM(block)
+	}
+	r := &CipherSuiteRegistry{}
+	for _, impl := range []CipherSuiteImpl{
+		{ID: testSuiteAESGCM, KeyExchange: KeyExchangeECDHERSA, KeyLen: 16, NewAEAD: newGCM},
+		{ID: testSuiteChaCha20, KeyExchange: KeyExchangeRSA, KeyLen: 32, NewAEAD: chacha20poly1305.New, XORNonce: true},
+		{ID: testSuiteAESCBC, KeyExchange: KeyExchangeECDHERSA, KeyLen: 32, TLS12Only: true, NewBlock: aes.NewCipher, MAC: sha256.New},
+	} {
+		if err := r.Register(impl); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return r
+}
+
+func TestCipherSuiteRegistry(t *testing.T) {
+	registry := testCipherSuiteRegistry(t)
+	for _, id := range []uint16{testSuiteAESGCM, testSuiteChaCha20, testSuiteAESCBC} {
+		t.Run(CipherSuiteName(id), func(t *testing.T) {
+			clientConfig := testConfig.Clone()
+			clientConfig.MaxVersion = VersionTLS12
+			clientConfig.CipherSuites = []uint16{id}
+			clientConfig.CipherSuiteRegistry = registry
+			serverConfig := testConfig.Clone()
+			serverConfig.CipherSuites = []uint16{TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256, id}
+			serverConfig.CipherSuiteRegistry = registry
+
+			serverState, clientState, err := testHandshake(t, clientConfig, serverConfig)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if clientState.CipherSuite != id || serverState.CipherSuite != id {
+				t.Errorf("negotiated %#04x, want %#04x", clientState.CipherSuite, id)
+			}
+
+			// The suites are only known to the connections using the registry.
+			serverConfig.CipherSuiteRegistry = nil
+			if _, _, err := testHandshake(t, clientConfig, serverConfig); err == nil {
+				t.Error("handshake succeeded without the registry")
+			}
+		})
+	}
+}
+
+func TestCipherSuiteRegistryWeak(t *testing.T) {
+	spec, err := utlsIdToSpec(HelloChrome_Auto)
+	if err != nil {
+		t.Fatal(err)
+	}
+	spec.CipherSuites = []uint16{DISABLED_TLS_ECDHE_RSA_WITH_AES_256_CBC_SHA384, TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256}
+
+	registry := &CipherSuiteRegistry{}
+	if err := registry.EnableWeak(DISABLED_TLS_ECDHE_RSA_WITH_AES_256_CBC_SHA384); err != nil {
+		t.Fatal(err)
+	}
+	if err := registry.EnableWeak(TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256); err == nil {
+		t.Error("EnableWeak accepted a suite without a weak implementation")
+	}
+
+	clientConfig := testConfig.Clone()
+	clientConfig.CipherSuiteRegistry = registry
+	serverConfig := testConfig.Clone()
+	serverConfig.MaxVersion = VersionTLS12
+	serverConfig.CipherSuites = []uint16{DISABLED_TLS_ECDHE_RSA_WITH_AES_256_CBC_SHA384}
+	serverConfig.CipherSuiteRegistry = registry
+
+	_, clientState, err := testUtlsHandshake(t, clientConfig, serverConfig, &spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if clientState.CipherSuite != DISABLED_TLS_ECDHE_RSA_WITH_AES_256_CBC_SHA384 {
+		t.Errorf("negotiated %#04x, want %#04x", clientState.CipherSuite, DISABLED_TLS_ECDHE_RSA_WITH_AES_256_CBC_SHA384)
+	}
+
+	// A client without the registry advertises the suite but can't use it.
+	clientConfig.CipherSuiteRegistry = nil
+	if _, _, err := testUtlsHandshake(t, clientConfig, serverConfig, &spec); err == nil {
+		t.Error("handshake with a weak suite succeeded without the registry")
+	}
+}
+
+func TestEnableWeakCiphers(t *testing.T) {
+	enabled := weakCiphersEnabled.Load()
+	t.Cleanup(func() { weakCiphersEnabled.Store(enabled) })
+	EnableWeakCiphers()
+
+	for _, id := range []uint16{DISABLED_TLS_RSA_WITH_AES_256_CBC_SHA256, DISABLED_TLS_ECDHE_ECDSA_WITH_AES_256_CBC_SHA384, DISABLED_TLS_ECDHE_RSA_WITH_AES_256_CBC_SHA384} {
+		if cipherSuiteByID(id) == nil {
+			t.Errorf("EnableWeakCiphers didn't enable %#04x", id)
+		}
+	}
+	for _, id := range []uint16{FAKE_TLS_ECDHE_ECDSA_WITH_3DES_EDE_CBC_SHA, FAKE_TLS_RSA_WITH_RC4_128_MD5} {
+		if cipherSuiteByID(id) != nil {
+			t.Errorf("EnableWeakCiphers enabled %#04x", id)
+		}
+		registry := &CipherSuiteRegistry{}
+		if err := registry.EnableWeak(id); err != nil || registry.cipherSuiteByID(id) == nil {
+			t.Errorf("EnableWeak didn't enable %#04x: %v", id, err)
+		}
+	}
+}
+
+func TestCipherSuiteRegistryInvalid(t *testing.T) {
+	r := &CipherSuiteRegistry{}
+	for name, impl := range map[string]CipherSuiteImpl{
+		"TLS 1.3":    {ID: TLS_AES_128_GCM_SHA256, KeyLen: 16, NewBlock: aes.NewCipher, MAC: sha256.New},
+		"no cipher":  {ID: testSuiteAESCBC, KeyLen: 16},
+		"no MAC":     {ID: testSuiteAESCBC, KeyLen: 16, NewBlock: aes.NewCipher},
+		"key length": {ID: testSuiteAESCBC, KeyLen: 20, NewBlock: aes.NewCipher, MAC: sha256.New},
+		"nonce size": {ID: testSuiteAESGCM, KeyLen: 32, NewAEAD: chacha20poly1305.NewX},
+	} {
+		if err := r.Register(impl); err == nil {
+			t.Errorf("%s: Register succeeded", name)
+		}
+	}
+}
@@ -0,0 +1,241 @@
+// Copyright 2026 uTLS Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tls
+
+import (
+	"crypto/x509"
+	"errors"
+	"time"
+
+	"golang.org/x/crypto/cryptobyte"
+)
+
+// clientSessionStateFormatV1 is the first version of the encoding produced by
+// ClientSessionState.Bytes. clientSessionStateFormatV2, the current one, adds
+// the TLS 1.0–1.2 session ID.
+const (
+	clientSessionStateFormatV1 uint16 = 1
+	clientSessionStateFormatV2 uint16 = 2
+)
+
+var errInvalidClientSessionState = errors.New("tls: invalid client session state encoding")
+
+// Bytes encodes the client session, including the ticket, the resumption
+// secret and the server certificates, so that it can be parsed by
+// ParseClientSessionState, possibly by another process.
+//
+// Unlike SessionState.Bytes, the encoding is versioned and stable across
+// releases of this package: it starts with a format version, and newer
+// releases will keep parsing older formats.
+//
+//	struct {
+//	    uint16 format = 2;
+//	    uint16 version;
+//	    uint16 cipher_suite;
+//	    uint64 created_at;
+//	    uint64 use_by;      /* 0 for TLS 1.0–1.2 */
+//	    uint32 age_add;     /* 0 for TLS 1.0–1.2 */
+//	    opaque secret<1..2^8-1>;
+//	    opaque ticket<0..2^16-1>;
+//	    opaque session_id<0..32>; /* absent if format = 1 */
+//	    uint8 ext_master_secret = { 0, 1 };
+//	    uint8 early_data = { 0, 1 };
+//	    opaque alpn<0..2^8-1>;
+//	    CertificateEntry certificate_list<0..2^24-1>;
+//	    CertificateChain verified_chains<0..2^24-1>; /* excluding leaf */
+//	    Extra extra<0..2^24-1>;
+//	} ClientSessionStateV2;
+//
+// The encoding contains secret values critical to the security of future and
+// possibly past sessions, and must be stored accordingly.
+func (css *ClientSessionState) Bytes() ([]byte, error) {
+	if css == nil || css.session == nil {
+		return nil, errors.New("tls: empty client session state")
+	}
+	s := css.session
+	if len(s.secret) == 0 || len(s.secret) > 0xff {
+		return nil, errors.New("tls: invalid client session secret")
+	}
+	if len(s.sessionID) > 32 {
+		return nil, errors.New("tls: invalid client session ID")
+	}
+
+	var b cryptobyte.Builder
+	b.AddUint16(clientSessionStateFormatV2)
+	b.AddUint16(s.version)
+	b.AddUint16(s.cipherSuite)
+	addUint64(&b, s.createdAt)
+	addUint64(&b, s.useBy)
+	b.AddUint32(s.ageAdd)
+	b.AddUint8LengthPrefixed(func(b *cryptobyte.Builder) {
+		b.AddBytes(s.secret)
+	})
+	b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+		b.AddBytes(s.ticket)
+	})
+	b.AddUint8LengthPrefixed(func(b *cryptobyte.Builder) {
+		b.AddBytes(s.sessionID)
+	})
+	addBool(&b, s.extMasterSecret)
+	addBool(&b, s.EarlyData)
+	b.AddUint8LengthPrefixed(func(b *cryptobyte.Builder) {
+		b.AddBytes([]byte(s.alpnProtocol))
+	})
+	marshalCertificate(&b, Certificate{
+		Certificate:                 certificatesToBytesSlice(s.peerCertificates),
+		OCSPStaple:                  s.ocspResponse,
+		SignedCertificateTimestamps: s.scts,
+	})
+	b.AddUint24LengthPrefixed(func(b *cryptobyte.Builder) {
+		for _, chain := range s.verifiedChains {
+			b.AddUint24LengthPrefixed(func(b *cryptobyte.Builder) {
+				// We elide the first certificate because it's always the leaf.
+				if len(chain) == 0 {
+					b.SetError(errors.New("tls: internal error: empty verified chain"))
+					return
+				}
+				for _, cert := range chain[1:] {
+					b.AddUint24LengthPrefixed(func(b *cryptobyte.Builder) {
+						b.AddBytes(cert.Raw)
+					})
+				}
+			})
+		}
+	})
+	b.AddUint24LengthPrefixed(func(b *cryptobyte.Builder) {
+		for _, extra := range s.Extra {
+			b.AddUint24LengthPrefixed(func(b *cryptobyte.Builder) {
+				b.AddBytes(extra)
+			})
+		}
+	})
+	return b.Bytes()
+}
+
+// ParseClientSessionState parses a ClientSessionState encoded by
+// ClientSessionState.Bytes.
+func ParseClientSessionState(data []byte) (*ClientSessionState, error) {
+	s := cryptobyte.String(data)
+	var format uint16
+	if !s.ReadUint16(&format) {
+		return nil, errInvalidClientSessionState
+	}
+	switch format {
+	case clientSessionStateFormatV1, clientSessionStateFormatV2:
+		return parseClientSessionStateBody(s, format)
+	default:
+		return nil, errors.New("tls: unsupported client session state format")
+	}
+}
+
+// parseClientSessionStateBody parses what follows the format version, as the
+// format 1 and 2 encodings only differ by the session ID.
+func parseClientSessionStateBody(s cryptobyte.String, format uint16) (*ClientSessionState, error) {
+	ss := &SessionState{isClient: true}
+	var extMasterSecret, earlyData bool
+	var alpn []byte
+	var cert Certificate
+	var chainList, extra cryptobyte.String
+	if !s.ReadUint16(&ss.version) ||
+		!s.ReadUint16(&ss.cipherSuite) ||
+		!readUint64(&s, &ss.createdAt) ||
+		!readUint64(&s, &ss.useBy) ||
+		!s.ReadUint32(&ss.ageAdd) ||
+		!readUint8LengthPrefixed(&s, &ss.secret) ||
+		len(ss.secret) == 0 ||
+		!readUint16LengthPrefixed(&s, &ss.ticket) ||
+		(format >= clientSessionStateFormatV2 && !readUint8LengthPrefixed(&s, &ss.sessionID)) ||
+		len(ss.sessionID) > 32 ||
+		!readBool(&s, &extMasterSecret) ||
+		!readBool(&s, &earlyData) ||
+		!readUint8LengthPrefixed(&s, &alpn) ||
+		!unmarshalCertificate(&s, &cert) ||
+		!s.ReadUint24LengthPrefixed(&chainList) ||
+		!s.ReadUint24LengthPrefixed(&extra) ||
+		!s.Empty() {
+		return nil, errInvalidClientSessionState
+	}
+	ss.extMasterSecret = extMasterSecret
+	ss.EarlyData = earlyData
+	ss.alpnProtocol = string(alpn)
+	if len(ss.ticket) == 0 {
+		ss.ticket = nil
+	}
+	if len(ss.sessionID) == 0 {
+		ss.sessionID = nil
+	}
+
+	for _, cert := range cert.Certificate {
+		c, err := globalCertCache.newCert(cert)
+		if err != nil {
+			return nil, err
+		}
+		ss.activeCertHandles = append(ss.activeCertHandles, c)
+		ss.peerCertificates = append(ss.peerCertificates, c.cert)
+	}
+	if len(ss.peerCertificates) == 0 {
+		return nil, errors.New("tls: no server certificates in client session")
+	}
+	ss.ocspResponse = cert.OCSPStaple
+	ss.scts = cert.SignedCertificateTimestamps
+
+	for !chainList.Empty() {
+		var certList cryptobyte.String
+		if !chainList.ReadUint24LengthPrefixed(&certList) {
+			return nil, errInvalidClientSessionState
+		}
+		chain := []*x509.Certificate{ss.peerCertificates[0]}
+		for !certList.Empty() {
+			var cert []byte
+			if !readUint24LengthPrefixed(&certList, &cert) {
+				return nil, errInvalidClientSessionState
+			}
+			c, err := globalCertCache.newCert(cert)
+			if err != nil {
+				return nil, err
+			}
+			ss.activeCertHandles = append(ss.activeCertHandles, c)
+			chain = append(chain, c.cert)
+		}
+		ss.verifiedChains = append(ss.verifiedChains, chain)
+	}
+
+	for !extra.Empty() {
+		var e []byte
+		if !readUint24LengthPrefixed(&extra, &e) {
+			return nil, errInvalidClientSessionState
+		}
+		ss.Extra = append(ss.Extra, e)
+	}
+
+	return &ClientSessionState{session: ss}, nil
+}
+
+func addBool(b *cryptobyte.Builder, v bool) {
+	if v {
+		b.AddUint8(1)
+	} else {
+		b.AddUint8(0)
+	}
+}
+
+func readBool(s *cryptobyte.String, out *bool) bool {
+	var v uint8
+	if !s.ReadUint8(&v) || v > 1 {
+		return false
+	}
+	*out = v == 1
+	return true
+}
+
+// expiry returns the time after which the session can no longer be resumed,
+// or the zero time if the session does not carry an explicit lifetime, as is
+// the case for TLS 1.0–1.2 tickets.
+func (css *ClientSessionState) expiry() time.Time {
+	if css == nil || css.session == nil || css.session.useBy == 0 {
+		return time.Time{}
+	}
+	return time.Unix(int64(css.session.useBy), 0)
+}
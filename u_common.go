@@ -12,6 +12,7 @@ import (
 	"fmt"
 	"hash"
 	"log"
+	"slices"
 
 	"github.com/bogdanfinn/utls/internal/helper"
 	"golang.org/x/crypto/cryptobyte"
@@ -28,6 +29,8 @@ const (
 	utlsTypeEncryptedExtensions uint8 = 8 // implemention incomplete by crypto/tls
 	// https://datatracker.ietf.org/doc/html/rfc8879#section-7.2
 	utlsTypeCompressedCertificate uint8 = 25
+	// https://datatracker.ietf.org/doc/html/draft-agl-tls-nextprotoneg-04#section-4
+	utlsTypeNextProtocol uint8 = 67
 )
 
 // TLS
@@ -41,10 +44,14 @@ const (
 	utlsFakeExtensionCustom             uint16 = 1234   // not IANA assigned, for ALPS
 	utlsExtensionECH                    uint16 = 0xfe0d // draft-ietf-tls-esni-17
 	utlsExtensionECHOuterExtensions     uint16 = 0xfd00 // draft-ietf-tls-esni-17
+	extensionEncryptThenMAC             uint16 = 22     // https://datatracker.ietf.org/doc/html/rfc7366
+	utlsExtensionPostHandshakeAuth      uint16 = 49     // https://datatracker.ietf.org/doc/html/rfc8446#section-4.2.6
+
+	// FakeExtensionEncryptThenMAC no longer breaks connections: it is
+	// implemented by EncryptThenMACExtension.
+	FakeExtensionEncryptThenMAC uint16 = extensionEncryptThenMAC
 
 	// extensions with 'fake' prefix break connection, if server echoes them back
-	FakeExtensionEncryptThenMAC       uint16 = 22
-	fakeExtensionEncryptThenMAC       uint16 = 22
 	fakeExtensionTokenBinding         uint16 = 24
 	fakeExtensionDelegatedCredentials uint16 = 34
 	fakeExtensionPreSharedKey         uint16 = 41
@@ -77,6 +84,19 @@ const (
 	FAKE_TLS_ECDHE_ECDSA_WITH_3DES_EDE_CBC_SHA = uint16(0xc008)
 )
 
+// DHE_RSA cipher suites, implemented with the groups of RFC 7919. Like the
+// other uTLS suites, they are only used when listed in Config.CipherSuites or
+// in a ClientHelloSpec.
+const (
+	TLS_DHE_RSA_WITH_AES_128_CBC_SHA          = FAKE_TLS_DHE_RSA_WITH_AES_128_CBC_SHA
+	TLS_DHE_RSA_WITH_AES_256_CBC_SHA          = FAKE_TLS_DHE_RSA_WITH_AES_256_CBC_SHA
+	TLS_DHE_RSA_WITH_AES_128_CBC_SHA256       = FAKE_TLS_DHE_RSA_WITH_AES_128_CBC_SHA256
+	TLS_DHE_RSA_WITH_AES_256_CBC_SHA256       = FAKE_TLS_DHE_RSA_WITH_AES_256_CBC_SHA256
+	TLS_DHE_RSA_WITH_AES_128_GCM_SHA256       = FAKE_TLS_DHE_RSA_WITH_AES_128_GCM_SHA256
+	TLS_DHE_RSA_WITH_AES_256_GCM_SHA384       = FAKE_TLS_DHE_RSA_WITH_AES_256_GCM_SHA384
+	TLS_DHE_RSA_WITH_CHACHA20_POLY1305_SHA256 = uint16(0xccaa)
+)
+
 const (
 	CurveSECP256R1 CurveID = 0x0017
 	CurveSECP384R1 CurveID = 0x0018
@@ -90,6 +110,27 @@ const (
 	FakeCurveFFDHE8192 CurveID = 0x0104
 )
 
+// The finite field Diffie-Hellman groups of RFC 7919. They aren't enabled by
+// default, but are used when listed in Config.CurvePreferences or in a
+// ClientHelloSpec, for TLS 1.3 key shares and TLS 1.2 DHE cipher suites.
+const (
+	FFDHE2048 = FakeCurveFFDHE2048
+	FFDHE3072 = FakeCurveFFDHE3072
+	FFDHE4096 = FakeCurveFFDHE4096
+	FFDHE6144 = FakeCurveFFDHE6144
+	FFDHE8192 = FakeCurveFFDHE8192
+)
+
+// Post-quantum groups besides X25519MLKEM768, see draft-ietf-tls-ecdhe-mlkem
+// and draft-ietf-tls-mlkem. They aren't enabled by default, but are used when
+// listed in Config.CurvePreferences or in a ClientHelloSpec.
+const (
+	SecP256r1MLKEM768  CurveID = 0x11eb
+	SecP384r1MLKEM1024 CurveID = 0x11ed
+	MLKEM768           CurveID = 0x0201
+	MLKEM1024          CurveID = 0x0202
+)
+
 const (
 	X25519Kyber768Draft00 CurveID = 0x6399
 
@@ -119,6 +160,28 @@ var (
 	// fakeEd448 = SignatureAndHash{0x08, 0x08}
 )
 
+// ML-DSA signature schemes, see draft-ietf-tls-mldsa. They are TLS 1.3 only,
+// and advertised by the Go client when Config.PQSignatureSchemesEnabled is
+// set.
+const (
+	MLDSA44 SignatureScheme = 0x0904
+	MLDSA65 SignatureScheme = 0x0905
+	MLDSA87 SignatureScheme = 0x0906
+)
+
+// The ShangMi cipher suites, key exchange group and signature scheme of
+// RFC 8998, used by Chinese browsers. They are TLS 1.3 only, and require
+// Config.InsecureShangMi, as their implementation is not constant time. The
+// suites and SM2WithSM3 are then enabled when Config.CipherSuites lists one of
+// the suites, and CurveSM2 when Config.CurvePreferences lists it.
+const (
+	TLS_SM4_GCM_SM3 uint16 = 0x00c6
+	TLS_SM4_CCM_SM3 uint16 = 0x00c7
+
+	CurveSM2   CurveID         = 0x0029
+	SM2WithSM3 SignatureScheme = 0x0708
+)
+
 // fake curves(groups)
 var (
 	FakeFFDHE2048 = uint16(0x0100)
@@ -209,8 +272,15 @@ type ClientHelloSpec struct {
 
 	// GreaseStyle: currently only random
 	// sessionID may or may not depend on ticket; nil => random
+	// It is called when a TLS 1.2 session ticket is offered, and the session
+	// ID of a TLS 1.2 session resumed without a ticket is used as is.
 	GetSessionID func(ticket []byte) [32]byte
 
+	// HelloRetryRequest describes how the ClientHello is rebuilt after a
+	// HelloRetryRequest. nil => that of the mimicked client if known, see
+	// HelloRetryRequestSpec.
+	HelloRetryRequest *HelloRetryRequestSpec
+
 	// TLSFingerprintLink string // ?? link to tlsfingerprint.io for informational purposes
 }
 
@@ -800,29 +870,26 @@ func isGroupSupported(id CurveID) bool {
 }
 
 func init() {
-	utlsSupportedCipherSuites = append(cipherSuites, []*cipherSuite{
+	utlsSupportedCipherSuites = append(slices.Clone(cipherSuites), []*cipherSuite{
 		{OLD_TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256, 32, 0, 12, ecdheRSAKA,
 			suiteECDHE | suiteTLS12, nil, nil, aeadChaCha20Poly1305},
 		{OLD_TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256, 32, 0, 12, ecdheECDSAKA,
 			suiteECDHE | suiteECSign | suiteTLS12, nil, nil, aeadChaCha20Poly1305},
 	}...)
+	utlsSupportedCipherSuites = append(utlsSupportedCipherSuites, dheCipherSuites...)
 }
 
 // EnableWeakCiphers allows utls connections to continue in some cases, when weak cipher was chosen.
 // This provides better compatibility with servers on the web, but weakens security. Feel free
 // to use this option if you establish additional secure connection inside of utls connection.
 // This option does not change the shape of parrots (i.e. same ciphers will be offered either way).
-// Must be called before establishing any connections.
+// It enables the DISABLED_* CBC suites only: RC4 and 3DES require
+// CipherSuiteRegistry.EnableWeak.
+//
+// Deprecated: EnableWeakCiphers affects every connection of the process. Use
+// CipherSuiteRegistry.EnableWeak with Config.CipherSuiteRegistry instead.
 func EnableWeakCiphers() {
-	utlsSupportedCipherSuites = append(cipherSuites, []*cipherSuite{
-		{DISABLED_TLS_RSA_WITH_AES_256_CBC_SHA256, 32, 32, 16, rsaKA,
-			suiteTLS12, cipherAES, macSHA256, nil},
-
-		{DISABLED_TLS_ECDHE_ECDSA_WITH_AES_256_CBC_SHA384, 32, 48, 16, ecdheECDSAKA,
-			suiteECDHE | suiteECSign | suiteTLS12 | suiteSHA384, cipherAES, utlsMacSHA384, nil},
-		{DISABLED_TLS_ECDHE_RSA_WITH_AES_256_CBC_SHA384, 32, 48, 16, ecdheRSAKA,
-			suiteECDHE | suiteTLS12 | suiteSHA384, cipherAES, utlsMacSHA384, nil},
-	}...)
+	weakCiphersEnabled.Store(true)
 }
 
 func mapSlice[T any, U any](slice []T, transform func(T) U) []U {
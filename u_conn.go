@@ -9,6 +9,7 @@ import (
 	"bytes"
 	"context"
 	"crypto/cipher"
+	"crypto/x509"
 	"encoding/binary"
 	"errors"
 	"fmt"
@@ -16,6 +17,7 @@ import (
 	"net"
 	"slices"
 	"strconv"
+	"time"
 
 	"golang.org/x/crypto/cryptobyte"
 )
@@ -36,6 +38,12 @@ type UConn struct {
 	clientHelloBuildStatus ClientHelloBuildStatus
 	clientHelloSpec        *ClientHelloSpec
 
+	// helloRetryRequestSpec is set by ApplyPreset.
+	helloRetryRequestSpec *HelloRetryRequestSpec
+
+	// getSessionID is set by ApplyPreset, see ClientHelloSpec.GetSessionID.
+	getSessionID func(ticket []byte) [32]byte
+
 	HandshakeState PubClientHandshakeState
 
 	greaseSeed [ssl_grease_last_index]uint16
@@ -44,6 +52,13 @@ type UConn struct {
 	WithRandomTLSExtensionOrder bool
 	WithForceHttp1              bool
 
+	// WithFalseStart enables TLS False Start (RFC 7918) in full TLS 1.2
+	// handshakes with an ECDHE key exchange, an AEAD cipher suite and a
+	// protocol selected by ALPN: Handshake returns, and application data can
+	// be written, before the Finished message of the server is received. It
+	// is then read, and verified, by the first Read.
+	WithFalseStart bool
+
 	// skipResumptionOnNilExtension is copied from `Config.PreferSkipResumptionOnNilExtension`.
 	//
 	// By default, if ClientHelloSpec is predefined or utls-generated (as opposed to HelloCustom), this flag will be updated to true.
@@ -125,6 +140,7 @@ func (uconn *UConn) buildHandshakeState(loadSession bool) error {
 		uconn.HandshakeState.C = uconn.Conn
 		uconn.echCtx = ech
 		uconn.clientHelloBuildStatus = BuildByGoTLS
+		uconn.traceHandshakeMsg(HandshakeEventClientHelloBuilt, hello)
 	} else {
 		uAssert(uconn.clientHelloBuildStatus == BuildByUtls || uconn.clientHelloBuildStatus == NotBuilt, "BuildHandshakeState failed: invalid call, client hello has already been built by go-tls")
 		if uconn.clientHelloBuildStatus == NotBuilt {
@@ -153,9 +169,12 @@ func (uconn *UConn) buildHandshakeState(loadSession bool) error {
 		if err != nil {
 			return err
 		}
+		uconn.traceHandshake(HandshakeEventClientHelloBuilt, uconn.HandshakeState.Hello.Raw, nil)
 
 		if loadSession {
-			uconn.uApplyPatch()
+			if err := uconn.uApplyPatch(); err != nil {
+				return err
+			}
 			uconn.sessionController.finalCheck()
 			uconn.clientHelloBuildStatus = BuildByUtls
 		}
@@ -164,24 +183,42 @@ func (uconn *UConn) buildHandshakeState(loadSession bool) error {
 	return nil
 }
 
+// uLoadSession offers the cached session, if any, in the ClientHello. As
+// Config.SessionTicketsDisabled disables client resumption altogether, it
+// skips the sessions cached under a session ID too.
 func (uconn *UConn) uLoadSession() error {
 	if cfg := uconn.config; cfg.SessionTicketsDisabled || cfg.ClientSessionCache == nil {
 		return nil
 	}
-	switch uconn.sessionController.shouldLoadSession() {
+	switch action := uconn.sessionController.shouldLoadSession(); action {
 	case shouldReturn:
 	case shouldSetTicket:
 		uconn.sessionController.setSessionTicketToUConn()
 	case shouldSetPsk:
 		uconn.sessionController.setPskToUConn()
-	case shouldLoad:
+	case shouldLoad, shouldLoadSessionID:
+		if action == shouldLoadSessionID && !uconn.hasSessionIDSession() {
+			// Don't hand out a ticket which can't be offered, as a
+			// PolicyClientSessionCache with SingleUseTickets would drop it.
+			return nil
+		}
 		hello := uconn.HandshakeState.Hello.getPrivatePtr()
 		uconn.sessionController.utlsAboutToLoadSession()
 		session, earlySecret, binderKey, err := uconn.loadSession(hello)
 		if session == nil || err != nil {
 			return err
 		}
-		if session.version == VersionTLS12 {
+		if session.version == VersionTLS12 && hello.sessionTicket == nil {
+			// The server cached the session under its session ID instead
+			// of issuing a ticket, so the session_ticket extension, if
+			// any, stays empty.
+			uconn.HandshakeState.Session = session
+			uconn.setSessionIDForSession(session, nil)
+		} else if action == shouldLoadSessionID {
+			// Without the session_ticket and pre_shared_key extensions,
+			// there is no way to offer the session, which replaced the
+			// one hasSessionIDSession found.
+		} else if session.version == VersionTLS12 {
 			// We use the session ticket extension for tls 1.2 session resumption
 			uconn.sessionController.initSessionTicketExt(session, hello.sessionTicket)
 			uconn.sessionController.setSessionTicketToUConn()
@@ -193,13 +230,24 @@ func (uconn *UConn) uLoadSession() error {
 	return nil
 }
 
-func (uconn *UConn) uApplyPatch() {
+func (uconn *UConn) uApplyPatch() error {
 	helloLen := len(uconn.HandshakeState.Hello.Raw)
 	if uconn.sessionController.shouldUpdateBinders() {
-		uconn.sessionController.updateBinders()
+		if err := uconn.sessionController.updateBinders(); err != nil {
+			return err
+		}
 		uconn.sessionController.setPskToUConn()
+	} else if ext, ok := uconn.sessionController.pskExtension.(*ExternalPreSharedKeyExtension); ok && ext.Len() > 0 {
+		// Without a session to resume, the session controller leaves the
+		// extension alone, but the binders of the external PSKs are still due.
+		if err := ext.PatchBuiltHello(uconn.HandshakeState.Hello); err != nil {
+			return err
+		}
+		uconn.HandshakeState.Hello.PskIdentities = ext.identities()
+		uconn.HandshakeState.Hello.PskBinders = ext.binders
 	}
 	uAssert(helloLen == len(uconn.HandshakeState.Hello.Raw), "tls: uApplyPatch Failed: the patch should never change the length of the marshaled clientHello")
+	return nil
 }
 
 func (uconn *UConn) DidTls12Resume() bool {
@@ -374,14 +422,17 @@ func (c *UConn) handshakeContext(ctx context.Context) (ret error) {
 	defer c.in.Unlock()
 
 	// [uTLS section begins]
+	c.traceHandshake(HandshakeEventStart, nil, nil)
 	if c.isClient {
 		err := c.BuildHandshakeState()
 		if err != nil {
+			c.traceHandshake(HandshakeEventDone, nil, err)
 			return err
 		}
 	}
 	// [uTLS section ends]
 	c.handshakeErr = c.handshakeFn(handshakeCtx)
+	c.traceHandshake(HandshakeEventDone, nil, c.handshakeErr)
 	if c.handshakeErr == nil {
 		c.handshakes++
 	} else {
@@ -829,6 +880,8 @@ func (c *Conn) utlsHandshakeMessageType(msgType byte) (handshakeMessage, error)
 	switch msgType {
 	case utlsTypeCompressedCertificate:
 		return new(utlsCompressedCertificateMsg), nil
+	case utlsTypeNextProtocol:
+		return new(utlsNextProtocolMsg), nil
 	case utlsTypeEncryptedExtensions:
 		if c.isClient {
 			return new(encryptedExtensionsMsg), nil
@@ -844,6 +897,10 @@ func (c *Conn) utlsHandshakeMessageType(msgType byte) (handshakeMessage, error)
 func (c *Conn) utlsConnectionStateLocked(state *ConnectionState) {
 	state.PeerApplicationSettings = c.utls.peerApplicationSettings
 	state.ECHRetryConfigs = c.utls.echRetryConfigs
+	state.PSKIdentity = c.utls.externalPSKIdentity
+	state.NegotiatedProtocolIsMutual = !c.utls.npnFallback
+	state.VerifiedSCTs = c.utls.verifiedSCTs
+	state.FetchedIntermediates = c.utls.fetchedIntermediates
 }
 
 type utlsConnExtraFields struct {
@@ -856,6 +913,55 @@ type utlsConnExtraFields struct {
 	echRetryConfigs []ECHConfig
 
 	sessionController *sessionController
+
+	// sessionCacheView is the view of a PolicyClientSessionCache used by
+	// the connection, see clientSessionCache.
+	sessionCacheView *partitionedSessionCache
+
+	// clientHelloFragmentation controls how the ClientHello is split into
+	// records and writes, see UConn.SetClientHelloFragmentation.
+	clientHelloFragmentation *ClientHelloFragmentation
+
+	// External PSKs: the ones offered by the client, in the order of the
+	// trailing identities of the pre_shared_key extension, and the identity
+	// of the one selected by the server.
+	externalPSKs        []*externalPSKOffer
+	externalPSKIdentity []byte
+
+	// Next Protocol Negotiation (NPN): set by a client which found no
+	// protocol in common with the server and fell back to its first one.
+	npnFallback bool
+
+	// False Start: the state of a client handshake which is complete but
+	// for the Finished message of the server, see finishFalseStart.
+	falseStart *clientHandshakeState
+
+	// Post-handshake authentication: set if the client offered it in a
+	// TLS 1.3 handshake.
+	postHandshakeAuth *postHandshakeAuthState
+
+	// Certificate Transparency: the SCTs verified according to
+	// Config.CTPolicy.
+	verifiedSCTs []*SignedCertificateTimestamp
+
+	// AIA fetching: the intermediates fetched to verify the certificate of
+	// the server.
+	fetchedIntermediates []*x509.Certificate
+
+	// rootStore is the root store bound to the ClientHelloID of a UConn, see
+	// BindRootStore.
+	rootStore *RootStore
+
+	// Handshake tracing: the tracer set by UConn.SetHandshakeTracer, and the
+	// time of the last HandshakeEventStart.
+	handshakeTracer HandshakeTracer
+	handshakeStart  time.Time
+
+	// handshakeRecorder is the recorder set by UConn.SetHandshakeRecorder.
+	handshakeRecorder *HandshakeRecorder
+
+	// pcapngRecorder is the recorder set by UConn.SetPcapngRecorder.
+	pcapngRecorder *PcapngRecorder
 }
 
 // Read reads data from the connection.
@@ -980,6 +1086,10 @@ func (c *UConn) handlePostHandshakeMessage() error {
 		return c.handleNewSessionTicket(msg)
 	case *keyUpdateMsg:
 		return c.handleKeyUpdate(msg)
+	case *certificateRequestMsgTLS13:
+		return c.handlePostHandshakeCertificateRequest(msg)
+	case *certificateMsgTLS13:
+		return c.handlePostHandshakeCertificate(msg)
 	}
 	// The QUIC layer is supposed to treat an unexpected post-handshake CertificateRequest
 	// as a QUIC-level PROTOCOL_VIOLATION error (RFC 9001, Section 4.4). Returning an
@@ -0,0 +1,458 @@
+// Copyright 2026 uTLS Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tls
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"golang.org/x/crypto/cryptobyte"
+	cryptobyte_asn1 "golang.org/x/crypto/cryptobyte/asn1"
+	"golang.org/x/crypto/ocsp"
+)
+
+// Certificate Transparency, RFC 6962. Servers prove that their certificate was
+// logged with Signed Certificate Timestamps (SCTs), which are embedded in the
+// certificate, stapled in an OCSP response, or sent in the
+// signed_certificate_timestamp extension, which clients request with
+// SCTExtension in their ClientHelloSpec.
+
+// oidEmbeddedSCTList and oidOCSPSCTList are the OIDs of the extensions
+// carrying SCTs in certificates and in OCSP responses. See RFC 6962,
+// Section 3.3.
+var (
+	oidEmbeddedSCTList = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 11129, 2, 4, 2}
+	oidOCSPSCTList     = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 11129, 2, 4, 5}
+)
+
+// CTLogState is the state of a log in a log list, see
+// https://googlechrome.github.io/CertificateTransparency/log_states.html.
+type CTLogState int
+
+const (
+	CTLogPending CTLogState = iota
+	CTLogQualified
+	CTLogUsable
+	CTLogReadOnly
+	CTLogRetired
+	CTLogRejected
+)
+
+var ctLogStateNames = map[string]CTLogState{
+	"pending":   CTLogPending,
+	"qualified": CTLogQualified,
+	"usable":    CTLogUsable,
+	"readonly":  CTLogReadOnly,
+	"retired":   CTLogRetired,
+	"rejected":  CTLogRejected,
+}
+
+func (s CTLogState) String() string {
+	for name, state := range ctLogStateNames {
+		if state == s {
+			return name
+		}
+	}
+	return fmt.Sprintf("CTLogState(%d)", int(s))
+}
+
+// CTLog is a Certificate Transparency log.
+type CTLog struct {
+	// ID is the SHA-256 hash of the DER-encoded public key of the log.
+	ID [32]byte
+
+	// Key is the public key of the log, an *ecdsa.PublicKey or an
+	// *rsa.PublicKey.
+	Key crypto.PublicKey
+
+	Operator    string
+	Description string
+
+	// State is the state of the log, which it entered at StateTime.
+	State     CTLogState
+	StateTime time.Time
+}
+
+// CTLogList is a list of Certificate Transparency logs.
+type CTLogList struct {
+	Logs []*CTLog
+}
+
+// ctLogListJSON is the schema of log_list.json, version 3, see
+// https://www.gstatic.com/ct/log_list/v3/log_list_schema.json.
+type ctLogListJSON struct {
+	Operators []struct {
+		Name      string      `json:"name"`
+		Logs      []ctLogJSON `json:"logs"`
+		TiledLogs []ctLogJSON `json:"tiled_logs"`
+	} `json:"operators"`
+}
+
+type ctLogJSON struct {
+	Description string `json:"description"`
+	LogID       []byte `json:"log_id"`
+	Key         []byte `json:"key"`
+	State       map[string]struct {
+		Timestamp time.Time `json:"timestamp"`
+	} `json:"state"`
+}
+
+// ParseCTLogList parses a list of logs in the format of the log_list.json
+// published by Chrome, version 3.
+func ParseCTLogList(data []byte) (*CTLogList, error) {
+	var list ctLogListJSON
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, fmt.Errorf("tls: invalid CT log list: %w", err)
+	}
+	l := &CTLogList{}
+	for _, operator := range list.Operators {
+		for _, log := range append(operator.Logs, operator.TiledLogs...) {
+			key, err := x509.ParsePKIXPublicKey(log.Key)
+			if err != nil {
+				return nil, fmt.Errorf("tls: invalid key of CT log %q: %w", log.Description, err)
+			}
+			id := sha256.Sum256(log.Key)
+			if !bytes.Equal(id[:], log.LogID) {
+				return nil, fmt.Errorf("tls: CT log %q has an ID which doesn't match its key", log.Description)
+			}
+			if len(log.State) > 1 {
+				return nil, fmt.Errorf("tls: CT log %q has several states", log.Description)
+			}
+			ctLog := &CTLog{ID: id, Key: key, Operator: operator.Name, Description: log.Description}
+			for name, state := range log.State {
+				var ok bool
+				if ctLog.State, ok = ctLogStateNames[name]; !ok {
+					return nil, fmt.Errorf("tls: CT log %q has an unknown state %q", log.Description, name)
+				}
+				ctLog.StateTime = state.Timestamp
+			}
+			l.Logs = append(l.Logs, ctLog)
+		}
+	}
+	return l, nil
+}
+
+// log returns the log with the given ID, or nil.
+func (l *CTLogList) log(id [32]byte) *CTLog {
+	for _, log := range l.Logs {
+		if log.ID == id {
+			return log
+		}
+	}
+	return nil
+}
+
+// SCTSource is where a server provided an SCT.
+type SCTSource int
+
+const (
+	SCTSourceEmbedded SCTSource = iota
+	SCTSourceTLSExtension
+	SCTSourceOCSP
+)
+
+// SignedCertificateTimestamp is an SCT of which the signature was verified.
+type SignedCertificateTimestamp struct {
+	Log       *CTLog
+	Timestamp time.Time
+	Source    SCTSource
+
+	// Raw is the SCT, in its TLS encoding.
+	Raw []byte
+}
+
+// CTPolicy configures the Certificate Transparency checks of the leaf
+// certificate of servers, see Config.CTPolicy.
+type CTPolicy struct {
+	// Logs is the list of logs trusted to verify SCTs.
+	Logs *CTLogList
+
+	// Enforce, if true, rejects certificates which don't comply with the
+	// policy of Chrome: SCTs embedded in the certificate must come from at
+	// least two distinct logs, or three for certificates valid for more than
+	// 180 days, one of which is still qualified, usable or read-only, while
+	// SCTs provided in the TLS handshake or an OCSP response must come from
+	// two distinct logs which are. Either way, the logs must be run by at
+	// least two operators. Otherwise, SCTs are only verified and reported in
+	// ConnectionState.VerifiedSCTs.
+	Enforce bool
+}
+
+// errCTNotCompliant is returned when a certificate doesn't comply with the
+// Certificate Transparency policy.
+var errCTNotCompliant = errors.New("tls: server certificate does not comply with the Certificate Transparency policy")
+
+// checkCertificateTransparency verifies the SCTs of the leaf certificate of
+// the server according to Config.CTPolicy, and sends an alert if it must be
+// rejected.
+func (c *Conn) checkCertificateTransparency(certs []*x509.Certificate) error {
+	policy := c.config.CTPolicy
+	if policy == nil || policy.Logs == nil {
+		return nil
+	}
+
+	leaf, issuer := certs[0], revocationIssuer(certs, c.verifiedChains)
+	now := c.config.time()
+
+	var verified []*SignedCertificateTimestamp
+	verify := func(scts [][]byte, source SCTSource) {
+		for _, raw := range scts {
+			if sct, err := verifySCT(policy.Logs, raw, source, leaf, issuer, now); err == nil {
+				verified = append(verified, sct)
+			}
+		}
+	}
+	if issuer != nil {
+		for _, ext := range leaf.Extensions {
+			if ext.Id.Equal(oidEmbeddedSCTList) {
+				verify(parseSCTListExtension(ext.Value), SCTSourceEmbedded)
+			}
+		}
+		if len(c.ocspResponse) > 0 {
+			if resp, err := ocsp.ParseResponseForCert(c.ocspResponse, leaf, issuer); err == nil {
+				for _, ext := range resp.Extensions {
+					if ext.Id.Equal(oidOCSPSCTList) {
+						verify(parseSCTListExtension(ext.Value), SCTSourceOCSP)
+					}
+				}
+			}
+		}
+	}
+	verify(c.scts, SCTSourceTLSExtension)
+	c.utls.verifiedSCTs = verified
+
+	if policy.Enforce && !ctCompliant(leaf, verified) {
+		c.sendAlert(alertBadCertificate)
+		return &CertificateVerificationError{UnverifiedCertificates: certs, Err: errCTNotCompliant}
+	}
+	return nil
+}
+
+// ctCompliant reports whether the verified SCTs of leaf comply with the
+// policy described in CTPolicy.Enforce.
+func ctCompliant(leaf *x509.Certificate, scts []*SignedCertificateTimestamp) bool {
+	var embedded, delivered []*SignedCertificateTimestamp
+	for _, sct := range scts {
+		switch log := sct.Log; {
+		case log.State == CTLogQualified || log.State == CTLogUsable || log.State == CTLogReadOnly:
+		case log.State == CTLogRetired && sct.Timestamp.Before(log.StateTime):
+			// SCTs of a log issued before it was retired still count
+			// when embedded, as they can't be replaced.
+			if sct.Source == SCTSourceEmbedded {
+				embedded = append(embedded, sct)
+			}
+			continue
+		default:
+			continue
+		}
+		if sct.Source == SCTSourceEmbedded {
+			embedded = append(embedded, sct)
+		} else {
+			delivered = append(delivered, sct)
+		}
+	}
+
+	required := 2
+	if leaf.NotAfter.Sub(leaf.NotBefore) > 180*24*time.Hour {
+		required = 3
+	}
+	if logs, operators, current := countCTLogs(embedded); logs >= required && operators >= 2 && current {
+		return true
+	}
+	logs, operators, _ := countCTLogs(delivered)
+	return logs >= 2 && operators >= 2
+}
+
+// countCTLogs returns the number of distinct logs and operators which issued
+// scts, and whether one of those logs is still qualified, usable or read-only.
+func countCTLogs(scts []*SignedCertificateTimestamp) (logs, operators int, current bool) {
+	seenLogs := make(map[[32]byte]bool)
+	seenOperators := make(map[string]bool)
+	for _, sct := range scts {
+		if !seenLogs[sct.Log.ID] {
+			seenLogs[sct.Log.ID] = true
+			logs++
+		}
+		if !seenOperators[sct.Log.Operator] {
+			seenOperators[sct.Log.Operator] = true
+			operators++
+		}
+		if sct.Log.State != CTLogRetired {
+			current = true
+		}
+	}
+	return logs, operators, current
+}
+
+// parseSCTListExtension returns the SCTs of the value of a certificate or OCSP
+// extension, an OCTET STRING wrapping a SignedCertificateTimestampList.
+func parseSCTListExtension(value []byte) [][]byte {
+	var list []byte
+	if rest, err := asn1.Unmarshal(value, &list); err != nil || len(rest) != 0 {
+		return nil
+	}
+	s := cryptobyte.String(list)
+	var scts cryptobyte.String
+	if !s.ReadUint16LengthPrefixed(&scts) || !s.Empty() {
+		return nil
+	}
+	var out [][]byte
+	for !scts.Empty() {
+		var sct []byte
+		if !readUint16LengthPrefixed(&scts, &sct) {
+			return nil
+		}
+		out = append(out, sct)
+	}
+	return out
+}
+
+// verifySCT parses a v1 SCT and verifies its signature by a log of logs, for
+// leaf, or for its precertificate if the SCT is embedded. See RFC 6962,
+// Section 3.2.
+func verifySCT(logs *CTLogList, raw []byte, source SCTSource, leaf, issuer *x509.Certificate, now time.Time) (*SignedCertificateTimestamp, error) {
+	s := cryptobyte.String(raw)
+	var version, hashAlg, sigAlg uint8
+	var logID []byte
+	var timestamp uint64
+	var extensions, signature []byte
+	if !s.ReadUint8(&version) || version != 0 ||
+		!s.ReadBytes(&logID, 32) ||
+		!s.ReadUint64(&timestamp) ||
+		!readUint16LengthPrefixed(&s, &extensions) ||
+		!s.ReadUint8(&hashAlg) || !s.ReadUint8(&sigAlg) ||
+		!readUint16LengthPrefixed(&s, &signature) || !s.Empty() {
+		return nil, errors.New("tls: invalid SCT")
+	}
+	log := logs.log([32]byte(logID))
+	if log == nil {
+		return nil, errors.New("tls: SCT of an unknown log")
+	}
+	sct := &SignedCertificateTimestamp{
+		Log:       log,
+		Timestamp: time.UnixMilli(int64(timestamp)),
+		Source:    source,
+		Raw:       raw,
+	}
+	if sct.Timestamp.After(now) {
+		return nil, errors.New("tls: SCT is in the future")
+	}
+
+	b := cryptobyte.NewBuilder(nil)
+	b.AddUint8(0) // sct_version: v1
+	b.AddUint8(0) // signature_type: certificate_timestamp
+	b.AddUint64(timestamp)
+	if source == SCTSourceEmbedded {
+		tbs, err := removeSCTListExtension(leaf.RawTBSCertificate)
+		if err != nil {
+			return nil, err
+		}
+		issuerKeyHash := sha256.Sum256(issuer.RawSubjectPublicKeyInfo)
+		b.AddUint16(1) // entry_type: precert_entry
+		b.AddBytes(issuerKeyHash[:])
+		b.AddUint24LengthPrefixed(func(b *cryptobyte.Builder) {
+			b.AddBytes(tbs)
+		})
+	} else {
+		b.AddUint16(0) // entry_type: x509_entry
+		b.AddUint24LengthPrefixed(func(b *cryptobyte.Builder) {
+			b.AddBytes(leaf.Raw)
+		})
+	}
+	b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+		b.AddBytes(extensions)
+	})
+	signed, err := b.Bytes()
+	if err != nil {
+		return nil, err
+	}
+	digest := sha256.Sum256(signed)
+
+	const hashSHA256, sigRSA, sigECDSA = 4, 1, 3
+	if hashAlg != hashSHA256 {
+		return nil, errors.New("tls: SCT with an unsupported hash algorithm")
+	}
+	switch key := log.Key.(type) {
+	case *ecdsa.PublicKey:
+		if sigAlg != sigECDSA || !ecdsa.VerifyASN1(key, digest[:], signature) {
+			return nil, errors.New("tls: invalid SCT signature")
+		}
+	case *rsa.PublicKey:
+		if sigAlg != sigRSA || rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature) != nil {
+			return nil, errors.New("tls: invalid SCT signature")
+		}
+	default:
+		return nil, fmt.Errorf("tls: CT log with an unsupported key type %T", key)
+	}
+	return sct, nil
+}
+
+// removeSCTListExtension returns the TBSCertificate of a precertificate, from
+// the one of the certificate it became: without the embedded SCTs.
+func removeSCTListExtension(rawTBS []byte) ([]byte, error) {
+	errInvalid := errors.New("tls: invalid TBSCertificate")
+	input := cryptobyte.String(rawTBS)
+	var tbs cryptobyte.String
+	if !input.ReadASN1(&tbs, cryptobyte_asn1.SEQUENCE) {
+		return nil, errInvalid
+	}
+	extensionsTag := cryptobyte_asn1.Tag(3).Constructed().ContextSpecific()
+
+	b := cryptobyte.NewBuilder(nil)
+	var err error
+	b.AddASN1(cryptobyte_asn1.SEQUENCE, func(b *cryptobyte.Builder) {
+		for !tbs.Empty() {
+			var element cryptobyte.String
+			var tag cryptobyte_asn1.Tag
+			if !tbs.ReadAnyASN1Element(&element, &tag) {
+				err = errInvalid
+				return
+			}
+			if tag != extensionsTag {
+				b.AddBytes(element)
+				continue
+			}
+			var extensions cryptobyte.String
+			if !element.ReadASN1(&element, extensionsTag) ||
+				!element.ReadASN1(&extensions, cryptobyte_asn1.SEQUENCE) {
+				err = errInvalid
+				return
+			}
+			b.AddASN1(extensionsTag, func(b *cryptobyte.Builder) {
+				b.AddASN1(cryptobyte_asn1.SEQUENCE, func(b *cryptobyte.Builder) {
+					for !extensions.Empty() {
+						var extension, ext cryptobyte.String
+						var oid asn1.ObjectIdentifier
+						if !extensions.ReadASN1Element(&extension, cryptobyte_asn1.SEQUENCE) {
+							err = errInvalid
+							return
+						}
+						ext = extension
+						if !ext.ReadASN1(&ext, cryptobyte_asn1.SEQUENCE) || !ext.ReadASN1ObjectIdentifier(&oid) {
+							err = errInvalid
+							return
+						}
+						if !oid.Equal(oidEmbeddedSCTList) {
+							b.AddBytes(extension)
+						}
+					}
+				})
+			})
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return b.Bytes()
+}
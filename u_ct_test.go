@@ -0,0 +1,345 @@
+// Copyright 2026 uTLS Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tls
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/cryptobyte"
+	"golang.org/x/crypto/ocsp"
+)
+
+// ctTestLog is a log of the list built by newCTTestLogs.
+type ctTestLog struct {
+	key *ecdsa.PrivateKey
+	id  [32]byte
+}
+
+// newCTTestLogs generates a log for each of the operator and state pairs, and
+// returns them with the list which contains them.
+func newCTTestLogs(t *testing.T, logs ...[2]string) ([]*ctTestLog, *CTLogList) {
+	t.Helper()
+	type jsonLog struct {
+		Description string                     `json:"description"`
+		LogID       string                     `json:"log_id"`
+		Key         string                     `json:"key"`
+		State       map[string]json.RawMessage `json:"state"`
+	}
+	type jsonOperator struct {
+		Name string    `json:"name"`
+		Logs []jsonLog `json:"logs"`
+	}
+	var operators []*jsonOperator
+	var testLogs []*ctTestLog
+	for i, l := range logs {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		der, err := x509.MarshalPKIXPublicKey(key.Public())
+		if err != nil {
+			t.Fatal(err)
+		}
+		testLog := &ctTestLog{key: key, id: sha256.Sum256(der)}
+		testLogs = append(testLogs, testLog)
+
+		var operator *jsonOperator
+		for _, o := range operators {
+			if o.Name == l[0] {
+				operator = o
+			}
+		}
+		if operator == nil {
+			operator = &jsonOperator{Name: l[0]}
+			operators = append(operators, operator)
+		}
+		operator.Logs = append(operator.Logs, jsonLog{
+			Description: "Test Log " + string(rune('A'+i)),
+			LogID:       base64.StdEncoding.EncodeToString(testLog.id[:]),
+			Key:         base64.StdEncoding.EncodeToString(der),
+			State: map[string]json.RawMessage{
+				l[1]: json.RawMessage(`{"timestamp": "` + revocationTestTime.Add(-time.Minute).UTC().Format(time.RFC3339) + `"}`),
+			},
+		})
+	}
+	data, err := json.Marshal(map[string]any{"version": "1.0", "operators": operators})
+	if err != nil {
+		t.Fatal(err)
+	}
+	list, err := ParseCTLogList(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return testLogs, list
+}
+
+// sct returns an SCT of the log for a certificate, or for a precertificate if
+// issuerKeyHash is not nil, timestamped at the given time.
+func (l *ctTestLog) sct(t *testing.T, entry []byte, issuerKeyHash []byte, timestamp time.Time) []byte {
+	t.Helper()
+	signed := cryptobyte.NewBuilder(nil)
+	signed.AddUint8(0)
+	signed.AddUint8(0)
+	signed.AddUint64(uint64(timestamp.UnixMilli()))
+	if issuerKeyHash != nil {
+		signed.AddUint16(1)
+		signed.AddBytes(issuerKeyHash)
+	} else {
+		signed.AddUint16(0)
+	}
+	signed.AddUint24LengthPrefixed(func(b *cryptobyte.Builder) { b.AddBytes(entry) })
+	signed.AddUint16(0)
+	digest := sha256.Sum256(signed.BytesOrPanic())
+	sig, err := ecdsa.SignASN1(rand.Reader, l.key, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b := cryptobyte.NewBuilder(nil)
+	b.AddUint8(0)
+	b.AddBytes(l.id[:])
+	b.AddUint64(uint64(timestamp.UnixMilli()))
+	b.AddUint16(0)
+	b.AddUint8(4)
+	b.AddUint8(3)
+	b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) { b.AddBytes(sig) })
+	return b.BytesOrPanic()
+}
+
+// sctListExtension returns a certificate or OCSP extension carrying scts.
+func sctListExtension(t *testing.T, oid asn1.ObjectIdentifier, scts [][]byte) pkix.Extension {
+	t.Helper()
+	b := cryptobyte.NewBuilder(nil)
+	b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+		for _, sct := range scts {
+			b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) { b.AddBytes(sct) })
+		}
+	})
+	value, err := asn1.Marshal(b.BytesOrPanic())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pkix.Extension{Id: oid, Value: value}
+}
+
+// issueEmbeddedSCTs replaces the leaf certificate of p with one valid for
+// lifetime, which embeds SCTs of logs for its precertificate.
+func (p *revocationTestPKI) issueEmbeddedSCTs(t *testing.T, lifetime time.Duration, logs []*ctTestLog, timestamp time.Time) {
+	t.Helper()
+	key := p.leafCert.PrivateKey.(*ecdsa.PrivateKey)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "example.golang"},
+		DNSNames:     []string{"example.golang"},
+		NotBefore:    revocationTestTime.Add(-time.Hour),
+		NotAfter:     revocationTestTime.Add(-time.Hour + lifetime),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	precertDER, err := x509.CreateCertificate(rand.Reader, template, p.ca, key.Public(), p.caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	precert, _ := x509.ParseCertificate(precertDER)
+	issuerKeyHash := sha256.Sum256(p.ca.RawSubjectPublicKeyInfo)
+	var scts [][]byte
+	for _, log := range logs {
+		scts = append(scts, log.sct(t, precert.RawTBSCertificate, issuerKeyHash[:], timestamp))
+	}
+
+	template.ExtraExtensions = []pkix.Extension{sctListExtension(t, oidEmbeddedSCTList, scts)}
+	leafDER, err := x509.CreateCertificate(rand.Reader, template, p.ca, key.Public(), p.caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.leaf, _ = x509.ParseCertificate(leafDER)
+	p.leafCert.Certificate[0] = leafDER
+}
+
+func testCTHandshake(t *testing.T, p *revocationTestPKI, serverCert Certificate, policy *CTPolicy) (ConnectionState, error) {
+	t.Helper()
+	serverConfig := testConfig.Clone()
+	serverConfig.Time = func() time.Time { return revocationTestTime }
+	serverConfig.Certificates = []Certificate{serverCert}
+
+	clientConfig := testConfig.Clone()
+	clientConfig.Time = func() time.Time { return revocationTestTime }
+	clientConfig.InsecureSkipVerify = false
+	clientConfig.ServerName = "example.golang"
+	clientConfig.RootCAs = x509.NewCertPool()
+	clientConfig.RootCAs.AddCert(p.ca)
+	clientConfig.CTPolicy = policy
+
+	var state ConnectionState
+	var err error
+	for _, vers := range []uint16{VersionTLS12, VersionTLS13} {
+		clientConfig.MaxVersion = vers
+		_, s, vErr := testHandshake(t, clientConfig, serverConfig)
+		if vErr != nil && err == nil {
+			err = vErr
+		} else if (vErr == nil) != (err == nil) {
+			t.Fatalf("TLS 1.2 and 1.3 disagree: %v", vErr)
+		}
+		state = s
+	}
+	return state, err
+}
+
+func TestCTHandshakeSCTs(t *testing.T) {
+	p := newRevocationTestPKI(t)
+	logs, list := newCTTestLogs(t,
+		[2]string{"Operator A", "usable"},
+		[2]string{"Operator A", "usable"},
+		[2]string{"Operator B", "qualified"},
+		[2]string{"Operator C", "pending"},
+	)
+	enforce := &CTPolicy{Logs: list, Enforce: true}
+	sct := func(log *ctTestLog) []byte {
+		return log.sct(t, p.leaf.Raw, nil, revocationTestTime.Add(-time.Minute))
+	}
+
+	tests := []struct {
+		name      string
+		scts      [][]byte
+		compliant bool
+		verified  int
+	}{
+		{"TwoOperators", [][]byte{sct(logs[0]), sct(logs[2])}, true, 2},
+		{"OneOperator", [][]byte{sct(logs[0]), sct(logs[1])}, false, 2},
+		{"SameLog", [][]byte{sct(logs[0]), sct(logs[0])}, false, 2},
+		{"PendingLog", [][]byte{sct(logs[0]), sct(logs[3])}, false, 2},
+		{"Future", [][]byte{sct(logs[0]), logs[2].sct(t, p.leaf.Raw, nil, revocationTestTime.Add(time.Hour))}, false, 1},
+		{"OtherCertificate", [][]byte{sct(logs[0]), logs[2].sct(t, p.ca.Raw, nil, revocationTestTime)}, false, 1},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cert := p.leafCert
+			cert.SignedCertificateTimestamps = test.scts
+			_, err := testCTHandshake(t, p, cert, enforce)
+			if test.compliant && err != nil {
+				t.Fatalf("compliant SCTs were rejected: %v", err)
+			} else if !test.compliant && (err == nil || !strings.Contains(err.Error(), errCTNotCompliant.Error())) {
+				t.Fatalf("non-compliant SCTs were accepted: %v", err)
+			}
+
+			state, err := testCTHandshake(t, p, cert, &CTPolicy{Logs: list})
+			if err != nil {
+				t.Fatalf("SCTs were rejected without enforcement: %v", err)
+			}
+			if len(state.VerifiedSCTs) != test.verified {
+				t.Errorf("got %d verified SCTs, want %d", len(state.VerifiedSCTs), test.verified)
+			}
+			for _, sct := range state.VerifiedSCTs {
+				if sct.Source != SCTSourceTLSExtension {
+					t.Errorf("got SCT source %v, want %v", sct.Source, SCTSourceTLSExtension)
+				}
+			}
+		})
+	}
+}
+
+func TestCTOCSPSCTs(t *testing.T) {
+	p := newRevocationTestPKI(t)
+	logs, list := newCTTestLogs(t,
+		[2]string{"Operator A", "usable"},
+		[2]string{"Operator B", "usable"},
+	)
+	var scts [][]byte
+	for _, log := range logs {
+		scts = append(scts, log.sct(t, p.leaf.Raw, nil, revocationTestTime.Add(-time.Minute)))
+	}
+	staple, err := ocsp.CreateResponse(p.ca, p.ca, ocsp.Response{
+		Status:          ocsp.Good,
+		SerialNumber:    p.leaf.SerialNumber,
+		ThisUpdate:      revocationTestTime.Add(-time.Minute),
+		NextUpdate:      revocationTestTime.Add(time.Hour),
+		ExtraExtensions: []pkix.Extension{sctListExtension(t, oidOCSPSCTList, scts)},
+	}, p.caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cert := p.leafCert
+	cert.OCSPStaple = staple
+	state, err := testCTHandshake(t, p, cert, &CTPolicy{Logs: list, Enforce: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(state.VerifiedSCTs) != 2 || state.VerifiedSCTs[0].Source != SCTSourceOCSP {
+		t.Errorf("unexpected verified SCTs %+v", state.VerifiedSCTs)
+	}
+}
+
+func TestCTEmbeddedSCTs(t *testing.T) {
+	logs, list := newCTTestLogs(t,
+		[2]string{"Operator A", "usable"},
+		[2]string{"Operator B", "usable"},
+		[2]string{"Operator B", "readonly"},
+		[2]string{"Operator C", "retired"},
+		[2]string{"Operator D", "retired"},
+		[2]string{"Operator E", "rejected"},
+	)
+	beforeRetirement := revocationTestTime.Add(-time.Hour)
+	const short, long = 90 * 24 * time.Hour, 365 * 24 * time.Hour
+
+	tests := []struct {
+		name      string
+		lifetime  time.Duration
+		logs      []*ctTestLog
+		timestamp time.Time
+		compliant bool
+	}{
+		{"Short", short, []*ctTestLog{logs[0], logs[1]}, beforeRetirement, true},
+		{"ShortOneOperator", short, []*ctTestLog{logs[1], logs[2]}, beforeRetirement, false},
+		{"Long", long, []*ctTestLog{logs[0], logs[1], logs[2]}, beforeRetirement, true},
+		{"LongTwoLogs", long, []*ctTestLog{logs[0], logs[1]}, beforeRetirement, false},
+		{"RetiredLog", short, []*ctTestLog{logs[0], logs[3]}, beforeRetirement, true},
+		{"RetiredLogAfterRetirement", short, []*ctTestLog{logs[0], logs[3]}, revocationTestTime, false},
+		{"RetiredLogsOnly", short, []*ctTestLog{logs[3], logs[4]}, beforeRetirement, false},
+		{"RejectedLog", short, []*ctTestLog{logs[0], logs[5]}, beforeRetirement, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			p := newRevocationTestPKI(t)
+			p.issueEmbeddedSCTs(t, test.lifetime, test.logs, test.timestamp)
+			state, err := testCTHandshake(t, p, p.leafCert, &CTPolicy{Logs: list, Enforce: true})
+			if test.compliant && err != nil {
+				t.Fatalf("compliant SCTs were rejected: %v", err)
+			} else if !test.compliant && (err == nil || !strings.Contains(err.Error(), errCTNotCompliant.Error())) {
+				t.Fatalf("non-compliant SCTs were accepted: %v", err)
+			}
+			if test.compliant && len(state.VerifiedSCTs) != len(test.logs) {
+				t.Errorf("got %d verified SCTs, want %d", len(state.VerifiedSCTs), len(test.logs))
+			}
+		})
+	}
+}
+
+func TestParseCTLogList(t *testing.T) {
+	_, list := newCTTestLogs(t, [2]string{"Operator A", "usable"}, [2]string{"Operator B", "retired"})
+	if len(list.Logs) != 2 {
+		t.Fatalf("got %d logs, want 2", len(list.Logs))
+	}
+	if log := list.Logs[1]; log.Operator != "Operator B" || log.State != CTLogRetired || !log.StateTime.Equal(revocationTestTime.Add(-time.Minute)) {
+		t.Errorf("unexpected log %+v", log)
+	}
+
+	const mismatchedID = `{"operators": [{"name": "A", "logs": [{"log_id": "AAAA", "key": "MFkwEwYHKoZIzj0CAQYIKoZIzj0DAQcDQgAE6Tx2p1yKY4015NyIYvdrk36es0uAc1zA4PQ+TGRY+3ZjUTIYY9Wyu+3q/147JG4vNVKLtDWarZwVqGkg6lAYzA==", "state": {"usable": {"timestamp": "2020-01-01T00:00:00Z"}}}]}]}`
+	if _, err := ParseCTLogList([]byte(mismatchedID)); err == nil {
+		t.Error("a log with a mismatched ID was accepted")
+	}
+}
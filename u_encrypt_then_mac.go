@@ -0,0 +1,88 @@
+// Copyright 2026 uTLS Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tls
+
+import (
+	"crypto/subtle"
+)
+
+// Encrypt-then-MAC, RFC 7366. When the server echoes the encrypt_then_mac
+// extension and selects a CBC cipher suite, the MAC is computed over the
+// padded ciphertext and appended to it, instead of being encrypted with the
+// plaintext. A server negotiates it when Config.EncryptThenMAC is set, and
+// UConn offers it with EncryptThenMACExtension.
+
+// isCBC reports whether suite is a CBC one, to which encrypt_then_mac applies.
+func (suite *cipherSuite) isCBC() bool {
+	if suite.cipher == nil {
+		return false
+	}
+	_, ok := suite.cipher(make([]byte, suite.keyLen), make([]byte, suite.ivLen), false).(cbcMode)
+	return ok
+}
+
+// prepareEncryptThenMAC sets whether the cipher passed to prepareCipherSpec
+// uses encrypt_then_mac. It is ignored for suites which aren't CBC ones, as
+// OpenSSL does if a server echoes the extension for such a suite.
+func (hc *halfConn) prepareEncryptThenMAC(negotiated bool) {
+	_, isCBC := hc.nextCipher.(cbcMode)
+	hc.nextEncryptThenMAC = negotiated && isCBC
+}
+
+// encryptEncryptThenMAC appends the padded and encrypted payload, followed by
+// its MAC, to record, which holds the header and the explicit IV, if any.
+func (hc *halfConn) encryptEncryptThenMAC(c cbcMode, record, payload, explicitNonce []byte) []byte {
+	blockSize := c.BlockSize()
+	paddingLen := blockSize - len(payload)%blockSize
+	var dst []byte
+	record, dst = sliceForAppend(record, len(payload)+paddingLen)
+	copy(dst, payload)
+	for i := len(payload); i < len(dst); i++ {
+		dst[i] = byte(paddingLen - 1)
+	}
+	if len(explicitNonce) > 0 {
+		c.SetIV(explicitNonce)
+	}
+	c.CryptBlocks(dst, dst)
+
+	// The MAC covers the header with the length of the IV and ciphertext,
+	// see RFC 7366, Section 3.
+	n := len(record) - recordHeaderLen
+	record[3] = byte(n >> 8)
+	record[4] = byte(n)
+	mac := tls10MAC(hc.mac, hc.scratchBuf[:0], hc.seq[:], record[:recordHeaderLen], record[recordHeaderLen:], nil)
+	return append(record, mac...)
+}
+
+// decryptEncryptThenMAC checks the MAC of record, and then decrypts it and
+// removes the padding. As the MAC is checked first, there is no padding
+// oracle to protect against.
+func (hc *halfConn) decryptEncryptThenMAC(c cbcMode, record []byte, explicitNonceLen int) ([]byte, error) {
+	payload := record[recordHeaderLen:]
+	blockSize := c.BlockSize()
+	n := len(payload) - hc.mac.Size()
+	if n < explicitNonceLen+blockSize || (n-explicitNonceLen)%blockSize != 0 {
+		return nil, alertBadRecordMAC
+	}
+
+	record[3] = byte(n >> 8)
+	record[4] = byte(n)
+	localMAC := tls10MAC(hc.mac, hc.scratchBuf[:0], hc.seq[:], record[:recordHeaderLen], payload[:n], nil)
+	if subtle.ConstantTimeCompare(localMAC, payload[n:]) != 1 {
+		return nil, alertBadRecordMAC
+	}
+
+	ciphertext := payload[:n]
+	if explicitNonceLen > 0 {
+		c.SetIV(ciphertext[:explicitNonceLen])
+		ciphertext = ciphertext[explicitNonceLen:]
+	}
+	c.CryptBlocks(ciphertext, ciphertext)
+	paddingLen, paddingGood := extractPadding(ciphertext)
+	if paddingGood != 255 {
+		return nil, alertBadRecordMAC
+	}
+	return ciphertext[:len(ciphertext)-paddingLen], nil
+}
@@ -0,0 +1,163 @@
+// Copyright 2026 uTLS Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tls
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestEncryptThenMACRecords(t *testing.T) {
+	key := make([]byte, 16)
+	macKey := make([]byte, 32)
+	for _, vers := range []uint16{VersionTLS10, VersionTLS12} {
+		newHalfConn := func(isRead bool) *halfConn {
+			hc := &halfConn{}
+			hc.prepareCipherSpec(vers, cipherAES(key, make([]byte, aes.BlockSize), isRead), hmac.New(sha256.New, macKey))
+			hc.prepareEncryptThenMAC(true)
+			if err := hc.changeCipherSpec(); err != nil {
+				t.Fatal(err)
+			}
+			return hc
+		}
+		out, in := newHalfConn(false), newHalfConn(true)
+		if !out.encryptThenMAC || !in.encryptThenMAC {
+			t.Fatal("encrypt_then_mac not enabled")
+		}
+
+		for _, n := range []int{0, 1, 15, 16, 17, 1000} {
+			payload := bytes.Repeat([]byte{'a'}, n)
+			header := []byte{byte(recordTypeApplicationData), byte(vers >> 8), byte(vers), 0, 0}
+			record, err := out.encrypt(header, payload, zeroSource{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			// The MAC follows a whole number of blocks.
+			if ciphertextLen := len(record) - recordHeaderLen - sha256.Size; ciphertextLen%aes.BlockSize != 0 {
+				t.Errorf("%d bytes of ciphertext", ciphertextLen)
+			}
+
+			tampered := bytes.Clone(record)
+			tampered[recordHeaderLen] ^= 1
+			if _, _, err := newHalfConn(true).decrypt(tampered); err != alertBadRecordMAC {
+				t.Errorf("got %v for a modified record, want %v", err, alertBadRecordMAC)
+			}
+
+			plaintext, typ, err := in.decrypt(record)
+			if err != nil {
+				t.Fatalf("TLS %x, %d bytes: %v", vers, n, err)
+			}
+			if typ != recordTypeApplicationData || !bytes.Equal(plaintext, payload) {
+				t.Errorf("TLS %x, %d bytes: got %x", vers, n, plaintext)
+			}
+		}
+	}
+
+	// encrypt_then_mac doesn't apply to AEAD and stream suites.
+	hc := &halfConn{}
+	hc.prepareCipherSpec(VersionTLS12, aeadAESGCM(key, make([]byte, 4)), nil)
+	hc.prepareEncryptThenMAC(true)
+	if hc.nextEncryptThenMAC {
+		t.Error("encrypt_then_mac enabled for an AEAD")
+	}
+	hc.prepareCipherSpec(VersionTLS12, cipherRC4(key, nil, false), hmac.New(sha1.New, macKey))
+	hc.prepareEncryptThenMAC(true)
+	if hc.nextEncryptThenMAC {
+		t.Error("encrypt_then_mac enabled for RC4")
+	}
+}
+
+func TestEncryptThenMACHandshake(t *testing.T) {
+	for _, test := range []struct {
+		suite          uint16
+		encryptThenMAC bool
+	}{
+		{TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA, true},
+		{TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA256, true},
+		{TLS_RSA_WITH_AES_256_CBC_SHA, true},
+		{TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256, false},
+	} {
+		t.Run(CipherSuiteName(test.suite), func(t *testing.T) {
+			spec := ClientHelloSpec{
+				TLSVersMax:   VersionTLS12,
+				TLSVersMin:   VersionTLS10,
+				CipherSuites: []uint16{test.suite},
+				Extensions: []TLSExtension{
+					&SupportedCurvesExtension{Curves: []CurveID{X25519}},
+					&SupportedPointsExtension{SupportedPoints: []uint8{0}}, // uncompressed
+					&SignatureAlgorithmsExtension{SupportedSignatureAlgorithms: []SignatureScheme{PSSWithSHA256, PKCS1WithSHA256}},
+					&EncryptThenMACExtension{},
+				},
+			}
+			serverConfig := testConfig.Clone()
+			serverConfig.CipherSuites = []uint16{test.suite}
+			serverConfig.EncryptThenMAC = true
+
+			c, s := localPipe(t)
+			client := UClient(c, testConfig.Clone(), HelloCustom, false, false)
+			if err := client.ApplyPreset(&spec); err != nil {
+				t.Fatal(err)
+			}
+			server := Server(s, serverConfig)
+			defer client.Close()
+			defer server.Close()
+
+			// Exchange enough data for several records in each direction.
+			data := bytes.Repeat([]byte("encrypt then MAC"), 2000)
+			errChan := make(chan error, 1)
+			go func() {
+				buf := make([]byte, len(data))
+				if _, err := io.ReadFull(server, buf); err != nil {
+					errChan <- err
+					return
+				}
+				_, err := server.Write(buf)
+				errChan <- err
+			}()
+			if _, err := client.Write(data); err != nil {
+				t.Fatal(err)
+			}
+			buf := make([]byte, len(data))
+			if _, err := io.ReadFull(client, buf); err != nil {
+				t.Fatal(err)
+			}
+			if err := <-errChan; err != nil {
+				t.Fatalf("server: %v", err)
+			}
+			if !bytes.Equal(buf, data) {
+				t.Error("data corrupted")
+			}
+
+			if client.in.encryptThenMAC != test.encryptThenMAC || client.out.encryptThenMAC != test.encryptThenMAC ||
+				server.in.encryptThenMAC != test.encryptThenMAC || server.out.encryptThenMAC != test.encryptThenMAC {
+				t.Errorf("encrypt_then_mac negotiated: client %v, server %v; want %v",
+					client.in.encryptThenMAC, server.in.encryptThenMAC, test.encryptThenMAC)
+			}
+		})
+	}
+}
+
+func TestEncryptThenMACUnsolicited(t *testing.T) {
+	clientConfig := testConfig.Clone()
+	clientConfig.MaxVersion = VersionTLS12
+	clientConfig.CipherSuites = []uint16{TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA}
+	c := Client(nil, clientConfig)
+	hs := &clientHandshakeState{
+		c:           c,
+		hello:       &clientHelloMsg{cipherSuites: clientConfig.CipherSuites},
+		serverHello: &serverHelloMsg{vers: VersionTLS12, cipherSuite: TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA, encryptThenMAC: true},
+	}
+	c.vers = VersionTLS12
+	c.conn = &discardConn{}
+	if _, err := hs.processServerHello(); err == nil || !strings.Contains(err.Error(), "encrypt_then_mac") {
+		t.Errorf("got %v for an unsolicited encrypt_then_mac extension", err)
+	}
+}
@@ -0,0 +1,385 @@
+// Copyright 2026 uTLS Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tls
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/hmac"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"hash"
+	"slices"
+	"time"
+
+	"github.com/bogdanfinn/utls/internal/hkdf"
+	"github.com/bogdanfinn/utls/internal/tls13"
+	"golang.org/x/crypto/cryptobyte"
+)
+
+// errExternalPSKWithECH is returned by clients configured with both external
+// PSKs and Encrypted Client Hello, as the outer ClientHello can't carry the
+// former without revealing them.
+var errExternalPSKWithECH = errors.New("tls: external PSKs can't be offered with Encrypted Client Hello")
+
+// ExternalPSK is a TLS 1.3 pre-shared key established out of band, see
+// RFC 8446, Section 2.2. See Config.ExternalPSKs.
+type ExternalPSK struct {
+	// Identity is the name of the key, as sent in the pre_shared_key
+	// extension. It must not be empty.
+	Identity []byte
+
+	// Key is the secret. It must not be empty, and should be at least 128
+	// bits of high-entropy data: binders allow offline guessing attacks.
+	Key []byte
+
+	// CipherSuite is the TLS 1.3 cipher suite associated with the key. Only
+	// its hash matters: the key can be used with any cipher suite with the
+	// same hash. If zero, TLS_AES_128_GCM_SHA256 is used.
+	CipherSuite uint16
+
+	// Import derives the key actually used from Key with the importer
+	// interface of RFC 9258, which binds it to TLS 1.3 and to the hash of
+	// CipherSuite. The identity sent is then the serialized ImportedIdentity,
+	// and ImportContext is used as its context. Key must be associated with
+	// SHA-256, the RFC 9258 default.
+	Import        bool
+	ImportContext []byte
+}
+
+// RFC 9258, Section 10.2.
+const (
+	importedPSKKDFSHA256 uint16 = 0x0001
+	importedPSKKDFSHA384 uint16 = 0x0002
+)
+
+// externalPSKOffer is an ExternalPSK ready to be offered or accepted: its
+// identity on the wire and its key schedule.
+type externalPSKOffer struct {
+	psk         *ExternalPSK
+	identity    []byte
+	suite       *cipherSuiteTLS13
+	earlySecret *tls13.EarlySecret
+	binderKey   []byte
+}
+
+func newExternalPSKOffer(psk *ExternalPSK) (*externalPSKOffer, error) {
+	if len(psk.Identity) == 0 || len(psk.Identity) > 0xffff {
+		return nil, errors.New("tls: invalid ExternalPSK identity length")
+	}
+	if len(psk.Key) == 0 {
+		return nil, errors.New("tls: empty ExternalPSK key")
+	}
+	suiteID := psk.CipherSuite
+	if suiteID == 0 {
+		suiteID = TLS_AES_128_GCM_SHA256
+	}
+	suite := cipherSuiteTLS13ByID(suiteID)
+	if suite == nil {
+		return nil, fmt.Errorf("tls: ExternalPSK cipher suite %#04x is not a TLS 1.3 cipher suite", suiteID)
+	}
+
+	offer := &externalPSKOffer{psk: psk, identity: psk.Identity, suite: suite}
+	key := psk.Key
+	if psk.Import {
+		offer.identity, key = importExternalPSK(psk, suite)
+		if len(offer.identity) > 0xffff {
+			return nil, errors.New("tls: invalid ExternalPSK imported identity length")
+		}
+	}
+	offer.earlySecret = tls13.NewEarlySecret(suite.hash.New, key)
+	if psk.Import {
+		offer.binderKey = offer.earlySecret.ImportedBinderKey()
+	} else {
+		offer.binderKey = offer.earlySecret.ExternalBinderKey()
+	}
+	return offer, nil
+}
+
+// importExternalPSK implements RFC 9258, Section 4.2, for a key associated
+// with SHA-256 and imported for TLS 1.3 and the hash of suite.
+//
+//	struct {
+//	    opaque external_identity<1...2^16-1>;
+//	    opaque context<0..2^16-1>;
+//	    uint16 target_protocol;
+//	    uint16 target_kdf;
+//	} ImportedIdentity;
+//
+//	epskx = HKDF-Extract(0, epsk)
+//	ipskx = HKDF-Expand-Label(epskx, "derived psk", Hash(ImportedIdentity), L)
+func importExternalPSK(psk *ExternalPSK, suite *cipherSuiteTLS13) (identity, key []byte) {
+	targetKDF := importedPSKKDFSHA256
+	if suite.hash == tls13Hash(crypto.SHA384) {
+		targetKDF = importedPSKKDFSHA384
+	}
+	var b cryptobyte.Builder
+	b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+		b.AddBytes(psk.Identity)
+	})
+	b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+		b.AddBytes(psk.ImportContext)
+	})
+	b.AddUint16(VersionTLS13)
+	b.AddUint16(targetKDF)
+	identity = b.BytesOrPanic()
+
+	identityHash := sha256.Sum256(identity)
+	epskx := hkdf.Extract(sha256.New, psk.Key, nil)
+	key = tls13.ExpandLabel(sha256.New, epskx, "derived psk", identityHash[:], suite.hash.Size())
+	return identity, key
+}
+
+// externalPSKOffers returns the offers for psks which can be used with one of
+// the offered cipher suites.
+func externalPSKOffers(psks []ExternalPSK, cipherSuites []uint16) ([]*externalPSKOffer, error) {
+	var offers []*externalPSKOffer
+	for i := range psks {
+		offer, err := newExternalPSKOffer(&psks[i])
+		if err != nil {
+			return nil, err
+		}
+		if !slices.ContainsFunc(cipherSuites, func(id uint16) bool {
+			suite := cipherSuiteTLS13ByID(id)
+			return suite != nil && suite.hash == offer.suite.hash
+		}) {
+			continue
+		}
+		offers = append(offers, offer)
+	}
+	return offers, nil
+}
+
+func (c *Config) pskKeyExchangeModes() []uint8 {
+	if len(c.PSKKeyExchangeModes) == 0 {
+		return []uint8{pskModeDHE}
+	}
+	return c.PSKKeyExchangeModes
+}
+
+// pskBinderKey is what is needed to compute the binder of one PSK identity.
+type pskBinderKey struct {
+	suite     *cipherSuiteTLS13
+	binderKey []byte
+}
+
+// computeAndUpdatePSKBinders is like computeAndUpdatePSK, for a ClientHello
+// carrying several PSK identities, possibly using different hashes.
+// newTranscript returns the transcript preceding the ClientHello for a hash.
+func computeAndUpdatePSKBinders(m *clientHelloMsg, keys []pskBinderKey, newTranscript func(tls13Hash) (hash.Hash, error)) error {
+	helloBytes, err := m.marshalWithoutBinders()
+	if err != nil {
+		return err
+	}
+	pskBinders := make([][]byte, 0, len(keys))
+	for _, k := range keys {
+		transcript, err := newTranscript(k.suite.hash)
+		if err != nil {
+			return err
+		}
+		transcript.Write(helloBytes)
+		pskBinders = append(pskBinders, k.suite.finishedHash(k.binderKey, transcript))
+	}
+	return m.updateBinders(pskBinders)
+}
+
+// offerExternalPSKs appends Config.ExternalPSKs to the pre_shared_key
+// extension of a ClientHello built by crypto/tls, after the resumption
+// identity set by loadSession, if any, and recomputes all binders.
+func (c *Conn) offerExternalPSKs(hello *clientHelloMsg, session *SessionState, binderKey []byte) error {
+	c.utls.externalPSKs = nil
+	if len(c.config.ExternalPSKs) == 0 || c.config.maxSupportedVersion(roleClient) < VersionTLS13 {
+		return nil
+	}
+	if c.config.EncryptedClientHelloConfigList != nil {
+		return errExternalPSKWithECH
+	}
+	offers, err := externalPSKOffers(c.config.ExternalPSKs, hello.cipherSuites)
+	if err != nil || len(offers) == 0 {
+		return err
+	}
+
+	var keys []pskBinderKey
+	if len(hello.pskIdentities) == 1 && session != nil && binderKey != nil {
+		keys = append(keys, pskBinderKey{cipherSuiteTLS13ByID(session.cipherSuite), binderKey})
+	} else {
+		hello.pskIdentities, hello.pskBinders = nil, nil
+	}
+	for _, offer := range offers {
+		// External PSKs have no ticket age, see RFC 8446, Section 4.2.11.
+		hello.pskIdentities = append(hello.pskIdentities, pskIdentity{label: offer.identity})
+		hello.pskBinders = append(hello.pskBinders, make([]byte, offer.suite.hash.Size()))
+		keys = append(keys, pskBinderKey{offer.suite, offer.binderKey})
+	}
+	hello.pskModes = c.config.pskKeyExchangeModes()
+
+	if err := computeAndUpdatePSKBinders(hello, keys, func(h tls13Hash) (hash.Hash, error) {
+		return h.New(), nil
+	}); err != nil {
+		return err
+	}
+	c.utls.externalPSKs = offers
+	return nil
+}
+
+// selectedExternalPSK returns the external PSK selected by the server, if the
+// selected identity is one of the external ones.
+func (hs *clientHandshakeStateTLS13) selectedExternalPSK() *externalPSKOffer {
+	offers := hs.c.utls.externalPSKs
+	first := len(hs.hello.pskIdentities) - len(offers)
+	if first < 0 || int(hs.serverHello.selectedIdentity) < first {
+		return nil
+	}
+	return offers[int(hs.serverHello.selectedIdentity)-first]
+}
+
+func (hs *clientHandshakeStateTLS13) useExternalPSK(offer *externalPSKOffer) error {
+	c := hs.c
+	if offer.suite.hash != hs.suite.hash {
+		c.sendAlert(alertIllegalParameter)
+		return errors.New("tls: server selected an invalid PSK and cipher suite pair")
+	}
+	hs.usingPSK = true
+	hs.earlySecret = offer.earlySecret
+	c.utls.externalPSKIdentity = bytes.Clone(offer.psk.Identity)
+	return nil
+}
+
+// updateExternalPSKsAfterHRR updates the pre_shared_key extension of the
+// second ClientHello when external PSKs were offered: identities with a hash
+// other than the one of the selected cipher suite are removed, as recommended
+// by RFC 8446, Section 4.1.4, and all binders are recomputed over the new
+// transcript.
+func (hs *clientHandshakeStateTLS13) updateExternalPSKsAfterHRR(chHash []byte) error {
+	c := hs.c
+	hello := hs.hello
+	offers := c.utls.externalPSKs
+
+	var (
+		identities []pskIdentity
+		binders    [][]byte
+		keys       []pskBinderKey
+		kept       []*externalPSKOffer
+	)
+	if len(hello.pskIdentities) > len(offers) && hs.session != nil {
+		if pskSuite := cipherSuiteTLS13ByID(hs.session.cipherSuite); pskSuite != nil && pskSuite.hash == hs.suite.hash {
+			identity := hello.pskIdentities[0]
+			ticketAge := c.config.time().Sub(time.Unix(int64(hs.session.createdAt), 0))
+			identity.obfuscatedTicketAge = uint32(ticketAge/time.Millisecond) + hs.session.ageAdd
+			identities = append(identities, identity)
+			binders = append(binders, make([]byte, hs.suite.hash.Size()))
+			keys = append(keys, pskBinderKey{hs.suite, hs.binderKey})
+		}
+	}
+	for _, offer := range offers {
+		if offer.suite.hash != hs.suite.hash {
+			continue
+		}
+		identities = append(identities, pskIdentity{label: offer.identity})
+		binders = append(binders, make([]byte, hs.suite.hash.Size()))
+		keys = append(keys, pskBinderKey{hs.suite, offer.binderKey})
+		kept = append(kept, offer)
+	}
+	c.utls.externalPSKs = kept
+	hello.pskIdentities, hello.pskBinders = identities, binders
+	if len(identities) == 0 {
+		return nil
+	}
+
+	return computeAndUpdatePSKBinders(hello, keys, func(tls13Hash) (hash.Hash, error) {
+		transcript := hs.suite.hash.New()
+		transcript.Write([]byte{typeMessageHash, 0, 0, uint8(len(chHash))})
+		transcript.Write(chHash)
+		if err := transcriptMsg(hs.serverHello, transcript); err != nil {
+			return nil, err
+		}
+		return transcript, nil
+	})
+}
+
+// pskKEWithoutKeyShares reports whether hello offers PSKs in psk_ke mode
+// without any key share, which only allows psk_ke handshakes. See RFC 8446,
+// Section 9.2.
+func (hello *clientHelloMsg) pskKEWithoutKeyShares() bool {
+	return len(hello.keyShares) == 0 && len(hello.pskIdentities) > 0 &&
+		slices.Contains(hello.pskModes, pskModePlain)
+}
+
+// checkForExternalPSK accepts one of the external PSKs offered by the client,
+// if any matches Config.ExternalPSKs and the preferred mutual
+// psk_key_exchange_mode is wantMode. processClientHello calls it with psk_ke,
+// before the (EC)DHE key exchange, and checkForResumption with psk_dhe_ke,
+// after any HelloRetryRequest.
+func (hs *serverHandshakeStateTLS13) checkForExternalPSK(wantMode uint8) (accepted bool, err error) {
+	c := hs.c
+	if len(c.config.ExternalPSKs) == 0 || len(hs.clientHello.pskIdentities) == 0 {
+		return false, nil
+	}
+
+	mode, ok := uint8(0), false
+	for _, m := range c.config.pskKeyExchangeModes() {
+		if slices.Contains(hs.clientHello.pskModes, m) {
+			mode, ok = m, true
+			break
+		}
+	}
+	if !ok || mode != wantMode {
+		return false, nil
+	}
+
+	if len(hs.clientHello.pskIdentities) != len(hs.clientHello.pskBinders) {
+		c.sendAlert(alertIllegalParameter)
+		return false, errors.New("tls: invalid or missing PSK binders")
+	}
+
+	offers := make([]*externalPSKOffer, 0, len(c.config.ExternalPSKs))
+	for i := range c.config.ExternalPSKs {
+		offer, err := newExternalPSKOffer(&c.config.ExternalPSKs[i])
+		if err != nil {
+			c.sendAlert(alertInternalError)
+			return false, err
+		}
+		if offer.suite.hash == hs.suite.hash {
+			offers = append(offers, offer)
+		}
+	}
+
+	for i, identity := range hs.clientHello.pskIdentities {
+		j := slices.IndexFunc(offers, func(offer *externalPSKOffer) bool {
+			return bytes.Equal(offer.identity, identity.label)
+		})
+		if j < 0 {
+			continue
+		}
+		offer := offers[j]
+
+		// Clone the transcript in case a HelloRetryRequest was recorded.
+		transcript := cloneHash(hs.transcript, hs.suite.hash)
+		if transcript == nil {
+			c.sendAlert(alertInternalError)
+			return false, errors.New("tls: internal error: failed to clone hash")
+		}
+		clientHelloBytes, err := hs.clientHello.marshalWithoutBinders()
+		if err != nil {
+			c.sendAlert(alertInternalError)
+			return false, err
+		}
+		transcript.Write(clientHelloBytes)
+		pskBinder := hs.suite.finishedHash(offer.binderKey, transcript)
+		if !hmac.Equal(hs.clientHello.pskBinders[i], pskBinder) {
+			c.sendAlert(alertDecryptError)
+			return false, errors.New("tls: invalid PSK binder")
+		}
+
+		hs.earlySecret = offer.earlySecret
+		hs.hello.selectedIdentityPresent = true
+		hs.hello.selectedIdentity = uint16(i)
+		hs.usingPSK = true
+		c.utls.externalPSKIdentity = bytes.Clone(offer.psk.Identity)
+		return true, nil
+	}
+	return false, nil
+}
@@ -0,0 +1,265 @@
+// Copyright 2026 uTLS Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tls
+
+import (
+	"bytes"
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+func testExternalPSKConfigs() (clientConfig, serverConfig *Config) {
+	serverConfig = testConfig.Clone()
+	serverConfig.Certificates = nil
+	serverConfig.MinVersion = VersionTLS13
+	serverConfig.ExternalPSKs = []ExternalPSK{
+		{Identity: []byte("server-only"), Key: bytes.Repeat([]byte{1}, 32)},
+		{Identity: []byte("shared"), Key: bytes.Repeat([]byte{2}, 32)},
+	}
+	clientConfig = testConfig.Clone()
+	clientConfig.InsecureSkipVerify = false
+	clientConfig.ServerName = "example.golang"
+	clientConfig.ExternalPSKs = []ExternalPSK{
+		{Identity: []byte("client-only"), Key: bytes.Repeat([]byte{3}, 32)},
+		{Identity: []byte("shared"), Key: bytes.Repeat([]byte{2}, 32)},
+	}
+	return
+}
+
+func checkExternalPSKStates(t *testing.T, serverState, clientState ConnectionState, identity string) {
+	t.Helper()
+	for side, state := range map[string]ConnectionState{"server": serverState, "client": clientState} {
+		if string(state.PSKIdentity) != identity {
+			t.Errorf("%s: PSKIdentity = %q, want %q", side, state.PSKIdentity, identity)
+		}
+		if state.DidResume {
+			t.Errorf("%s: external PSK handshake reported as a resumption", side)
+		}
+	}
+	if len(clientState.PeerCertificates) != 0 {
+		t.Errorf("server sent certificates in an external PSK handshake")
+	}
+}
+
+func TestExternalPSK(t *testing.T) {
+	clientConfig, serverConfig := testExternalPSKConfigs()
+	serverState, clientState, err := testHandshake(t, clientConfig, serverConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkExternalPSKStates(t, serverState, clientState, "shared")
+	if clientState.testingOnlyCurveID == 0 {
+		t.Errorf("psk_dhe_ke handshake did not use a key exchange")
+	}
+}
+
+func TestExternalPSKPlainMode(t *testing.T) {
+	clientConfig, serverConfig := testExternalPSKConfigs()
+	clientConfig.PSKKeyExchangeModes = []uint8{PskModePlain, PskModeDHE}
+	serverConfig.PSKKeyExchangeModes = []uint8{PskModePlain, PskModeDHE}
+	serverState, clientState, err := testHandshake(t, clientConfig, serverConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkExternalPSKStates(t, serverState, clientState, "shared")
+	if clientState.testingOnlyCurveID != 0 || serverState.testingOnlyCurveID != 0 {
+		t.Errorf("psk_ke handshake used a key exchange")
+	}
+
+	// A server which only allows psk_dhe_ke must not pick psk_ke.
+	serverConfig.PSKKeyExchangeModes = nil
+	_, clientState, err = testHandshake(t, clientConfig, serverConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if clientState.testingOnlyCurveID == 0 {
+		t.Errorf("server picked psk_ke although it only allows psk_dhe_ke")
+	}
+}
+
+func TestExternalPSKImported(t *testing.T) {
+	clientConfig, serverConfig := testExternalPSKConfigs()
+	for _, config := range []*Config{clientConfig, serverConfig} {
+		for i := range config.ExternalPSKs {
+			config.ExternalPSKs[i].Import = true
+			config.ExternalPSKs[i].ImportContext = []byte("context")
+		}
+	}
+	serverState, clientState, err := testHandshake(t, clientConfig, serverConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkExternalPSKStates(t, serverState, clientState, "shared")
+
+	// The import context is part of the imported identity.
+	serverConfig.ExternalPSKs[1].ImportContext = []byte("other")
+	serverConfig.Certificates = testConfig.Certificates
+	clientConfig.InsecureSkipVerify = true
+	_, clientState, err = testHandshake(t, clientConfig, serverConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if clientState.PSKIdentity != nil {
+		t.Errorf("imported PSK accepted with a different context")
+	}
+}
+
+func TestExternalPSKSHA384(t *testing.T) {
+	clientConfig, serverConfig := testExternalPSKConfigs()
+	for _, config := range []*Config{clientConfig, serverConfig} {
+		config.ExternalPSKs[1].CipherSuite = TLS_AES_256_GCM_SHA384
+	}
+
+	// With SHA-256 suites only, the SHA-384 key isn't even offered, and the
+	// handshake falls back to certificates.
+	serverConfig.Certificates = testConfig.Certificates
+	clientConfig.InsecureSkipVerify = true
+	_, clientState, err := testHandshake(t, clientConfig, serverConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if clientState.PSKIdentity != nil {
+		t.Errorf("PSK accepted with a cipher suite of another hash")
+	}
+
+	spec := testExternalPSKSpec(t)
+	spec.CipherSuites = []uint16{TLS_AES_256_GCM_SHA384}
+	serverState, clientState, err := testUtlsHandshake(t, clientConfig, serverConfig, spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkExternalPSKStates(t, serverState, clientState, "shared")
+}
+
+func TestExternalPSKWrongKey(t *testing.T) {
+	clientConfig, serverConfig := testExternalPSKConfigs()
+	clientConfig.ExternalPSKs[1].Key = bytes.Repeat([]byte{9}, 32)
+	_, _, err := testHandshake(t, clientConfig, serverConfig)
+	if err == nil || !strings.Contains(err.Error(), "invalid PSK binder") {
+		t.Fatalf("expected an invalid binder error, got %v", err)
+	}
+}
+
+func TestExternalPSKHelloRetryRequest(t *testing.T) {
+	clientConfig, serverConfig := testExternalPSKConfigs()
+	serverConfig.CurvePreferences = []CurveID{CurveP384}
+	serverState, clientState, err := testHandshake(t, clientConfig, serverConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !serverState.testingOnlyDidHRR {
+		t.Fatal("expected a HelloRetryRequest")
+	}
+	checkExternalPSKStates(t, serverState, clientState, "shared")
+}
+
+func TestExternalPSKWithResumption(t *testing.T) {
+	clientConfig, serverConfig := testExternalPSKConfigs()
+	clientConfig.InsecureSkipVerify = true
+	clientConfig.ClientSessionCache = NewLRUClientSessionCache(1)
+	serverConfig.Certificates = testConfig.Certificates
+	sharedPSK := serverConfig.ExternalPSKs[1]
+	serverConfig.ExternalPSKs = serverConfig.ExternalPSKs[:1]
+
+	// The first handshake uses certificates, and stores a ticket.
+	if _, clientState, err := testHandshake(t, clientConfig, serverConfig); err != nil {
+		t.Fatal(err)
+	} else if clientState.PSKIdentity != nil || clientState.DidResume {
+		t.Fatal("first handshake unexpectedly used a PSK")
+	}
+
+	// The next ones offer the ticket first, then the external PSKs.
+	serverState, clientState, err := testHandshake(t, clientConfig, serverConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !serverState.DidResume || !clientState.DidResume || clientState.PSKIdentity != nil {
+		t.Errorf("expected a resumption, got DidResume=%v PSKIdentity=%q", clientState.DidResume, clientState.PSKIdentity)
+	}
+
+	// The server prefers external PSKs over tickets.
+	serverConfig.ExternalPSKs = append(serverConfig.ExternalPSKs, sharedPSK)
+	serverState, clientState, err = testHandshake(t, clientConfig, serverConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkExternalPSKStates(t, serverState, clientState, "shared")
+}
+
+// testExternalPSKSpec returns the latest Chrome spec, with its
+// pre_shared_key extension replaced by an ExternalPreSharedKeyExtension.
+func testExternalPSKSpec(t *testing.T) *ClientHelloSpec {
+	chrome, err := utlsIdToSpec(HelloChrome_Auto)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exts := chrome.Extensions[:0]
+	for _, ext := range chrome.Extensions {
+		if _, ok := ext.(PreSharedKeyExtension); !ok {
+			exts = append(exts, ext)
+		}
+	}
+	chrome.Extensions = append(exts, &ExternalPreSharedKeyExtension{})
+	return &chrome
+}
+
+func TestExternalPSKUTLS(t *testing.T) {
+	clientConfig, serverConfig := testExternalPSKConfigs()
+	serverState, clientState, err := testUtlsHandshake(t, clientConfig, serverConfig, testExternalPSKSpec(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkExternalPSKStates(t, serverState, clientState, "shared")
+}
+
+func TestExternalPSKPlainModeNoKeyShare(t *testing.T) {
+	clientConfig, serverConfig := testExternalPSKConfigs()
+	clientConfig.PSKKeyExchangeModes = []uint8{PskModePlain}
+	serverConfig.PSKKeyExchangeModes = []uint8{PskModePlain, PskModeDHE}
+
+	// A psk_ke only ClientHello needs neither supported_groups nor key_share.
+	spec := testExternalPSKSpec(t)
+	exts := spec.Extensions[:0]
+	for _, ext := range spec.Extensions {
+		switch ext := ext.(type) {
+		case *KeyShareExtension, *SupportedCurvesExtension:
+			continue
+		case *PSKKeyExchangeModesExtension:
+			ext.Modes = []uint8{PskModePlain}
+		}
+		exts = append(exts, ext)
+	}
+	spec.Extensions = exts
+
+	serverState, clientState, err := testUtlsHandshake(t, clientConfig, serverConfig, spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkExternalPSKStates(t, serverState, clientState, "shared")
+	if serverState.testingOnlyDidHRR {
+		t.Error("server sent a HelloRetryRequest for a psk_ke handshake")
+	}
+	if clientState.testingOnlyCurveID != 0 || serverState.testingOnlyCurveID != 0 {
+		t.Errorf("psk_ke handshake used a key exchange")
+	}
+}
+
+func TestExternalPSKWithECH(t *testing.T) {
+	clientConfig, _ := testExternalPSKConfigs()
+	clientConfig.MinVersion = VersionTLS13
+	echConfigList, err := hex.DecodeString("0045fe0d0041590020002092a01233db2218518ccbbbbc24df20686af417b37388de6460e94011974777090004000100010012636c6f7564666c6172652d6563682e636f6d0000")
+	if err != nil {
+		t.Fatal(err)
+	}
+	clientConfig.EncryptedClientHelloConfigList = echConfigList
+
+	if err := Client(nil, clientConfig).offerExternalPSKs(&clientHelloMsg{}, nil, nil); err != errExternalPSKWithECH {
+		t.Errorf("Client: got error %v, want %v", err, errExternalPSKWithECH)
+	}
+	if err := UClient(nil, clientConfig, HelloCustom, false, false).ApplyPreset(testExternalPSKSpec(t)); err != errExternalPSKWithECH {
+		t.Errorf("UClient: got error %v, want %v", err, errExternalPSKWithECH)
+	}
+}
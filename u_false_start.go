@@ -0,0 +1,63 @@
+// Copyright 2026 uTLS Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tls
+
+// TLS False Start, RFC 7918. In a full TLS 1.2 handshake, the client sends
+// application data right after its Finished message, without waiting for the
+// ChangeCipherSpec and Finished messages of the server, which are read before
+// the first record read after the handshake instead. Like Chrome and
+// BoringSSL, a UConn only does it with WithFalseStart, for ECDHE key exchanges
+// with AEAD suites when ALPN selected a protocol.
+
+// falseStart reports whether the client can False Start the handshake.
+func (hs *clientHandshakeState) falseStart() bool {
+	c := hs.c
+
+	if hs.uconn == nil || !hs.uconn.WithFalseStart || c.quic != nil || c.handshakes > 0 {
+		return false
+	}
+	return hs.suite.flags&suiteECDHE != 0 && hs.suite.aead != nil && hs.serverHello.alpnProtocol != ""
+}
+
+// startFalseStart completes the handshake before the Finished message of the
+// server is read, which is left to finishFalseStart.
+func (hs *clientHandshakeState) startFalseStart() {
+	c := hs.c
+
+	c.utls.falseStart = hs
+	c.ekm = ekmFromMasterSecret(c.vers, hs.suite, hs.masterSecret, hs.hello.random, hs.serverHello.random)
+	c.isHandshakeComplete.Store(true)
+}
+
+// finishFalseStart reads the rest of a False Started handshake, the session
+// ticket and Finished message of the server, and saves the session. It must
+// be called with c.in held, before reading any record after the handshake. If
+// it fails, both directions of the connection are broken, so that no more
+// application data is sent to an unauthenticated server.
+func (c *Conn) finishFalseStart() error {
+	hs := c.utls.falseStart
+	if hs == nil {
+		return nil
+	}
+	c.utls.falseStart = nil
+
+	if err := hs.readSessionTicket(); err != nil {
+		return c.failFalseStart(err)
+	}
+	if err := hs.readFinished(c.serverFinished[:]); err != nil {
+		return c.failFalseStart(err)
+	}
+	if err := hs.saveSessionTicket(); err != nil {
+		return c.failFalseStart(err)
+	}
+	return nil
+}
+
+func (c *Conn) failFalseStart(err error) error {
+	c.out.Lock()
+	c.out.setErrorLocked(err)
+	c.out.Unlock()
+	return c.in.setErrorLocked(err)
+}
@@ -0,0 +1,121 @@
+// Copyright 2026 uTLS Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tls
+
+import (
+	"io"
+	"testing"
+)
+
+func TestFalseStart(t *testing.T) {
+	tests := []struct {
+		name       string
+		suite      uint16
+		alpn       bool
+		falseStart bool
+	}{
+		{"AEAD", TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256, true, true},
+		{"NoALPN", TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256, false, false},
+		{"CBC", TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA, true, false},
+		{"RSA", TLS_RSA_WITH_AES_128_GCM_SHA256, true, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			extensions := []TLSExtension{
+				&SupportedCurvesExtension{Curves: []CurveID{X25519}},
+				&SupportedPointsExtension{SupportedPoints: []uint8{0}}, // uncompressed
+				&SignatureAlgorithmsExtension{SupportedSignatureAlgorithms: []SignatureScheme{PSSWithSHA256, PKCS1WithSHA256}},
+				&SessionTicketExtension{},
+			}
+			if test.alpn {
+				extensions = append(extensions, &ALPNExtension{AlpnProtocols: []string{"h2"}})
+			}
+			spec := &ClientHelloSpec{
+				TLSVersMax:   VersionTLS12,
+				TLSVersMin:   VersionTLS12,
+				CipherSuites: []uint16{test.suite},
+				Extensions:   extensions,
+			}
+			serverConfig := testConfig.Clone()
+			serverConfig.NextProtos = []string{"h2"}
+			serverConfig.CipherSuites = []uint16{test.suite}
+			clientConfig := testConfig.Clone()
+			clientConfig.ServerName = "example.golang"
+			clientConfig.ClientSessionCache = NewLRUClientSessionCache(0)
+
+			c, s := localPipe(t)
+			serverErr := make(chan error, 1)
+			go func() {
+				server := Server(s, serverConfig)
+				defer server.Close()
+				buf := make([]byte, 4)
+				if _, err := io.ReadFull(server, buf); err != nil {
+					serverErr <- err
+					return
+				}
+				_, err := server.Write(buf)
+				serverErr <- err
+			}()
+
+			client := UClient(c, clientConfig, HelloCustom, false, false)
+			defer client.Close()
+			client.WithFalseStart = true
+			if err := client.ApplyPreset(spec); err != nil {
+				t.Fatal(err)
+			}
+			if err := client.Handshake(); err != nil {
+				t.Fatalf("client: %v", err)
+			}
+			if got := client.utls.falseStart != nil; got != test.falseStart {
+				t.Fatalf("False Start: got %v, want %v", got, test.falseStart)
+			}
+			if _, err := client.Write([]byte("ping")); err != nil {
+				t.Fatal(err)
+			}
+			// Read through the embedded Conn, which must finish the handshake
+			// as well.
+			buf := make([]byte, 4)
+			if _, err := io.ReadFull(client.Conn, buf); err != nil {
+				t.Fatal(err)
+			}
+			if string(buf) != "ping" {
+				t.Errorf("got %q", buf)
+			}
+			if err := <-serverErr; err != nil {
+				t.Fatalf("server: %v", err)
+			}
+			if client.utls.falseStart != nil {
+				t.Error("the handshake wasn't finished by Read")
+			}
+			if _, ok := clientConfig.ClientSessionCache.Get("example.golang"); !ok {
+				t.Error("the session wasn't saved")
+			}
+		})
+	}
+}
+
+func TestFalseStartUnexpectedRecord(t *testing.T) {
+	c, s := localPipe(t)
+	defer c.Close()
+	defer s.Close()
+	// An application data record in place of the ChangeCipherSpec.
+	go s.Write([]byte{byte(recordTypeApplicationData), 3, 3, 0, 1, 0})
+
+	client := Client(c, testConfig.Clone())
+	client.vers = VersionTLS12
+	client.in.Lock()
+	defer client.in.Unlock()
+	client.utls.falseStart = &clientHandshakeState{
+		c:           client,
+		hello:       &clientHelloMsg{},
+		serverHello: &serverHelloMsg{},
+	}
+	if err := client.finishFalseStart(); err == nil {
+		t.Fatal("finished a handshake without the Finished message of the server")
+	}
+	if client.in.err == nil || client.out.err == nil {
+		t.Error("the connection wasn't broken in both directions")
+	}
+}
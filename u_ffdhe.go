@@ -0,0 +1,513 @@
+// Copyright 2026 uTLS Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tls
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"slices"
+
+	"golang.org/x/crypto/cryptobyte"
+)
+
+// The finite field Diffie-Hellman groups of RFC 7919, Appendix A. Their
+// generator is 2.
+const (
+	ffdhe2048Prime = "FFFFFFFFFFFFFFFFADF85458A2BB4A9AAFDC5620273D3CF1D8B9C583CE2D3695" +
+		"A9E13641146433FBCC939DCE249B3EF97D2FE363630C75D8F681B202AEC4617A" +
+		"D3DF1ED5D5FD65612433F51F5F066ED0856365553DED1AF3B557135E7F57C935" +
+		"984F0C70E0E68B77E2A689DAF3EFE8721DF158A136ADE73530ACCA4F483A797A" +
+		"BC0AB182B324FB61D108A94BB2C8E3FBB96ADAB760D7F4681D4F42A3DE394DF4" +
+		"AE56EDE76372BB190B07A7C8EE0A6D709E02FCE1CDF7E2ECC03404CD28342F61" +
+		"9172FE9CE98583FF8E4F1232EEF28183C3FE3B1B4C6FAD733BB5FCBC2EC22005" +
+		"C58EF1837D1683B2C6F34A26C1B2EFFA886B423861285C97FFFFFFFFFFFFFFFF"
+	ffdhe3072Prime = "FFFFFFFFFFFFFFFFADF85458A2BB4A9AAFDC5620273D3CF1D8B9C583CE2D3695" +
+		"A9E13641146433FBCC939DCE249B3EF97D2FE363630C75D8F681B202AEC4617A" +
+		"D3DF1ED5D5FD65612433F51F5F066ED0856365553DED1AF3B557135E7F57C935" +
+		"984F0C70E0E68B77E2A689DAF3EFE8721DF158A136ADE73530ACCA4F483A797A" +
+		"BC0AB182B324FB61D108A94BB2C8E3FBB96ADAB760D7F4681D4F42A3DE394DF4" +
+		"AE56EDE76372BB190B07A7C8EE0A6D709E02FCE1CDF7E2ECC03404CD28342F61" +
+		"9172FE9CE98583FF8E4F1232EEF28183C3FE3B1B4C6FAD733BB5FCBC2EC22005" +
+		"C58EF1837D1683B2C6F34A26C1B2EFFA886B4238611FCFDCDE355B3B6519035B" +
+		"BC34F4DEF99C023861B46FC9D6E6C9077AD91D2691F7F7EE598CB0FAC186D91C" +
+		"AEFE130985139270B4130C93BC437944F4FD4452E2D74DD364F2E21E71F54BFF" +
+		"5CAE82AB9C9DF69EE86D2BC522363A0DABC521979B0DEADA1DBF9A42D5C4484E" +
+		"0ABCD06BFA53DDEF3C1B20EE3FD59D7C25E41D2B66C62E37FFFFFFFFFFFFFFFF"
+	ffdhe4096Prime = "FFFFFFFFFFFFFFFFADF85458A2BB4A9AAFDC5620273D3CF1D8B9C583CE2D3695" +
+		"A9E13641146433FBCC939DCE249B3EF97D2FE363630C75D8F681B202AEC4617A" +
+		"D3DF1ED5D5FD65612433F51F5F066ED0856365553DED1AF3B557135E7F57C935" +
+		"984F0C70E0E68B77E2A689DAF3EFE8721DF158A136ADE73530ACCA4F483A797A" +
+		"BC0AB182B324FB61D108A94BB2C8E3FBB96ADAB760D7F4681D4F42A3DE394DF4" +
+		"AE56EDE76372BB190B07A7C8EE0A6D709E02FCE1CDF7E2ECC03404CD28342F61" +
+		"9172FE9CE98583FF8E4F1232EEF28183C3FE3B1B4C6FAD733BB5FCBC2EC22005" +
+		"C58EF1837D1683B2C6F34A26C1B2EFFA886B4238611FCFDCDE355B3B6519035B" +
+		"BC34F4DEF99C023861B46FC9D6E6C9077AD91D2691F7F7EE598CB0FAC186D91C" +
+		"AEFE130985139270B4130C93BC437944F4FD4452E2D74DD364F2E21E71F54BFF" +
+		"5CAE82AB9C9DF69EE86D2BC522363A0DABC521979B0DEADA1DBF9A42D5C4484E" +
+		"0ABCD06BFA53DDEF3C1B20EE3FD59D7C25E41D2B669E1EF16E6F52C3164DF4FB" +
+		"7930E9E4E58857B6AC7D5F42D69F6D187763CF1D5503400487F55BA57E31CC7A" +
+		"7135C886EFB4318AED6A1E012D9E6832A907600A918130C46DC778F971AD0038" +
+		"092999A333CB8B7A1A1DB93D7140003C2A4ECEA9F98D0ACC0A8291CDCEC97DCF" +
+		"8EC9B55A7F88A46B4DB5A851F44182E1C68A007E5E655F6AFFFFFFFFFFFFFFFF"
+	ffdhe6144Prime = "FFFFFFFFFFFFFFFFADF85458A2BB4A9AAFDC5620273D3CF1D8B9C583CE2D3695" +
+		"A9E13641146433FBCC939DCE249B3EF97D2FE363630C75D8F681B202AEC4617A" +
+		"D3DF1ED5D5FD65612433F51F5F066ED0856365553DED1AF3B557135E7F57C935" +
+		"984F0C70E0E68B77E2A689DAF3EFE8721DF158A136ADE73530ACCA4F483A797A" +
+		"BC0AB182B324FB61D108A94BB2C8E3FBB96ADAB760D7F4681D4F42A3DE394DF4" +
+		"AE56EDE76372BB190B07A7C8EE0A6D709E02FCE1CDF7E2ECC03404CD28342F61" +
+		"9172FE9CE98583FF8E4F1232EEF28183C3FE3B1B4C6FAD733BB5FCBC2EC22005" +
+		"C58EF1837D1683B2C6F34A26C1B2EFFA886B4238611FCFDCDE355B3B6519035B" +
+		"BC34F4DEF99C023861B46FC9D6E6C9077AD91D2691F7F7EE598CB0FAC186D91C" +
+		"AEFE130985139270B4130C93BC437944F4FD4452E2D74DD364F2E21E71F54BFF" +
+		"5CAE82AB9C9DF69EE86D2BC522363A0DABC521979B0DEADA1DBF9A42D5C4484E" +
+		"0ABCD06BFA53DDEF3C1B20EE3FD59D7C25E41D2B669E1EF16E6F52C3164DF4FB" +
+		"7930E9E4E58857B6AC7D5F42D69F6D187763CF1D5503400487F55BA57E31CC7A" +
+		"7135C886EFB4318AED6A1E012D9E6832A907600A918130C46DC778F971AD0038" +
+		"092999A333CB8B7A1A1DB93D7140003C2A4ECEA9F98D0ACC0A8291CDCEC97DCF" +
+		"8EC9B55A7F88A46B4DB5A851F44182E1C68A007E5E0DD9020BFD64B645036C7A" +
+		"4E677D2C38532A3A23BA4442CAF53EA63BB454329B7624C8917BDD64B1C0FD4C" +
+		"B38E8C334C701C3ACDAD0657FCCFEC719B1F5C3E4E46041F388147FB4CFDB477" +
+		"A52471F7A9A96910B855322EDB6340D8A00EF092350511E30ABEC1FFF9E3A26E" +
+		"7FB29F8C183023C3587E38DA0077D9B4763E4E4B94B2BBC194C6651E77CAF992" +
+		"EEAAC0232A281BF6B3A739C1226116820AE8DB5847A67CBEF9C9091B462D538C" +
+		"D72B03746AE77F5E62292C311562A846505DC82DB854338AE49F5235C95B9117" +
+		"8CCF2DD5CACEF403EC9D1810C6272B045B3B71F9DC6B80D63FDD4A8E9ADB1E69" +
+		"62A69526D43161C1A41D570D7938DAD4A40E329CD0E40E65FFFFFFFFFFFFFFFF"
+	ffdhe8192Prime = "FFFFFFFFFFFFFFFFADF85458A2BB4A9AAFDC5620273D3CF1D8B9C583CE2D3695" +
+		"A9E13641146433FBCC939DCE249B3EF97D2FE363630C75D8F681B202AEC4617A" +
+		"D3DF1ED5D5FD65612433F51F5F066ED0856365553DED1AF3B557135E7F57C935" +
+		"984F0C70E0E68B77E2A689DAF3EFE8721DF158A136ADE73530ACCA4F483A797A" +
+		"BC0AB182B324FB61D108A94BB2C8E3FBB96ADAB760D7F4681D4F42A3DE394DF4" +
+		"AE56EDE76372BB190B07A7C8EE0A6D709E02FCE1CDF7E2ECC03404CD28342F61" +
+		"9172FE9CE98583FF8E4F1232EEF28183C3FE3B1B4C6FAD733BB5FCBC2EC22005" +
+		"C58EF1837D1683B2C6F34A26C1B2EFFA886B4238611FCFDCDE355B3B6519035B" +
+		"BC34F4DEF99C023861B46FC9D6E6C9077AD91D2691F7F7EE598CB0FAC186D91C" +
+		"AEFE130985139270B4130C93BC437944F4FD4452E2D74DD364F2E21E71F54BFF" +
+		"5CAE82AB9C9DF69EE86D2BC522363A0DABC521979B0DEADA1DBF9A42D5C4484E" +
+		"0ABCD06BFA53DDEF3C1B20EE3FD59D7C25E41D2B669E1EF16E6F52C3164DF4FB" +
+		"7930E9E4E58857B6AC7D5F42D69F6D187763CF1D5503400487F55BA57E31CC7A" +
+		"7135C886EFB4318AED6A1E012D9E6832A907600A918130C46DC778F971AD0038" +
+		"092999A333CB8B7A1A1DB93D7140003C2A4ECEA9F98D0ACC0A8291CDCEC97DCF" +
+		"8EC9B55A7F88A46B4DB5A851F44182E1C68A007E5E0DD9020BFD64B645036C7A" +
+		"4E677D2C38532A3A23BA4442CAF53EA63BB454329B7624C8917BDD64B1C0FD4C" +
+		"B38E8C334C701C3ACDAD0657FCCFEC719B1F5C3E4E46041F388147FB4CFDB477" +
+		"A52471F7A9A96910B855322EDB6340D8A00EF092350511E30ABEC1FFF9E3A26E" +
+		"7FB29F8C183023C3587E38DA0077D9B4763E4E4B94B2BBC194C6651E77CAF992" +
+		"EEAAC0232A281BF6B3A739C1226116820AE8DB5847A67CBEF9C9091B462D538C" +
+		"D72B03746AE77F5E62292C311562A846505DC82DB854338AE49F5235C95B9117" +
+		"8CCF2DD5CACEF403EC9D1810C6272B045B3B71F9DC6B80D63FDD4A8E9ADB1E69" +
+		"62A69526D43161C1A41D570D7938DAD4A40E329CCFF46AAA36AD004CF600C838" +
+		"1E425A31D951AE64FDB23FCEC9509D43687FEB69EDD1CC5E0B8CC3BDF64B10EF" +
+		"86B63142A3AB8829555B2F747C932665CB2C0F1CC01BD70229388839D2AF05E4" +
+		"54504AC78B7582822846C0BA35C35F5C59160CC046FD8251541FC68C9C86B022" +
+		"BB7099876A460E7451A8A93109703FEE1C217E6C3826E52C51AA691E0E423CFC" +
+		"99E9E31650C1217B624816CDAD9A95F9D5B8019488D9C0A0A1FE3075A577E231" +
+		"83F81D4A3F2FA4571EFC8CE0BA8A4FE8B6855DFE72B0A66EDED2FBABFBE58A30" +
+		"FAFABE1C5D71A87E2F741EF8C1FE86FEA6BBFDE530677F0D97D11D49F7A8443D" +
+		"0822E506A9F4614E011E2A94838FF88CD68C8BB7C5C6424CFFFFFFFFFFFFFFFF"
+)
+
+// minDHEPrimeBits is the smallest prime accepted from a TLS 1.2 server which
+// uses custom DHE parameters instead of an RFC 7919 group.
+const minDHEPrimeBits = 2048
+
+type ffdheGroup struct {
+	p *big.Int
+	// exponentBits is the size of the private exponents, which RFC 7919,
+	// Section 5.2, allows to be much shorter than p.
+	exponentBits int
+}
+
+func newFFDHEGroup(prime string, exponentBits int) *ffdheGroup {
+	p, ok := new(big.Int).SetString(prime, 16)
+	if !ok {
+		panic("tls: invalid FFDHE prime")
+	}
+	return &ffdheGroup{p: p, exponentBits: exponentBits}
+}
+
+var ffdheGroups = map[CurveID]*ffdheGroup{
+	FFDHE2048: newFFDHEGroup(ffdhe2048Prime, 225),
+	FFDHE3072: newFFDHEGroup(ffdhe3072Prime, 275),
+	FFDHE4096: newFFDHEGroup(ffdhe4096Prime, 325),
+	FFDHE6144: newFFDHEGroup(ffdhe6144Prime, 375),
+	FFDHE8192: newFFDHEGroup(ffdhe8192Prime, 400),
+}
+
+// ffdheGroupIDs lists the groups of ffdheGroups in preference order.
+var ffdheGroupIDs = []CurveID{FFDHE2048, FFDHE3072, FFDHE4096, FFDHE6144, FFDHE8192}
+
+var ffdheGenerator = big.NewInt(2)
+
+func isFFDHEGroup(group CurveID) bool {
+	_, ok := ffdheGroups[group]
+	return ok
+}
+
+// isFFDHECodepoint reports whether group is in the range RFC 7919, Section
+// 4, reserves for FFDHE groups, whether known or not.
+func isFFDHECodepoint(group CurveID) bool {
+	return group >= 256 && group <= 511
+}
+
+// ffdheGroupByPrime returns the group of ffdheGroups with prime p, or zero.
+func ffdheGroupByPrime(p *big.Int) CurveID {
+	for _, id := range ffdheGroupIDs {
+		if ffdheGroups[id].p.Cmp(p) == 0 {
+			return id
+		}
+	}
+	return 0
+}
+
+// dhGenerateKey returns a private exponent of exponentBits bits at most, and
+// the matching public value left-padded to the size of p.
+func dhGenerateKey(random io.Reader, p, g *big.Int, exponentBits int) (x *big.Int, public []byte, err error) {
+	max := new(big.Int).Lsh(big.NewInt(1), uint(exponentBits))
+	max.Sub(max, big.NewInt(2))
+	x, err = rand.Int(random, max)
+	if err != nil {
+		return nil, nil, err
+	}
+	x.Add(x, big.NewInt(2))
+	y := new(big.Int).Exp(g, x, p)
+	return x, y.FillBytes(make([]byte, (p.BitLen()+7)/8)), nil
+}
+
+// dhSharedSecret returns the shared secret with the public value of the
+// peer, which is checked to be in [2, p-2], see RFC 7919, Section 5.1.
+func dhSharedSecret(x, p *big.Int, peerPublic []byte) (*big.Int, error) {
+	y := new(big.Int).SetBytes(peerPublic)
+	if y.Cmp(big.NewInt(1)) <= 0 || y.Cmp(new(big.Int).Sub(p, big.NewInt(1))) >= 0 {
+		return nil, errors.New("tls: invalid DHE public value")
+	}
+	return new(big.Int).Exp(y, x, p), nil
+}
+
+// ffdheKeyShare holds the client secret of a TLS 1.3 key share for a group
+// in ffdheGroups.
+type ffdheKeyShare struct {
+	group CurveID
+	x     *big.Int
+}
+
+// generateFFDHEKeyShare returns a new client secret for group, and the
+// matching key share, see RFC 8446, Section 4.2.8.1.
+func generateFFDHEKeyShare(random io.Reader, group CurveID) (*ffdheKeyShare, []byte, error) {
+	g, ok := ffdheGroups[group]
+	if !ok {
+		return nil, nil, errors.New("tls: internal error: unsupported FFDHE group")
+	}
+	x, public, err := dhGenerateKey(random, g.p, ffdheGenerator, g.exponentBits)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &ffdheKeyShare{group: group, x: x}, public, nil
+}
+
+func (k *ffdheKeyShare) curveID() CurveID {
+	return k.group
+}
+
+// sharedKey returns the shared secret, left-padded to the size of the prime
+// as required by RFC 8446, Section 7.4.1.
+func (k *ffdheKeyShare) sharedKey(serverShare []byte) ([]byte, error) {
+	p := ffdheGroups[k.group].p
+	size := (p.BitLen() + 7) / 8
+	if len(serverShare) != size {
+		return nil, errors.New("tls: invalid server FFDHE key share")
+	}
+	z, err := dhSharedSecret(k.x, p, serverShare)
+	if err != nil {
+		return nil, errors.New("tls: invalid server FFDHE key share")
+	}
+	return z.FillBytes(make([]byte, size)), nil
+}
+
+// ffdheServerShare returns the server key share and the shared secret for
+// the client key share of a group in ffdheGroups.
+func ffdheServerShare(random io.Reader, group CurveID, clientShare []byte) (serverShare, sharedKey []byte, err error) {
+	key, serverShare, err := generateFFDHEKeyShare(random, group)
+	if err != nil {
+		return nil, nil, err
+	}
+	sharedKey, err = key.sharedKey(clientShare)
+	if err != nil {
+		return nil, nil, errors.New("tls: invalid FFDHE client key share")
+	}
+	return serverShare, sharedKey, nil
+}
+
+// ffdheGroupsForServer returns the FFDHE groups a TLS 1.2 server accepts for
+// DHE cipher suites: those of Config.CurvePreferences if set, or all of them.
+func ffdheGroupsForServer(config *Config) []CurveID {
+	if len(config.CurvePreferences) == 0 {
+		return ffdheGroupIDs
+	}
+	return slices.DeleteFunc(slices.Clone(config.CurvePreferences), func(group CurveID) bool {
+		return !isFFDHEGroup(group)
+	})
+}
+
+// selectDHEGroup picks the group for a TLS 1.2 DHE key exchange. Following
+// RFC 7919, Section 4, a client which advertises FFDHE groups must get one of
+// them, and a client which doesn't gets ffdhe2048.
+func selectDHEGroup(config *Config, supportedCurves []CurveID) (CurveID, bool) {
+	if !slices.ContainsFunc(supportedCurves, isFFDHECodepoint) {
+		return FFDHE2048, true
+	}
+	serverGroups := ffdheGroupsForServer(config)
+	for _, group := range supportedCurves {
+		if slices.Contains(serverGroups, group) {
+			return group, true
+		}
+	}
+	return 0, false
+}
+
+// dheCipherSuites are the DHE_RSA cipher suites, which are part of
+// utlsSupportedCipherSuites.
+var dheCipherSuites = []*cipherSuite{
+	{TLS_DHE_RSA_WITH_AES_128_GCM_SHA256, 16, 0, 4, dheRSAKA, suiteDHE | suiteTLS12, nil, nil, aeadAESGCM},
+	{TLS_DHE_RSA_WITH_AES_256_GCM_SHA384, 32, 0, 4, dheRSAKA, suiteDHE | suiteTLS12 | suiteSHA384, nil, nil, aeadAESGCM},
+	{TLS_DHE_RSA_WITH_CHACHA20_POLY1305_SHA256, 32, 0, 12, dheRSAKA, suiteDHE | suiteTLS12, nil, nil, aeadChaCha20Poly1305},
+	{TLS_DHE_RSA_WITH_AES_128_CBC_SHA256, 16, 32, 16, dheRSAKA, suiteDHE | suiteTLS12, cipherAES, macSHA256, nil},
+	{TLS_DHE_RSA_WITH_AES_256_CBC_SHA256, 32, 32, 16, dheRSAKA, suiteDHE | suiteTLS12, cipherAES, macSHA256, nil},
+	{TLS_DHE_RSA_WITH_AES_128_CBC_SHA, 16, 20, 16, dheRSAKA, suiteDHE, cipherAES, macSHA1, nil},
+	{TLS_DHE_RSA_WITH_AES_256_CBC_SHA, 32, 20, 16, dheRSAKA, suiteDHE, cipherAES, macSHA1, nil},
+}
+
+func dheRSAKA(version uint16) keyAgreement {
+	return &dheKeyAgreement{version: version}
+}
+
+// dheKeyAgreement implements a TLS 1.0–1.2 key agreement where the server
+// sends finite field Diffie-Hellman parameters signed with its RSA key, see
+// RFC 5246, Section 7.4.3, and RFC 7919.
+type dheKeyAgreement struct {
+	version uint16
+	// group is the RFC 7919 group of the parameters, or zero if they are
+	// custom ones.
+	group CurveID
+
+	// p and x are generated in generateServerKeyExchange.
+	p, x *big.Int
+
+	// ckx and preMasterSecret are generated in processServerKeyExchange
+	// and returned in generateClientKeyExchange.
+	ckx             *clientKeyExchangeMsg
+	preMasterSecret []byte
+}
+
+func (ka *dheKeyAgreement) generateServerKeyExchange(config *Config, cert *Certificate, clientHello *clientHelloMsg, hello *serverHelloMsg) (*serverKeyExchangeMsg, error) {
+	group, ok := selectDHEGroup(config, clientHello.supportedCurves)
+	if !ok {
+		return nil, errors.New("tls: no supported FFDHE groups offered")
+	}
+	g := ffdheGroups[group]
+	x, public, err := dhGenerateKey(config.rand(), g.p, ffdheGenerator, g.exponentBits)
+	if err != nil {
+		return nil, err
+	}
+	ka.group, ka.p, ka.x = group, g.p, x
+
+	var b cryptobyte.Builder
+	b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+		b.AddBytes(g.p.Bytes())
+	})
+	b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+		b.AddBytes(ffdheGenerator.Bytes())
+	})
+	b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+		b.AddBytes(public)
+	})
+	serverDHParams, err := b.Bytes()
+	if err != nil {
+		return nil, err
+	}
+	return signServerKeyExchange(config, cert, ka.version, true, clientHello, hello, serverDHParams)
+}
+
+func (ka *dheKeyAgreement) processClientKeyExchange(config *Config, cert *Certificate, ckx *clientKeyExchangeMsg, version uint16) ([]byte, error) {
+	input := cryptobyte.String(ckx.ciphertext)
+	var public cryptobyte.String
+	if !input.ReadUint16LengthPrefixed(&public) || !input.Empty() {
+		return nil, errClientKeyExchange
+	}
+	z, err := dhSharedSecret(ka.x, ka.p, public)
+	if err != nil {
+		return nil, errClientKeyExchange
+	}
+	// RFC 5246, Section 8.1.2, strips the leading zeros of Z.
+	return z.Bytes(), nil
+}
+
+func (ka *dheKeyAgreement) processServerKeyExchange(config *Config, clientHello *clientHelloMsg, serverHello *serverHelloMsg, cert *x509.Certificate, skx *serverKeyExchangeMsg) error {
+	input := cryptobyte.String(skx.key)
+	var pBytes, gBytes, serverPublic cryptobyte.String
+	if !input.ReadUint16LengthPrefixed(&pBytes) ||
+		!input.ReadUint16LengthPrefixed(&gBytes) ||
+		!input.ReadUint16LengthPrefixed(&serverPublic) {
+		return errServerKeyExchange
+	}
+	serverDHParams := skx.key[:len(skx.key)-len(input)]
+	p := new(big.Int).SetBytes(pBytes)
+	g := new(big.Int).SetBytes(gBytes)
+
+	ka.group = ffdheGroupByPrime(p)
+	exponentBits := p.BitLen() - 1
+	if ka.group != 0 {
+		// A client which advertised FFDHE groups expects one of them, see
+		// RFC 7919, Section 4.
+		offered := slices.DeleteFunc(slices.Clone(clientHello.supportedCurves), func(group CurveID) bool {
+			return !isFFDHEGroup(group)
+		})
+		if len(offered) > 0 && !slices.Contains(offered, ka.group) {
+			return errors.New("tls: server selected an unadvertised FFDHE group")
+		}
+		if g.Cmp(ffdheGenerator) != 0 {
+			return errServerKeyExchange
+		}
+		exponentBits = ffdheGroups[ka.group].exponentBits
+	} else {
+		if p.BitLen() < minDHEPrimeBits {
+			return fmt.Errorf("tls: server DHE prime of %d bits is too small", p.BitLen())
+		}
+		if p.Bit(0) == 0 || g.Cmp(big.NewInt(1)) <= 0 || g.Cmp(new(big.Int).Sub(p, big.NewInt(1))) >= 0 {
+			return errServerKeyExchange
+		}
+	}
+
+	x, public, err := dhGenerateKey(config.rand(), p, g, exponentBits)
+	if err != nil {
+		return err
+	}
+	z, err := dhSharedSecret(x, p, serverPublic)
+	if err != nil {
+		return errServerKeyExchange
+	}
+	ka.preMasterSecret = z.Bytes()
+
+	var b cryptobyte.Builder
+	b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+		b.AddBytes(public)
+	})
+	ka.ckx = &clientKeyExchangeMsg{ciphertext: b.BytesOrPanic()}
+
+	return verifyServerKeyExchange(ka.version, true, clientHello, serverHello, cert, serverDHParams, input)
+}
+
+func (ka *dheKeyAgreement) generateClientKeyExchange(config *Config, clientHello *clientHelloMsg, cert *x509.Certificate) ([]byte, *clientKeyExchangeMsg, error) {
+	if ka.ckx == nil {
+		return nil, nil, errors.New("tls: missing ServerKeyExchange message")
+	}
+
+	return ka.preMasterSecret, ka.ckx, nil
+}
+
+// signServerKeyExchange returns a ServerKeyExchange carrying params and their
+// signature, like the one of ecdheKeyAgreement.generateServerKeyExchange.
+func signServerKeyExchange(config *Config, cert *Certificate, version uint16, isRSA bool, clientHello *clientHelloMsg, hello *serverHelloMsg, params []byte) (*serverKeyExchangeMsg, error) {
+	priv, ok := cert.PrivateKey.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("tls: certificate private key of type %T does not implement crypto.Signer", cert.PrivateKey)
+	}
+
+	var signatureAlgorithm SignatureScheme
+	var sigType uint8
+	var sigHash crypto.Hash
+	var err error
+	if version >= VersionTLS12 {
+		signatureAlgorithm, err = selectSignatureScheme(version, cert, clientHello.supportedSignatureAlgorithms)
+		if err != nil {
+			return nil, err
+		}
+		sigType, sigHash, err = typeAndHashFromSignatureScheme(signatureAlgorithm)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		sigType, sigHash, err = legacyTypeAndHashFromPublicKey(priv.Public())
+		if err != nil {
+			return nil, err
+		}
+	}
+	if (sigType == signaturePKCS1v15 || sigType == signatureRSAPSS) != isRSA {
+		return nil, errors.New("tls: certificate cannot be used with the selected cipher suite")
+	}
+
+	signed := hashForServerKeyExchange(sigType, sigHash, version, clientHello.random, hello.random, params)
+
+	signOpts := crypto.SignerOpts(sigHash)
+	if sigType == signatureRSAPSS {
+		signOpts = &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash, Hash: sigHash}
+	}
+	sig, err := priv.Sign(config.rand(), signed, signOpts)
+	if err != nil {
+		return nil, errors.New("tls: failed to sign key exchange parameters: " + err.Error())
+	}
+
+	var b cryptobyte.Builder
+	b.AddBytes(params)
+	if version >= VersionTLS12 {
+		b.AddUint16(uint16(signatureAlgorithm))
+	}
+	b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+		b.AddBytes(sig)
+	})
+	key, err := b.Bytes()
+	if err != nil {
+		return nil, err
+	}
+	return &serverKeyExchangeMsg{key: key}, nil
+}
+
+// verifyServerKeyExchange checks the signature sig of the ServerKeyExchange
+// params, like ecdheKeyAgreement.processServerKeyExchange.
+func verifyServerKeyExchange(version uint16, isRSA bool, clientHello *clientHelloMsg, serverHello *serverHelloMsg, cert *x509.Certificate, params, sig []byte) error {
+	input := cryptobyte.String(sig)
+	var sigType uint8
+	var sigHash crypto.Hash
+	var err error
+	if version >= VersionTLS12 {
+		var signatureAlgorithm uint16
+		if !input.ReadUint16(&signatureAlgorithm) {
+			return errServerKeyExchange
+		}
+		if !isSupportedSignatureAlgorithm(SignatureScheme(signatureAlgorithm), clientHello.supportedSignatureAlgorithms) ||
+			isTLS13OnlySignatureScheme(SignatureScheme(signatureAlgorithm)) {
+			return errors.New("tls: certificate used with invalid signature algorithm")
+		}
+		sigType, sigHash, err = typeAndHashFromSignatureScheme(SignatureScheme(signatureAlgorithm))
+		if err != nil {
+			return err
+		}
+	} else {
+		sigType, sigHash, err = legacyTypeAndHashFromPublicKey(cert.PublicKey)
+		if err != nil {
+			return err
+		}
+	}
+	if (sigType == signaturePKCS1v15 || sigType == signatureRSAPSS) != isRSA {
+		return errServerKeyExchange
+	}
+
+	var signature cryptobyte.String
+	if !input.ReadUint16LengthPrefixed(&signature) || !input.Empty() {
+		return errServerKeyExchange
+	}
+
+	signed := hashForServerKeyExchange(sigType, sigHash, version, clientHello.random, serverHello.random, params)
+	if err := verifyHandshakeSignature(sigType, cert.PublicKey, sigHash, signed, signature); err != nil {
+		return errors.New("tls: invalid signature by the server certificate: " + err.Error())
+	}
+	return nil
+}
@@ -0,0 +1,179 @@
+// Copyright 2026 uTLS Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tls
+
+import (
+	"fmt"
+	"math/big"
+	"testing"
+)
+
+func TestFFDHEGroupPrimes(t *testing.T) {
+	sizes := map[CurveID]int{FFDHE2048: 2048, FFDHE3072: 3072, FFDHE4096: 4096, FFDHE6144: 6144, FFDHE8192: 8192}
+	for _, id := range ffdheGroupIDs {
+		p := ffdheGroups[id].p
+		if p.BitLen() != sizes[id] {
+			t.Errorf("%v: prime has %d bits, want %d", id, p.BitLen(), sizes[id])
+		}
+		// The primes are safe primes whose 64 top and bottom bits are set.
+		q := new(big.Int).Rsh(p, 1)
+		if p.Bits()[0] != ^big.Word(0) || !p.ProbablyPrime(1) || !q.ProbablyPrime(1) {
+			t.Errorf("%v: invalid prime", id)
+		}
+	}
+}
+
+func TestFFDHEGroups(t *testing.T) {
+	for _, group := range ffdheGroupIDs {
+		t.Run(fmt.Sprintf("%#04x", uint16(group)), func(t *testing.T) {
+			clientConfig := testConfig.Clone()
+			clientConfig.CurvePreferences = []CurveID{group}
+			serverConfig := testConfig.Clone()
+			serverConfig.CurvePreferences = []CurveID{group}
+
+			serverState, clientState, err := testHandshake(t, clientConfig, serverConfig)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if clientState.testingOnlyCurveID != group || serverState.testingOnlyCurveID != group {
+				t.Errorf("negotiated %v, want %v", clientState.testingOnlyCurveID, group)
+			}
+			if serverState.testingOnlyDidHRR {
+				t.Errorf("unexpected HelloRetryRequest")
+			}
+
+			// The FFDHE groups come last in the preferences, so the client
+			// sends an X25519 key share and gets a HelloRetryRequest.
+			clientConfig.CurvePreferences = []CurveID{group, X25519}
+			serverState, clientState, err = testHandshake(t, clientConfig, serverConfig)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if clientState.testingOnlyCurveID != group || !serverState.testingOnlyDidHRR {
+				t.Errorf("negotiated %v (HRR: %v), want %v after a HelloRetryRequest", clientState.testingOnlyCurveID, serverState.testingOnlyDidHRR, group)
+			}
+		})
+	}
+}
+
+func TestFFDHEGroupsUTLS(t *testing.T) {
+	serverConfig := testConfig.Clone()
+	serverConfig.CurvePreferences = []CurveID{FFDHE3072}
+
+	spec, err := utlsIdToSpec(HelloChrome_Auto)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, ext := range spec.Extensions {
+		switch ext := ext.(type) {
+		case *SupportedCurvesExtension:
+			ext.Curves = []CurveID{X25519, CurveP256, FFDHE2048, FFDHE3072}
+		case *KeyShareExtension:
+			ext.KeyShares = []KeyShare{{Group: X25519}}
+		}
+	}
+	serverState, clientState, err := testUtlsHandshake(t, testConfig, serverConfig, &spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if clientState.testingOnlyCurveID != FFDHE3072 || !serverState.testingOnlyDidHRR {
+		t.Errorf("negotiated %v (HRR: %v), want %v after a HelloRetryRequest", clientState.testingOnlyCurveID, serverState.testingOnlyDidHRR, FFDHE3072)
+	}
+}
+
+func TestFFDHETLS12(t *testing.T) {
+	config := testConfig.Clone()
+	config.CurvePreferences = []CurveID{FFDHE2048, CurveP256}
+	if prefs := config.curvePreferences(VersionTLS12); len(prefs) != 1 || prefs[0] != CurveP256 {
+		t.Errorf("TLS 1.2 curve preferences = %v", prefs)
+	}
+
+	for _, suite := range dheCipherSuites {
+		t.Run(CipherSuiteName(suite.id), func(t *testing.T) {
+			clientConfig := testConfig.Clone()
+			clientConfig.MaxVersion = VersionTLS12
+			clientConfig.CipherSuites = []uint16{suite.id}
+			serverConfig := testConfig.Clone()
+			serverConfig.CipherSuites = []uint16{TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256, suite.id}
+
+			serverState, clientState, err := testHandshake(t, clientConfig, serverConfig)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if clientState.CipherSuite != suite.id || serverState.CipherSuite != suite.id {
+				t.Errorf("negotiated %#04x, want %#04x", clientState.CipherSuite, suite.id)
+			}
+			// The Go client doesn't advertise FFDHE groups for TLS 1.2, so the
+			// server falls back to ffdhe2048.
+			if clientState.testingOnlyCurveID != FFDHE2048 {
+				t.Errorf("negotiated %v, want %v", clientState.testingOnlyCurveID, FFDHE2048)
+			}
+		})
+	}
+}
+
+func TestFFDHETLS12UTLS(t *testing.T) {
+	spec := ClientHelloSpec{
+		TLSVersMax:   VersionTLS12,
+		TLSVersMin:   VersionTLS10,
+		CipherSuites: []uint16{TLS_DHE_RSA_WITH_AES_128_GCM_SHA256},
+		Extensions: []TLSExtension{
+			&SNIExtension{},
+			&SupportedCurvesExtension{Curves: []CurveID{FFDHE2048, FFDHE4096}},
+			&SignatureAlgorithmsExtension{SupportedSignatureAlgorithms: []SignatureScheme{PSSWithSHA256, PKCS1WithSHA256}},
+		},
+	}
+
+	clientConfig := testConfig.Clone()
+	serverConfig := testConfig.Clone()
+	serverConfig.CipherSuites = []uint16{TLS_DHE_RSA_WITH_AES_128_GCM_SHA256}
+	serverConfig.CurvePreferences = []CurveID{FFDHE4096}
+	_, clientState, err := testUtlsHandshake(t, clientConfig, serverConfig, &spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if clientState.testingOnlyCurveID != FFDHE4096 {
+		t.Errorf("negotiated %v, want %v", clientState.testingOnlyCurveID, FFDHE4096)
+	}
+
+	// A client which advertises FFDHE groups gets one of them, or a
+	// handshake failure.
+	serverConfig.CurvePreferences = []CurveID{FFDHE3072}
+	if _, _, err := testUtlsHandshake(t, clientConfig, serverConfig, &spec); err == nil {
+		t.Error("handshake without a mutual FFDHE group succeeded")
+	}
+}
+
+func TestDHEServerParameters(t *testing.T) {
+	// Server parameters with a group the client didn't advertise, or with a
+	// custom prime which is too small.
+	for _, test := range []struct {
+		name            string
+		p               *big.Int
+		supportedCurves []CurveID
+	}{
+		{"unadvertised", ffdheGroups[FFDHE3072].p, []CurveID{FFDHE2048}},
+		{"small prime", new(big.Int).Rsh(ffdheGroups[FFDHE2048].p, 1024), nil},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			p := test.p
+			_, public, err := dhGenerateKey(testConfig.rand(), p, ffdheGenerator, 225)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var key []byte
+			for _, v := range [][]byte{p.Bytes(), ffdheGenerator.Bytes(), public} {
+				key = append(key, byte(len(v)>>8), byte(len(v)))
+				key = append(key, v...)
+			}
+			ka := &dheKeyAgreement{version: VersionTLS12}
+			clientHello := &clientHelloMsg{supportedCurves: test.supportedCurves}
+			err = ka.processServerKeyExchange(testConfig, clientHello, &serverHelloMsg{}, nil, &serverKeyExchangeMsg{key: key})
+			if err == nil || err == errServerKeyExchange {
+				t.Errorf("processServerKeyExchange = %v, want a parameter error", err)
+			}
+		})
+	}
+}
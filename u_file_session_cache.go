@@ -0,0 +1,304 @@
+// Copyright 2026 uTLS Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tls
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/bogdanfinn/utls/internal/hkdf"
+)
+
+const (
+	// fileSessionCacheFormatV1 is the version byte leading every cache file.
+	fileSessionCacheFormatV1 = 1
+
+	// fileSessionCacheSuffix is the file name suffix of cache entries.
+	fileSessionCacheSuffix = ".session"
+
+	// defaultFileSessionCacheMaxAge is the lifetime given to sessions which do
+	// not carry one themselves (TLS 1.0–1.2 tickets).
+	defaultFileSessionCacheMaxAge = 24 * time.Hour
+)
+
+// FileClientSessionCache is a ClientSessionCache which stores every session
+// in its own file in a directory, so that sessions survive process restarts
+// and can be shared by processes using the same directory and key.
+//
+// Sessions are serialized with ClientSessionState.Bytes and encrypted at rest
+// with AES-256-GCM. File names are derived from the session key with a keyed
+// hash, so they do not reveal the server names. Expired sessions are removed
+// when they are looked up, or in bulk by Prune.
+//
+// Writes are atomic (write to a temporary file and rename), so concurrent
+// readers in other processes never observe a partially written entry.
+type FileClientSessionCache struct {
+	// MaxAge is the maximum lifetime of sessions which do not carry an
+	// explicit lifetime, counted from the moment the ticket was received.
+	// TLS 1.3 sessions expire at the end of the lifetime announced by the
+	// server. If zero, 24 hours is used.
+	MaxAge time.Duration
+
+	// Time returns the current time. If nil, time.Now is used.
+	Time func() time.Time
+
+	// OnError, if not nil, is called with the errors of Put, such as a full
+	// disk, which the ClientSessionCache interface can't return.
+	OnError func(err error)
+
+	dir     string
+	aead    cipher.AEAD
+	nameKey []byte
+}
+
+// NewFileClientSessionCache returns a FileClientSessionCache storing sessions
+// in dir, which is created if needed. key must be 32 bytes long and kept
+// secret; all processes sharing dir must use the same key.
+//
+// The exported fields of the returned cache must not be modified once it is
+// in use.
+func NewFileClientSessionCache(dir string, key []byte) (*FileClientSessionCache, error) {
+	if len(key) != 32 {
+		return nil, errors.New("tls: FileClientSessionCache key must be 32 bytes long")
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+
+	prk := hkdf.Extract(sha256.New, key, nil)
+	block, err := aes.NewCipher(hkdf.Expand(sha256.New, prk, "utls file session cache encryption", 32))
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	return &FileClientSessionCache{
+		dir:     dir,
+		aead:    aead,
+		nameKey: hkdf.Expand(sha256.New, prk, "utls file session cache name", 32),
+	}, nil
+}
+
+func (c *FileClientSessionCache) now() time.Time {
+	if c.Time == nil {
+		return time.Now()
+	}
+	return c.Time()
+}
+
+func (c *FileClientSessionCache) maxAge() time.Duration {
+	if c.MaxAge == 0 {
+		return defaultFileSessionCacheMaxAge
+	}
+	return c.MaxAge
+}
+
+// fileName returns the name of the file storing the session for sessionKey.
+func (c *FileClientSessionCache) fileName(sessionKey string) string {
+	mac := hmac.New(sha256.New, c.nameKey)
+	mac.Write([]byte(sessionKey))
+	return hex.EncodeToString(mac.Sum(nil)) + fileSessionCacheSuffix
+}
+
+// Get returns the session stored for sessionKey, if any. Expired entries are
+// removed. Entries which cannot be decrypted, for example because they were
+// written with another key, are ignored.
+func (c *FileClientSessionCache) Get(sessionKey string) (*ClientSessionState, bool) {
+	name := c.fileName(sessionKey)
+	data, err := os.ReadFile(filepath.Join(c.dir, name))
+	if err != nil {
+		return nil, false
+	}
+	expiresAt, ok := parseFileSessionHeader(data)
+	if !ok {
+		return nil, false
+	}
+	if !c.now().Before(expiresAt) {
+		os.Remove(filepath.Join(c.dir, name))
+		return nil, false
+	}
+	state, err := c.open(name, data)
+	if err != nil {
+		return nil, false
+	}
+	cs, err := ParseClientSessionState(state)
+	if err != nil {
+		return nil, false
+	}
+	return cs, true
+}
+
+// Put stores cs for sessionKey, replacing any previous entry. If cs is nil, or
+// already expired, the entry for sessionKey is removed instead. A cs without
+// a session is ignored. Errors are reported to OnError.
+func (c *FileClientSessionCache) Put(sessionKey string, cs *ClientSessionState) {
+	name := c.fileName(sessionKey)
+	if cs == nil {
+		c.remove(name)
+		return
+	}
+	if cs.session == nil {
+		return
+	}
+
+	expiresAt := cs.expiry()
+	if expiresAt.IsZero() {
+		expiresAt = time.Unix(int64(cs.session.createdAt), 0).Add(c.maxAge())
+	}
+	if !c.now().Before(expiresAt) {
+		c.remove(name)
+		return
+	}
+
+	state, err := cs.Bytes()
+	if err != nil {
+		c.reportError(err)
+		return
+	}
+	data, err := c.seal(name, expiresAt, state)
+	if err != nil {
+		c.reportError(err)
+		return
+	}
+	if err := c.writeFile(name, data); err != nil {
+		c.reportError(err)
+	}
+}
+
+// remove removes the file name from c.dir, if it exists.
+func (c *FileClientSessionCache) remove(name string) {
+	if err := os.Remove(filepath.Join(c.dir, name)); err != nil && !os.IsNotExist(err) {
+		c.reportError(err)
+	}
+}
+
+func (c *FileClientSessionCache) reportError(err error) {
+	if c.OnError != nil {
+		c.OnError(err)
+	}
+}
+
+// Prune removes all expired entries, as well as entries which can't be
+// decrypted or parsed, for example because they were written with another key.
+func (c *FileClientSessionCache) Prune() error {
+	entries, err := os.ReadDir(c.dir)
+	if err != nil {
+		return err
+	}
+	now := c.now()
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), fileSessionCacheSuffix) {
+			continue
+		}
+		path := filepath.Join(c.dir, entry.Name())
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		if c.validEntry(entry.Name(), data, now) {
+			continue
+		}
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// validEntry reports whether data, the content of the file name, is an
+// unexpired session which Get can return.
+func (c *FileClientSessionCache) validEntry(name string, data []byte, now time.Time) bool {
+	expiresAt, ok := parseFileSessionHeader(data)
+	if !ok || !now.Before(expiresAt) {
+		return false
+	}
+	state, err := c.open(name, data)
+	if err != nil {
+		return false
+	}
+	_, err = ParseClientSessionState(state)
+	return err == nil
+}
+
+// A cache file is laid out as
+//
+//	uint8  format = 1;
+//	uint64 expires_at; /* seconds since UNIX epoch */
+//	opaque nonce[12];
+//	opaque sealed_state[];
+//
+// where sealed_state is the output of ClientSessionState.Bytes encrypted with
+// AES-256-GCM. The header and the file name are authenticated as additional
+// data, so entries can't be moved to another key or have their expiry changed.
+const fileSessionHeaderLen = 1 + 8
+
+func parseFileSessionHeader(data []byte) (expiresAt time.Time, ok bool) {
+	if len(data) < fileSessionHeaderLen || data[0] != fileSessionCacheFormatV1 {
+		return time.Time{}, false
+	}
+	return time.Unix(int64(binary.BigEndian.Uint64(data[1:fileSessionHeaderLen])), 0), true
+}
+
+func (c *FileClientSessionCache) seal(name string, expiresAt time.Time, state []byte) ([]byte, error) {
+	nonceSize := c.aead.NonceSize()
+	data := make([]byte, fileSessionHeaderLen+nonceSize, fileSessionHeaderLen+nonceSize+len(state)+c.aead.Overhead())
+	data[0] = fileSessionCacheFormatV1
+	binary.BigEndian.PutUint64(data[1:fileSessionHeaderLen], uint64(expiresAt.Unix()))
+	nonce := data[fileSessionHeaderLen:]
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	return c.aead.Seal(data, nonce, state, fileSessionAdditionalData(name, data[:fileSessionHeaderLen])), nil
+}
+
+func (c *FileClientSessionCache) open(name string, data []byte) ([]byte, error) {
+	nonceSize := c.aead.NonceSize()
+	if len(data) < fileSessionHeaderLen+nonceSize {
+		return nil, errors.New("tls: truncated session cache entry")
+	}
+	nonce := data[fileSessionHeaderLen : fileSessionHeaderLen+nonceSize]
+	return c.aead.Open(nil, nonce, data[fileSessionHeaderLen+nonceSize:], fileSessionAdditionalData(name, data[:fileSessionHeaderLen]))
+}
+
+func fileSessionAdditionalData(name string, header []byte) []byte {
+	return append([]byte(name), header...)
+}
+
+// writeFile atomically replaces the file name in c.dir with data.
+func (c *FileClientSessionCache) writeFile(name string, data []byte) error {
+	f, err := os.CreateTemp(c.dir, ".tmp-*")
+	if err != nil {
+		return err
+	}
+	tmp := f.Name()
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, filepath.Join(c.dir, name)); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,249 @@
+// Copyright 2026 uTLS Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tls
+
+import (
+	"bytes"
+	"crypto/x509"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// capturingSessionCache is a ClientSessionCache that remembers the last
+// session it was given.
+type capturingSessionCache struct {
+	sync.Mutex
+	last *ClientSessionState
+}
+
+func (c *capturingSessionCache) Get(string) (*ClientSessionState, bool) { return nil, false }
+
+func (c *capturingSessionCache) Put(_ string, cs *ClientSessionState) {
+	c.Lock()
+	defer c.Unlock()
+	if cs != nil {
+		c.last = cs
+	}
+}
+
+func testFileSessionCacheConfigs(version uint16) (clientConfig, serverConfig *Config) {
+	serverConfig = testConfig.Clone()
+	serverConfig.MaxVersion = version
+	clientConfig = testConfig.Clone()
+	clientConfig.MaxVersion = version
+	clientConfig.ServerName = "example.golang"
+	return
+}
+
+func TestClientSessionStateBytes(t *testing.T) {
+	for _, version := range []uint16{VersionTLS12, VersionTLS13} {
+		t.Run(VersionName(version), func(t *testing.T) {
+			clientConfig, serverConfig := testFileSessionCacheConfigs(version)
+			cache := &capturingSessionCache{}
+			clientConfig.ClientSessionCache = cache
+			if _, _, err := testHandshake(t, clientConfig, serverConfig); err != nil {
+				t.Fatal(err)
+			}
+			cache.Lock()
+			cs := cache.last
+			cache.Unlock()
+			if cs == nil {
+				t.Fatal("no session was stored")
+			}
+			cs.session.Extra = [][]byte{[]byte("extra")}
+
+			b, err := cs.Bytes()
+			if err != nil {
+				t.Fatal(err)
+			}
+			parsed, err := ParseClientSessionState(b)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want, got := cs.session, parsed.session
+			if got.version != want.version || got.cipherSuite != want.cipherSuite ||
+				got.createdAt != want.createdAt || got.useBy != want.useBy ||
+				got.ageAdd != want.ageAdd || got.extMasterSecret != want.extMasterSecret ||
+				got.EarlyData != want.EarlyData || got.alpnProtocol != want.alpnProtocol {
+				t.Errorf("parsed session %+v does not match %+v", got, want)
+			}
+			if !bytes.Equal(got.secret, want.secret) || !bytes.Equal(got.ticket, want.ticket) {
+				t.Errorf("secret or ticket mismatch after round trip")
+			}
+			if len(got.peerCertificates) != len(want.peerCertificates) ||
+				!got.peerCertificates[0].Equal(want.peerCertificates[0]) {
+				t.Errorf("certificate chain mismatch after round trip")
+			}
+			if len(got.Extra) != 1 || string(got.Extra[0]) != "extra" {
+				t.Errorf("extra data mismatch after round trip: %q", got.Extra)
+			}
+			if version == VersionTLS13 && got.useBy == 0 {
+				t.Errorf("TLS 1.3 ticket lifetime was not preserved")
+			}
+
+			b[1] = 0xff // unknown format version
+			if _, err := ParseClientSessionState(b); err == nil {
+				t.Errorf("expected an error for an unknown format version")
+			}
+			if _, err := ParseClientSessionState(b[:len(b)-1]); err == nil {
+				t.Errorf("expected an error for a truncated encoding")
+			}
+		})
+	}
+}
+
+func TestFileClientSessionCacheResumption(t *testing.T) {
+	key := bytes.Repeat([]byte{0x42}, 32)
+	for _, version := range []uint16{VersionTLS12, VersionTLS13} {
+		t.Run(VersionName(version), func(t *testing.T) {
+			dir := t.TempDir()
+			clientConfig, serverConfig := testFileSessionCacheConfigs(version)
+
+			newCache := func() *FileClientSessionCache {
+				cache, err := NewFileClientSessionCache(dir, key)
+				if err != nil {
+					t.Fatal(err)
+				}
+				cache.Time = testConfig.Time
+				return cache
+			}
+
+			clientConfig.ClientSessionCache = newCache()
+			_, state, err := testHandshake(t, clientConfig, serverConfig)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if state.DidResume {
+				t.Fatal("first handshake unexpectedly resumed")
+			}
+
+			// A fresh cache over the same directory simulates a restart.
+			clientConfig.ClientSessionCache = newCache()
+			_, state, err = testHandshake(t, clientConfig, serverConfig)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !state.DidResume {
+				t.Fatal("handshake did not resume from the persisted session")
+			}
+
+			// A cache using another key must not be able to read the entries.
+			other, err := NewFileClientSessionCache(dir, bytes.Repeat([]byte{0x43}, 32))
+			if err != nil {
+				t.Fatal(err)
+			}
+			other.Time = testConfig.Time
+			if _, ok := other.Get(clientConfig.ServerName); ok {
+				t.Error("session was readable with a different key")
+			}
+		})
+	}
+}
+
+func TestFileClientSessionCacheExpiry(t *testing.T) {
+	dir := t.TempDir()
+	cache, err := NewFileClientSessionCache(dir, make([]byte, 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Unix(1000, 0)
+	cache.Time = func() time.Time { return now }
+	cache.MaxAge = time.Hour
+	cert, err := x509.ParseCertificate(testRSACertificate)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tls12 := MakeClientSessionState([]byte("ticket"), VersionTLS12, TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+		make([]byte, 48), []*x509.Certificate{cert}, nil)
+	tls12.SetCreatedAt(uint64(now.Unix()))
+	cache.Put("a", tls12)
+
+	tls13 := MakeClientSessionState([]byte("ticket"), VersionTLS13, TLS_AES_128_GCM_SHA256,
+		make([]byte, 32), []*x509.Certificate{cert}, nil)
+	tls13.SetCreatedAt(uint64(now.Unix()))
+	tls13.SetUseBy(uint64(now.Add(2 * time.Hour).Unix()))
+	cache.Put("b", tls13)
+
+	if _, ok := cache.Get("a"); !ok {
+		t.Fatal("TLS 1.2 session missing")
+	}
+	if _, ok := cache.Get("b"); !ok {
+		t.Fatal("TLS 1.3 session missing")
+	}
+
+	now = now.Add(90 * time.Minute)
+	if _, ok := cache.Get("a"); ok {
+		t.Error("TLS 1.2 session did not expire after MaxAge")
+	}
+	if _, ok := cache.Get("b"); !ok {
+		t.Error("TLS 1.3 session expired before its lifetime")
+	}
+
+	now = now.Add(time.Hour)
+	if err := cache.Prune(); err != nil {
+		t.Fatal(err)
+	}
+	files, _ := filepath.Glob(filepath.Join(dir, "*"+fileSessionCacheSuffix))
+	if len(files) != 0 {
+		t.Errorf("Prune left %d expired entries", len(files))
+	}
+
+	cache.Put("c", tls13) // already expired
+	cache.Put("d", nil)
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("expected an empty cache directory, got %d entries", len(entries))
+	}
+}
+
+func TestFileClientSessionCacheErrors(t *testing.T) {
+	dir := t.TempDir()
+	cache, err := NewFileClientSessionCache(dir, make([]byte, 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var errs []error
+	cache.OnError = func(err error) { errs = append(errs, err) }
+	cert, err := x509.ParseCertificate(testRSACertificate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cs := MakeClientSessionState([]byte("ticket"), VersionTLS13, TLS_AES_128_GCM_SHA256,
+		make([]byte, 32), []*x509.Certificate{cert}, nil)
+	cs.SetCreatedAt(uint64(time.Now().Unix()))
+	cs.SetUseBy(uint64(time.Now().Add(time.Hour).Unix()))
+
+	// A state without a session is ignored.
+	cache.Put("empty", &ClientSessionState{})
+
+	// An entry written with another key is pruned.
+	other, err := NewFileClientSessionCache(dir, bytes.Repeat([]byte{1}, 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	other.Put("other", cs)
+	cache.Put("a", cs)
+	if err := cache.Prune(); err != nil {
+		t.Fatal(err)
+	}
+	if files, _ := filepath.Glob(filepath.Join(dir, "*"+fileSessionCacheSuffix)); len(files) != 1 {
+		t.Errorf("Prune left %d entries, want 1", len(files))
+	}
+	if len(errs) != 0 {
+		t.Fatalf("unexpected errors %v", errs)
+	}
+
+	// Write errors are reported.
+	if err := os.RemoveAll(dir); err != nil {
+		t.Fatal(err)
+	}
+	cache.Put("a", cs)
+	if len(errs) != 1 {
+		t.Errorf("got errors %v, want a write error", errs)
+	}
+}
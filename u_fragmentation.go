@@ -0,0 +1,163 @@
+// Copyright 2026 uTLS Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tls
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ClientHelloFragmentation describes how the ClientHello is split on the wire.
+//
+// The handshake message itself (and therefore the transcript hash) is never
+// changed: only the way its bytes are framed into TLS records and handed to
+// the underlying net.Conn differs. Both splits apply to every ClientHello sent
+// on the connection, including the second ClientHello after a
+// HelloRetryRequest and the outer ClientHello when ECH is in use.
+type ClientHelloFragmentation struct {
+	// RecordSizes is the list of plaintext sizes of the TLS records carrying
+	// the ClientHello. The first record holds RecordSizes[0] bytes of the
+	// handshake message, the second RecordSizes[1] bytes and so on. Whatever
+	// remains after the last size is sent in as few records as possible.
+	// If empty, the ClientHello is framed as usual.
+	RecordSizes []int
+
+	// TCPSegmentSizes is the list of sizes of the consecutive writes to the
+	// underlying net.Conn, counted over the serialized records (headers
+	// included). Whatever remains after the last size is written at once.
+	// If empty, all records are written at once.
+	//
+	// Note that the kernel may still coalesce writes unless Nagle's algorithm
+	// is disabled on the socket, which is the default for *net.TCPConn.
+	TCPSegmentSizes []int
+
+	// TCPWriteDelay is the pause between two consecutive writes to the
+	// underlying net.Conn. It is only used if the ClientHello is written in
+	// more than one write.
+	TCPWriteDelay time.Duration
+}
+
+func (f *ClientHelloFragmentation) validate() error {
+	for _, size := range f.RecordSizes {
+		if size <= 0 || size > maxPlaintext {
+			return fmt.Errorf("tls: invalid ClientHello record size %d", size)
+		}
+	}
+	for _, size := range f.TCPSegmentSizes {
+		if size <= 0 {
+			return fmt.Errorf("tls: invalid ClientHello TCP segment size %d", size)
+		}
+	}
+	if f.TCPWriteDelay < 0 {
+		return errors.New("tls: negative ClientHello TCP write delay")
+	}
+	return nil
+}
+
+func (f *ClientHelloFragmentation) clone() *ClientHelloFragmentation {
+	if f == nil {
+		return nil
+	}
+	return &ClientHelloFragmentation{
+		RecordSizes:     append([]int(nil), f.RecordSizes...),
+		TCPSegmentSizes: append([]int(nil), f.TCPSegmentSizes...),
+		TCPWriteDelay:   f.TCPWriteDelay,
+	}
+}
+
+// SetClientHelloFragmentation sets how the ClientHello is split into TLS
+// records and TCP writes. It must be called before the handshake starts.
+// A nil fragmentation restores the default behavior.
+func (uconn *UConn) SetClientHelloFragmentation(f *ClientHelloFragmentation) error {
+	if uconn.isHandshakeComplete.Load() || uconn.handshakes > 0 {
+		return errors.New("tls: SetClientHelloFragmentation called after the handshake")
+	}
+	if f == nil {
+		uconn.utls.clientHelloFragmentation = nil
+		return nil
+	}
+	if err := f.validate(); err != nil {
+		return err
+	}
+	uconn.utls.clientHelloFragmentation = f.clone()
+	return nil
+}
+
+// ClientHelloFragmentation returns a copy of the fragmentation set by
+// SetClientHelloFragmentation, or nil if the ClientHello is sent as usual.
+func (uconn *UConn) ClientHelloFragmentation() *ClientHelloFragmentation {
+	return uconn.utls.clientHelloFragmentation.clone()
+}
+
+// writeFragmentedClientHelloLocked writes the marshaled ClientHello data
+// according to c.utls.clientHelloFragmentation. c.out must be locked.
+func (c *Conn) writeFragmentedClientHelloLocked(data []byte) (int, error) {
+	f := c.utls.clientHelloFragmentation
+
+	var records []byte
+	n := 0
+	for i := 0; len(data) > 0; i++ {
+		m := len(data)
+		if i < len(f.RecordSizes) {
+			m = min(m, f.RecordSizes[i])
+		}
+		m = min(m, maxPlaintext)
+
+		record, err := c.encryptRecordLocked(recordTypeHandshake, data[:m])
+		if err != nil {
+			return n, err
+		}
+		records = append(records, record...)
+		n += m
+		data = data[m:]
+	}
+
+	// Handshake messages sent while buffering are flushed together with the
+	// rest of the flight, so there are no separate writes to shape.
+	if c.buffering || len(f.TCPSegmentSizes) == 0 {
+		if _, err := c.write(records); err != nil {
+			return 0, err
+		}
+		return n, nil
+	}
+
+	for i := 0; len(records) > 0; i++ {
+		if i > 0 && f.TCPWriteDelay > 0 {
+			time.Sleep(f.TCPWriteDelay)
+		}
+		m := len(records)
+		if i < len(f.TCPSegmentSizes) {
+			m = min(m, f.TCPSegmentSizes[i])
+		}
+		if _, err := c.write(records[:m]); err != nil {
+			return 0, err
+		}
+		records = records[m:]
+	}
+	return n, nil
+}
+
+// encryptRecordLocked returns a single protected record of type typ carrying
+// payload, using the same framing as writeRecordLocked. c.out must be locked.
+func (c *Conn) encryptRecordLocked(typ recordType, payload []byte) ([]byte, error) {
+	_, record := sliceForAppend(nil, recordHeaderLen)
+	record[0] = byte(typ)
+	vers := c.vers
+	if vers == 0 {
+		// Some TLS servers fail if the record version is
+		// greater than TLS 1.0 for the initial ClientHello.
+		vers = VersionTLS10
+	} else if vers == VersionTLS13 {
+		// TLS 1.3 froze the record layer version to 1.2.
+		// See RFC 8446, Section 5.1.
+		vers = VersionTLS12
+	}
+	record[1] = byte(vers >> 8)
+	record[2] = byte(vers)
+	record[3] = byte(len(payload) >> 8)
+	record[4] = byte(len(payload))
+	return c.out.encrypt(record, payload, c.config.rand())
+}
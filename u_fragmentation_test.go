@@ -0,0 +1,152 @@
+// Copyright 2026 uTLS Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tls
+
+import (
+	"net"
+	"sync"
+	"testing"
+)
+
+// writesRecordingConn records the size of every Write made to the wrapped conn.
+type writesRecordingConn struct {
+	net.Conn
+	sync.Mutex
+	writes [][]byte
+}
+
+func (w *writesRecordingConn) Write(b []byte) (int, error) {
+	w.Lock()
+	w.writes = append(w.writes, append([]byte(nil), b...))
+	w.Unlock()
+	return w.Conn.Write(b)
+}
+
+// clientHelloRecordSizes returns the payload sizes of the handshake records
+// carrying the first ClientHello in the given stream, and the rest of it.
+func clientHelloRecordSizes(t *testing.T, stream []byte) (sizes []int, rest []byte) {
+	t.Helper()
+	helloLen, total := -1, 0
+	for len(stream) >= recordHeaderLen && (helloLen < 0 || total < helloLen) {
+		if recordType(stream[0]) != recordTypeHandshake {
+			t.Fatalf("unexpected record type %d", stream[0])
+		}
+		n := int(stream[3])<<8 | int(stream[4])
+		payload := stream[recordHeaderLen : recordHeaderLen+n]
+		if helloLen < 0 {
+			if payload[0] != typeClientHello {
+				t.Fatalf("first handshake message is %d, not a ClientHello", payload[0])
+			}
+			helloLen = 4 + (int(payload[1])<<16 | int(payload[2])<<8 | int(payload[3]))
+		}
+		sizes = append(sizes, n)
+		total += n
+		stream = stream[recordHeaderLen+n:]
+	}
+	if total != helloLen {
+		t.Fatalf("ClientHello spans %d bytes, expected %d", total, helloLen)
+	}
+	return sizes, stream
+}
+
+func testClientHelloFragmentation(t *testing.T, serverConfig *Config, f *ClientHelloFragmentation) (*UConn, *writesRecordingConn) {
+	c, s := localPipe(t)
+	rc := &writesRecordingConn{Conn: c}
+
+	clientConfig := testConfig.Clone()
+	clientConfig.ServerName = "example.golang"
+	uconn := UClient(rc, clientConfig, HelloChrome_Auto, false, false)
+	if err := uconn.SetClientHelloFragmentation(f); err != nil {
+		t.Fatal(err)
+	}
+
+	errChan := make(chan error, 1)
+	go func() {
+		server := Server(s, serverConfig)
+		errChan <- server.Handshake()
+		server.Close()
+	}()
+	if err := uconn.Handshake(); err != nil {
+		t.Fatalf("client handshake: %v", err)
+	}
+	if err := <-errChan; err != nil {
+		t.Fatalf("server handshake: %v", err)
+	}
+	uconn.Close()
+	return uconn, rc
+}
+
+func TestUTLSClientHelloFragmentation(t *testing.T) {
+	serverConfig := testConfig.Clone()
+	serverConfig.CurvePreferences = nil
+
+	f := &ClientHelloFragmentation{
+		RecordSizes:     []int{5, 100},
+		TCPSegmentSizes: []int{1, 40},
+	}
+	_, rc := testClientHelloFragmentation(t, serverConfig, f)
+
+	if len(rc.writes) < 3 {
+		t.Fatalf("expected the ClientHello to span at least 3 writes, got %d", len(rc.writes))
+	}
+	if len(rc.writes[0]) != 1 || len(rc.writes[1]) != 40 {
+		t.Errorf("unexpected TCP write sizes: %d, %d", len(rc.writes[0]), len(rc.writes[1]))
+	}
+
+	var stream []byte
+	for _, w := range rc.writes[:3] {
+		stream = append(stream, w...)
+	}
+	sizes, _ := clientHelloRecordSizes(t, stream)
+	if len(sizes) != 3 || sizes[0] != 5 || sizes[1] != 100 {
+		t.Errorf("unexpected ClientHello record sizes %v", sizes)
+	}
+}
+
+func TestUTLSClientHelloFragmentationHRR(t *testing.T) {
+	// Force a HelloRetryRequest: the server only accepts a group for which
+	// the client sends no key share in its first flight.
+	serverConfig := testConfig.Clone()
+	serverConfig.CurvePreferences = []CurveID{CurveP384}
+
+	f := &ClientHelloFragmentation{RecordSizes: []int{64, 100}}
+	uconn, rc := testClientHelloFragmentation(t, serverConfig, f)
+	if !uconn.didHRR {
+		t.Fatal("expected a HelloRetryRequest")
+	}
+
+	var stream []byte
+	for _, w := range rc.writes {
+		stream = append(stream, w...)
+	}
+	sizes, stream := clientHelloRecordSizes(t, stream)
+	if len(sizes) < 3 || sizes[0] != 64 || sizes[1] != 100 {
+		t.Errorf("unexpected first ClientHello record sizes %v", sizes)
+	}
+
+	// The second ClientHello is rebuilt after the HelloRetryRequest and
+	// follows the ChangeCipherSpec sent for middlebox compatibility.
+	for len(stream) >= recordHeaderLen && recordType(stream[0]) == recordTypeChangeCipherSpec {
+		stream = stream[recordHeaderLen+(int(stream[3])<<8|int(stream[4])):]
+	}
+	sizes, _ = clientHelloRecordSizes(t, stream)
+	if len(sizes) < 3 || sizes[0] != 64 || sizes[1] != 100 {
+		t.Errorf("unexpected second ClientHello record sizes %v", sizes)
+	}
+}
+
+func TestUTLSClientHelloFragmentationInvalid(t *testing.T) {
+	uconn := UClient(&net.TCPConn{}, &Config{ServerName: "foobar"}, HelloChrome_Auto, false, false)
+	for _, f := range []*ClientHelloFragmentation{
+		{RecordSizes: []int{0}},
+		{RecordSizes: []int{maxPlaintext + 1}},
+		{TCPSegmentSizes: []int{-1}},
+		{TCPWriteDelay: -1},
+	} {
+		if err := uconn.SetClientHelloFragmentation(f); err == nil {
+			t.Errorf("expected an error for %+v", f)
+		}
+	}
+}
@@ -32,11 +32,12 @@ func (hs *clientHandshakeStateTLS13) utlsReadServerCertificate(msg any) (process
 					if err = transcriptMsg(compressedCertMsg, hs.transcript); err != nil {
 						return nil, err
 					}
-					msg, err = hs.decompressCert(*compressedCertMsg)
+					certMsg, err := hs.decompressCert(*compressedCertMsg)
 					if err != nil {
 						return nil, fmt.Errorf("tls: failed to decompress certificate message: %w", err)
 					} else {
-						return msg, nil
+						hs.c.traceHandshakeMsg(HandshakeEventCertificateDecompressed, certMsg)
+						return certMsg, nil
 					}
 				}
 			}
@@ -158,6 +159,7 @@ func (hs *clientHandshakeStateTLS13) utlsReadServerParameters(encryptedExtension
 		// Check if the ALPN selected by the server exists in the client's list.
 		if alps, ok := hs.uconn.config.ApplicationSettings[hs.serverHello.alpnProtocol]; ok {
 			hs.c.utls.localApplicationSettings = alps
+			hs.c.traceHandshake(HandshakeEventApplicationSettings, hs.c.utls.peerApplicationSettings, nil)
 		} else {
 			// return errors.New("tls: server selected ALPN doesn't match a client ALPS")
 			return nil // ignore if client doesn't have ALPS in use.
@@ -246,7 +248,7 @@ func (c *Conn) makeClientHelloForApplyPreset() (*clientHelloMsg, *keySharePrivat
 	hello.cipherSuites = make([]uint16, 0, len(configCipherSuites))
 
 	for _, suiteId := range preferenceOrder {
-		suite := mutualCipherSuite(configCipherSuites, suiteId)
+		suite := config.mutualCipherSuite(configCipherSuites, suiteId) // [uTLS]
 		if suite == nil {
 			continue
 		}
@@ -257,6 +259,7 @@ func (c *Conn) makeClientHelloForApplyPreset() (*clientHelloMsg, *keySharePrivat
 		}
 		hello.cipherSuites = append(hello.cipherSuites, suiteId)
 	}
+	hello.cipherSuites = config.appendUTLSCipherSuites(hello.cipherSuites, configCipherSuites, maxVersion) // [uTLS]
 
 	_, err := io.ReadFull(config.rand(), hello.random)
 	if err != nil {
@@ -276,7 +279,7 @@ func (c *Conn) makeClientHelloForApplyPreset() (*clientHelloMsg, *keySharePrivat
 	}
 
 	if maxVersion >= VersionTLS12 {
-		hello.supportedSignatureAlgorithms = supportedSignatureAlgorithms()
+		hello.supportedSignatureAlgorithms = signatureSchemesForVersion(config.supportedSignatureAlgorithms(), maxVersion) // [uTLS]
 	}
 	if testingOnlyForceClientHelloSignatureAlgorithms != nil {
 		hello.supportedSignatureAlgorithms = testingOnlyForceClientHelloSignatureAlgorithms
@@ -295,6 +298,9 @@ func (c *Conn) makeClientHelloForApplyPreset() (*clientHelloMsg, *keySharePrivat
 		} else {
 			hello.cipherSuites = append(hello.cipherSuites, defaultCipherSuitesTLS13NoAES...)
 		}
+		if !fips140tls.Required() {
+			hello.cipherSuites = append(hello.cipherSuites, config.shangMiCipherSuites()...) // [uTLS]
+		}
 
 		if len(hello.supportedCurves) == 0 {
 			return nil, nil, nil, errors.New("tls: no supported elliptic curves for ECDHE")
@@ -406,6 +412,8 @@ func (c *UConn) clientHandshake(ctx context.Context) (err error) {
 
 	sessionIsLocked := c.utls.sessionController.isSessionLocked()
 
+	c.utls.rootStore = boundRootStore(c.ClientHelloID)
+
 	// after this point exactly 1 out of 2 HandshakeState pointers is non-nil,
 	// useTLS13 variable tells which pointer
 	// [uTLS section ends]
@@ -453,6 +461,9 @@ func (c *UConn) clientHandshake(ctx context.Context) (err error) {
 		session, earlySecret, binderKey, err = c.loadSession(hello)
 
 		// [uTLS section start]
+		if err == nil {
+			err = c.offerExternalPSKs(hello, session, binderKey)
+		}
 	} else {
 		session = c.HandshakeState.Session
 
@@ -477,7 +488,7 @@ func (c *UConn) clientHandshake(ctx context.Context) (err error) {
 			// delete tickets to recover from a corrupted PSK.
 			if err != nil {
 				if cacheKey := c.clientSessionCacheKey(); cacheKey != "" {
-					c.config.ClientSessionCache.Put(cacheKey, nil)
+					c.clientSessionCache().Put(cacheKey, nil)
 				}
 			}
 		}()
@@ -508,6 +519,7 @@ func (c *UConn) clientHandshake(ctx context.Context) (err error) {
 
 	c.serverName = hello.serverName
 
+	c.recordECHInnerHello(ech)
 	if _, err := c.writeHandshakeRecord(hello, nil); err != nil {
 		return err
 	}
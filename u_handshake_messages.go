@@ -5,6 +5,8 @@
 package tls
 
 import (
+	"errors"
+
 	"golang.org/x/crypto/cryptobyte"
 )
 
@@ -53,6 +55,47 @@ func (m *utlsCompressedCertificateMsg) unmarshal(data []byte) bool {
 	return true
 }
 
+// utlsNextProtocolMsg is the NextProtocol message of NPN, which a client sends
+// between its ChangeCipherSpec and Finished messages.
+// https://datatracker.ietf.org/doc/html/draft-agl-tls-nextprotoneg-04#section-4
+type utlsNextProtocolMsg struct {
+	proto string
+}
+
+func (m *utlsNextProtocolMsg) marshal() ([]byte, error) {
+	if len(m.proto) > 255 {
+		return nil, errors.New("tls: invalid NPN protocol")
+	}
+	// The padding hides the length of the protocol, by making the length
+	// of the message body a multiple of 32 bytes.
+	padding := 32 - (len(m.proto)+2)%32
+
+	var b cryptobyte.Builder
+	b.AddUint8(utlsTypeNextProtocol)
+	b.AddUint24LengthPrefixed(func(b *cryptobyte.Builder) {
+		b.AddUint8LengthPrefixed(func(b *cryptobyte.Builder) {
+			b.AddBytes([]byte(m.proto))
+		})
+		b.AddUint8LengthPrefixed(func(b *cryptobyte.Builder) {
+			b.AddBytes(make([]byte, padding))
+		})
+	})
+	return b.Bytes()
+}
+
+func (m *utlsNextProtocolMsg) unmarshal(data []byte) bool {
+	s := cryptobyte.String(data)
+	var proto, padding cryptobyte.String
+	if !s.Skip(4) || // message type and uint24 length field
+		!s.ReadUint8LengthPrefixed(&proto) ||
+		!s.ReadUint8LengthPrefixed(&padding) ||
+		!s.Empty() {
+		return false
+	}
+	m.proto = string(proto)
+	return true
+}
+
 type utlsEncryptedExtensionsMsgExtraFields struct {
 	applicationSettings          []byte
 	applicationSettingsCodepoint uint16
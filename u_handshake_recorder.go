@@ -0,0 +1,205 @@
+// Copyright 2026 uTLS Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tls
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// HandshakeRecorder records the exact handshake messages exchanged by a
+// connection, with the NSS key log lines of its secrets, for instance to attach
+// them to a bug report, see UConn.SetHandshakeRecorder. It can be dumped during
+// or after the handshake, whether it succeeded or failed, and is safe for
+// concurrent use.
+type HandshakeRecorder struct {
+	mu       sync.Mutex
+	messages []RecordedHandshakeMessage
+	keyLog   []byte
+}
+
+// RecordedHandshakeMessage is a handshake message recorded by a
+// HandshakeRecorder.
+type RecordedHandshakeMessage struct {
+	// Sent is whether the connection sent the message, rather than received
+	// it.
+	Sent bool
+
+	// Level is the encryption level of the message. The messages of TLS 1.2
+	// encrypted after ChangeCipherSpec are at QUICEncryptionLevelApplication.
+	Level QUICEncryptionLevel
+
+	// Type is the handshake message type, such as 1 for ClientHello.
+	Type uint8
+
+	// Data is the message, with its header.
+	Data []byte
+
+	// ECHInner is whether the message is the inner ClientHello of Encrypted
+	// Client Hello, which is sent encrypted in the outer ClientHello that
+	// follows it.
+	ECHInner bool
+
+	// Time is when the message was sent or received.
+	Time time.Time
+}
+
+var handshakeMessageTypeNames = map[uint8]string{
+	typeHelloRequest:              "HelloRequest",
+	typeClientHello:               "ClientHello",
+	typeServerHello:               "ServerHello",
+	typeNewSessionTicket:          "NewSessionTicket",
+	typeEndOfEarlyData:            "EndOfEarlyData",
+	typeEncryptedExtensions:       "EncryptedExtensions",
+	typeCertificate:               "Certificate",
+	typeServerKeyExchange:         "ServerKeyExchange",
+	typeCertificateRequest:        "CertificateRequest",
+	typeServerHelloDone:           "ServerHelloDone",
+	typeCertificateVerify:         "CertificateVerify",
+	typeClientKeyExchange:         "ClientKeyExchange",
+	typeFinished:                  "Finished",
+	typeCertificateStatus:         "CertificateStatus",
+	typeKeyUpdate:                 "KeyUpdate",
+	utlsTypeCompressedCertificate: "CompressedCertificate",
+	utlsTypeNextProtocol:          "NextProtocol",
+}
+
+// TypeName returns the name of the type of the message, such as
+// "ClientHello".
+func (m *RecordedHandshakeMessage) TypeName() string {
+	if name, ok := handshakeMessageTypeNames[m.Type]; ok {
+		return name
+	}
+	return "HandshakeMessage(" + strconv.Itoa(int(m.Type)) + ")"
+}
+
+// Messages returns the messages recorded so far, in the order they were sent
+// and received.
+func (r *HandshakeRecorder) Messages() []RecordedHandshakeMessage {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]RecordedHandshakeMessage(nil), r.messages...)
+}
+
+// KeyLog returns the key log lines of the secrets recorded so far, in the NSS
+// key log format, see Config.KeyLogWriter. They are recorded even if
+// Config.KeyLogWriter is nil.
+func (r *HandshakeRecorder) KeyLog() []byte {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return bytes.Clone(r.keyLog)
+}
+
+// WriteTo writes a dump of the recording to w: a line per message with its
+// direction, ">" for sent and "<" for received, its encryption level, its type
+// and its hex encoding, followed by the key log lines.
+func (r *HandshakeRecorder) WriteTo(w io.Writer) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var b bytes.Buffer
+	b.WriteString("# Handshake messages\n")
+	for i := range r.messages {
+		m := &r.messages[i]
+		direction := "<"
+		if m.Sent {
+			direction = ">"
+		}
+		name := m.TypeName()
+		if m.ECHInner {
+			name += "(ECH inner)"
+		}
+		fmt.Fprintf(&b, "%s %s %s %x\n", direction, m.Level, name, m.Data)
+	}
+	b.WriteString("# NSS key log\n")
+	b.Write(r.keyLog)
+	return b.WriteTo(w)
+}
+
+func (r *HandshakeRecorder) recordMessage(m RecordedHandshakeMessage) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.messages = append(r.messages, m)
+}
+
+func (r *HandshakeRecorder) recordKeyLog(line []byte) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.keyLog = append(r.keyLog, line...)
+}
+
+// SetHandshakeRecorder makes the connection record its handshake messages and
+// secrets with r. A nil r stops the recording.
+func (uconn *UConn) SetHandshakeRecorder(r *HandshakeRecorder) {
+	uconn.utls.handshakeRecorder = r
+}
+
+// recordLevel returns the encryption level of the messages protected by hc.
+func (hc *halfConn) recordLevel() QUICEncryptionLevel {
+	if hc.level == QUICEncryptionLevelInitial && hc.cipher != nil {
+		return QUICEncryptionLevelApplication
+	}
+	return hc.level
+}
+
+// recordHandshakeMessage records the encoding of a handshake message sent or
+// received by the connection, if it has a recorder.
+func (c *Conn) recordHandshakeMessage(data []byte, sent bool) {
+	r := c.utls.handshakeRecorder
+	if r == nil || len(data) == 0 {
+		return
+	}
+	level := c.in.recordLevel()
+	if sent {
+		level = c.out.recordLevel()
+	}
+	r.recordMessage(RecordedHandshakeMessage{
+		Sent:  sent,
+		Level: level,
+		Type:  data[0],
+		Data:  bytes.Clone(data),
+		Time:  time.Now(),
+	})
+}
+
+// recordECHInnerHello records the inner ClientHello of ech, if any, before the
+// outer ClientHello is sent.
+func (c *Conn) recordECHInnerHello(ech *echClientContext) {
+	r := c.utls.handshakeRecorder
+	if r == nil || ech == nil || ech.innerHello == nil {
+		return
+	}
+	data, err := ech.innerHello.marshal()
+	if err != nil {
+		return
+	}
+	r.recordMessage(RecordedHandshakeMessage{
+		Sent:     true,
+		Level:    QUICEncryptionLevelInitial,
+		Type:     typeClientHello,
+		Data:     bytes.Clone(data),
+		ECHInner: true,
+		Time:     time.Now(),
+	})
+}
+
+// writeKeyLog logs a secret with Config.writeKeyLog, and records it with the
+// recorders of the connection, if any.
+func (c *Conn) writeKeyLog(label string, clientRandom, secret []byte) error {
+	if c.utls.handshakeRecorder != nil || c.utls.pcapngRecorder != nil {
+		line := fmt.Appendf(nil, "%s %x %x\n", label, clientRandom, secret)
+		if r := c.utls.handshakeRecorder; r != nil {
+			r.recordKeyLog(line)
+		}
+		if r := c.utls.pcapngRecorder; r != nil {
+			r.recordKeyLog(line)
+		}
+	}
+	return c.config.writeKeyLog(label, clientRandom, secret)
+}
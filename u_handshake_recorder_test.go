@@ -0,0 +1,154 @@
+// Copyright 2026 uTLS Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tls
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+)
+
+// testRecorderHandshake runs a handshake of a UConn recording it with r
+// against a server with serverConfig, and returns the error of the client.
+func testRecorderHandshake(t *testing.T, clientConfig, serverConfig *Config, id ClientHelloID, r *HandshakeRecorder) error {
+	t.Helper()
+	c, s := localPipe(t)
+	go func() {
+		server := Server(s, serverConfig)
+		server.Handshake()
+		server.Close()
+	}()
+	client := UClient(c, clientConfig, id, false, false)
+	client.SetHandshakeRecorder(r)
+	defer client.Close()
+	return client.Handshake()
+}
+
+func recordedMessagesString(messages []RecordedHandshakeMessage) string {
+	var s []string
+	for _, m := range messages {
+		direction := "<"
+		if m.Sent {
+			direction = ">"
+		}
+		s = append(s, fmt.Sprintf("%s %v %s", direction, m.Level, m.TypeName()))
+	}
+	return strings.Join(s, ", ")
+}
+
+func TestHandshakeRecorderTLS13(t *testing.T) {
+	r := &HandshakeRecorder{}
+	serverConfig := testConfig.Clone()
+	serverConfig.MinVersion = VersionTLS13
+	if err := testRecorderHandshake(t, testConfig.Clone(), serverConfig, HelloChrome_Auto, r); err != nil {
+		t.Fatal(err)
+	}
+
+	messages := r.Messages()
+	want := "> Initial ClientHello, < Initial ServerHello, < Handshake EncryptedExtensions, " +
+		"< Handshake Certificate, < Handshake CertificateVerify, < Handshake Finished, > Handshake Finished"
+	if got := recordedMessagesString(messages); got != want {
+		t.Errorf("got messages %s, want %s", got, want)
+	}
+	for _, m := range messages {
+		if m.Type != m.Data[0] || len(m.Data) < 4 || int(m.Data[1])<<16|int(m.Data[2])<<8|int(m.Data[3]) != len(m.Data)-4 {
+			t.Errorf("invalid %s message %x", m.TypeName(), m.Data)
+		}
+	}
+
+	keyLog := r.KeyLog()
+	for _, label := range []string{keyLogLabelClientHandshake, keyLogLabelServerHandshake, keyLogLabelClientTraffic, keyLogLabelServerTraffic} {
+		if !bytes.Contains(keyLog, []byte(label+" ")) {
+			t.Errorf("the key log misses %s:\n%s", label, keyLog)
+		}
+	}
+
+	var dump bytes.Buffer
+	if _, err := r.WriteTo(&dump); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(dump.String(), fmt.Sprintf("> Initial ClientHello %x\n", messages[0].Data)) || !bytes.HasSuffix(dump.Bytes(), keyLog) {
+		t.Errorf("unexpected dump:\n%s", dump.String())
+	}
+}
+
+func TestHandshakeRecorderFailure(t *testing.T) {
+	r := &HandshakeRecorder{}
+	clientConfig := testConfig.Clone()
+	clientConfig.MaxVersion = VersionTLS12
+	clientConfig.InsecureSkipVerify = false
+	clientConfig.ServerName = "example.golang"
+	if err := testRecorderHandshake(t, clientConfig, testConfig.Clone(), HelloGolang, r); err == nil {
+		t.Fatal("the handshake with an untrusted certificate succeeded")
+	}
+
+	want := "> Initial ClientHello, < Initial ServerHello, < Initial Certificate"
+	if got := recordedMessagesString(r.Messages()); !strings.HasPrefix(got, want) {
+		t.Errorf("got messages %s, want %s", got, want)
+	}
+	if len(r.KeyLog()) != 0 {
+		t.Errorf("unexpected key log %s", r.KeyLog())
+	}
+}
+
+func TestHandshakeRecorderECH(t *testing.T) {
+	r := &HandshakeRecorder{}
+	clientConfig, serverConfig, _ := testECHConfigs(t)
+	if err := testRecorderHandshake(t, clientConfig, serverConfig, HelloChrome_Auto, r); err != nil {
+		t.Fatal(err)
+	}
+
+	messages := r.Messages()
+	if len(messages) < 2 || messages[0].Type != typeClientHello || messages[1].Type != typeClientHello {
+		t.Fatalf("got messages %s, want the inner and the outer ClientHello first", recordedMessagesString(messages))
+	}
+	inner, outer := messages[0], messages[1]
+	if !inner.ECHInner || !inner.Sent || inner.Level != QUICEncryptionLevelInitial {
+		t.Errorf("the first ClientHello is not recorded as the sent inner ClientHello: %+v", inner)
+	}
+	if outer.ECHInner {
+		t.Error("the outer ClientHello is recorded as the inner ClientHello")
+	}
+	if !bytes.Contains(inner.Data, []byte("secret.example")) || bytes.Contains(outer.Data, []byte("secret.example")) {
+		t.Error("the inner server name is not recorded in the inner ClientHello only")
+	}
+	for _, m := range messages[2:] {
+		if m.ECHInner {
+			t.Errorf("%s is recorded as the inner ClientHello", m.TypeName())
+		}
+	}
+}
+
+func TestHandshakeRecorderClientEncryptedExtensions(t *testing.T) {
+	r := &HandshakeRecorder{}
+	c, s := localPipe(t)
+	defer s.Close()
+	go io.Copy(io.Discard, s)
+
+	client := Client(c, testConfig.Clone())
+	defer client.Close()
+	client.utls.handshakeRecorder = r
+	client.utls.applicationSettingsCodepoint = utlsExtensionApplicationSettingsNew
+	client.utls.localApplicationSettings = []byte("settings")
+	hs := &clientHandshakeStateTLS13{c: client, transcript: sha256.New()}
+	if err := hs.sendClientEncryptedExtensions(); err != nil {
+		t.Fatal(err)
+	}
+
+	messages := r.Messages()
+	if got, want := recordedMessagesString(messages), "> Initial EncryptedExtensions"; got != want {
+		t.Fatalf("got messages %s, want %s", got, want)
+	}
+	m := new(utlsClientEncryptedExtensionsMsg)
+	if !m.unmarshal(messages[0].Data) {
+		t.Fatalf("invalid client EncryptedExtensions %x", messages[0].Data)
+	}
+	if m.applicationSettingsCodepoint != utlsExtensionApplicationSettingsNew || string(m.applicationSettings) != "settings" {
+		t.Errorf("got application settings %q with codepoint %d", m.applicationSettings, m.applicationSettingsCodepoint)
+	}
+}
@@ -0,0 +1,222 @@
+// Copyright 2026 uTLS Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tls
+
+import (
+	"bytes"
+	"strconv"
+	"time"
+)
+
+// HandshakeEventType identifies an event of a handshake, see HandshakeTracer.
+type HandshakeEventType int
+
+const (
+	// HandshakeEventStart is the start of a handshake.
+	HandshakeEventStart HandshakeEventType = iota
+
+	// HandshakeEventClientHelloBuilt is the building of the ClientHello by a
+	// client, and HandshakeEventClientHelloSent its sending. A client sends a
+	// second ClientHello after a HelloRetryRequest.
+	HandshakeEventClientHelloBuilt
+	HandshakeEventClientHelloSent
+
+	// HandshakeEventHelloRetryRequest and HandshakeEventServerHello are the
+	// reception of a HelloRetryRequest and a ServerHello.
+	HandshakeEventHelloRetryRequest
+	HandshakeEventServerHello
+
+	// HandshakeEventEncryptedExtensions is the reception of the
+	// EncryptedExtensions of a TLS 1.3 server.
+	HandshakeEventEncryptedExtensions
+
+	// HandshakeEventCertificate is the reception of a Certificate or
+	// CompressedCertificate message, and HandshakeEventCertificateDecompressed
+	// the decompression of the latter into the former.
+	HandshakeEventCertificate
+	HandshakeEventCertificateDecompressed
+
+	// HandshakeEventApplicationSettings is the exchange of Application-Layer
+	// Protocol Settings (ALPS) with the server. Its Data is the settings of
+	// the server.
+	HandshakeEventApplicationSettings
+
+	// HandshakeEventECHAccepted and HandshakeEventECHRejected are the
+	// acceptance and the rejection by the server of Encrypted Client Hello.
+	HandshakeEventECHAccepted
+	HandshakeEventECHRejected
+
+	// HandshakeEventFinishedSent and HandshakeEventFinishedReceived are the
+	// sending and the reception of a Finished message.
+	HandshakeEventFinishedSent
+	HandshakeEventFinishedReceived
+
+	// HandshakeEventSessionTicket is the reception of a NewSessionTicket
+	// message, during the handshake in TLS 1.2, or after it in TLS 1.3.
+	HandshakeEventSessionTicket
+
+	// HandshakeEventMessageSent and HandshakeEventMessageReceived are the
+	// sending and the reception of any other handshake message.
+	HandshakeEventMessageSent
+	HandshakeEventMessageReceived
+
+	// HandshakeEventDone is the end of a handshake. Its Err is the error of
+	// the handshake, if any.
+	HandshakeEventDone
+)
+
+var handshakeEventTypeNames = []string{
+	HandshakeEventStart:                   "Start",
+	HandshakeEventClientHelloBuilt:        "ClientHelloBuilt",
+	HandshakeEventClientHelloSent:         "ClientHelloSent",
+	HandshakeEventHelloRetryRequest:       "HelloRetryRequest",
+	HandshakeEventServerHello:             "ServerHello",
+	HandshakeEventEncryptedExtensions:     "EncryptedExtensions",
+	HandshakeEventCertificate:             "Certificate",
+	HandshakeEventCertificateDecompressed: "CertificateDecompressed",
+	HandshakeEventApplicationSettings:     "ApplicationSettings",
+	HandshakeEventECHAccepted:             "ECHAccepted",
+	HandshakeEventECHRejected:             "ECHRejected",
+	HandshakeEventFinishedSent:            "FinishedSent",
+	HandshakeEventFinishedReceived:        "FinishedReceived",
+	HandshakeEventSessionTicket:           "SessionTicket",
+	HandshakeEventMessageSent:             "MessageSent",
+	HandshakeEventMessageReceived:         "MessageReceived",
+	HandshakeEventDone:                    "Done",
+}
+
+func (t HandshakeEventType) String() string {
+	if t >= 0 && int(t) < len(handshakeEventTypeNames) {
+		return handshakeEventTypeNames[t]
+	}
+	return "HandshakeEventType(" + strconv.Itoa(int(t)) + ")"
+}
+
+// HandshakeEvent is an event of a handshake.
+type HandshakeEvent struct {
+	Type HandshakeEventType
+
+	// Time is when the event happened, and Elapsed the time since the last
+	// HandshakeEventStart, or zero before it.
+	Time    time.Time
+	Elapsed time.Duration
+
+	// Data is the handshake message of the event, with its header, if any,
+	// unless documented otherwise. It must not be modified.
+	Data []byte
+
+	// Err is the error of HandshakeEventDone.
+	Err error
+}
+
+// HandshakeTracer is called for the events of the handshakes of a connection,
+// see Config.HandshakeTracer and UConn.SetHandshakeTracer. It is called
+// synchronously while the connection is locked, so it must not call its
+// methods.
+type HandshakeTracer interface {
+	TraceHandshake(event HandshakeEvent)
+}
+
+// HandshakeTracerFunc is a function which implements HandshakeTracer.
+type HandshakeTracerFunc func(event HandshakeEvent)
+
+func (f HandshakeTracerFunc) TraceHandshake(event HandshakeEvent) {
+	f(event)
+}
+
+// SetHandshakeTracer sets the tracer of the handshakes of the connection,
+// overriding Config.HandshakeTracer.
+func (uconn *UConn) SetHandshakeTracer(tracer HandshakeTracer) {
+	uconn.utls.handshakeTracer = tracer
+}
+
+func (c *Conn) handshakeTracer() HandshakeTracer {
+	if c.utls.handshakeTracer != nil {
+		return c.utls.handshakeTracer
+	}
+	return c.config.HandshakeTracer
+}
+
+// traceHandshake reports an event to the tracer of the connection, if any.
+func (c *Conn) traceHandshake(typ HandshakeEventType, data []byte, err error) {
+	tracer := c.handshakeTracer()
+	if tracer == nil {
+		return
+	}
+	now := time.Now()
+	if typ == HandshakeEventStart {
+		c.utls.handshakeStart = now
+	}
+	event := HandshakeEvent{Type: typ, Time: now, Data: data, Err: err}
+	if !c.utls.handshakeStart.IsZero() {
+		event.Elapsed = now.Sub(c.utls.handshakeStart)
+	}
+	tracer.TraceHandshake(event)
+}
+
+// traceHandshakeMsg reports an event about msg, which is only marshaled if
+// the connection has a tracer.
+func (c *Conn) traceHandshakeMsg(typ HandshakeEventType, msg handshakeMessage) {
+	if c.handshakeTracer() == nil {
+		return
+	}
+	data, err := msg.marshal()
+	if err != nil {
+		return
+	}
+	c.traceHandshake(typ, data, nil)
+}
+
+// traceHandshakeMessage reports the sending or the reception of a handshake
+// message, of which data is the encoding.
+func (c *Conn) traceHandshakeMessage(msg handshakeMessage, data []byte, sent bool) {
+	if c.handshakeTracer() == nil {
+		return
+	}
+	typ := HandshakeEventMessageReceived
+	if sent {
+		typ = HandshakeEventMessageSent
+	}
+	switch msg := msg.(type) {
+	case *clientHelloMsg:
+		if sent {
+			typ = HandshakeEventClientHelloSent
+		}
+	case *serverHelloMsg:
+		if !sent && bytes.Equal(msg.random, helloRetryRequestRandom) {
+			typ = HandshakeEventHelloRetryRequest
+		} else if !sent {
+			typ = HandshakeEventServerHello
+		}
+	case *encryptedExtensionsMsg:
+		if !sent {
+			typ = HandshakeEventEncryptedExtensions
+		}
+	case *certificateMsg, *certificateMsgTLS13, *utlsCompressedCertificateMsg:
+		if !sent {
+			typ = HandshakeEventCertificate
+		}
+	case *finishedMsg:
+		if sent {
+			typ = HandshakeEventFinishedSent
+		} else {
+			typ = HandshakeEventFinishedReceived
+		}
+	case *newSessionTicketMsg, *newSessionTicketMsgTLS13:
+		if !sent {
+			typ = HandshakeEventSessionTicket
+		}
+	}
+	c.traceHandshake(typ, data, nil)
+}
+
+// traceECH reports whether the server accepted Encrypted Client Hello.
+func (c *Conn) traceECH() {
+	if c.echAccepted {
+		c.traceHandshake(HandshakeEventECHAccepted, nil, nil)
+	} else {
+		c.traceHandshake(HandshakeEventECHRejected, nil, nil)
+	}
+}
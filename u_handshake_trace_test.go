@@ -0,0 +1,148 @@
+// Copyright 2026 uTLS Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tls
+
+import (
+	"slices"
+	"sync"
+	"testing"
+)
+
+// testHandshakeTracer records the events of handshakes.
+type testHandshakeTracer struct {
+	mu     sync.Mutex
+	events []HandshakeEvent
+}
+
+func (tr *testHandshakeTracer) TraceHandshake(event HandshakeEvent) {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	tr.events = append(tr.events, event)
+}
+
+func (tr *testHandshakeTracer) types() []HandshakeEventType {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	var types []HandshakeEventType
+	for _, event := range tr.events {
+		types = append(types, event.Type)
+	}
+	return types
+}
+
+func TestHandshakeTracerTLS13(t *testing.T) {
+	clientTracer, serverTracer := &testHandshakeTracer{}, &testHandshakeTracer{}
+	clientConfig, serverConfig := testConfig.Clone(), testConfig.Clone()
+	clientConfig.HandshakeTracer = clientTracer
+	serverConfig.HandshakeTracer = serverTracer
+	serverConfig.MinVersion = VersionTLS13
+
+	chrome, err := utlsIdToSpec(HelloChrome_Auto)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := testUtlsHandshake(t, clientConfig, serverConfig, &chrome); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []HandshakeEventType{
+		HandshakeEventStart,
+		HandshakeEventClientHelloBuilt,
+		HandshakeEventClientHelloSent,
+		HandshakeEventServerHello,
+		HandshakeEventEncryptedExtensions,
+		HandshakeEventCertificate,
+		HandshakeEventMessageReceived, // CertificateVerify
+		HandshakeEventFinishedReceived,
+		HandshakeEventFinishedSent,
+		HandshakeEventDone,
+	}
+	got := clientTracer.types()
+	// The server sends session tickets after the handshake.
+	got = slices.DeleteFunc(got, func(typ HandshakeEventType) bool { return typ == HandshakeEventSessionTicket })
+	if !slices.Equal(got, want) {
+		t.Errorf("got client events %v, want %v", got, want)
+	}
+
+	for i, event := range clientTracer.events {
+		if i > 0 && event.Time.Before(clientTracer.events[i-1].Time) {
+			t.Errorf("event %v is before the previous one", event.Type)
+		}
+		if event.Type == HandshakeEventClientHelloSent && (len(event.Data) == 0 || event.Data[0] != typeClientHello) {
+			t.Errorf("unexpected ClientHello %x", event.Data)
+		}
+		if event.Type == HandshakeEventDone && (event.Err != nil || event.Elapsed <= 0) {
+			t.Errorf("unexpected Done event %+v", event)
+		}
+	}
+
+	serverTypes := serverTracer.types()
+	if serverTypes[0] != HandshakeEventStart || serverTypes[1] != HandshakeEventMessageReceived ||
+		!slices.Contains(serverTypes, HandshakeEventFinishedReceived) || serverTypes[len(serverTypes)-1] != HandshakeEventDone {
+		t.Errorf("unexpected server events %v", serverTypes)
+	}
+}
+
+func TestHandshakeTracerHelloRetryRequest(t *testing.T) {
+	tracer := &testHandshakeTracer{}
+	clientConfig, serverConfig := testConfig.Clone(), testConfig.Clone()
+	clientConfig.HandshakeTracer = tracer
+	clientConfig.CurvePreferences = []CurveID{X25519, CurveP256}
+	serverConfig.CurvePreferences = []CurveID{CurveP256}
+	if _, _, err := testHandshake(t, clientConfig, serverConfig); err != nil {
+		t.Fatal(err)
+	}
+
+	got := tracer.types()
+	i := slices.Index(got, HandshakeEventHelloRetryRequest)
+	if i < 0 || got[i+1] != HandshakeEventClientHelloSent {
+		t.Errorf("unexpected events %v", got)
+	}
+	if n := len(slices.DeleteFunc(slices.Clone(got), func(typ HandshakeEventType) bool { return typ != HandshakeEventClientHelloSent })); n != 2 {
+		t.Errorf("got %d ClientHelloSent events, want 2", n)
+	}
+}
+
+func TestHandshakeTracerTLS12(t *testing.T) {
+	tracer := &testHandshakeTracer{}
+	clientConfig := testConfig.Clone()
+	clientConfig.MaxVersion = VersionTLS12
+	clientConfig.ClientSessionCache = NewLRUClientSessionCache(1)
+
+	c, s := localPipe(t)
+	done := make(chan error, 1)
+	go func() {
+		server := Server(s, testConfig.Clone())
+		done <- server.Handshake()
+		server.Close()
+	}()
+	client := UClient(c, clientConfig, HelloGolang, false, false)
+	client.SetHandshakeTracer(tracer)
+	defer client.Close()
+	if err := client.Handshake(); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+
+	want := []HandshakeEventType{
+		HandshakeEventStart,
+		HandshakeEventClientHelloBuilt,
+		HandshakeEventClientHelloSent,
+		HandshakeEventServerHello,
+		HandshakeEventCertificate,
+		HandshakeEventMessageReceived, // ServerKeyExchange
+		HandshakeEventMessageReceived, // ServerHelloDone
+		HandshakeEventMessageSent,     // ClientKeyExchange
+		HandshakeEventFinishedSent,
+		HandshakeEventSessionTicket,
+		HandshakeEventFinishedReceived,
+		HandshakeEventDone,
+	}
+	if got := tracer.types(); !slices.Equal(got, want) {
+		t.Errorf("got events %v, want %v", got, want)
+	}
+}
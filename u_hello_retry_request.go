@@ -0,0 +1,436 @@
+// Copyright 2026 uTLS Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tls
+
+import (
+	"errors"
+	"hash"
+	"io"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/cryptobyte"
+)
+
+// HelloRetryRequestSpec describes how a ClientHelloSpec is turned into the
+// second ClientHello after a HelloRetryRequest (see RFC 8446, Section 4.1.4).
+//
+// Whatever the spec, the key_share extension gets a new share for the group
+// selected by the server, early_data is removed, and the server's cookie is
+// echoed, as the RFC requires. The other extensions keep their order.
+type HelloRetryRequestSpec struct {
+	// CookieAfter is the type of the extension that the cookie extension is
+	// inserted after, when the ClientHelloSpec doesn't have a CookieExtension.
+	// If zero, or if there is no such extension, the cookie extension is
+	// inserted at a random position among the extensions that
+	// ShuffleChromeTLSExtensions would shuffle.
+	CookieAfter uint16
+
+	// RegenerateGREASE draws new GREASE values for the second ClientHello.
+	// Browsers keep those of the first one.
+	RegenerateGREASE bool
+
+	// KeepPaddingLength keeps the padding extension as long as in the first
+	// ClientHello, instead of recomputing it for the second one.
+	KeepPaddingLength bool
+
+	// OmitPSK drops the pre_shared_key extension from the second ClientHello.
+	// Otherwise, its ticket age and binders are updated, and identities whose
+	// hash doesn't match the selected cipher suite are removed.
+	OmitPSK bool
+
+	// KeyShares is how the key_share extension is rebuilt around the new
+	// share. See HRRKeyShares.
+	KeyShares HRRKeyShares
+}
+
+// HRRKeyShares controls which key shares of the first ClientHello are kept in
+// the second one, next to the new share for the group selected by the server.
+type HRRKeyShares int
+
+const (
+	// HRRKeySharesSelectedGroup sends the new share alone, as RFC 8446
+	// requires and browsers do.
+	HRRKeySharesSelectedGroup HRRKeyShares = iota
+
+	// HRRKeySharesKeepGREASE keeps the GREASE shares of the first ClientHello
+	// with their values, before the new share.
+	HRRKeySharesKeepGREASE
+
+	// HRRKeySharesRegenerateGREASE keeps the GREASE shares of the first
+	// ClientHello with new GREASE values, before the new share. With
+	// RegenerateGREASE, those are the new GREASE group of supported_groups.
+	HRRKeySharesRegenerateGREASE
+
+	// HRRKeySharesKeepAll keeps all the shares of the first ClientHello, in
+	// their order, and appends the new share. Servers which enforce RFC 8446
+	// reject such a ClientHello.
+	HRRKeySharesKeepAll
+)
+
+// BoringSSL only adds its GREASE key share to the first ClientHello, and NSS
+// only sends the share for the selected group after a HelloRetryRequest.
+var (
+	// BoringSSL keeps the position of the cookie in its permutation of the
+	// extensions, which Chrome shuffles since version 106.
+	helloRetryRequestChromeShuffled = &HelloRetryRequestSpec{KeyShares: HRRKeySharesSelectedGroup}
+	// Without permutation, BoringSSL sends the cookie after supported_versions.
+	helloRetryRequestBoringSSL = &HelloRetryRequestSpec{CookieAfter: ExtensionSupportedVersions, KeyShares: HRRKeySharesSelectedGroup}
+	// NSS sends the cookie after signature_algorithms.
+	helloRetryRequestNSS = &HelloRetryRequestSpec{CookieAfter: ExtensionSignatureAlgorithms, KeyShares: HRRKeySharesSelectedGroup}
+)
+
+// helloRetryRequestSpecForID returns the HelloRetryRequestSpec of the client
+// mimicked by id, or nil if it isn't known.
+func helloRetryRequestSpecForID(id ClientHelloID) *HelloRetryRequestSpec {
+	switch id.Client {
+	case helloFirefox:
+		return helloRetryRequestNSS
+	case helloChrome:
+		if id.Version == helloAutoVers || leadingVersion(id.Version) >= 106 {
+			return helloRetryRequestChromeShuffled
+		}
+		return helloRetryRequestBoringSSL
+	case helloEdge, helloOpera, hello360, helloQQ, helloAndroid, helloSafari, helloIOS, helloIPad:
+		return helloRetryRequestBoringSSL
+	}
+	return nil
+}
+
+// leadingVersion parses the major version at the start of a
+// ClientHelloID.Version such as "115_PQ", or returns zero.
+func leadingVersion(version string) int {
+	end := strings.IndexFunc(version, func(r rune) bool { return r < '0' || r > '9' })
+	if end >= 0 {
+		version = version[:end]
+	}
+	v, _ := strconv.Atoi(version)
+	return v
+}
+
+// extensionType returns the type of ext as marshaled, or zero.
+func extensionType(ext TLSExtension) uint16 {
+	if ext.Len() < 2 {
+		return 0
+	}
+	b := make([]byte, ext.Len())
+	if n, _ := ext.Read(b); n < 2 {
+		return 0
+	}
+	return uint16(b[0])<<8 | uint16(b[1])
+}
+
+// shuffledRange returns the bounds of the extensions which
+// ShuffleChromeTLSExtensions may move, skipping the leading GREASE and the
+// trailing GREASE, padding and pre_shared_key extensions.
+func shuffledRange(exts []TLSExtension) (lo, hi int) {
+	fixed := func(ext TLSExtension) bool {
+		switch ext.(type) {
+		case *UtlsGREASEExtension, *UtlsPaddingExtension, PreSharedKeyExtension:
+			return true
+		}
+		return false
+	}
+	for lo < len(exts) && fixed(exts[lo]) {
+		lo++
+	}
+	hi = len(exts)
+	for hi > lo && fixed(exts[hi-1]) {
+		hi--
+	}
+	return lo, hi
+}
+
+// insertCookie adds a CookieExtension to uconn.Extensions where spec says.
+func (uconn *UConn) insertCookie(spec *HelloRetryRequestSpec, cookie []byte) error {
+	exts := uconn.Extensions
+	lo, hi := shuffledRange(exts)
+
+	index := -1
+	if spec.CookieAfter != 0 {
+		for i, ext := range exts {
+			if extensionType(ext) == spec.CookieAfter {
+				index = i + 1
+				break
+			}
+		}
+	}
+	if index < 0 {
+		p, err := newPRNG()
+		if err != nil {
+			return err
+		}
+		index = lo + p.Intn(hi-lo+1)
+	}
+
+	uconn.Extensions = append(exts[:index:index], append([]TLSExtension{&CookieExtension{Cookie: cookie}}, exts[index:]...)...)
+	return nil
+}
+
+// regenerateGREASE replaces the GREASE values set by ApplyPreset with new ones.
+func (uconn *UConn) regenerateGREASE() error {
+	if err := uconn.newGREASESeed(); err != nil {
+		return err
+	}
+	hello := uconn.HandshakeState.Hello
+	for i := range hello.CipherSuites {
+		if isGREASEUint16(hello.CipherSuites[i]) {
+			hello.CipherSuites[i] = GetBoringGREASEValue(uconn.greaseSeed, ssl_grease_cipher)
+		}
+	}
+
+	greaseExtensionsSeen := 0
+	for _, e := range uconn.Extensions {
+		switch ext := e.(type) {
+		case *UtlsGREASEExtension:
+			if greaseExtensionsSeen == 0 {
+				ext.Value = GetBoringGREASEValue(uconn.greaseSeed, ssl_grease_extension1)
+			} else {
+				ext.Value = GetBoringGREASEValue(uconn.greaseSeed, ssl_grease_extension2)
+			}
+			greaseExtensionsSeen++
+		case *SupportedCurvesExtension:
+			for i := range ext.Curves {
+				if isGREASEUint16(uint16(ext.Curves[i])) {
+					ext.Curves[i] = CurveID(GetBoringGREASEValue(uconn.greaseSeed, ssl_grease_group))
+				}
+			}
+		case *KeyShareExtension:
+			for i := range ext.KeyShares {
+				if isGREASEUint16(uint16(ext.KeyShares[i].Group)) {
+					ext.KeyShares[i].Group = CurveID(GetBoringGREASEValue(uconn.greaseSeed, ssl_grease_group))
+				}
+			}
+		case *SupportedVersionsExtension:
+			for i := range ext.Versions {
+				if isGREASEUint16(ext.Versions[i]) {
+					ext.Versions[i] = GetBoringGREASEValue(uconn.greaseSeed, ssl_grease_version)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// helloRetryRequestKeyShares returns the key shares of the second ClientHello,
+// from those of the first one and the new one for the selected group.
+func (uconn *UConn) helloRetryRequestKeyShares(spec *HelloRetryRequestSpec, first, selected []KeyShare) ([]KeyShare, error) {
+	var shares []KeyShare
+	switch spec.KeyShares {
+	case HRRKeySharesSelectedGroup:
+	case HRRKeySharesKeepGREASE, HRRKeySharesRegenerateGREASE:
+		for _, ks := range first {
+			if !isGREASEUint16(uint16(ks.Group)) {
+				continue
+			}
+			if spec.KeyShares == HRRKeySharesRegenerateGREASE {
+				group, err := uconn.newGREASEGroup(ks.Group)
+				if err != nil {
+					return nil, err
+				}
+				ks.Group = group
+			}
+			shares = append(shares, ks)
+		}
+	case HRRKeySharesKeepAll:
+		shares = append(shares, first...)
+	default:
+		return nil, errors.New("tls: unknown HelloRetryRequestSpec.KeyShares")
+	}
+	return append(shares, selected...), nil
+}
+
+// newGREASEGroup returns a random GREASE group other than old.
+func (uconn *UConn) newGREASEGroup(old CurveID) (CurveID, error) {
+	var seed [ssl_grease_last_index]uint16
+	var b [2]byte
+	if _, err := io.ReadFull(uconn.config.rand(), b[:]); err != nil {
+		return 0, errors.New("tls: short read from Rand: " + err.Error())
+	}
+	seed[ssl_grease_group] = uint16(b[0])<<8 | uint16(b[1])
+	group := CurveID(GetBoringGREASEValue(seed, ssl_grease_group))
+	if group == old {
+		group ^= 0x1010
+	}
+	return group, nil
+}
+
+// applyHelloRetryRequest updates uconn.Extensions after a HelloRetryRequest,
+// once crypto/tls has updated hs.hello, and marshals the second ClientHello
+// into hs.hello.
+func (hs *clientHandshakeStateTLS13) applyHelloRetryRequest() error {
+	uconn := hs.uconn
+	spec := uconn.helloRetryRequestSpec
+	if spec == nil {
+		spec = &HelloRetryRequestSpec{}
+	}
+
+	if len(hs.hello.pskIdentities) > 0 && hs.echContext != nil {
+		return errors.New("uTLS does not support reprocessing of PSK key triggered by HelloRetryRequest with Encrypted Client Hello")
+	}
+
+	keyShareExtFound := false
+	exts := uconn.Extensions[:0]
+	for _, ext := range uconn.Extensions {
+		switch ext := ext.(type) {
+		case *KeyShareExtension:
+			shares, err := uconn.helloRetryRequestKeyShares(spec, ext.KeyShares, keyShares(hs.hello.keyShares).ToPublic())
+			if err != nil {
+				return err
+			}
+			ext.KeyShares = shares
+			keyShareExtFound = true
+		case *GenericExtension:
+			if ext.Id == ExtensionEarlyData {
+				continue
+			}
+		}
+		exts = append(exts, ext)
+	}
+	uconn.Extensions = exts
+	if !keyShareExtFound {
+		return errors.New("uTLS: received HelloRetryRequest, but keyshare not found among client's " +
+			"uconn.Extensions")
+	}
+
+	if len(hs.serverHello.cookie) > 0 {
+		// serverHello specified a cookie, let's echo it
+		cookieFound := false
+		for _, ext := range uconn.Extensions {
+			if ks, ok := ext.(*CookieExtension); ok {
+				ks.Cookie = hs.serverHello.cookie
+				cookieFound = true
+			}
+		}
+		if !cookieFound {
+			if err := uconn.insertCookie(spec, hs.serverHello.cookie); err != nil {
+				return err
+			}
+		}
+	}
+
+	if spec.RegenerateGREASE {
+		if err := uconn.regenerateGREASE(); err != nil {
+			return err
+		}
+	}
+
+	for _, ext := range uconn.Extensions {
+		if padding, ok := ext.(*UtlsPaddingExtension); ok && spec.KeepPaddingLength {
+			paddingLen, willPad := padding.PaddingLen, padding.WillPad
+			getPaddingLen := padding.GetPaddingLen
+			padding.GetPaddingLen = func(int) (int, bool) { return paddingLen, willPad }
+			defer func() { padding.GetPaddingLen = getPaddingLen }()
+		}
+	}
+
+	binderKeys, err := hs.updatePSKExtensionAfterHRR(spec)
+	if err != nil {
+		return err
+	}
+
+	if err := uconn.MarshalClientHelloNoECH(); err != nil {
+		return err
+	}
+	hs.hello.original = uconn.HandshakeState.Hello.Raw
+
+	if len(binderKeys) == 0 {
+		return nil
+	}
+	// The binders cover the second ClientHello as marshaled by uTLS, after
+	// the hash of the first one and the HelloRetryRequest, which is what
+	// hs.transcript holds at this point.
+	if err := computeAndUpdatePSKBinders(hs.hello, binderKeys, func(tls13Hash) (hash.Hash, error) {
+		return cloneHash(hs.transcript, hs.suite.hash), nil
+	}); err != nil {
+		return err
+	}
+	if err := writePSKBinders(hs.hello.original, hs.hello.pskBinders); err != nil {
+		return err
+	}
+	uconn.HandshakeState.Hello.PskIdentities = pskIdentities(hs.hello.pskIdentities).ToPublic()
+	uconn.HandshakeState.Hello.PskBinders = hs.hello.pskBinders
+	return nil
+}
+
+// updatePSKExtensionAfterHRR makes the pre_shared_key extension match the
+// identities crypto/tls kept in hs.hello, with placeholder binders, and
+// returns the keys of the binders to compute. Extensions which don't carry
+// real binders, such as FakePreSharedKeyExtension, are left alone.
+func (hs *clientHandshakeStateTLS13) updatePSKExtensionAfterHRR(spec *HelloRetryRequestSpec) ([]pskBinderKey, error) {
+	uconn := hs.uconn
+	var keys []pskBinderKey
+	for i, ext := range uconn.Extensions {
+		switch ext := ext.(type) {
+		case *UtlsPreSharedKeyExtension:
+			if ext.Len() == 0 {
+				continue
+			}
+			if len(hs.hello.pskIdentities) > 0 && !spec.OmitPSK {
+				ext.Identities = pskIdentities(hs.hello.pskIdentities).ToPublic()
+				ext.Binders = [][]byte{make([]byte, hs.suite.hash.Size())}
+				ext.cachedLength = nil
+				keys = []pskBinderKey{{hs.suite, hs.binderKey}}
+			}
+		case *ExternalPreSharedKeyExtension:
+			if ext.Len() == 0 {
+				continue
+			}
+			if len(hs.hello.pskIdentities) > 0 && !spec.OmitPSK {
+				// updateExternalPSKsAfterHRR kept the resumption identity
+				// first, if its hash matches, then the matching offers.
+				ext.offers = hs.c.utls.externalPSKs
+				if len(hs.hello.pskIdentities) > len(ext.offers) {
+					ext.resumption.Identities = pskIdentities(hs.hello.pskIdentities[:1]).ToPublic()
+				} else {
+					ext.resumption = PreSharedKeyCommon{}
+				}
+				ext.binders = ext.placeholderBinders()
+				keys = ext.bindersKeys()
+			}
+		default:
+			continue
+		}
+
+		if keys == nil {
+			uconn.Extensions = append(uconn.Extensions[:i:i], uconn.Extensions[i+1:]...)
+			hs.hello.pskIdentities, hs.hello.pskBinders = nil, nil
+			hs.c.utls.externalPSKs = nil
+			uconn.HandshakeState.Hello.PskIdentities = nil
+			uconn.HandshakeState.Hello.PskBinders = nil
+			return nil, nil
+		}
+		hs.hello.pskBinders = make([][]byte, len(keys))
+		for j, k := range keys {
+			hs.hello.pskBinders[j] = make([]byte, k.suite.hash.Size())
+		}
+		return keys, nil
+	}
+	return nil, nil
+}
+
+// writePSKBinders overwrites the binders at the end of a marshaled
+// ClientHello, which must have the same lengths.
+func writePSKBinders(raw []byte, binders [][]byte) error {
+	bindersLen := 2
+	for _, binder := range binders {
+		bindersLen += 1 + len(binder)
+	}
+	if len(raw) < bindersLen {
+		return errors.New("tls: internal error: failed to update binders")
+	}
+	b := cryptobyte.NewFixedBuilder(raw[:len(raw)-bindersLen])
+	b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+		for _, binder := range binders {
+			b.AddUint8LengthPrefixed(func(b *cryptobyte.Builder) {
+				b.AddBytes(binder)
+			})
+		}
+	})
+	if out, err := b.Bytes(); err != nil || len(out) != len(raw) {
+		return errors.New("tls: internal error: failed to update binders")
+	}
+	return nil
+}
@@ -0,0 +1,253 @@
+// Copyright 2026 uTLS Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tls
+
+import (
+	"bytes"
+	"io"
+	"slices"
+	"testing"
+)
+
+// readHandshakeRecord reads records from c until a handshake one, skipping
+// the dummy ChangeCipherSpec, and returns its body.
+func readHandshakeRecord(t *testing.T, c io.Reader) []byte {
+	t.Helper()
+	for {
+		header := make([]byte, recordHeaderLen)
+		if _, err := io.ReadFull(c, header); err != nil {
+			t.Fatal(err)
+		}
+		body := make([]byte, int(header[3])<<8|int(header[4]))
+		if _, err := io.ReadFull(c, body); err != nil {
+			t.Fatal(err)
+		}
+		if recordType(header[0]) == recordTypeHandshake {
+			return body
+		}
+	}
+}
+
+// captureHelloRetryRequest answers the first ClientHello of id with a
+// HelloRetryRequest selecting P-384 and carrying a cookie, and returns both
+// ClientHellos.
+func captureHelloRetryRequest(t *testing.T, id ClientHelloID, spec *ClientHelloSpec) (ch1, ch2 *clientHelloMsg) {
+	c, s := localPipe(t)
+	config := testConfig.Clone()
+	config.ServerName = "example.golang"
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		uconn := UClient(c, config, id, false, false)
+		if spec != nil {
+			if err := uconn.ApplyPreset(spec); err != nil {
+				t.Error(err)
+				c.Close()
+				return
+			}
+		}
+		uconn.Handshake()
+		c.Close()
+	}()
+	defer func() { <-done }()
+	defer s.Close()
+
+	ch1 = new(clientHelloMsg)
+	if !ch1.unmarshal(readHandshakeRecord(t, s)) {
+		t.Fatal("failed to parse the first ClientHello")
+	}
+	hrr := &serverHelloMsg{
+		vers:             VersionTLS12,
+		random:           helloRetryRequestRandom,
+		sessionId:        ch1.sessionId,
+		cipherSuite:      TLS_AES_128_GCM_SHA256,
+		supportedVersion: VersionTLS13,
+		selectedGroup:    CurveP384,
+		cookie:           []byte("cookie"),
+	}
+	hrrBytes, err := hrr.marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	record := append([]byte{byte(recordTypeHandshake), 3, 3, byte(len(hrrBytes) >> 8), byte(len(hrrBytes))}, hrrBytes...)
+	if _, err := s.Write(record); err != nil {
+		t.Fatal(err)
+	}
+	ch2 = new(clientHelloMsg)
+	if !ch2.unmarshal(readHandshakeRecord(t, s)) {
+		t.Fatal("failed to parse the second ClientHello")
+	}
+	return ch1, ch2
+}
+
+func checkSecondClientHello(t *testing.T, ch1, ch2 *clientHelloMsg) {
+	t.Helper()
+	if !bytes.Equal(ch2.cookie, []byte("cookie")) {
+		t.Errorf("cookie = %q, want %q", ch2.cookie, "cookie")
+	}
+	if len(ch2.keyShares) != 1 || ch2.keyShares[0].group != CurveP384 {
+		t.Errorf("second ClientHello key shares are not a single P-384 share")
+	}
+	if !bytes.Equal(ch1.random, ch2.random) || !bytes.Equal(ch1.sessionId, ch2.sessionId) {
+		t.Errorf("random or session ID changed in the second ClientHello")
+	}
+	// Browsers keep their GREASE values.
+	if !slices.Equal(ch1.cipherSuites, ch2.cipherSuites) || !slices.Equal(ch1.supportedCurves, ch2.supportedCurves) ||
+		!slices.Equal(ch1.supportedVersions, ch2.supportedVersions) {
+		t.Errorf("cipher suites, groups or versions changed in the second ClientHello")
+	}
+	// Apart from the cookie, the extensions keep their order.
+	if exts := slices.DeleteFunc(slices.Clone(ch2.extensions), func(ext uint16) bool {
+		return ext == ExtensionCookie
+	}); !slices.Equal(ch1.extensions, exts) {
+		t.Errorf("extensions changed in the second ClientHello:\n%v\n%v", ch1.extensions, ch2.extensions)
+	}
+}
+
+func TestHelloRetryRequestProfiles(t *testing.T) {
+	for _, test := range []struct {
+		id          ClientHelloID
+		cookieAfter uint16
+	}{
+		{HelloFirefox_Auto, ExtensionSignatureAlgorithms},
+		{HelloSafari_Auto, ExtensionSupportedVersions},
+		{HelloChrome_102, ExtensionSupportedVersions},
+		{HelloChrome_Auto, 0},
+	} {
+		t.Run(test.id.Str(), func(t *testing.T) {
+			ch1, ch2 := captureHelloRetryRequest(t, test.id, nil)
+			checkSecondClientHello(t, ch1, ch2)
+
+			i := slices.Index(ch2.extensions, ExtensionCookie)
+			if test.cookieAfter != 0 {
+				if i < 1 || ch2.extensions[i-1] != test.cookieAfter {
+					t.Errorf("cookie at %d is not after extension %d: %v", i, test.cookieAfter, ch2.extensions)
+				}
+				return
+			}
+			// Chrome places it among the permuted extensions, between the
+			// two GREASE extensions.
+			lastGREASE := len(ch2.extensions) - 1
+			for lastGREASE > 0 && !isGREASEUint16(ch2.extensions[lastGREASE]) {
+				lastGREASE--
+			}
+			if i < 1 || i > lastGREASE {
+				t.Errorf("cookie at %d is outside the permuted extensions: %v", i, ch2.extensions)
+			}
+		})
+	}
+}
+
+func TestHelloRetryRequestSpecOverride(t *testing.T) {
+	spec, err := utlsIdToSpec(HelloChrome_102)
+	if err != nil {
+		t.Fatal(err)
+	}
+	spec.HelloRetryRequest = &HelloRetryRequestSpec{CookieAfter: ExtensionALPN}
+	_, ch2 := captureHelloRetryRequest(t, HelloCustom, &spec)
+	i := slices.Index(ch2.extensions, ExtensionCookie)
+	if i < 1 || ch2.extensions[i-1] != ExtensionALPN {
+		t.Errorf("cookie at %d is not after ALPN: %v", i, ch2.extensions)
+	}
+}
+
+func TestHelloRetryRequestResumption(t *testing.T) {
+	clientConfig := testConfig.Clone()
+	clientConfig.ClientSessionCache = NewLRUClientSessionCache(1)
+	clientConfig.OmitEmptyPsk = true
+	serverConfig := testConfig.Clone()
+	serverConfig.CurvePreferences = []CurveID{CurveP384}
+
+	chrome := func() *ClientHelloSpec {
+		spec, err := utlsIdToSpec(HelloChrome_112_PSK)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return &spec
+	}
+
+	if _, _, err := testUtlsHandshake(t, clientConfig, serverConfig, chrome()); err != nil {
+		t.Fatal(err)
+	}
+	serverState, clientState, err := testUtlsHandshake(t, clientConfig, serverConfig, chrome())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !serverState.testingOnlyDidHRR {
+		t.Error("expected a HelloRetryRequest")
+	}
+	if !serverState.DidResume || !clientState.DidResume {
+		t.Error("session was not resumed after a HelloRetryRequest")
+	}
+
+	// With OmitPSK, the second ClientHello does a full handshake.
+	spec := chrome()
+	spec.HelloRetryRequest = &HelloRetryRequestSpec{OmitPSK: true}
+	serverState, clientState, err = testUtlsHandshake(t, clientConfig, serverConfig, spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !serverState.testingOnlyDidHRR || clientState.DidResume {
+		t.Errorf("expected a full handshake after a HelloRetryRequest, got DidHRR=%v DidResume=%v",
+			serverState.testingOnlyDidHRR, clientState.DidResume)
+	}
+}
+
+func TestExternalPSKHelloRetryRequestUTLS(t *testing.T) {
+	clientConfig, serverConfig := testExternalPSKConfigs()
+	serverConfig.CurvePreferences = []CurveID{CurveP384}
+	serverState, clientState, err := testUtlsHandshake(t, clientConfig, serverConfig, testExternalPSKSpec(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !serverState.testingOnlyDidHRR {
+		t.Fatal("expected a HelloRetryRequest")
+	}
+	checkExternalPSKStates(t, serverState, clientState, "shared")
+}
+
+func TestHelloRetryRequestKeyShares(t *testing.T) {
+	for _, test := range []struct {
+		name      string
+		keyShares HRRKeyShares
+		grease    bool
+		others    bool
+	}{
+		{"SelectedGroup", HRRKeySharesSelectedGroup, false, false},
+		{"KeepGREASE", HRRKeySharesKeepGREASE, true, false},
+		{"RegenerateGREASE", HRRKeySharesRegenerateGREASE, true, false},
+		{"KeepAll", HRRKeySharesKeepAll, true, true},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			spec, err := utlsIdToSpec(HelloChrome_120)
+			if err != nil {
+				t.Fatal(err)
+			}
+			spec.HelloRetryRequest = &HelloRetryRequestSpec{KeyShares: test.keyShares}
+			ch1, ch2 := captureHelloRetryRequest(t, HelloCustom, &spec)
+
+			var want []keyShare
+			for _, ks := range ch1.keyShares {
+				if isGREASEUint16(uint16(ks.group)) && test.grease || !isGREASEUint16(uint16(ks.group)) && test.others {
+					want = append(want, ks)
+				}
+			}
+			if len(ch2.keyShares) != len(want)+1 || ch2.keyShares[len(want)].group != CurveP384 {
+				t.Fatalf("got key shares for %v, want %d shares then P-384", ch2.keyShares, len(want))
+			}
+			for i, ks := range want {
+				got := ch2.keyShares[i]
+				sameGroup := got.group == ks.group
+				if test.keyShares == HRRKeySharesRegenerateGREASE {
+					if sameGroup || !isGREASEUint16(uint16(got.group)) {
+						t.Errorf("GREASE share %d: got group %x, want a new GREASE group", i, got.group)
+					}
+				} else if !sameGroup || !bytes.Equal(got.data, ks.data) {
+					t.Errorf("share %d: got group %x, want %x", i, got.group, ks.group)
+				}
+			}
+		})
+	}
+}
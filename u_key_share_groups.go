@@ -0,0 +1,77 @@
+// Copyright 2026 uTLS Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tls
+
+import (
+	"errors"
+	"io"
+)
+
+// extraKeyShare holds the client secrets of a TLS 1.3 key share for a group
+// which isn't a plain ECDH curve, see isExtraGroup.
+type extraKeyShare interface {
+	curveID() CurveID
+	// sharedKey returns the shared secret for the key share of the server.
+	sharedKey(serverShare []byte) ([]byte, error)
+}
+
+// isExtraGroup reports whether group is an ML-KEM, FFDHE or SM2 group, whose
+// key shares are handled by extraKeyShare.
+func isExtraGroup(group CurveID) bool {
+	return isMLKEMGroup(group) || isFFDHEGroup(group) || group == CurveSM2
+}
+
+// generateExtraKeyShare returns a new client secret for group, and the
+// matching key share. CurveSM2 requires config to enable the ShangMi
+// algorithms.
+func generateExtraKeyShare(config *Config, group CurveID) (extraKeyShare, []byte, error) {
+	rand := config.rand()
+	switch {
+	case isMLKEMGroup(group):
+		k, share, err := generateMLKEMKeyShare(rand, group)
+		if err != nil {
+			return nil, nil, err
+		}
+		return k, share, nil
+	case isFFDHEGroup(group):
+		k, share, err := generateFFDHEKeyShare(rand, group)
+		if err != nil {
+			return nil, nil, err
+		}
+		return k, share, nil
+	case group == CurveSM2:
+		if !config.shangMiEnabled() {
+			return nil, nil, errShangMiDisabled
+		}
+		k, share, err := generateSM2KeyShare(rand)
+		if err != nil {
+			return nil, nil, err
+		}
+		return k, share, nil
+	}
+	return nil, nil, errors.New("tls: internal error: unsupported key share group")
+}
+
+// extraServerShare returns the server key share and the shared secret for
+// the client key share of a group of isExtraGroup.
+func extraServerShare(rand io.Reader, group CurveID, clientShare []byte) (serverShare, sharedKey []byte, err error) {
+	if isFFDHEGroup(group) {
+		return ffdheServerShare(rand, group, clientShare)
+	}
+	if group == CurveSM2 {
+		return sm2ServerShare(rand, clientShare)
+	}
+	return mlkemEncapsulate(rand, group, clientShare)
+}
+
+// extraKeyShare returns the client secrets for group, or nil.
+func (ks *keySharePrivateKeys) extraKeyShare(group CurveID) extraKeyShare {
+	for _, k := range ks.extraKeyShares {
+		if k.curveID() == group {
+			return k
+		}
+	}
+	return nil
+}
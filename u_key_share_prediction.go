@@ -0,0 +1,163 @@
+// Copyright 2026 uTLS Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tls
+
+import (
+	"container/list"
+	"slices"
+	"sync"
+)
+
+// KeySharePredictionCache is a cache of the key exchange group each server
+// selected in a HelloRetryRequest, used by a UConn to send a key share for
+// that group upfront next time, as browsers do. Implementations should
+// expect to be called concurrently from different goroutines.
+type KeySharePredictionCache interface {
+	// Get searches for the group associated with the given key.
+	Get(serverKey string) (group CurveID, ok bool)
+
+	// Put adds the group to the cache with the given key. A group of zero
+	// removes the entry.
+	Put(serverKey string, group CurveID)
+}
+
+// lruKeySharePredictionCache is a KeySharePredictionCache implementation
+// that uses an LRU caching strategy.
+type lruKeySharePredictionCache struct {
+	sync.Mutex
+
+	m        map[string]*list.Element
+	q        *list.List
+	capacity int
+}
+
+type lruKeySharePredictionCacheEntry struct {
+	serverKey string
+	group     CurveID
+}
+
+// NewLRUKeySharePredictionCache returns a [KeySharePredictionCache] with the
+// given capacity that uses an LRU strategy. If capacity is < 1, a default
+// capacity is used instead.
+func NewLRUKeySharePredictionCache(capacity int) KeySharePredictionCache {
+	const defaultKeySharePredictionCacheCapacity = 256
+
+	if capacity < 1 {
+		capacity = defaultKeySharePredictionCacheCapacity
+	}
+	return &lruKeySharePredictionCache{
+		m:        make(map[string]*list.Element),
+		q:        list.New(),
+		capacity: capacity,
+	}
+}
+
+// Put adds the provided (serverKey, group) pair to the cache. If group is zero,
+// the entry corresponding to serverKey is removed from the cache instead.
+func (c *lruKeySharePredictionCache) Put(serverKey string, group CurveID) {
+	c.Lock()
+	defer c.Unlock()
+
+	if elem, ok := c.m[serverKey]; ok {
+		if group == 0 {
+			c.q.Remove(elem)
+			delete(c.m, serverKey)
+		} else {
+			elem.Value.(*lruKeySharePredictionCacheEntry).group = group
+			c.q.MoveToFront(elem)
+		}
+		return
+	}
+	if group == 0 {
+		return
+	}
+
+	if c.q.Len() < c.capacity {
+		entry := &lruKeySharePredictionCacheEntry{serverKey, group}
+		c.m[serverKey] = c.q.PushFront(entry)
+		return
+	}
+
+	elem := c.q.Back()
+	entry := elem.Value.(*lruKeySharePredictionCacheEntry)
+	delete(c.m, entry.serverKey)
+	entry.serverKey = serverKey
+	entry.group = group
+	c.q.MoveToFront(elem)
+	c.m[serverKey] = elem
+}
+
+// Get returns the group associated with a given key. It returns (0, false) if
+// no value is found.
+func (c *lruKeySharePredictionCache) Get(serverKey string) (CurveID, bool) {
+	c.Lock()
+	defer c.Unlock()
+
+	if elem, ok := c.m[serverKey]; ok {
+		c.q.MoveToFront(elem)
+		return elem.Value.(*lruKeySharePredictionCacheEntry).group, true
+	}
+	return 0, false
+}
+
+// predictKeyShares returns a copy of the key shares of ext, adjusted for the
+// group KeySharePredictionCache remembers for this server, if any. The copy
+// belongs to the connection, while ext may be shared by the connections of a
+// ClientHelloSpec. The group must be listed by the spec's
+// supported_groups extension; a share without a key is returned for it.
+//
+// A classical group replaces the first classical share, keeping the
+// post-quantum one in place, since only one classical key is kept for the
+// handshake. A post-quantum group is sent first.
+func (uconn *UConn) predictKeyShares(ext *KeyShareExtension) []KeyShare {
+	cache := uconn.config.KeySharePredictionCache
+	if cache == nil {
+		return slices.Clone(ext.KeyShares)
+	}
+	cacheKey := uconn.clientSessionCacheKey()
+	if cacheKey == "" {
+		return slices.Clone(ext.KeyShares)
+	}
+	group, ok := cache.Get(cacheKey)
+	if !ok || group == 0 || isGREASEUint16(uint16(group)) {
+		return slices.Clone(ext.KeyShares)
+	}
+
+	supported := false
+	for _, e := range uconn.Extensions {
+		if curves, ok := e.(*SupportedCurvesExtension); ok {
+			supported = slices.Contains(curves.Curves, group)
+		}
+	}
+	if !supported || slices.ContainsFunc(ext.KeyShares, func(ks KeyShare) bool {
+		return ks.Group == group
+	}) {
+		return slices.Clone(ext.KeyShares)
+	}
+
+	keyShares := slices.Clone(ext.KeyShares)
+	predicted := KeyShare{Group: group}
+	if isPQGroup(group) {
+		i := slices.IndexFunc(keyShares, func(ks KeyShare) bool {
+			return !isGREASEUint16(uint16(ks.Group))
+		})
+		if i < 0 {
+			i = len(keyShares)
+		}
+		return slices.Insert(keyShares, i, predicted)
+	}
+	i := slices.IndexFunc(keyShares, func(ks KeyShare) bool {
+		return !isGREASEUint16(uint16(ks.Group)) && !isPQGroup(ks.Group)
+	})
+	if i < 0 {
+		return append(keyShares, predicted)
+	}
+	keyShares[i] = predicted
+	return keyShares
+}
+
+func isPQGroup(group CurveID) bool {
+	return isPQKeyExchange(group) || group == X25519Kyber768Draft00
+}
@@ -0,0 +1,154 @@
+// Copyright 2026 uTLS Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tls
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestKeySharePrediction(t *testing.T) {
+	clientConfig := testConfig.Clone()
+	clientConfig.ServerName = "example.golang"
+	clientConfig.KeySharePredictionCache = NewLRUKeySharePredictionCache(0)
+	serverConfig := testConfig.Clone()
+	serverConfig.CurvePreferences = []CurveID{CurveP256}
+
+	chrome := func() *ClientHelloSpec {
+		spec, err := utlsIdToSpec(HelloChrome_Auto)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return &spec
+	}
+
+	serverState, clientState, err := testUtlsHandshake(t, clientConfig, serverConfig, chrome())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !serverState.testingOnlyDidHRR {
+		t.Fatal("first handshake did not use a HelloRetryRequest")
+	}
+	if group, ok := clientConfig.KeySharePredictionCache.Get("example.golang"); !ok || group != CurveP256 {
+		t.Fatalf("cached group = %v, %v; want %v", group, ok, CurveP256)
+	}
+
+	serverState, clientState, err = testUtlsHandshake(t, clientConfig, serverConfig, chrome())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if serverState.testingOnlyDidHRR {
+		t.Error("second handshake used a HelloRetryRequest")
+	}
+	if clientState.testingOnlyCurveID != CurveP256 {
+		t.Errorf("second handshake used %v, want %v", clientState.testingOnlyCurveID, CurveP256)
+	}
+
+	// The hybrid share is still offered to servers that accept it.
+	serverConfig.CurvePreferences = nil
+	_, clientState, err = testUtlsHandshake(t, clientConfig, serverConfig, chrome())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if clientState.testingOnlyCurveID != X25519MLKEM768 {
+		t.Errorf("third handshake used %v, want %v", clientState.testingOnlyCurveID, X25519MLKEM768)
+	}
+}
+
+func TestKeySharePredictionPlainConn(t *testing.T) {
+	clientConfig := testConfig.Clone()
+	clientConfig.ServerName = "example.golang"
+	clientConfig.KeySharePredictionCache = NewLRUKeySharePredictionCache(0)
+	serverConfig := testConfig.Clone()
+	serverConfig.CurvePreferences = []CurveID{CurveP256}
+
+	// Only UConn handshakes use the cache, plain Conn clients leave it alone.
+	serverState, _, err := testHandshake(t, clientConfig, serverConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !serverState.testingOnlyDidHRR {
+		t.Fatal("handshake did not use a HelloRetryRequest")
+	}
+	if group, ok := clientConfig.KeySharePredictionCache.Get("example.golang"); ok {
+		t.Errorf("plain Conn handshake cached group %v", group)
+	}
+}
+
+func TestKeySharePredictionUnsupportedGroup(t *testing.T) {
+	config := testConfig.Clone()
+	config.ServerName = "example.golang"
+	config.KeySharePredictionCache = NewLRUKeySharePredictionCache(0)
+	config.KeySharePredictionCache.Put("example.golang", CurveP521)
+
+	spec, err := utlsIdToSpec(HelloChrome_Auto)
+	if err != nil {
+		t.Fatal(err)
+	}
+	uconn := UClient(nil, config, HelloCustom, false, false)
+	if err := uconn.ApplyPreset(&spec); err != nil {
+		t.Fatal(err)
+	}
+	for _, ext := range uconn.Extensions {
+		if ks, ok := ext.(*KeyShareExtension); ok {
+			for _, share := range ks.KeyShares {
+				if share.Group == CurveP521 {
+					t.Errorf("key share sent for %v, which the spec doesn't support", CurveP521)
+				}
+			}
+		}
+	}
+}
+
+func TestKeySharePredictionSharedSpec(t *testing.T) {
+	config := testConfig.Clone()
+	config.ServerName = "example.golang"
+	config.KeySharePredictionCache = NewLRUKeySharePredictionCache(0)
+	config.KeySharePredictionCache.Put("example.golang", CurveP256)
+
+	spec, err := utlsIdToSpec(HelloChrome_Auto)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var specShares *KeyShareExtension
+	for _, ext := range spec.Extensions {
+		if ks, ok := ext.(*KeyShareExtension); ok {
+			specShares = ks
+		}
+	}
+	want := fmt.Sprint(specShares.KeyShares)
+
+	// Connections applying the same spec must not modify its key shares.
+	for range 2 {
+		if err := UClient(nil, config, HelloCustom, false, false).ApplyPreset(&spec); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := fmt.Sprint(specShares.KeyShares); got != want {
+		t.Errorf("the key shares of the spec changed from %s to %s", want, got)
+	}
+}
+
+func TestLRUKeySharePredictionCache(t *testing.T) {
+	cache := NewLRUKeySharePredictionCache(2)
+	cache.Put("a", CurveP256)
+	cache.Put("b", CurveP384)
+	cache.Get("a")
+	cache.Put("c", X25519)
+
+	if _, ok := cache.Get("b"); ok {
+		t.Error("least recently used entry was not evicted")
+	}
+	if group, _ := cache.Get("a"); group != CurveP256 {
+		t.Errorf("a = %v, want %v", group, CurveP256)
+	}
+	cache.Put("a", 0)
+	if _, ok := cache.Get("a"); ok {
+		t.Error("entry was not removed by a zero group")
+	}
+	if group, _ := cache.Get("c"); group != X25519 {
+		t.Errorf("c = %v, want %v", group, X25519)
+	}
+}
@@ -0,0 +1,109 @@
+// Copyright 2026 uTLS Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tls
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+// KeyUpdatePolicy makes TLS 1.3 connections update their traffic keys with
+// KeyUpdate messages, RFC 8446, Section 4.6.3, after a traffic key protected
+// a number of records or bytes of data. It applies to each direction: the
+// sending key is updated before the next record is written, and an update of
+// the receiving key is requested from the peer.
+//
+// RFC 8446, Section 5.5 limits AES-GCM to 2^24.5 full-size records per key,
+// which any limit below 2^24 records keeps well clear of.
+type KeyUpdatePolicy struct {
+	// MaxRecords is the number of records after which a traffic key is
+	// updated. Zero means no limit.
+	MaxRecords uint64
+
+	// MaxBytes is the number of bytes of data after which a traffic key is
+	// updated. Zero means no limit.
+	MaxBytes uint64
+}
+
+// exceeded reports whether the traffic key of hc protected too much data.
+func (p *KeyUpdatePolicy) exceeded(hc *halfConn) bool {
+	if p == nil {
+		return false
+	}
+	return p.MaxRecords > 0 && binary.BigEndian.Uint64(hc.seq[:]) >= p.MaxRecords ||
+		p.MaxBytes > 0 && hc.keyUpdateBytes >= p.MaxBytes
+}
+
+// SendKeyUpdate updates the traffic key used to send data on a TLS 1.3
+// connection, and sends a KeyUpdate message to let the peer know. If
+// requestUpdate is true, the peer is asked to update its own sending key too.
+//
+// The handshake must be complete. QUIC connections don't use KeyUpdate
+// messages, see RFC 9001, Section 6.
+func (c *Conn) SendKeyUpdate(requestUpdate bool) error {
+	if !c.isHandshakeComplete.Load() {
+		return errors.New("tls: KeyUpdate sent before the handshake is complete")
+	}
+
+	c.out.Lock()
+	defer c.out.Unlock()
+
+	return c.sendKeyUpdateLocked(requestUpdate)
+}
+
+func (c *Conn) sendKeyUpdateLocked(requestUpdate bool) error {
+	if c.vers != VersionTLS13 || c.quic != nil {
+		return errors.New("tls: KeyUpdate is only supported by TLS 1.3 over TCP")
+	}
+	if err := c.out.err; err != nil {
+		return err
+	}
+
+	cipherSuite := cipherSuiteTLS13ByID(c.cipherSuite)
+	if cipherSuite == nil {
+		return c.sendAlertLocked(alertInternalError)
+	}
+
+	msg := &keyUpdateMsg{updateRequested: requestUpdate}
+	msgBytes, err := msg.marshal()
+	if err != nil {
+		return err
+	}
+	if _, err := c.writeRecordLocked(recordTypeHandshake, msgBytes); err != nil {
+		return c.out.setErrorLocked(err)
+	}
+
+	newSecret := cipherSuite.nextTrafficSecret(c.out.trafficSecret)
+	c.out.setTrafficSecret(cipherSuite, QUICEncryptionLevelInitial, newSecret)
+	return nil
+}
+
+// maybeUpdateSendingKeyLocked updates the sending key, if it exceeded
+// Config.KeyUpdatePolicy. It must be called with c.out held, before writing an
+// application data record.
+func (c *Conn) maybeUpdateSendingKeyLocked() error {
+	if c.vers != VersionTLS13 || c.quic != nil || !c.config.KeyUpdatePolicy.exceeded(&c.out) {
+		return nil
+	}
+	return c.sendKeyUpdateLocked(false)
+}
+
+// maybeRequestKeyUpdate asks the peer to update its sending key, if the
+// receiving key exceeded Config.KeyUpdatePolicy and no update was requested
+// yet. It must be called with c.in held, after reading an application data
+// record.
+func (c *Conn) maybeRequestKeyUpdate() {
+	if c.vers != VersionTLS13 || c.quic != nil || c.in.keyUpdateRequested ||
+		!c.config.KeyUpdatePolicy.exceeded(&c.in) {
+		return
+	}
+	c.in.keyUpdateRequested = true
+
+	c.out.Lock()
+	defer c.out.Unlock()
+
+	// An error is surfaced at the next write.
+	c.sendKeyUpdateLocked(true)
+}
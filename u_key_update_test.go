@@ -0,0 +1,135 @@
+// Copyright 2026 uTLS Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tls
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+// testKeyUpdateConns returns the client and server of a completed handshake.
+func testKeyUpdateConns(t *testing.T, clientConfig, serverConfig *Config) (client, server *Conn) {
+	t.Helper()
+	c, s := localPipe(t)
+	client, server = Client(c, clientConfig), Server(s, serverConfig)
+	t.Cleanup(func() { client.Close(); server.Close() })
+	errChan := make(chan error, 1)
+	go func() { errChan <- server.Handshake() }()
+	if err := client.Handshake(); err != nil {
+		t.Fatalf("client: %v", err)
+	}
+	if err := <-errChan; err != nil {
+		t.Fatalf("server: %v", err)
+	}
+	return client, server
+}
+
+func testKeyUpdateTransfer(t *testing.T, from, to *Conn, data string) {
+	t.Helper()
+	if _, err := from.Write([]byte(data)); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, len(data))
+	if _, err := io.ReadFull(to, buf); err != nil {
+		t.Fatal(err)
+	}
+	if string(buf) != data {
+		t.Fatalf("got %q, want %q", buf, data)
+	}
+}
+
+// nextTrafficSecrets returns secret updated n times.
+func nextTrafficSecrets(c *Conn, secret []byte, n int) []byte {
+	suite := cipherSuiteTLS13ByID(c.cipherSuite)
+	for i := 0; i < n; i++ {
+		secret = suite.nextTrafficSecret(secret)
+	}
+	return secret
+}
+
+func TestSendKeyUpdate(t *testing.T) {
+	client, server := testKeyUpdateConns(t, testConfig.Clone(), testConfig.Clone())
+	clientOut, clientIn := client.out.trafficSecret, client.in.trafficSecret
+
+	if err := client.SendKeyUpdate(true); err != nil {
+		t.Fatal(err)
+	}
+	testKeyUpdateTransfer(t, client, server, "ping")
+	testKeyUpdateTransfer(t, server, client, "pong")
+
+	if !bytes.Equal(client.out.trafficSecret, nextTrafficSecrets(client, clientOut, 1)) {
+		t.Error("the client sending key wasn't updated")
+	}
+	if !bytes.Equal(client.in.trafficSecret, nextTrafficSecrets(client, clientIn, 1)) {
+		t.Error("the server didn't update its sending key as requested")
+	}
+
+	if err := server.SendKeyUpdate(false); err != nil {
+		t.Fatal(err)
+	}
+	testKeyUpdateTransfer(t, server, client, "ping")
+	testKeyUpdateTransfer(t, client, server, "pong")
+	if !bytes.Equal(client.in.trafficSecret, nextTrafficSecrets(client, clientIn, 2)) {
+		t.Error("the server sending key wasn't updated")
+	}
+	if !bytes.Equal(client.out.trafficSecret, nextTrafficSecrets(client, clientOut, 1)) {
+		t.Error("the client sending key was updated without a request")
+	}
+}
+
+func TestSendKeyUpdateTLS12(t *testing.T) {
+	clientConfig := testConfig.Clone()
+	clientConfig.MaxVersion = VersionTLS12
+	client, _ := testKeyUpdateConns(t, clientConfig, testConfig.Clone())
+	if err := client.SendKeyUpdate(false); err == nil {
+		t.Error("sent a KeyUpdate in TLS 1.2")
+	}
+}
+
+func TestKeyUpdatePolicy(t *testing.T) {
+	t.Run("MaxRecords", func(t *testing.T) {
+		clientConfig := testConfig.Clone()
+		clientConfig.KeyUpdatePolicy = &KeyUpdatePolicy{MaxRecords: 2}
+		client, server := testKeyUpdateConns(t, clientConfig, testConfig.Clone())
+		clientOut := client.out.trafficSecret
+
+		// The key is updated before the third and fifth records.
+		for i := 0; i < 5; i++ {
+			testKeyUpdateTransfer(t, client, server, "ping")
+		}
+		if !bytes.Equal(client.out.trafficSecret, nextTrafficSecrets(client, clientOut, 2)) {
+			t.Error("the client sending key wasn't updated twice")
+		}
+		if !bytes.Equal(server.in.trafficSecret, client.out.trafficSecret) {
+			t.Error("the server didn't follow the updates")
+		}
+	})
+
+	t.Run("MaxBytes", func(t *testing.T) {
+		serverConfig := testConfig.Clone()
+		serverConfig.KeyUpdatePolicy = &KeyUpdatePolicy{MaxBytes: 10}
+		client, server := testKeyUpdateConns(t, testConfig.Clone(), serverConfig)
+		serverIn, serverOut := server.in.trafficSecret, server.out.trafficSecret
+
+		// The server asks the client to update its key after reading 10
+		// bytes, which it does once it reads the request.
+		testKeyUpdateTransfer(t, client, server, "0123456789")
+		if !server.in.keyUpdateRequested {
+			t.Fatal("the server didn't request a KeyUpdate")
+		}
+		testKeyUpdateTransfer(t, server, client, "ping")
+		testKeyUpdateTransfer(t, client, server, "pong")
+		if !bytes.Equal(server.in.trafficSecret, nextTrafficSecrets(server, serverIn, 1)) {
+			t.Error("the client didn't update its sending key as requested")
+		}
+		if !bytes.Equal(server.out.trafficSecret, nextTrafficSecrets(server, serverOut, 1)) {
+			t.Error("the server sending key wasn't updated with the request")
+		}
+		if server.in.keyUpdateRequested {
+			t.Error("the request wasn't cleared by the update")
+		}
+	})
+}
@@ -0,0 +1,222 @@
+// Copyright 2026 uTLS Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tls
+
+import (
+	"crypto/ecdh"
+	"crypto/mlkem"
+	"errors"
+	"io"
+	"slices"
+)
+
+// mlkemGroup describes the key shares of a TLS 1.3 group built on ML-KEM,
+// optionally combined with an ECDH exchange, see
+// draft-ietf-tls-ecdhe-mlkem and draft-ietf-tls-mlkem.
+//
+// The client share is the ML-KEM encapsulation key and the ECDH public key,
+// the server share is the ML-KEM ciphertext and the ECDH public key, and the
+// shared secret is the ML-KEM shared secret and the ECDH one, each pair in the
+// order given by mlkemFirst.
+type mlkemGroup struct {
+	ecdh       CurveID // zero for pure ML-KEM
+	mlkemFirst bool
+	mlkem1024  bool
+}
+
+var mlkemGroups = map[CurveID]mlkemGroup{
+	X25519MLKEM768:     {ecdh: X25519, mlkemFirst: true},
+	SecP256r1MLKEM768:  {ecdh: CurveP256},
+	SecP384r1MLKEM1024: {ecdh: CurveP384, mlkem1024: true},
+	MLKEM768:           {mlkemFirst: true},
+	MLKEM1024:          {mlkemFirst: true, mlkem1024: true},
+}
+
+// isMLKEMGroup reports whether group is in mlkemGroups.
+func isMLKEMGroup(group CurveID) bool {
+	_, ok := mlkemGroups[group]
+	return ok
+}
+
+func (g mlkemGroup) encapsulationKeySize() int {
+	if g.mlkem1024 {
+		return mlkem.EncapsulationKeySize1024
+	}
+	return mlkem.EncapsulationKeySize768
+}
+
+func (g mlkemGroup) ciphertextSize() int {
+	if g.mlkem1024 {
+		return mlkem.CiphertextSize1024
+	}
+	return mlkem.CiphertextSize768
+}
+
+func (g mlkemGroup) ecdhPublicKeySize() int {
+	switch g.ecdh {
+	case X25519:
+		return x25519PublicKeySize
+	case CurveP256:
+		return 1 + 2*32
+	case CurveP384:
+		return 1 + 2*48
+	}
+	return 0
+}
+
+func (g mlkemGroup) join(mlkemPart, ecdhPart []byte) []byte {
+	if g.mlkemFirst {
+		return append(slices.Clip(mlkemPart), ecdhPart...)
+	}
+	return append(slices.Clip(ecdhPart), mlkemPart...)
+}
+
+// split cuts data, made by join, into its ML-KEM part of mlkemLen bytes and
+// its ECDH part.
+func (g mlkemGroup) split(data []byte, mlkemLen int) (mlkemPart, ecdhPart []byte, ok bool) {
+	if len(data) != mlkemLen+g.ecdhPublicKeySize() {
+		return nil, nil, false
+	}
+	if g.mlkemFirst {
+		return data[:mlkemLen], data[mlkemLen:], true
+	}
+	return data[len(data)-mlkemLen:], data[:len(data)-mlkemLen], true
+}
+
+// mlkemKeyShare holds the client secrets of a key share for a group in
+// mlkemGroups.
+type mlkemKeyShare struct {
+	group  CurveID
+	ecdhe  *ecdh.PrivateKey // nil for pure ML-KEM
+	dk768  *mlkem.DecapsulationKey768
+	dk1024 *mlkem.DecapsulationKey1024
+}
+
+// generateMLKEMKeyShare returns new client secrets for group, and the
+// matching key share.
+func generateMLKEMKeyShare(rand io.Reader, group CurveID) (*mlkemKeyShare, []byte, error) {
+	g, ok := mlkemGroups[group]
+	if !ok {
+		return nil, nil, errors.New("tls: internal error: unsupported ML-KEM group")
+	}
+	k := &mlkemKeyShare{group: group}
+
+	seed := make([]byte, mlkem.SeedSize)
+	if _, err := io.ReadFull(rand, seed); err != nil {
+		return nil, nil, err
+	}
+	var encapsulationKey []byte
+	if g.mlkem1024 {
+		dk, err := mlkem.NewDecapsulationKey1024(seed)
+		if err != nil {
+			return nil, nil, err
+		}
+		k.dk1024, encapsulationKey = dk, dk.EncapsulationKey().Bytes()
+	} else {
+		dk, err := mlkem.NewDecapsulationKey768(seed)
+		if err != nil {
+			return nil, nil, err
+		}
+		k.dk768, encapsulationKey = dk, dk.EncapsulationKey().Bytes()
+	}
+
+	var ecdhPublicKey []byte
+	if g.ecdh != 0 {
+		ecdhe, err := generateECDHEKey(rand, g.ecdh)
+		if err != nil {
+			return nil, nil, err
+		}
+		k.ecdhe, ecdhPublicKey = ecdhe, ecdhe.PublicKey().Bytes()
+	}
+	return k, g.join(encapsulationKey, ecdhPublicKey), nil
+}
+
+func (k *mlkemKeyShare) curveID() CurveID {
+	return k.group
+}
+
+// sharedKey decapsulates the server key share.
+func (k *mlkemKeyShare) sharedKey(serverShare []byte) ([]byte, error) {
+	g := mlkemGroups[k.group]
+	ciphertext, ecdhPeerData, ok := g.split(serverShare, g.ciphertextSize())
+	if !ok {
+		return nil, errors.New("tls: invalid server ML-KEM key share")
+	}
+
+	var mlkemShared []byte
+	var err error
+	if g.mlkem1024 {
+		mlkemShared, err = k.dk1024.Decapsulate(ciphertext)
+	} else {
+		mlkemShared, err = k.dk768.Decapsulate(ciphertext)
+	}
+	if err != nil {
+		return nil, errors.New("tls: invalid server ML-KEM key share")
+	}
+	if k.ecdhe == nil {
+		return mlkemShared, nil
+	}
+	ecdhShared, err := getSharedKey(ecdhPeerData, k.ecdhe)
+	if err != nil {
+		return nil, errors.New("tls: invalid server key share")
+	}
+	return g.join(mlkemShared, ecdhShared), nil
+}
+
+// mlkemEncapsulate returns the server key share and the shared secret for
+// the client key share of a group in mlkemGroups.
+func mlkemEncapsulate(rand io.Reader, group CurveID, clientShare []byte) (serverShare, sharedKey []byte, err error) {
+	g, ok := mlkemGroups[group]
+	if !ok {
+		return nil, nil, errors.New("tls: internal error: unsupported ML-KEM group")
+	}
+	encapsulationKey, ecdhPeerData, ok := g.split(clientShare, g.encapsulationKeySize())
+	if !ok {
+		return nil, nil, errors.New("tls: invalid ML-KEM client key share")
+	}
+
+	var mlkemShared, ciphertext []byte
+	if g.mlkem1024 {
+		ek, err := mlkem.NewEncapsulationKey1024(encapsulationKey)
+		if err != nil {
+			return nil, nil, errors.New("tls: invalid ML-KEM client key share")
+		}
+		mlkemShared, ciphertext = ek.Encapsulate()
+	} else {
+		ek, err := mlkem.NewEncapsulationKey768(encapsulationKey)
+		if err != nil {
+			return nil, nil, errors.New("tls: invalid ML-KEM client key share")
+		}
+		mlkemShared, ciphertext = ek.Encapsulate()
+	}
+	if g.ecdh == 0 {
+		return ciphertext, mlkemShared, nil
+	}
+
+	key, err := generateECDHEKey(rand, g.ecdh)
+	if err != nil {
+		return nil, nil, err
+	}
+	peerKey, err := key.Curve().NewPublicKey(ecdhPeerData)
+	if err != nil {
+		return nil, nil, errors.New("tls: invalid client key share")
+	}
+	ecdhShared, err := key.ECDH(peerKey)
+	if err != nil {
+		return nil, nil, errors.New("tls: invalid client key share")
+	}
+	return g.join(ciphertext, key.PublicKey().Bytes()), g.join(mlkemShared, ecdhShared), nil
+}
+
+// withOptInCurvePreferences adds the groups which aren't enabled by default
+// to defaults, so that they are used when Config.CurvePreferences lists them:
+// the ML-KEM groups after X25519MLKEM768, then CurveSM2, and the FFDHE
+// groups last.
+func withOptInCurvePreferences(defaults []CurveID) []CurveID {
+	optIn := []CurveID{SecP256r1MLKEM768, SecP384r1MLKEM1024, MLKEM768, MLKEM1024}
+	i := slices.Index(defaults, X25519MLKEM768) + 1
+	list := append(slices.Insert(slices.Clone(defaults), i, optIn...), CurveSM2)
+	return append(list, ffdheGroupIDs...)
+}
@@ -0,0 +1,91 @@
+// Copyright 2026 uTLS Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tls
+
+import (
+	"fmt"
+	"testing"
+)
+
+var testMLKEMGroups = []CurveID{X25519MLKEM768, SecP256r1MLKEM768, SecP384r1MLKEM1024, MLKEM768, MLKEM1024}
+
+func TestMLKEMGroups(t *testing.T) {
+	for _, group := range testMLKEMGroups {
+		t.Run(fmt.Sprintf("%#04x", uint16(group)), func(t *testing.T) {
+			clientConfig := testConfig.Clone()
+			clientConfig.CurvePreferences = []CurveID{group, X25519}
+			serverConfig := testConfig.Clone()
+			serverConfig.CurvePreferences = []CurveID{group}
+
+			serverState, clientState, err := testHandshake(t, clientConfig, serverConfig)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if clientState.testingOnlyCurveID != group || serverState.testingOnlyCurveID != group {
+				t.Errorf("negotiated %v, want %v", clientState.testingOnlyCurveID, group)
+			}
+			if serverState.testingOnlyDidHRR {
+				t.Errorf("unexpected HelloRetryRequest")
+			}
+		})
+	}
+}
+
+func TestMLKEMGroupsTLS12(t *testing.T) {
+	config := testConfig.Clone()
+	config.CurvePreferences = []CurveID{SecP256r1MLKEM768, CurveP256}
+	for _, curve := range config.curvePreferences(VersionTLS12) {
+		if isMLKEMGroup(curve) {
+			t.Errorf("ML-KEM group %v enabled for TLS 1.2", curve)
+		}
+	}
+	if prefs := config.curvePreferences(VersionTLS13); len(prefs) != 2 || prefs[0] != SecP256r1MLKEM768 {
+		t.Errorf("TLS 1.3 curve preferences = %v", prefs)
+	}
+}
+
+func TestMLKEMGroupsUTLS(t *testing.T) {
+	for _, group := range testMLKEMGroups {
+		t.Run(fmt.Sprintf("%#04x", uint16(group)), func(t *testing.T) {
+			serverConfig := testConfig.Clone()
+			serverConfig.CurvePreferences = []CurveID{group}
+
+			spec, err := utlsIdToSpec(HelloChrome_Auto)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, ext := range spec.Extensions {
+				switch ext := ext.(type) {
+				case *SupportedCurvesExtension:
+					ext.Curves = []CurveID{GREASE_PLACEHOLDER, group, X25519, CurveP256}
+				case *KeyShareExtension:
+					ext.KeyShares = []KeyShare{{Group: GREASE_PLACEHOLDER, Data: []byte{0}}, {Group: group}, {Group: X25519}}
+				}
+			}
+			serverState, clientState, err := testUtlsHandshake(t, testConfig, serverConfig, &spec)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if clientState.testingOnlyCurveID != group || serverState.testingOnlyDidHRR {
+				t.Errorf("negotiated %v (HRR: %v), want %v", clientState.testingOnlyCurveID, serverState.testingOnlyDidHRR, group)
+			}
+
+			// Without a key share for it, the server asks for the group
+			// with a HelloRetryRequest.
+			for _, ext := range spec.Extensions {
+				if ks, ok := ext.(*KeyShareExtension); ok {
+					ks.KeyShares = []KeyShare{{Group: GREASE_PLACEHOLDER, Data: []byte{0}}, {Group: X25519}}
+				}
+			}
+			serverState, clientState, err = testUtlsHandshake(t, testConfig, serverConfig, &spec)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if clientState.testingOnlyCurveID != group || !serverState.testingOnlyDidHRR {
+				t.Errorf("negotiated %v (HRR: %v), want %v after a HelloRetryRequest", clientState.testingOnlyCurveID, serverState.testingOnlyDidHRR, group)
+			}
+		})
+	}
+}
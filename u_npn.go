@@ -0,0 +1,95 @@
+// Copyright 2026 uTLS Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tls
+
+import (
+	"errors"
+	"slices"
+)
+
+// Next Protocol Negotiation, draft-agl-tls-nextprotoneg-04. It predates ALPN
+// and was removed from crypto/tls, but old browsers offer it. In a TLS 1.2
+// handshake, the server lists its protocols in the ServerHello and the client
+// sends its choice in an encrypted NextProtocol message, right before its
+// Finished message. Both sides use Config.NextProtos, and ALPN takes
+// precedence when it selected a protocol.
+
+// mutualNextProtocol returns the first protocol of the server which the client
+// supports. If there is none, it returns the first protocol of the client, as
+// the draft requires, and true.
+func mutualNextProtocol(clientProtos, serverProtos []string) (string, bool) {
+	for _, proto := range serverProtos {
+		if slices.Contains(clientProtos, proto) {
+			return proto, false
+		}
+	}
+	if len(clientProtos) == 0 {
+		return "", true
+	}
+	return clientProtos[0], true
+}
+
+// processServerNPN checks the next_protocol_negotiation extension of the
+// ServerHello and selects the protocol to send in the NextProtocol message.
+func (hs *clientHandshakeState) processServerNPN() error {
+	c := hs.c
+
+	if !hs.serverHello.nextProtoNeg {
+		return nil
+	}
+	if !hs.hello.nextProtoNeg {
+		c.sendAlert(alertUnsupportedExtension)
+		return errors.New("tls: server advertised unrequested NPN extension")
+	}
+	if hs.serverHello.alpnProtocol != "" {
+		c.sendAlert(alertUnsupportedExtension)
+		return errors.New("tls: server advertised both NPN and ALPN")
+	}
+	c.clientProtocol, c.utls.npnFallback = mutualNextProtocol(c.config.NextProtos, hs.serverHello.nextProtos)
+	return nil
+}
+
+// sendNextProtocol sends the NextProtocol message, if the server offered NPN.
+func (hs *clientHandshakeState) sendNextProtocol() error {
+	if !hs.serverHello.nextProtoNeg {
+		return nil
+	}
+	nextProto := &utlsNextProtocolMsg{proto: hs.c.clientProtocol}
+	_, err := hs.c.writeHandshakeRecord(nextProto, &hs.finishedHash)
+	return err
+}
+
+// negotiateNPN lists the protocols of the server in the ServerHello, if the
+// client supports NPN and ALPN didn't select a protocol.
+func (hs *serverHandshakeState) negotiateNPN() {
+	c := hs.c
+
+	if hs.clientHello.nextProtoNeg && hs.hello.alpnProtocol == "" &&
+		len(c.config.NextProtos) > 0 && c.handshakes == 0 {
+		hs.hello.nextProtoNeg = true
+		hs.hello.nextProtos = c.config.NextProtos
+	}
+}
+
+// readNextProtocol reads the NextProtocol message of the client, if the
+// server offered NPN.
+func (hs *serverHandshakeState) readNextProtocol() error {
+	c := hs.c
+
+	if !hs.hello.nextProtoNeg {
+		return nil
+	}
+	msg, err := c.readHandshake(&hs.finishedHash)
+	if err != nil {
+		return err
+	}
+	nextProto, ok := msg.(*utlsNextProtocolMsg)
+	if !ok {
+		c.sendAlert(alertUnexpectedMessage)
+		return unexpectedMessageError(nextProto, msg)
+	}
+	c.clientProtocol = nextProto.proto
+	return nil
+}
@@ -0,0 +1,97 @@
+// Copyright 2026 uTLS Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tls
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNextProtocolMsg(t *testing.T) {
+	for _, proto := range []string{"", "h2", "http/1.1", strings.Repeat("a", 30), strings.Repeat("a", 255)} {
+		b, err := (&utlsNextProtocolMsg{proto: proto}).marshal()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if (len(b)-4)%32 != 0 {
+			t.Errorf("%q: %d bytes long body", proto, len(b)-4)
+		}
+		var m utlsNextProtocolMsg
+		if !m.unmarshal(b) || m.proto != proto {
+			t.Errorf("%q: got %q", proto, m.proto)
+		}
+	}
+	if _, err := (&utlsNextProtocolMsg{proto: strings.Repeat("a", 256)}).marshal(); err == nil {
+		t.Error("marshaled a too long protocol")
+	}
+}
+
+func TestNPNHandshake(t *testing.T) {
+	tests := []struct {
+		name         string
+		clientProtos []string
+		alpn         bool
+		clientProto  string
+		serverProto  string
+		mutual       bool
+	}{
+		{"Mutual", []string{"spdy/3", "http/1.1"}, false, "http/1.1", "http/1.1", true},
+		{"Fallback", []string{"spdy/3"}, false, "spdy/3", "spdy/3", false},
+		{"ALPN", []string{"h2", "http/1.1"}, true, "h2", "h2", true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			extensions := []TLSExtension{
+				&SupportedCurvesExtension{Curves: []CurveID{X25519}},
+				&SupportedPointsExtension{SupportedPoints: []uint8{0}}, // uncompressed
+				&SignatureAlgorithmsExtension{SupportedSignatureAlgorithms: []SignatureScheme{PSSWithSHA256, PKCS1WithSHA256}},
+				&NPNExtension{NextProtos: test.clientProtos},
+			}
+			if test.alpn {
+				extensions = append(extensions, &ALPNExtension{AlpnProtocols: test.clientProtos})
+			}
+			spec := &ClientHelloSpec{
+				TLSVersMax:   VersionTLS12,
+				TLSVersMin:   VersionTLS12,
+				CipherSuites: []uint16{TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256},
+				Extensions:   extensions,
+			}
+			serverConfig := testConfig.Clone()
+			serverConfig.NextProtos = []string{"h2", "http/1.1"}
+
+			serverState, clientState, err := testUtlsHandshake(t, testConfig.Clone(), serverConfig, spec)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if clientState.NegotiatedProtocol != test.clientProto || clientState.NegotiatedProtocolIsMutual != test.mutual {
+				t.Errorf("client negotiated %q, mutual %v; want %q, %v", clientState.NegotiatedProtocol,
+					clientState.NegotiatedProtocolIsMutual, test.clientProto, test.mutual)
+			}
+			if serverState.NegotiatedProtocol != test.serverProto || !serverState.NegotiatedProtocolIsMutual {
+				t.Errorf("server negotiated %q, want %q", serverState.NegotiatedProtocol, test.serverProto)
+			}
+		})
+	}
+}
+
+func TestNPNUnsolicited(t *testing.T) {
+	c := Client(nil, testConfig.Clone())
+	c.vers = VersionTLS12
+	c.conn = &discardConn{}
+	hs := &clientHandshakeState{
+		c:           c,
+		hello:       &clientHelloMsg{},
+		serverHello: &serverHelloMsg{nextProtoNeg: true, nextProtos: []string{"h2"}},
+	}
+	if err := hs.processServerNPN(); err == nil {
+		t.Error("accepted an unsolicited NPN extension")
+	}
+
+	hs.hello.nextProtoNeg = true
+	hs.serverHello.alpnProtocol = "h2"
+	if err := hs.processServerNPN(); err == nil {
+		t.Error("accepted both NPN and ALPN")
+	}
+}
@@ -30,7 +30,13 @@ func UTLSIdToSpec(id ClientHelloID) (ClientHelloSpec, error) {
 	return utlsIdToSpec(id)
 }
 
-func utlsIdToSpec(id ClientHelloID) (ClientHelloSpec, error) {
+func utlsIdToSpec(id ClientHelloID) (spec ClientHelloSpec, err error) {
+	defer func() {
+		if err == nil && spec.HelloRetryRequest == nil {
+			spec.HelloRetryRequest = helloRetryRequestSpecForID(id)
+		}
+	}()
+
 	switch id.Str() {
 	case HelloChrome_58.Str(), HelloChrome_62.Str():
 		return ClientHelloSpec{
@@ -3329,18 +3335,9 @@ func (uconn *UConn) ApplyPreset(p *ClientHelloSpec) error {
 		hello.CompressionMethods = []uint8{CompressionNone}
 	}
 
-	// Currently, GREASE is assumed to come from BoringSSL
-	grease_bytes := make([]byte, 2*ssl_grease_last_index)
 	grease_extensions_seen := 0
-	_, err = io.ReadFull(uconn.config.rand(), grease_bytes)
-	if err != nil {
-		return errors.New("tls: short read from Rand: " + err.Error())
-	}
-	for i := range uconn.greaseSeed {
-		uconn.greaseSeed[i] = binary.LittleEndian.Uint16(grease_bytes[2*i : 2*i+2])
-	}
-	if GetBoringGREASEValue(uconn.greaseSeed, ssl_grease_extension1) == GetBoringGREASEValue(uconn.greaseSeed, ssl_grease_extension2) {
-		uconn.greaseSeed[ssl_grease_extension2] ^= 0x1010
+	if err := uconn.newGREASESeed(); err != nil {
+		return err
 	}
 
 	hello.CipherSuites = make([]uint16, len(p.CipherSuites))
@@ -3368,11 +3365,17 @@ func (uconn *UConn) ApplyPreset(p *ClientHelloSpec) error {
 	uconn.Extensions = make([]TLSExtension, len(p.Extensions))
 	copy(uconn.Extensions, p.Extensions)
 
+	uconn.helloRetryRequestSpec = p.HelloRetryRequest
+	uconn.getSessionID = p.GetSessionID
+	if uconn.helloRetryRequestSpec == nil {
+		uconn.helloRetryRequestSpec = helloRetryRequestSpecForID(uconn.ClientHelloID)
+	}
+
 	// Check whether NPN extension actually exists
 	var haveNPN bool
 
 	// reGrease, and point things to each other
-	for _, e := range uconn.Extensions {
+	for extIndex, e := range uconn.Extensions {
 		switch ext := e.(type) {
 		case *SNIExtension:
 			if ext.ServerName == "" {
@@ -3399,6 +3402,10 @@ func (uconn *UConn) ApplyPreset(p *ClientHelloSpec) error {
 				}
 			}
 		case *KeyShareExtension:
+			// [uTLS] the key shares are filled in below, so work on a copy of
+			// the extension, which the connections of the spec may share.
+			ext = &KeyShareExtension{KeyShares: uconn.predictKeyShares(ext)}
+			uconn.Extensions[extIndex] = ext
 			preferredCurveIsSet := false
 			for i := range ext.KeyShares {
 				curveID := ext.KeyShares[i].Group
@@ -3431,6 +3438,13 @@ func (uconn *UConn) ApplyPreset(p *ClientHelloSpec) error {
 					}
 					uconn.HandshakeState.State13.KeyShareKeys.mlkem = mlkemKey
 					uconn.HandshakeState.State13.KeyShareKeys.mlkemEcdhe = ecdheKey
+				} else if isExtraGroup(curveID) {
+					key, data, err := generateExtraKeyShare(uconn.config, curveID)
+					if err != nil {
+						return err
+					}
+					ext.KeyShares[i].Data = data
+					uconn.HandshakeState.State13.KeyShareKeys.extraKeyShares = append(uconn.HandshakeState.State13.KeyShareKeys.extraKeyShares, key)
 				} else {
 					ecdheKey, err := generateECDHEKey(uconn.config.rand(), curveID)
 					if err != nil {
@@ -3454,6 +3468,10 @@ func (uconn *UConn) ApplyPreset(p *ClientHelloSpec) error {
 			}
 		case *NPNExtension:
 			haveNPN = true
+		case *ExternalPreSharedKeyExtension:
+			if err := ext.prepare(uconn); err != nil {
+				return err
+			}
 		}
 	}
 
@@ -3469,6 +3487,23 @@ func (uconn *UConn) ApplyPreset(p *ClientHelloSpec) error {
 	return nil
 }
 
+// newGREASESeed draws the values of GREASE placeholders.
+func (uconn *UConn) newGREASESeed() error {
+	// Currently, GREASE is assumed to come from BoringSSL
+	grease_bytes := make([]byte, 2*ssl_grease_last_index)
+	_, err := io.ReadFull(uconn.config.rand(), grease_bytes)
+	if err != nil {
+		return errors.New("tls: short read from Rand: " + err.Error())
+	}
+	for i := range uconn.greaseSeed {
+		uconn.greaseSeed[i] = binary.LittleEndian.Uint16(grease_bytes[2*i : 2*i+2])
+	}
+	if GetBoringGREASEValue(uconn.greaseSeed, ssl_grease_extension1) == GetBoringGREASEValue(uconn.greaseSeed, ssl_grease_extension2) {
+		uconn.greaseSeed[ssl_grease_extension2] ^= 0x1010
+	}
+	return nil
+}
+
 func (uconn *UConn) generateRandomizedSpec() (ClientHelloSpec, error) {
 	return generateRandomizedSpec(&uconn.ClientHelloID, uconn.serverName, uconn.config.NextProtos)
 }
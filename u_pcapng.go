@@ -0,0 +1,275 @@
+// Copyright 2026 uTLS Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tls
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+// pcapng block types and constants, see
+// https://www.ietf.org/archive/id/draft-ietf-opsawg-pcapng-02.html.
+const (
+	pcapngBlockSectionHeader     uint32 = 0x0a0d0d0a
+	pcapngBlockInterface         uint32 = 0x00000001
+	pcapngBlockEnhancedPacket    uint32 = 0x00000006
+	pcapngBlockDecryptionSecrets uint32 = 0x0000000a
+
+	pcapngByteOrderMagic   uint32 = 0x1a2b3c4d
+	pcapngLinkTypeRaw      uint16 = 101        // LINKTYPE_RAW, packets begin with an IPv4 or IPv6 header
+	pcapngSecretsTLSKeyLog uint32 = 0x544c534b // "TLSK", NSS key log
+)
+
+// pcapngMaxSegment is the maximum payload of the synthesized TCP segments,
+// which fits in the length of both IPv4 and IPv6 packets.
+const pcapngMaxSegment = 65535 - 20 - 20
+
+// The TCP flags of the synthesized segments.
+const (
+	tcpFlagSYN = 0x02
+	tcpFlagPSH = 0x08
+	tcpFlagACK = 0x10
+)
+
+// PcapngRecorder records the TCP byte stream of a connection with its NSS key
+// log, and writes them as a pcapng file which Wireshark opens decrypted without
+// any other setup, for instance to share a failing handshake, see
+// UConn.SetPcapngRecorder. The IP and TCP headers of the packets, including a
+// TCP handshake, are synthesized from the addresses of the connection, and
+// each read from or write to it is a packet.
+//
+// A PcapngRecorder records a single connection. It can be written during or
+// after the handshake, whether it succeeded or failed, and is safe for
+// concurrent use.
+type PcapngRecorder struct {
+	mu                     sync.Mutex
+	start                  time.Time
+	clientAddr, serverAddr *net.TCPAddr
+	segments               []pcapngSegment
+	keyLog                 []byte
+}
+
+type pcapngSegment struct {
+	time time.Time
+	sent bool // by the client
+	data []byte
+}
+
+// SetPcapngRecorder makes the connection record its TCP byte stream and its
+// secrets with r. It must be called before the handshake. A nil r stops the
+// recording.
+func (uconn *UConn) SetPcapngRecorder(r *PcapngRecorder) {
+	uconn.utls.pcapngRecorder = r
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.start = time.Now()
+	r.clientAddr = pcapngAddr(uconn.conn.LocalAddr(), net.IPv4(192, 0, 2, 1), 49152)
+	r.serverAddr = pcapngAddr(uconn.conn.RemoteAddr(), net.IPv4(192, 0, 2, 2), 443)
+}
+
+// pcapngAddr returns addr if it is a TCP address with an IP, and the
+// documentation address ip and port otherwise.
+func pcapngAddr(addr net.Addr, ip net.IP, port int) *net.TCPAddr {
+	if addr, ok := addr.(*net.TCPAddr); ok && addr.IP != nil {
+		return addr
+	}
+	return &net.TCPAddr{IP: ip, Port: port}
+}
+
+func (r *PcapngRecorder) recordSegment(data []byte, sent bool) {
+	if len(data) == 0 {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.segments = append(r.segments, pcapngSegment{time: time.Now(), sent: sent, data: bytes.Clone(data)})
+}
+
+func (r *PcapngRecorder) recordKeyLog(line []byte) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.keyLog = append(r.keyLog, line...)
+}
+
+// KeyLog returns the key log lines of the secrets recorded so far, in the NSS
+// key log format, see Config.KeyLogWriter.
+func (r *PcapngRecorder) KeyLog() []byte {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return bytes.Clone(r.keyLog)
+}
+
+// WriteTo writes the recording to w as a pcapng file: a section of a raw IP
+// interface, with a Decryption Secrets Block of the key log before the packets.
+func (r *PcapngRecorder) WriteTo(w io.Writer) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var b []byte
+	b = appendPcapngBlock(b, pcapngBlockSectionHeader, func(b []byte) []byte {
+		b = binary.LittleEndian.AppendUint32(b, pcapngByteOrderMagic)
+		b = binary.LittleEndian.AppendUint16(b, 1) // major version
+		b = binary.LittleEndian.AppendUint16(b, 0) // minor version
+		return binary.LittleEndian.AppendUint64(b, ^uint64(0))
+	})
+	b = appendPcapngBlock(b, pcapngBlockInterface, func(b []byte) []byte {
+		b = binary.LittleEndian.AppendUint16(b, pcapngLinkTypeRaw)
+		b = binary.LittleEndian.AppendUint16(b, 0) // reserved
+		return binary.LittleEndian.AppendUint32(b, 0)
+	})
+	if len(r.keyLog) > 0 {
+		b = appendPcapngBlock(b, pcapngBlockDecryptionSecrets, func(b []byte) []byte {
+			b = binary.LittleEndian.AppendUint32(b, pcapngSecretsTLSKeyLog)
+			b = binary.LittleEndian.AppendUint32(b, uint32(len(r.keyLog)))
+			return append(b, r.keyLog...)
+		})
+	}
+
+	if r.clientAddr == nil {
+		return bytes.NewBuffer(b).WriteTo(w)
+	}
+	// The sequence numbers of the client and the server, after their SYN.
+	clientSeq, serverSeq := uint32(1), uint32(1)
+	b = r.appendPacket(b, r.start, true, tcpFlagSYN, 0, 0, nil)
+	b = r.appendPacket(b, r.start, false, tcpFlagSYN|tcpFlagACK, 0, clientSeq, nil)
+	b = r.appendPacket(b, r.start, true, tcpFlagACK, clientSeq, serverSeq, nil)
+	for _, s := range r.segments {
+		for data := s.data; len(data) > 0; {
+			payload := data[:min(len(data), pcapngMaxSegment)]
+			data = data[len(payload):]
+			if s.sent {
+				b = r.appendPacket(b, s.time, true, tcpFlagPSH|tcpFlagACK, clientSeq, serverSeq, payload)
+				clientSeq += uint32(len(payload))
+			} else {
+				b = r.appendPacket(b, s.time, false, tcpFlagPSH|tcpFlagACK, serverSeq, clientSeq, payload)
+				serverSeq += uint32(len(payload))
+			}
+		}
+	}
+	return bytes.NewBuffer(b).WriteTo(w)
+}
+
+// appendPacket appends an Enhanced Packet Block of a TCP segment sent by the
+// client or by the server.
+func (r *PcapngRecorder) appendPacket(b []byte, t time.Time, fromClient bool, flags uint8, seq, ack uint32, payload []byte) []byte {
+	src, dst := r.clientAddr, r.serverAddr
+	if !fromClient {
+		src, dst = dst, src
+	}
+	packet := appendIPTCPSegment(nil, src, dst, flags, seq, ack, payload)
+	return appendPcapngBlock(b, pcapngBlockEnhancedPacket, func(b []byte) []byte {
+		// Timestamps are in microseconds, the default resolution of interfaces.
+		ts := uint64(t.UnixMicro())
+		b = binary.LittleEndian.AppendUint32(b, 0) // interface
+		b = binary.LittleEndian.AppendUint32(b, uint32(ts>>32))
+		b = binary.LittleEndian.AppendUint32(b, uint32(ts))
+		b = binary.LittleEndian.AppendUint32(b, uint32(len(packet))) // captured length
+		b = binary.LittleEndian.AppendUint32(b, uint32(len(packet))) // original length
+		return append(b, packet...)
+	})
+}
+
+// appendPcapngBlock appends a block of type typ, of which body appends the
+// body, padded to 32 bits.
+func appendPcapngBlock(b []byte, typ uint32, body func([]byte) []byte) []byte {
+	start := len(b)
+	b = binary.LittleEndian.AppendUint32(b, typ)
+	b = binary.LittleEndian.AppendUint32(b, 0) // total length, set below
+	b = body(b)
+	for len(b)%4 != 0 {
+		b = append(b, 0)
+	}
+	length := uint32(len(b) - start + 4)
+	binary.LittleEndian.PutUint32(b[start+4:], length)
+	return binary.LittleEndian.AppendUint32(b, length)
+}
+
+// appendIPTCPSegment appends an IPv4 packet, or an IPv6 one if either address
+// is not an IPv4 address, with a TCP segment of payload.
+func appendIPTCPSegment(b []byte, src, dst *net.TCPAddr, flags uint8, seq, ack uint32, payload []byte) []byte {
+	tcpLength := 20 + len(payload)
+	var pseudoHeader []byte
+	if src4, dst4 := src.IP.To4(), dst.IP.To4(); src4 != nil && dst4 != nil {
+		header := make([]byte, 0, 20)
+		header = append(header, 0x45, 0) // version 4, 20 bytes header
+		header = binary.BigEndian.AppendUint16(header, uint16(20+tcpLength))
+		header = append(header, 0, 0, 0x40, 0) // no identification, don't fragment
+		header = append(header, 64, 6, 0, 0)   // TTL, TCP, checksum set below
+		header = append(header, src4...)
+		header = append(header, dst4...)
+		binary.BigEndian.PutUint16(header[10:], internetChecksum(header))
+		b = append(b, header...)
+
+		pseudoHeader = append(pseudoHeader, src4...)
+		pseudoHeader = append(pseudoHeader, dst4...)
+		pseudoHeader = append(pseudoHeader, 0, 6)
+		pseudoHeader = binary.BigEndian.AppendUint16(pseudoHeader, uint16(tcpLength))
+	} else {
+		b = binary.BigEndian.AppendUint32(b, 6<<28) // version 6
+		b = binary.BigEndian.AppendUint16(b, uint16(tcpLength))
+		b = append(b, 6, 64) // TCP, hop limit
+		b = append(b, src.IP.To16()...)
+		b = append(b, dst.IP.To16()...)
+
+		pseudoHeader = append(pseudoHeader, src.IP.To16()...)
+		pseudoHeader = append(pseudoHeader, dst.IP.To16()...)
+		pseudoHeader = binary.BigEndian.AppendUint32(pseudoHeader, uint32(tcpLength))
+		pseudoHeader = append(pseudoHeader, 0, 0, 0, 6)
+	}
+
+	start := len(b)
+	b = binary.BigEndian.AppendUint16(b, uint16(src.Port))
+	b = binary.BigEndian.AppendUint16(b, uint16(dst.Port))
+	b = binary.BigEndian.AppendUint32(b, seq)
+	b = binary.BigEndian.AppendUint32(b, ack)
+	b = append(b, 5<<4, flags)                   // 20 bytes header
+	b = binary.BigEndian.AppendUint16(b, 0xffff) // window
+	b = append(b, 0, 0, 0, 0)                    // checksum set below, urgent pointer
+	b = append(b, payload...)
+	binary.BigEndian.PutUint16(b[start+16:], internetChecksum(append(pseudoHeader, b[start:]...)))
+	return b
+}
+
+// internetChecksum returns the checksum of RFC 1071 of b.
+func internetChecksum(b []byte) uint16 {
+	var sum uint32
+	for ; len(b) >= 2; b = b[2:] {
+		sum += uint32(b[0])<<8 | uint32(b[1])
+	}
+	if len(b) == 1 {
+		sum += uint32(b[0]) << 8
+	}
+	for sum > 0xffff {
+		sum = sum>>16 + sum&0xffff
+	}
+	return ^uint16(sum)
+}
+
+// pcapngReader records the bytes read from a connection with a PcapngRecorder.
+type pcapngReader struct {
+	r        io.Reader
+	recorder *PcapngRecorder
+}
+
+func (r *pcapngReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.recorder.recordSegment(p[:n], false)
+	return n, err
+}
+
+// recordPcapngSegment records data written to the connection, if it has a
+// PcapngRecorder.
+func (c *Conn) recordPcapngSegment(data []byte) {
+	if r := c.utls.pcapngRecorder; r != nil {
+		r.recordSegment(data, true)
+	}
+}
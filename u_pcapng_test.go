@@ -0,0 +1,121 @@
+// Copyright 2026 uTLS Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tls
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"testing"
+)
+
+// pcapngBlock is a block of a pcapng file, without its type and lengths.
+type pcapngBlock struct {
+	typ  uint32
+	body []byte
+}
+
+func parsePcapng(t *testing.T, b []byte) []pcapngBlock {
+	t.Helper()
+	var blocks []pcapngBlock
+	for len(b) > 0 {
+		if len(b) < 12 {
+			t.Fatalf("truncated block %x", b)
+		}
+		typ, length := binary.LittleEndian.Uint32(b), binary.LittleEndian.Uint32(b[4:])
+		if length%4 != 0 || length < 12 || int(length) > len(b) || binary.LittleEndian.Uint32(b[length-4:]) != length {
+			t.Fatalf("invalid length %d of block %x", length, typ)
+		}
+		blocks = append(blocks, pcapngBlock{typ, b[8 : length-4]})
+		b = b[length:]
+	}
+	return blocks
+}
+
+func TestPcapngRecorder(t *testing.T) {
+	pcapng, handshake := &PcapngRecorder{}, &HandshakeRecorder{}
+	c, s := localPipe(t)
+	go func() {
+		server := Server(s, testConfig.Clone())
+		if err := server.Handshake(); err == nil {
+			server.Write([]byte("hello"))
+		}
+		server.Close()
+	}()
+	client := UClient(c, testConfig.Clone(), HelloChrome_Auto, false, false)
+	client.SetPcapngRecorder(pcapng)
+	client.SetHandshakeRecorder(handshake)
+	defer client.Close()
+	if _, err := client.Read(make([]byte, 5)); err != nil {
+		t.Fatal(err)
+	}
+
+	var file bytes.Buffer
+	if _, err := pcapng.WriteTo(&file); err != nil {
+		t.Fatal(err)
+	}
+	blocks := parsePcapng(t, file.Bytes())
+	if len(blocks) < 3 || blocks[0].typ != pcapngBlockSectionHeader ||
+		binary.LittleEndian.Uint32(blocks[0].body) != pcapngByteOrderMagic {
+		t.Fatal("the file does not start with a section header")
+	}
+	if blocks[1].typ != pcapngBlockInterface || binary.LittleEndian.Uint16(blocks[1].body) != pcapngLinkTypeRaw {
+		t.Fatal("the section has no raw IP interface")
+	}
+	dsb := blocks[2]
+	if dsb.typ != pcapngBlockDecryptionSecrets || binary.LittleEndian.Uint32(dsb.body) != pcapngSecretsTLSKeyLog {
+		t.Fatal("the interface is not followed by a TLS key log")
+	}
+	keyLog := dsb.body[8 : 8+binary.LittleEndian.Uint32(dsb.body[4:])]
+	if !bytes.Equal(keyLog, handshake.KeyLog()) || !bytes.Contains(keyLog, []byte(keyLogLabelServerTraffic)) {
+		t.Errorf("unexpected key log %s", keyLog)
+	}
+
+	// Reassemble the TCP streams of the packets.
+	clientPort := uint16(c.LocalAddr().(*net.TCPAddr).Port)
+	var sent, received []byte
+	var packets int
+	for _, block := range blocks[3:] {
+		if block.typ != pcapngBlockEnhancedPacket {
+			t.Fatalf("unexpected block %x", block.typ)
+		}
+		packet := block.body[20 : 20+binary.LittleEndian.Uint32(block.body[12:])]
+		if packet[0] != 0x45 || int(binary.BigEndian.Uint16(packet[2:])) != len(packet) || packet[9] != 6 {
+			t.Fatalf("invalid IPv4 header %x", packet[:20])
+		}
+		if internetChecksum(packet[:20]) != 0 {
+			t.Error("invalid IPv4 checksum")
+		}
+		pseudoHeader := append(append([]byte(nil), packet[12:20]...), 0, 6, byte((len(packet)-20)>>8), byte(len(packet)-20))
+		if internetChecksum(append(pseudoHeader, packet[20:]...)) != 0 {
+			t.Error("invalid TCP checksum")
+		}
+		packets++
+		if packets <= 3 {
+			continue // the TCP handshake
+		}
+		seq := binary.BigEndian.Uint32(packet[24:])
+		if binary.BigEndian.Uint16(packet[20:]) == clientPort {
+			if seq != uint32(1+len(sent)) {
+				t.Errorf("got sequence number %d, want %d", seq, 1+len(sent))
+			}
+			sent = append(sent, packet[40:]...)
+		} else {
+			received = append(received, packet[40:]...)
+		}
+	}
+	if packets < 5 {
+		t.Fatalf("got %d packets", packets)
+	}
+
+	// The streams are the records of the handshake messages.
+	messages := handshake.Messages()
+	if recordType(sent[0]) != recordTypeHandshake || !bytes.Contains(sent, messages[0].Data) {
+		t.Error("the client stream does not start with the ClientHello")
+	}
+	if recordType(received[0]) != recordTypeHandshake || !bytes.Contains(received, messages[1].Data) {
+		t.Error("the server stream does not start with the ServerHello")
+	}
+}
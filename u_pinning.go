@@ -0,0 +1,152 @@
+// Copyright 2026 uTLS Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tls
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"strings"
+)
+
+// SPKIPin is the SHA-256 hash of the DER-encoded SubjectPublicKeyInfo of a
+// certificate.
+type SPKIPin [32]byte
+
+// SPKIPinOf returns the pin of the public key of cert.
+func SPKIPinOf(cert *x509.Certificate) SPKIPin {
+	return sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+}
+
+// ParseSPKIPin parses a pin encoded in base64, optionally prefixed with
+// "sha256/" as in HTTP Public Key Pinning and most pinning configurations.
+func ParseSPKIPin(s string) (SPKIPin, error) {
+	var pin SPKIPin
+	b, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(s, "sha256/"))
+	if err != nil || len(b) != len(pin) {
+		return pin, fmt.Errorf("tls: invalid SPKI pin %q", s)
+	}
+	copy(pin[:], b)
+	return pin, nil
+}
+
+// String returns the pin in the "sha256/" form accepted by ParseSPKIPin.
+func (p SPKIPin) String() string {
+	return "sha256/" + base64.StdEncoding.EncodeToString(p[:])
+}
+
+// HostPins are the pins of a host. A verified certificate chain matches if any
+// of its certificates has the public key of a pin or a backup pin. When the
+// chains aren't verified, as with InsecureSkipVerify, only the leaf
+// certificate, whose key the handshake proves the server holds, can match.
+type HostPins struct {
+	Pins []SPKIPin
+
+	// BackupPins are the pins of keys which are not deployed yet, to which
+	// the host can switch without breaking clients.
+	BackupPins []SPKIPin
+}
+
+// CertificatePins configures the public key pinning of servers, see
+// Config.CertificatePins.
+type CertificatePins struct {
+	// Hosts maps lower-case host names, or patterns like "*.example.com"
+	// which match a single label, to their pins. An exact host name takes
+	// precedence over a pattern. Hosts without pins are not checked.
+	Hosts map[string]*HostPins
+
+	// ReportOnly, if true, only reports mismatches with Report rather than
+	// rejecting the certificates.
+	ReportOnly bool
+
+	// Report, if not nil, is called with the mismatches.
+	Report func(err *PinMismatchError)
+}
+
+// PinMismatchError is returned by Handshake, wrapped in a
+// CertificateVerificationError, when no certificate of the chains of a server
+// matches the pins of its host.
+type PinMismatchError struct {
+	// Host is the server name, and Pattern the entry of CertificatePins.Hosts
+	// which matched it.
+	Host    string
+	Pattern string
+
+	// PeerPins are the pins of the certificates of the server, in the order
+	// of the first verified chain, or as sent by the server if the chains
+	// weren't verified.
+	PeerPins []SPKIPin
+}
+
+func (e *PinMismatchError) Error() string {
+	return fmt.Sprintf("tls: certificate of %s does not match the pins of %s", e.Host, e.Pattern)
+}
+
+// lookup returns the pins of host, and the entry of Hosts they come from.
+func (p *CertificatePins) lookup(host string) (*HostPins, string) {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	if pins, ok := p.Hosts[host]; ok {
+		return pins, host
+	}
+	if _, parent, ok := strings.Cut(host, "."); ok {
+		pattern := "*." + parent
+		if pins, ok := p.Hosts[pattern]; ok {
+			return pins, pattern
+		}
+	}
+	return nil, ""
+}
+
+// checkPins checks the certificates of the server against
+// Config.CertificatePins. Verified chains are checked if any, or else the leaf
+// certificate only, as any other certificate sent by the server, such as the
+// public intermediate of a pinned host, proves nothing.
+func (c *Conn) checkPins(certs []*x509.Certificate) error {
+	config := c.config.CertificatePins
+	if config == nil {
+		return nil
+	}
+	pins, pattern := config.lookup(c.config.ServerName)
+	if pins == nil || len(pins.Pins)+len(pins.BackupPins) == 0 {
+		return nil
+	}
+
+	accepted := make(map[SPKIPin]bool)
+	for _, pin := range pins.Pins {
+		accepted[pin] = true
+	}
+	for _, pin := range pins.BackupPins {
+		accepted[pin] = true
+	}
+	chains := c.verifiedChains
+	if len(chains) == 0 && len(certs) > 0 {
+		chains = [][]*x509.Certificate{certs[:1]}
+	}
+	for _, chain := range chains {
+		for _, cert := range chain {
+			if accepted[SPKIPinOf(cert)] {
+				return nil
+			}
+		}
+	}
+
+	err := &PinMismatchError{Host: c.config.ServerName, Pattern: pattern}
+	peerCerts := certs
+	if len(c.verifiedChains) > 0 {
+		peerCerts = c.verifiedChains[0]
+	}
+	for _, cert := range peerCerts {
+		err.PeerPins = append(err.PeerPins, SPKIPinOf(cert))
+	}
+	if config.Report != nil {
+		config.Report(err)
+	}
+	if config.ReportOnly {
+		return nil
+	}
+	c.sendAlert(alertBadCertificate)
+	return &CertificateVerificationError{UnverifiedCertificates: certs, Err: err}
+}
@@ -0,0 +1,141 @@
+// Copyright 2026 uTLS Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tls
+
+import (
+	"crypto/x509"
+	"errors"
+	"testing"
+	"time"
+)
+
+func testPinningHandshake(t *testing.T, p *revocationTestPKI, serverName string, pins *CertificatePins) error {
+	t.Helper()
+	return testPinningHandshakeWithCert(t, p, p.leafCert, serverName, pins)
+}
+
+func testPinningHandshakeWithCert(t *testing.T, p *revocationTestPKI, cert Certificate, serverName string, pins *CertificatePins) error {
+	t.Helper()
+	serverConfig := testConfig.Clone()
+	serverConfig.Time = func() time.Time { return revocationTestTime }
+	serverConfig.Certificates = []Certificate{cert}
+
+	clientConfig := testConfig.Clone()
+	clientConfig.Time = func() time.Time { return revocationTestTime }
+	clientConfig.ServerName = serverName
+	clientConfig.RootCAs = x509.NewCertPool()
+	clientConfig.RootCAs.AddCert(p.ca)
+	clientConfig.InsecureSkipVerify = serverName != "example.golang"
+	clientConfig.CertificatePins = pins
+
+	c, s := localPipe(t)
+	done := make(chan error, 1)
+	go func() {
+		server := Server(s, serverConfig)
+		done <- server.Handshake()
+		server.Close()
+	}()
+	client := Client(c, clientConfig)
+	err := client.Handshake()
+	client.Close()
+	<-done
+	return err
+}
+
+func TestCertificatePins(t *testing.T) {
+	p := newRevocationTestPKI(t)
+	other := newRevocationTestPKI(t)
+	leafPin, caPin, otherPin := SPKIPinOf(p.leaf), SPKIPinOf(p.ca), SPKIPinOf(other.ca)
+
+	tests := []struct {
+		name       string
+		serverName string
+		hosts      map[string]*HostPins
+		mismatch   string // the pattern reported, if any
+	}{
+		{"Leaf", "example.golang", map[string]*HostPins{"example.golang": {Pins: []SPKIPin{leafPin}}}, ""},
+		{"CA", "example.golang", map[string]*HostPins{"example.golang": {Pins: []SPKIPin{otherPin, caPin}}}, ""},
+		{"Backup", "example.golang", map[string]*HostPins{"example.golang": {Pins: []SPKIPin{otherPin}, BackupPins: []SPKIPin{leafPin}}}, ""},
+		{"Mismatch", "example.golang", map[string]*HostPins{"example.golang": {Pins: []SPKIPin{otherPin}}}, "example.golang"},
+		{"OtherHost", "example.golang", map[string]*HostPins{"other.golang": {Pins: []SPKIPin{otherPin}}}, ""},
+		{"Wildcard", "www.example.golang", map[string]*HostPins{"*.example.golang": {Pins: []SPKIPin{otherPin}}}, "*.example.golang"},
+		{"WildcardSingleLabel", "a.www.example.golang", map[string]*HostPins{"*.example.golang": {Pins: []SPKIPin{otherPin}}}, ""},
+		{"ExactOverWildcard", "WWW.example.golang", map[string]*HostPins{
+			"*.example.golang":   {Pins: []SPKIPin{otherPin}},
+			"www.example.golang": {Pins: []SPKIPin{leafPin}},
+		}, ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var reported *PinMismatchError
+			pins := &CertificatePins{
+				Hosts:  test.hosts,
+				Report: func(err *PinMismatchError) { reported = err },
+			}
+			err := testPinningHandshake(t, p, test.serverName, pins)
+			if test.mismatch == "" {
+				if err != nil || reported != nil {
+					t.Fatalf("unexpected mismatch: %v, %v", err, reported)
+				}
+				return
+			}
+			var pinErr *PinMismatchError
+			if !errors.As(err, &pinErr) {
+				t.Fatalf("got error %v, want a PinMismatchError", err)
+			}
+			if pinErr.Pattern != test.mismatch || reported != pinErr {
+				t.Errorf("got pattern %q, want %q", pinErr.Pattern, test.mismatch)
+			}
+			if len(pinErr.PeerPins) != 2 || pinErr.PeerPins[0] != leafPin || pinErr.PeerPins[1] != caPin {
+				t.Errorf("unexpected peer pins %v", pinErr.PeerPins)
+			}
+
+			reported = nil
+			pins.ReportOnly = true
+			if err := testPinningHandshake(t, p, test.serverName, pins); err != nil {
+				t.Errorf("a mismatch was rejected in report-only mode: %v", err)
+			}
+			if reported == nil {
+				t.Error("a mismatch wasn't reported in report-only mode")
+			}
+		})
+	}
+}
+
+func TestCertificatePinsUnverifiedChain(t *testing.T) {
+	p, attacker := newRevocationTestPKI(t), newRevocationTestPKI(t)
+	pins := &CertificatePins{Hosts: map[string]*HostPins{
+		"www.example.golang": {Pins: []SPKIPin{SPKIPinOf(p.ca)}},
+	}}
+
+	// Without verified chains, the pinned CA appended by an attacker to its
+	// own chain must not match.
+	cert := attacker.leafCert
+	cert.Certificate = append(cert.Certificate[:len(cert.Certificate):len(cert.Certificate)], p.ca.Raw)
+	err := testPinningHandshakeWithCert(t, p, cert, "www.example.golang", pins)
+	var pinErr *PinMismatchError
+	if !errors.As(err, &pinErr) {
+		t.Fatalf("got error %v, want a PinMismatchError", err)
+	}
+
+	// The leaf is still checked.
+	pins.Hosts["www.example.golang"].Pins = []SPKIPin{SPKIPinOf(attacker.leaf)}
+	if err := testPinningHandshakeWithCert(t, p, cert, "www.example.golang", pins); err != nil {
+		t.Errorf("the pinned leaf of an unverified chain was rejected: %v", err)
+	}
+}
+
+func TestParseSPKIPin(t *testing.T) {
+	p := newRevocationTestPKI(t)
+	pin := SPKIPinOf(p.leaf)
+	for _, s := range []string{pin.String(), pin.String()[len("sha256/"):]} {
+		if parsed, err := ParseSPKIPin(s); err != nil || parsed != pin {
+			t.Errorf("ParseSPKIPin(%q) = %v, %v", s, parsed, err)
+		}
+	}
+	if _, err := ParseSPKIPin("sha256/AAAA"); err == nil {
+		t.Error("a short pin was accepted")
+	}
+}
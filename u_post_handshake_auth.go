@@ -0,0 +1,255 @@
+// Copyright 2026 uTLS Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tls
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/hmac"
+	"crypto/rsa"
+	"errors"
+	"hash"
+)
+
+// Post-handshake authentication, RFC 8446, Section 4.6.2. A TLS 1.3 server can
+// request a certificate at any time after the handshake from a client which
+// offered post_handshake_auth. The client answers with Certificate,
+// CertificateVerify and Finished messages, authenticated with the transcript
+// up to its Finished message followed by the CertificateRequest, which carries
+// a certificate_request_context, possibly empty, echoed in the Certificate
+// message.
+//
+// UConn offers it with PostHandshakeAuthExtension in its ClientHelloSpec.
+// Servers only request it with requestClientCertificate, for tests.
+
+// postHandshakeAuthState is the state of a connection on which the client
+// offered post_handshake_auth.
+type postHandshakeAuthState struct {
+	suite      *cipherSuiteTLS13
+	transcript hash.Hash // up to the client Finished message
+
+	// certReq is the pending CertificateRequest of a server, and
+	// certReqTranscript the transcript up to it. Both are guarded by c.out.
+	certReq           *certificateRequestMsgTLS13
+	certReqTranscript hash.Hash
+}
+
+// enablePostHandshakeAuth keeps the transcript of a TLS 1.3 handshake, up to
+// the client Finished message, for post-handshake authentication. QUIC
+// doesn't allow it, see RFC 9001, Section 4.4.
+func (c *Conn) enablePostHandshakeAuth(suite *cipherSuiteTLS13, transcript hash.Hash) {
+	if c.quic != nil {
+		return
+	}
+	c.utls.postHandshakeAuth = &postHandshakeAuthState{suite: suite, transcript: transcript}
+}
+
+// handlePostHandshakeCertificateRequest answers a post-handshake
+// CertificateRequest with the certificate from Config.Certificates or
+// Config.GetClientCertificate, which may be empty.
+func (c *Conn) handlePostHandshakeCertificateRequest(certReq *certificateRequestMsgTLS13) error {
+	state := c.utls.postHandshakeAuth
+	if !c.isClient || state == nil {
+		c.sendAlert(alertUnexpectedMessage)
+		return errors.New("tls: received unexpected post-handshake certificate request")
+	}
+
+	transcript := cloneHash(state.transcript, state.suite.hash)
+	if transcript == nil {
+		c.sendAlert(alertInternalError)
+		return errors.New("tls: internal error: failed to clone hash")
+	}
+	if err := transcriptMsg(certReq, transcript); err != nil {
+		return err
+	}
+
+	cert, err := c.getClientCertificate(&CertificateRequestInfo{
+		AcceptableCAs:    certReq.certificateAuthorities,
+		SignatureSchemes: certReq.supportedSignatureAlgorithms,
+		Version:          c.vers,
+		ctx:              context.Background(),
+	})
+	if err != nil {
+		c.sendAlert(alertInternalError)
+		return err
+	}
+
+	certMsg := new(certificateMsgTLS13)
+	certMsg.certificate = *cert
+	certMsg.scts = certReq.scts && len(cert.SignedCertificateTimestamps) > 0
+	certMsg.ocspStapling = certReq.ocspStapling && len(cert.OCSPStaple) > 0
+	certMsg.context = certReq.context
+
+	if _, err := c.writeHandshakeRecord(certMsg, transcript); err != nil {
+		return err
+	}
+
+	// If we sent an empty certificate message, skip the CertificateVerify.
+	if len(cert.Certificate) != 0 {
+		certVerifyMsg := new(certificateVerifyMsg)
+		certVerifyMsg.hasSignatureAlgorithm = true
+		certVerifyMsg.signatureAlgorithm, err = selectSignatureScheme(c.vers, cert, certReq.supportedSignatureAlgorithms)
+		if err == nil {
+			err = c.config.checkShangMiSignature(certVerifyMsg.signatureAlgorithm)
+		}
+		if err != nil {
+			c.sendAlert(alertHandshakeFailure)
+			return err
+		}
+		sigType, sigHash, err := typeAndHashFromSignatureScheme(certVerifyMsg.signatureAlgorithm)
+		if err != nil {
+			return c.sendAlert(alertInternalError)
+		}
+		signed := signedMessage(sigHash, clientSignatureContext, transcript)
+		signOpts := crypto.SignerOpts(sigHash)
+		if sigType == signatureRSAPSS {
+			signOpts = &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash, Hash: sigHash}
+		}
+		certVerifyMsg.signature, err = cert.PrivateKey.(crypto.Signer).Sign(c.config.rand(), signed, signOpts)
+		if err != nil {
+			c.sendAlert(alertInternalError)
+			return errors.New("tls: failed to sign handshake: " + err.Error())
+		}
+		if _, err := c.writeHandshakeRecord(certVerifyMsg, transcript); err != nil {
+			return err
+		}
+	}
+
+	// The Finished message is keyed with the current client application
+	// traffic secret. See RFC 8446, Section 4.4.
+	finished := &finishedMsg{
+		verifyData: state.suite.finishedHash(c.out.trafficSecret, transcript),
+	}
+	_, err = c.writeHandshakeRecord(finished, nil)
+	return err
+}
+
+// requestClientCertificate sends a post-handshake CertificateRequest with
+// certReqContext to a client which offered post_handshake_auth. The answer of
+// the client is read, and its certificate verified according to
+// Config.ClientAuth, by Read.
+func (c *Conn) requestClientCertificate(certReqContext []byte) error {
+	if c.isClient || !c.isHandshakeComplete.Load() || c.vers != VersionTLS13 {
+		return errors.New("tls: post-handshake authentication is only requested by TLS 1.3 servers")
+	}
+	state := c.utls.postHandshakeAuth
+	if state == nil {
+		return errors.New("tls: client does not support post-handshake authentication")
+	}
+
+	certReq := new(certificateRequestMsgTLS13)
+	certReq.ocspStapling = true
+	certReq.scts = true
+	certReq.supportedSignatureAlgorithms = c.config.supportedSignatureAlgorithms()
+	if c.config.ClientCAs != nil {
+		certReq.certificateAuthorities = c.config.ClientCAs.Subjects()
+	}
+	certReq.context = certReqContext
+	transcript := cloneHash(state.transcript, state.suite.hash)
+	if transcript == nil {
+		return errors.New("tls: internal error: failed to clone hash")
+	}
+	data, err := certReq.marshal()
+	if err != nil {
+		return err
+	}
+	transcript.Write(data)
+
+	c.out.Lock()
+	defer c.out.Unlock()
+
+	if state.certReq != nil {
+		return errors.New("tls: a post-handshake certificate request is already pending")
+	}
+	if _, err := c.writeRecordLocked(recordTypeHandshake, data); err != nil {
+		return err
+	}
+	state.certReq, state.certReqTranscript = certReq, transcript
+	return nil
+}
+
+// handlePostHandshakeCertificate reads the answer of the client to the
+// pending post-handshake CertificateRequest, and verifies it.
+func (c *Conn) handlePostHandshakeCertificate(certMsg *certificateMsgTLS13) error {
+	var certReq *certificateRequestMsgTLS13
+	var transcript hash.Hash
+	state := c.utls.postHandshakeAuth
+	if !c.isClient && state != nil {
+		c.out.Lock()
+		certReq, transcript = state.certReq, state.certReqTranscript
+		state.certReq, state.certReqTranscript = nil, nil
+		c.out.Unlock()
+	}
+	if certReq == nil {
+		c.sendAlert(alertUnexpectedMessage)
+		return errors.New("tls: received unexpected post-handshake certificate")
+	}
+	if !bytes.Equal(certMsg.context, certReq.context) {
+		c.sendAlert(alertIllegalParameter)
+		return errors.New("tls: received post-handshake certificate with the wrong context")
+	}
+	if err := transcriptMsg(certMsg, transcript); err != nil {
+		return err
+	}
+
+	if err := c.processCertsFromClient(certMsg.certificate); err != nil {
+		return err
+	}
+	if c.config.VerifyConnection != nil {
+		if err := c.config.VerifyConnection(c.connectionStateLocked()); err != nil {
+			c.sendAlert(alertBadCertificate)
+			return err
+		}
+	}
+
+	if len(certMsg.certificate.Certificate) != 0 {
+		msg, err := c.readHandshake(nil)
+		if err != nil {
+			return err
+		}
+		certVerify, ok := msg.(*certificateVerifyMsg)
+		if !ok {
+			c.sendAlert(alertUnexpectedMessage)
+			return unexpectedMessageError(certVerify, msg)
+		}
+		if !isSupportedSignatureAlgorithm(certVerify.signatureAlgorithm, certReq.supportedSignatureAlgorithms) {
+			c.sendAlert(alertIllegalParameter)
+			return errors.New("tls: client certificate used with invalid signature algorithm")
+		}
+		sigType, sigHash, err := typeAndHashFromSignatureScheme(certVerify.signatureAlgorithm)
+		if err != nil {
+			return c.sendAlert(alertInternalError)
+		}
+		if sigType == signaturePKCS1v15 || sigHash == crypto.SHA1 {
+			c.sendAlert(alertIllegalParameter)
+			return errors.New("tls: client certificate used with invalid signature algorithm")
+		}
+		signed := signedMessage(sigHash, clientSignatureContext, transcript)
+		if err := verifyHandshakeSignature(sigType, c.peerPublicKey,
+			sigHash, signed, certVerify.signature); err != nil {
+			c.sendAlert(alertDecryptError)
+			return errors.New("tls: invalid signature by the client certificate: " + err.Error())
+		}
+		if err := transcriptMsg(certVerify, transcript); err != nil {
+			return err
+		}
+	}
+
+	msg, err := c.readHandshake(nil)
+	if err != nil {
+		return err
+	}
+	finished, ok := msg.(*finishedMsg)
+	if !ok {
+		c.sendAlert(alertUnexpectedMessage)
+		return unexpectedMessageError(finished, msg)
+	}
+	if !hmac.Equal(state.suite.finishedHash(c.in.trafficSecret, transcript), finished.verifyData) {
+		c.sendAlert(alertDecryptError)
+		return errors.New("tls: invalid client finished hash")
+	}
+	return nil
+}
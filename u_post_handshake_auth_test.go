@@ -0,0 +1,157 @@
+// Copyright 2026 uTLS Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tls
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+// testPostHandshakeAuthSpec returns the latest Chrome spec, without its
+// pre_shared_key extension and with a PostHandshakeAuthExtension.
+func testPostHandshakeAuthSpec(t *testing.T) *ClientHelloSpec {
+	chrome, err := utlsIdToSpec(HelloChrome_Auto)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exts := chrome.Extensions[:0]
+	for _, ext := range chrome.Extensions {
+		if _, ok := ext.(PreSharedKeyExtension); !ok {
+			exts = append(exts, ext)
+		}
+	}
+	chrome.Extensions = append(exts, &PostHandshakeAuthExtension{})
+	return &chrome
+}
+
+// testPostHandshakeAuth runs a handshake, after which the server requests a
+// client certificate with clientAuth and certReqContext, and returns the
+// ConnectionState of the server and the error of its Read, which processes the
+// answer of the client.
+func testPostHandshakeAuth(t *testing.T, clientConfig *Config, clientAuth ClientAuthType, certReqContext []byte) (ConnectionState, error) {
+	t.Helper()
+	c, s := localPipe(t)
+	serverConfig := testConfig.Clone()
+	serverConfig.MinVersion = VersionTLS13
+
+	type result struct {
+		state ConnectionState
+		err   error
+	}
+	serverChan := make(chan result, 1)
+	go func() {
+		server := Server(s, serverConfig)
+		defer server.Close()
+		if err := server.Handshake(); err != nil {
+			serverChan <- result{err: err}
+			return
+		}
+		server.config.ClientAuth = clientAuth
+		if err := server.requestClientCertificate(certReqContext); err != nil {
+			serverChan <- result{err: err}
+			return
+		}
+		if _, err := server.Write([]byte("ping")); err != nil {
+			serverChan <- result{err: err}
+			return
+		}
+		buf := make([]byte, 4)
+		_, err := io.ReadFull(server, buf)
+		serverChan <- result{server.ConnectionState(), err}
+	}()
+
+	client := UClient(c, clientConfig, HelloCustom, false, false)
+	defer client.Close()
+	if err := client.ApplyPreset(testPostHandshakeAuthSpec(t)); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Handshake(); err != nil {
+		t.Fatalf("client: %v", err)
+	}
+	buf := make([]byte, 4)
+	if _, err := io.ReadFull(client, buf); err == nil {
+		client.Write([]byte("pong"))
+	}
+	r := <-serverChan
+	return r.state, r.err
+}
+
+func TestPostHandshakeAuth(t *testing.T) {
+	clientConfig := testConfig.Clone()
+	state, err := testPostHandshakeAuth(t, clientConfig, RequireAnyClientCert, []byte("context"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(state.PeerCertificates) == 0 {
+		t.Error("the server didn't get the client certificate")
+	}
+
+	getClientCertificateCalled := false
+	clientConfig.Certificates = nil
+	clientConfig.GetClientCertificate = func(cri *CertificateRequestInfo) (*Certificate, error) {
+		getClientCertificateCalled = true
+		return &testConfig.Certificates[0], nil
+	}
+	state, err = testPostHandshakeAuth(t, clientConfig, RequireAnyClientCert, []byte("context"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !getClientCertificateCalled || len(state.PeerCertificates) == 0 {
+		t.Error("GetClientCertificate wasn't used")
+	}
+
+	// The certificate_request_context may be empty.
+	state, err = testPostHandshakeAuth(t, clientConfig, RequireAnyClientCert, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(state.PeerCertificates) == 0 {
+		t.Error("the server didn't get the client certificate with an empty context")
+	}
+}
+
+func TestPostHandshakeAuthGetClientCertificateError(t *testing.T) {
+	clientConfig := testConfig.Clone()
+	clientConfig.Certificates = nil
+	clientConfig.GetClientCertificate = func(cri *CertificateRequestInfo) (*Certificate, error) {
+		return nil, errors.New("no certificate")
+	}
+	_, err := testPostHandshakeAuth(t, clientConfig, RequestClientCert, []byte("context"))
+	if err == nil || !strings.Contains(err.Error(), "internal error") {
+		t.Fatalf("got %v, want an internal_error alert", err)
+	}
+}
+
+func TestPostHandshakeAuthNoCertificate(t *testing.T) {
+	clientConfig := testConfig.Clone()
+	clientConfig.Certificates = nil
+
+	if _, err := testPostHandshakeAuth(t, clientConfig, RequestClientCert, []byte("context")); err != nil {
+		t.Fatalf("an empty certificate was rejected: %v", err)
+	}
+	if _, err := testPostHandshakeAuth(t, clientConfig, RequireAnyClientCert, []byte("context")); err == nil {
+		t.Fatal("an empty certificate was accepted")
+	}
+}
+
+func TestPostHandshakeAuthNotOffered(t *testing.T) {
+	serverConfig := testConfig.Clone()
+	serverConfig.MinVersion = VersionTLS13
+	c, s := localPipe(t)
+	defer c.Close()
+	go func() {
+		Client(c, testConfig.Clone()).Handshake()
+	}()
+	server := Server(s, serverConfig)
+	defer server.Close()
+	if err := server.Handshake(); err != nil {
+		t.Fatal(err)
+	}
+	if err := server.requestClientCertificate([]byte("context")); err == nil {
+		t.Error("requested a certificate from a client which didn't offer post_handshake_auth")
+	}
+}
@@ -3,7 +3,9 @@ package tls
 import (
 	"encoding/json"
 	"errors"
+	"hash"
 	"io"
+	"slices"
 
 	"golang.org/x/crypto/cryptobyte"
 )
@@ -342,7 +344,7 @@ func (e *FakePreSharedKeyExtension) writeToUConn(uc *UConn) error {
 	if uc.config.ClientSessionCache == nil {
 		return nil // don't write the extension if there is no session cache
 	}
-	if session, ok := uc.config.ClientSessionCache.Get(uc.clientSessionCacheKey()); !ok || session == nil {
+	if session, ok := uc.peekClientSession(uc.clientSessionCacheKey()); !ok || session == nil {
 		return nil // don't write the extension if there is no session cache available for this session
 	}
 	uc.HandshakeState.Hello.PskIdentities = e.Identities
@@ -483,4 +485,164 @@ var (
 	_ TLSExtensionWriter    = (*FakePreSharedKeyExtension)(nil)
 )
 
-// type ExternalPreSharedKeyExtension struct{} // TODO: wait for whoever cares about external PSK to implement it
+// ExternalPreSharedKeyExtension is an extension used to offer external PSKs
+// (see ExternalPSK) in the ClientHello, after the resumption ticket if there
+// is one, with one binder per identity.
+//
+// It replaces UtlsPreSharedKeyExtension as the last extension of a
+// ClientHelloSpec. Unlike the latter, it does not need a ClientSessionCache.
+//
+// External PSKs can't be offered with Encrypted Client Hello, which only
+// protects the resumption PSKs of the inner ClientHello: building the
+// ClientHello fails if both are configured.
+type ExternalPreSharedKeyExtension struct {
+	UnimplementedPreSharedKeyExtension
+
+	// PSKs are offered in order. If empty, Config.ExternalPSKs is used.
+	PSKs []ExternalPSK
+
+	// Deprecated: Set OmitEmptyPsk in Config instead.
+	OmitEmptyPsk bool
+
+	// resumption is the resumed session, set by InitializeByUtls.
+	resumption PreSharedKeyCommon
+	offers     []*externalPSKOffer
+	binders    [][]byte
+}
+
+func (e *ExternalPreSharedKeyExtension) IsInitialized() bool {
+	return e.resumption.Session != nil
+}
+
+func (e *ExternalPreSharedKeyExtension) InitializeByUtls(session *SessionState, earlySecret []byte, binderKey []byte, identities []PskIdentity) {
+	e.resumption = PreSharedKeyCommon{
+		Identities:  identities,
+		BinderKey:   binderKey,
+		EarlySecret: earlySecret,
+		Session:     session,
+	}
+	e.binders = e.placeholderBinders()
+}
+
+// prepare derives the PSKs to offer with the cipher suites of the ClientHello.
+// It is called by ApplyPreset, so that the length of the extension is known
+// before any extension marshals the ClientHello in writeToUConn.
+func (e *ExternalPreSharedKeyExtension) prepare(uc *UConn) error {
+	psks := e.PSKs
+	if len(psks) == 0 {
+		psks = uc.config.ExternalPSKs
+	}
+	if len(psks) > 0 && uc.config.EncryptedClientHelloConfigList != nil {
+		return errExternalPSKWithECH
+	}
+	offers, err := externalPSKOffers(psks, uc.HandshakeState.Hello.CipherSuites)
+	if err != nil {
+		return err
+	}
+	e.offers = offers
+	e.binders = e.placeholderBinders()
+	return nil
+}
+
+func (e *ExternalPreSharedKeyExtension) writeToUConn(uc *UConn) error {
+	if err := e.prepare(uc); err != nil {
+		return err
+	}
+	uc.utls.externalPSKs = e.offers
+	uc.HandshakeState.Hello.TicketSupported = true
+	return nil
+}
+
+func (e *ExternalPreSharedKeyExtension) identities() []PskIdentity {
+	identities := slices.Clone(e.resumption.Identities)
+	for _, offer := range e.offers {
+		identities = append(identities, PskIdentity{Label: offer.identity})
+	}
+	return identities
+}
+
+func (e *ExternalPreSharedKeyExtension) bindersKeys() []pskBinderKey {
+	var keys []pskBinderKey
+	if e.resumption.Session != nil {
+		keys = append(keys, pskBinderKey{cipherSuiteTLS13ByID(e.resumption.Session.cipherSuite), e.resumption.BinderKey})
+	}
+	for _, offer := range e.offers {
+		keys = append(keys, pskBinderKey{offer.suite, offer.binderKey})
+	}
+	return keys
+}
+
+func (e *ExternalPreSharedKeyExtension) placeholderBinders() [][]byte {
+	var binders [][]byte
+	for _, k := range e.bindersKeys() {
+		binders = append(binders, make([]byte, k.suite.hash.Size()))
+	}
+	return binders
+}
+
+func (e *ExternalPreSharedKeyExtension) GetPreSharedKeyCommon() PreSharedKeyCommon {
+	common := e.resumption
+	common.Identities = e.identities()
+	common.Binders = e.binders
+	return common
+}
+
+func (e *ExternalPreSharedKeyExtension) Len() int {
+	return pskExtLen(e.identities(), e.binders)
+}
+
+func (e *ExternalPreSharedKeyExtension) SetOmitEmptyPsk(val bool) {
+	e.OmitEmptyPsk = val
+}
+
+func (e *ExternalPreSharedKeyExtension) Read(b []byte) (int, error) {
+	if !e.OmitEmptyPsk && e.Len() == 0 {
+		return 0, ErrEmptyPsk
+	}
+	return readPskIntoBytes(b, e.identities(), e.binders)
+}
+
+func (e *ExternalPreSharedKeyExtension) PatchBuiltHello(hello *PubClientHelloMsg) error {
+	if e.Len() == 0 {
+		return nil
+	}
+	private := hello.getCachedPrivatePtr()
+	if private == nil {
+		private = hello.getPrivatePtr()
+	}
+	private.original = hello.Raw
+	private.pskBinders = e.binders
+
+	helloBytes, err := private.marshalWithoutBinders()
+	if err != nil {
+		return err
+	}
+	if err := computeAndUpdatePSKBinders(private, e.bindersKeys(), func(h tls13Hash) (hash.Hash, error) {
+		return h.New(), nil
+	}); err != nil {
+		return err
+	}
+
+	b := cryptobyte.NewFixedBuilder(private.original[:len(helloBytes)])
+	b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+		for _, binder := range private.pskBinders {
+			b.AddUint8LengthPrefixed(func(b *cryptobyte.Builder) {
+				b.AddBytes(binder)
+			})
+		}
+	})
+	if out, err := b.Bytes(); err != nil || len(out) != len(private.original) {
+		return errors.New("tls: internal error: failed to update binders")
+	}
+	e.binders = private.pskBinders
+	return nil
+}
+
+func (e *ExternalPreSharedKeyExtension) UnmarshalJSON(_ []byte) error {
+	return nil // ignore the data
+}
+
+var (
+	_ PreSharedKeyExtension = (*ExternalPreSharedKeyExtension)(nil)
+	_ TLSExtensionJSON      = (*ExternalPreSharedKeyExtension)(nil)
+)
@@ -238,18 +238,30 @@ type PubCipherSuiteTLS13 struct {
 	Id     uint16
 	KeyLen int
 	Aead   func(key, fixedNonce []byte) aead
-	Hash   crypto.Hash
+
+	// Hash is zero for the ShangMi suites, as their SM3 hash has no
+	// crypto.Hash value. NewHash supports all the suites.
+	Hash crypto.Hash
+}
+
+// NewHash returns a new hash.Hash of the hash of the suite.
+func (c *PubCipherSuiteTLS13) NewHash() hash.Hash {
+	return c.toPrivate().hash.New()
 }
 
 func (c *cipherSuiteTLS13) toPublic() *PubCipherSuiteTLS13 {
 	if c == nil {
 		return nil
 	} else {
+		h := crypto.Hash(c.hash)
+		if c.hash == hashSM3 { // [uTLS]
+			h = 0
+		}
 		return &PubCipherSuiteTLS13{
 			Id:     c.id,
 			KeyLen: c.keyLen,
 			Aead:   c.aead,
-			Hash:   c.hash,
+			Hash:   h,
 		}
 	}
 }
@@ -258,11 +270,15 @@ func (c *PubCipherSuiteTLS13) toPrivate() *cipherSuiteTLS13 {
 	if c == nil {
 		return nil
 	} else {
+		h := tls13Hash(c.Hash)
+		if h == 0 && isShangMiCipherSuite(c.Id) { // [uTLS]
+			h = hashSM3
+		}
 		return &cipherSuiteTLS13{
 			id:     c.Id,
 			keyLen: c.KeyLen,
 			aead:   c.Aead,
-			hash:   c.Hash,
+			hash:   h,
 		}
 	}
 }
@@ -279,6 +295,7 @@ type PubServerHelloMsg struct {
 	OcspStapling                 bool
 	Scts                         [][]byte
 	ExtendedMasterSecret         bool
+	EncryptThenMAC               bool
 	TicketSupported              bool // used by go tls to determine whether to add the session ticket ext
 	SecureRenegotiation          []byte
 	SecureRenegotiationSupported bool
@@ -310,6 +327,7 @@ func (shm *PubServerHelloMsg) getPrivatePtr() *serverHelloMsg {
 			ocspStapling:                 shm.OcspStapling,
 			scts:                         shm.Scts,
 			extendedMasterSecret:         shm.ExtendedMasterSecret,
+			encryptThenMAC:               shm.EncryptThenMAC,
 			ticketSupported:              shm.TicketSupported,
 			secureRenegotiation:          shm.SecureRenegotiation,
 			secureRenegotiationSupported: shm.SecureRenegotiationSupported,
@@ -340,6 +358,7 @@ func (shm *serverHelloMsg) getPublicPtr() *PubServerHelloMsg {
 			OcspStapling:                 shm.ocspStapling,
 			Scts:                         shm.scts,
 			ExtendedMasterSecret:         shm.extendedMasterSecret,
+			EncryptThenMAC:               shm.encryptThenMAC,
 			TicketSupported:              shm.ticketSupported,
 			SecureRenegotiation:          shm.secureRenegotiation,
 			SecureRenegotiationSupported: shm.secureRenegotiationSupported,
@@ -366,6 +385,8 @@ type PubClientHelloMsg struct {
 	OcspStapling                 bool
 	Scts                         bool
 	Ems                          bool // [uTLS] actually implemented due to its prevalence
+	EncryptThenMAC               bool
+	PostHandshakeAuth            bool
 	SupportedCurves              []CurveID
 	SupportedPoints              []uint8
 	TicketSupported              bool
@@ -425,7 +446,9 @@ func (chm *PubClientHelloMsg) getPrivatePtr() *clientHelloMsg {
 			quicTransportParameters: chm.QuicTransportParameters,
 			encryptedClientHello:    chm.encryptedClientHello,
 
-			nextProtoNeg: chm.NextProtoNeg,
+			nextProtoNeg:      chm.NextProtoNeg,
+			encryptThenMAC:    chm.EncryptThenMAC,
+			postHandshakeAuth: chm.PostHandshakeAuth,
 		}
 		chm.cachedPrivateHello = private
 		return private
@@ -456,6 +479,8 @@ func (chm *clientHelloMsg) getPublicPtr() *PubClientHelloMsg {
 			OcspStapling:                 chm.ocspStapling,
 			Scts:                         chm.scts,
 			Ems:                          chm.extendedMasterSecret,
+			EncryptThenMAC:               chm.encryptThenMAC,
+			PostHandshakeAuth:            chm.postHandshakeAuth,
 			SupportedCurves:              chm.supportedCurves,
 			SupportedPoints:              chm.supportedPoints,
 			TicketSupported:              chm.ticketSupported,
@@ -802,6 +827,34 @@ func (css *ClientSessionState) SetAgeAdd(ageAdd uint32) {
 	css.session.ageAdd = ageAdd
 }
 
+func (css *ClientSessionState) SetEarlyData(earlyData bool) {
+	if css.session == nil {
+		css.session = &SessionState{}
+	}
+	css.session.EarlyData = earlyData
+}
+
+// Time at which the ticket was received, in seconds since UNIX epoch
+func (css *ClientSessionState) CreatedAt() uint64 {
+	return css.session.createdAt
+}
+
+// Time after which a TLS 1.3 ticket must not be used, in seconds since UNIX epoch.
+// It is zero for TLS 1.0–1.2 sessions.
+func (css *ClientSessionState) UseBy() uint64 {
+	return css.session.useBy
+}
+
+// Obfuscation value for the age of a TLS 1.3 ticket
+func (css *ClientSessionState) AgeAdd() uint32 {
+	return css.session.ageAdd
+}
+
+// Whether the TLS 1.3 ticket allows sending early data
+func (css *ClientSessionState) EarlyData() bool {
+	return css.session.EarlyData
+}
+
 // TicketKey is the internal representation of a session ticket key.
 type TicketKey struct {
 	AesKey  [16]byte
@@ -892,6 +945,8 @@ type KeySharePrivateKeys struct {
 	Ecdhe      *ecdh.PrivateKey
 	mlkem      *mlkem.DecapsulationKey768
 	mlkemEcdhe *ecdh.PrivateKey
+
+	extraKeyShares []extraKeyShare
 }
 
 func (ksp *KeySharePrivateKeys) ToPrivate() *keySharePrivateKeys {
@@ -903,6 +958,8 @@ func (ksp *KeySharePrivateKeys) ToPrivate() *keySharePrivateKeys {
 		ecdhe:      ksp.Ecdhe,
 		mlkem:      ksp.mlkem,
 		mlkemEcdhe: ksp.mlkemEcdhe,
+
+		extraKeyShares: ksp.extraKeyShares,
 	}
 }
 
@@ -915,5 +972,7 @@ func (ksp *keySharePrivateKeys) ToPublic() *KeySharePrivateKeys {
 		Ecdhe:      ksp.ecdhe,
 		mlkem:      ksp.mlkem,
 		mlkemEcdhe: ksp.mlkemEcdhe,
+
+		extraKeyShares: ksp.extraKeyShares,
 	}
 }
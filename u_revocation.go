@@ -0,0 +1,236 @@
+// Copyright 2026 uTLS Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tls
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"time"
+
+	"golang.org/x/crypto/ocsp"
+)
+
+// HTTPFetcher performs the HTTP requests of a client which fetches data about
+// certificates, such as OCSP responses and CRLs. [*http.Client] implements it.
+type HTTPFetcher interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+// RevocationPolicy controls whether a client accepts server certificates of
+// which the revocation status is unknown.
+type RevocationPolicy int
+
+const (
+	// RevocationSoftFail accepts certificates of which the revocation status
+	// is unknown, as browsers do. Servers which staple an invalid OCSP
+	// response are still rejected.
+	RevocationSoftFail RevocationPolicy = iota
+
+	// RevocationHardFail rejects certificates of which the revocation status
+	// is unknown.
+	RevocationHardFail
+)
+
+// RevocationCheck configures the revocation checks of the leaf certificate of
+// servers, see Config.RevocationCheck.
+type RevocationCheck struct {
+	// Policy controls whether a certificate of which the revocation status
+	// is unknown is accepted. Revoked certificates are always rejected.
+	Policy RevocationPolicy
+
+	// Fetcher, if not nil, is used when the server didn't staple a valid OCSP
+	// response, to ask the OCSP responders of the certificate, then to fetch
+	// its CRL distribution points. Otherwise, only stapled OCSP responses are
+	// checked.
+	Fetcher HTTPFetcher
+}
+
+// maxRevocationResponseSize bounds the size of fetched OCSP responses and CRLs.
+const maxRevocationResponseSize = 10 << 20
+
+// errRevocationUnknown is returned when the revocation status of a
+// certificate can't be determined.
+var errRevocationUnknown = errors.New("tls: revocation status of the server certificate is unknown")
+
+// checkRevocation checks the revocation status of the leaf certificate of the
+// server according to Config.RevocationCheck, and sends an alert if it must
+// be rejected. An invalid stapled OCSP response is rejected whatever the
+// policy, as a server which staples one is either broken or under attack.
+func (c *Conn) checkRevocation(ctx context.Context, certs []*x509.Certificate) error {
+	check := c.config.RevocationCheck
+	if check == nil {
+		return nil
+	}
+
+	leaf, issuer := certs[0], revocationIssuer(certs, c.verifiedChains)
+	now := c.config.time()
+
+	status, err := -1, errRevocationUnknown
+	if issuer != nil && len(c.ocspResponse) > 0 {
+		status, err = checkOCSPResponse(c.ocspResponse, leaf, issuer, now)
+		if status == -1 {
+			c.sendAlert(alertBadCertificateStatusResponse)
+			return &CertificateVerificationError{UnverifiedCertificates: certs, Err: err}
+		}
+	}
+	if status != ocsp.Good && status != ocsp.Revoked && issuer != nil && check.Fetcher != nil {
+		status, err = fetchRevocationStatus(ctx, check.Fetcher, leaf, issuer, now)
+	}
+
+	switch {
+	case status == ocsp.Revoked:
+		c.sendAlert(alertCertificateRevoked)
+		return &CertificateVerificationError{UnverifiedCertificates: certs, Err: err}
+	case status == ocsp.Good || check.Policy == RevocationSoftFail:
+		return nil
+	case len(c.ocspResponse) > 0:
+		c.sendAlert(alertBadCertificateStatusResponse)
+	default:
+		c.sendAlert(alertCertificateUnknown)
+	}
+	return &CertificateVerificationError{UnverifiedCertificates: certs, Err: err}
+}
+
+// revocationIssuer returns the issuer of the leaf certificate, from the
+// verified chains if any, or nil.
+func revocationIssuer(certs []*x509.Certificate, verifiedChains [][]*x509.Certificate) *x509.Certificate {
+	if len(verifiedChains) > 0 && len(verifiedChains[0]) > 1 {
+		return verifiedChains[0][1]
+	}
+	if len(certs) > 1 && certs[0].CheckSignatureFrom(certs[1]) == nil {
+		return certs[1]
+	}
+	return nil
+}
+
+// checkOCSPResponse verifies an OCSP response for leaf: its signature, by
+// issuer or a responder it delegated to, and its freshness. It returns the
+// status of the certificate, ocsp.Good, ocsp.Revoked or ocsp.Unknown, or -1
+// and an error if the response is invalid.
+func checkOCSPResponse(der []byte, leaf, issuer *x509.Certificate, now time.Time) (int, error) {
+	resp, err := ocsp.ParseResponseForCert(der, leaf, issuer)
+	if err != nil {
+		return -1, fmt.Errorf("tls: invalid OCSP response: %w", err)
+	}
+	if responder := resp.Certificate; responder != nil && !bytes.Equal(responder.Raw, issuer.Raw) {
+		// RFC 6960, Section 4.2.2.2.
+		if !slices.Contains(responder.ExtKeyUsage, x509.ExtKeyUsageOCSPSigning) {
+			return -1, errors.New("tls: OCSP responder certificate is not authorized to sign responses")
+		}
+		if now.Before(responder.NotBefore) || now.After(responder.NotAfter) {
+			return -1, errors.New("tls: OCSP responder certificate is expired or not yet valid")
+		}
+	}
+	// RFC 6960, Section 4.2.2.1. Allow for some clock skew.
+	const clockSkew = 5 * time.Minute
+	if resp.ThisUpdate.After(now.Add(clockSkew)) {
+		return -1, errors.New("tls: OCSP response is not yet valid")
+	}
+	if !resp.NextUpdate.IsZero() && resp.NextUpdate.Before(now.Add(-clockSkew)) {
+		return -1, errors.New("tls: OCSP response is expired")
+	}
+
+	switch resp.Status {
+	case ocsp.Good:
+		return ocsp.Good, nil
+	case ocsp.Revoked:
+		return ocsp.Revoked, fmt.Errorf("tls: server certificate was revoked at %v", resp.RevokedAt)
+	}
+	return ocsp.Unknown, errRevocationUnknown
+}
+
+// fetchRevocationStatus asks the OCSP responders of leaf, then fetches its
+// CRLs, until one of them provides its status.
+func fetchRevocationStatus(ctx context.Context, fetcher HTTPFetcher, leaf, issuer *x509.Certificate, now time.Time) (int, error) {
+	status, err := -1, errRevocationUnknown
+
+	if len(leaf.OCSPServer) > 0 {
+		req, reqErr := ocsp.CreateRequest(leaf, issuer, nil)
+		if reqErr != nil {
+			return -1, reqErr
+		}
+		for _, url := range leaf.OCSPServer {
+			var der []byte
+			der, err = httpFetch(ctx, fetcher, http.MethodPost, url, "application/ocsp-request", req, maxRevocationResponseSize)
+			if err != nil {
+				continue
+			}
+			if status, err = checkOCSPResponse(der, leaf, issuer, now); status == ocsp.Good || status == ocsp.Revoked {
+				return status, err
+			}
+		}
+	}
+
+	for _, url := range leaf.CRLDistributionPoints {
+		var der []byte
+		der, err = httpFetch(ctx, fetcher, http.MethodGet, url, "", nil, maxRevocationResponseSize)
+		if err != nil {
+			continue
+		}
+		if status, err = checkCRL(der, leaf, issuer, now); status != -1 {
+			return status, err
+		}
+	}
+	return -1, err
+}
+
+// checkCRL verifies a CRL issued by issuer, and returns the status of leaf,
+// ocsp.Good or ocsp.Revoked, or -1 and an error if the CRL is invalid.
+func checkCRL(der []byte, leaf, issuer *x509.Certificate, now time.Time) (int, error) {
+	crl, err := x509.ParseRevocationList(der)
+	if err != nil {
+		return -1, fmt.Errorf("tls: invalid CRL: %w", err)
+	}
+	if err := crl.CheckSignatureFrom(issuer); err != nil {
+		return -1, fmt.Errorf("tls: invalid CRL signature: %w", err)
+	}
+	if !crl.NextUpdate.IsZero() && crl.NextUpdate.Before(now) {
+		return -1, errors.New("tls: CRL is expired")
+	}
+	for _, entry := range crl.RevokedCertificateEntries {
+		if entry.SerialNumber.Cmp(leaf.SerialNumber) == 0 {
+			return ocsp.Revoked, fmt.Errorf("tls: server certificate was revoked at %v", entry.RevocationTime)
+		}
+	}
+	return ocsp.Good, nil
+}
+
+// httpFetch performs an HTTP request with fetcher, canceled with ctx, and
+// returns the response body, which must not be larger than maxSize.
+func httpFetch(ctx context.Context, fetcher HTTPFetcher, method, url, contentType string, body []byte, maxSize int64) ([]byte, error) {
+	var reqBody io.Reader
+	if body != nil {
+		reqBody = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, url, reqBody)
+	if err != nil {
+		return nil, err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	resp, err := fetcher.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("tls: fetching %s: %s", url, resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > maxSize {
+		return nil, fmt.Errorf("tls: fetching %s: response larger than %d bytes", url, maxSize)
+	}
+	return data, nil
+}
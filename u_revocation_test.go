@@ -0,0 +1,288 @@
+// Copyright 2026 uTLS Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tls
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"io"
+	"math/big"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ocsp"
+)
+
+var revocationTestTime = time.Unix(1700000000, 0)
+
+const (
+	revocationTestOCSPURL = "http://ocsp.example/"
+	revocationTestCRLURL  = "http://crl.example/ca.crl"
+)
+
+// revocationTestPKI is a CA which issued a leaf certificate, and the means
+// to vouch for its status.
+type revocationTestPKI struct {
+	ca, leaf *x509.Certificate
+	caKey    *ecdsa.PrivateKey
+	leafCert Certificate
+}
+
+func newRevocationTestPKI(t *testing.T) *revocationTestPKI {
+	t.Helper()
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Revocation Test CA"},
+		NotBefore:             revocationTestTime.Add(-time.Hour),
+		NotAfter:              revocationTestTime.Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, caKey.Public(), caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca, _ := x509.ParseCertificate(caDER)
+
+	leafKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leafTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(2),
+		Subject:               pkix.Name{CommonName: "example.golang"},
+		DNSNames:              []string{"example.golang"},
+		NotBefore:             revocationTestTime.Add(-time.Hour),
+		NotAfter:              revocationTestTime.Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		OCSPServer:            []string{revocationTestOCSPURL},
+		CRLDistributionPoints: []string{revocationTestCRLURL},
+	}
+	leafDER, err := x509.CreateCertificate(rand.Reader, leafTemplate, ca, leafKey.Public(), caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, _ := x509.ParseCertificate(leafDER)
+
+	return &revocationTestPKI{
+		ca:       ca,
+		leaf:     leaf,
+		caKey:    caKey,
+		leafCert: Certificate{Certificate: [][]byte{leafDER, caDER}, PrivateKey: leafKey},
+	}
+}
+
+func (p *revocationTestPKI) ocspResponse(t *testing.T, status int, nextUpdate time.Time) []byte {
+	t.Helper()
+	resp, err := ocsp.CreateResponse(p.ca, p.ca, ocsp.Response{
+		Status:       status,
+		SerialNumber: p.leaf.SerialNumber,
+		ThisUpdate:   revocationTestTime.Add(-time.Minute),
+		NextUpdate:   nextUpdate,
+		RevokedAt:    revocationTestTime.Add(-time.Minute),
+	}, p.caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp
+}
+
+func (p *revocationTestPKI) crl(t *testing.T, revoked bool) []byte {
+	t.Helper()
+	template := &x509.RevocationList{
+		Number:     big.NewInt(1),
+		ThisUpdate: revocationTestTime.Add(-time.Minute),
+		NextUpdate: revocationTestTime.Add(time.Hour),
+	}
+	if revoked {
+		template.RevokedCertificateEntries = []x509.RevocationListEntry{
+			{SerialNumber: p.leaf.SerialNumber, RevocationTime: revocationTestTime.Add(-time.Minute)},
+		}
+	}
+	crl, err := x509.CreateRevocationList(rand.Reader, template, p.ca, p.caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return crl
+}
+
+// testHTTPFetcher is a local stand-in for the OCSP responders and CRL
+// distribution points, serving the bodies by URL.
+type testHTTPFetcher struct {
+	bodies   map[string][]byte
+	requests []string
+}
+
+func (f *testHTTPFetcher) Do(req *http.Request) (*http.Response, error) {
+	f.requests = append(f.requests, req.Method+" "+req.URL.String())
+	body, ok := f.bodies[req.URL.String()]
+	if !ok {
+		return &http.Response{StatusCode: http.StatusNotFound, Status: "404 Not Found", Body: io.NopCloser(strings.NewReader(""))}, nil
+	}
+	if req.Method == http.MethodPost && req.Header.Get("Content-Type") != "application/ocsp-request" {
+		return &http.Response{StatusCode: http.StatusBadRequest, Status: "400 Bad Request", Body: io.NopCloser(strings.NewReader(""))}, nil
+	}
+	return &http.Response{StatusCode: http.StatusOK, Status: "200 OK", Body: io.NopCloser(bytes.NewReader(body))}, nil
+}
+
+func testRevocationHandshake(t *testing.T, p *revocationTestPKI, staple []byte, check *RevocationCheck) error {
+	t.Helper()
+	serverConfig := testConfig.Clone()
+	serverConfig.Time = func() time.Time { return revocationTestTime }
+	serverConfig.Certificates = []Certificate{p.leafCert}
+	serverConfig.Certificates[0].OCSPStaple = staple
+
+	clientConfig := testConfig.Clone()
+	clientConfig.Time = func() time.Time { return revocationTestTime }
+	clientConfig.InsecureSkipVerify = false
+	clientConfig.ServerName = "example.golang"
+	clientConfig.RootCAs = x509.NewCertPool()
+	clientConfig.RootCAs.AddCert(p.ca)
+	clientConfig.RevocationCheck = check
+
+	var err error
+	for _, vers := range []uint16{VersionTLS12, VersionTLS13} {
+		clientConfig.MaxVersion = vers
+		_, _, vErr := testHandshake(t, clientConfig, serverConfig)
+		if vErr != nil && err == nil {
+			err = vErr
+		} else if (vErr == nil) != (err == nil) {
+			t.Fatalf("TLS 1.2 and 1.3 disagree: %v", vErr)
+		}
+	}
+	return err
+}
+
+func TestRevocationStapled(t *testing.T) {
+	p := newRevocationTestPKI(t)
+	hardFail := &RevocationCheck{Policy: RevocationHardFail}
+	softFail := &RevocationCheck{Policy: RevocationSoftFail}
+
+	good := p.ocspResponse(t, ocsp.Good, revocationTestTime.Add(time.Hour))
+	if err := testRevocationHandshake(t, p, good, hardFail); err != nil {
+		t.Errorf("a good staple was rejected: %v", err)
+	}
+
+	revoked := p.ocspResponse(t, ocsp.Revoked, revocationTestTime.Add(time.Hour))
+	if err := testRevocationHandshake(t, p, revoked, softFail); err == nil || !strings.Contains(err.Error(), "revoked") {
+		t.Errorf("a revoked certificate was accepted: %v", err)
+	}
+
+	stale := p.ocspResponse(t, ocsp.Good, revocationTestTime.Add(-time.Hour))
+	if err := testRevocationHandshake(t, p, stale, hardFail); err == nil {
+		t.Error("an expired staple was accepted with hard-fail")
+	}
+	// Invalid staples are rejected whatever the policy.
+	if err := testRevocationHandshake(t, p, stale, softFail); err == nil || !strings.Contains(err.Error(), "bad certificate status response") {
+		t.Errorf("an expired staple was accepted with soft-fail: %v", err)
+	}
+
+	if err := testRevocationHandshake(t, p, nil, hardFail); err == nil {
+		t.Error("a certificate of unknown status was accepted with hard-fail")
+	}
+
+	// A response signed by another key than the issuer's.
+	other := newRevocationTestPKI(t)
+	other.leaf = p.leaf
+	forged := other.ocspResponse(t, ocsp.Good, revocationTestTime.Add(time.Hour))
+	for _, check := range []*RevocationCheck{hardFail, softFail} {
+		if err := testRevocationHandshake(t, p, forged, check); err == nil {
+			t.Errorf("a forged staple was accepted with policy %v", check.Policy)
+		}
+	}
+}
+
+func TestRevocationDelegatedResponder(t *testing.T) {
+	p := newRevocationTestPKI(t)
+
+	for _, authorized := range []bool{true, false} {
+		responderKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		template := &x509.Certificate{
+			SerialNumber: big.NewInt(3),
+			Subject:      pkix.Name{CommonName: "OCSP Responder"},
+			NotBefore:    revocationTestTime.Add(-time.Hour),
+			NotAfter:     revocationTestTime.Add(time.Hour),
+			KeyUsage:     x509.KeyUsageDigitalSignature,
+		}
+		if authorized {
+			template.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageOCSPSigning}
+		}
+		der, err := x509.CreateCertificate(rand.Reader, template, p.ca, responderKey.Public(), p.caKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		responder, _ := x509.ParseCertificate(der)
+		staple, err := ocsp.CreateResponse(p.ca, responder, ocsp.Response{
+			Status:       ocsp.Good,
+			SerialNumber: p.leaf.SerialNumber,
+			ThisUpdate:   revocationTestTime.Add(-time.Minute),
+			NextUpdate:   revocationTestTime.Add(time.Hour),
+			Certificate:  responder,
+		}, responderKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = testRevocationHandshake(t, p, staple, &RevocationCheck{Policy: RevocationHardFail})
+		if authorized && err != nil {
+			t.Errorf("a response of an authorized responder was rejected: %v", err)
+		} else if !authorized && err == nil {
+			t.Error("a response of an unauthorized responder was accepted")
+		}
+	}
+}
+
+func TestRevocationFetched(t *testing.T) {
+	p := newRevocationTestPKI(t)
+
+	fetcher := &testHTTPFetcher{bodies: map[string][]byte{
+		revocationTestOCSPURL: p.ocspResponse(t, ocsp.Good, revocationTestTime.Add(time.Hour)),
+	}}
+	check := &RevocationCheck{Policy: RevocationHardFail, Fetcher: fetcher}
+	if err := testRevocationHandshake(t, p, nil, check); err != nil {
+		t.Errorf("a good OCSP response was rejected: %v", err)
+	}
+	if len(fetcher.requests) == 0 || fetcher.requests[0] != "POST "+revocationTestOCSPURL {
+		t.Errorf("unexpected requests %q", fetcher.requests)
+	}
+
+	// Without an OCSP responder, the CRL is fetched.
+	fetcher = &testHTTPFetcher{bodies: map[string][]byte{
+		revocationTestCRLURL: p.crl(t, true),
+	}}
+	check.Fetcher = fetcher
+	if err := testRevocationHandshake(t, p, nil, check); err == nil || !strings.Contains(err.Error(), "revoked") {
+		t.Errorf("a certificate revoked by the CRL was accepted: %v", err)
+	}
+	fetcher.bodies[revocationTestCRLURL] = p.crl(t, false)
+	if err := testRevocationHandshake(t, p, nil, check); err != nil {
+		t.Errorf("a certificate missing from the CRL was rejected: %v", err)
+	}
+
+	// A valid staple spares the fetches.
+	fetcher.requests = nil
+	good := p.ocspResponse(t, ocsp.Good, revocationTestTime.Add(time.Hour))
+	if err := testRevocationHandshake(t, p, good, check); err != nil {
+		t.Fatal(err)
+	}
+	if len(fetcher.requests) != 0 {
+		t.Errorf("unexpected requests %q", fetcher.requests)
+	}
+}
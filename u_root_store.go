@@ -0,0 +1,241 @@
+// Copyright 2026 uTLS Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tls
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RootStore is the set of root certificates a browser trusts to issue server
+// certificates, such as Mozilla NSS, the Chrome Root Store or the Apple root
+// store, with the dates after which it distrusts the certificates issued by
+// some of them. A RootStore must not be modified once in use.
+type RootStore struct {
+	// Name identifies the root store in errors.
+	Name string
+
+	pool          *x509.CertPool
+	roots         []*x509.Certificate
+	distrustAfter map[[32]byte]time.Time // by SHA-256 of the root
+}
+
+// NewRootStore returns an empty root store.
+func NewRootStore(name string) *RootStore {
+	return &RootStore{
+		Name:          name,
+		pool:          x509.NewCertPool(),
+		distrustAfter: make(map[[32]byte]time.Time),
+	}
+}
+
+// AddCert adds a root certificate to the store.
+func (s *RootStore) AddCert(cert *x509.Certificate) {
+	s.pool.AddCert(cert)
+	s.roots = append(s.roots, cert)
+}
+
+// SetDistrustAfter makes the store distrust the certificates issued by root,
+// directly or through intermediates, with a NotBefore date after t.
+func (s *RootStore) SetDistrustAfter(root *x509.Certificate, t time.Time) {
+	s.distrustAfter[sha256.Sum256(root.Raw)] = t
+}
+
+// DistrustAfter returns the date set by SetDistrustAfter for root, if any.
+func (s *RootStore) DistrustAfter(root *x509.Certificate) (time.Time, bool) {
+	t, ok := s.distrustAfter[sha256.Sum256(root.Raw)]
+	return t, ok
+}
+
+// Roots returns the root certificates of the store.
+func (s *RootStore) Roots() []*x509.Certificate {
+	return s.roots
+}
+
+// AppendCertsFromPEM adds the certificates of a series of PEM encoded
+// certificates, like the Chrome Root Store and the Apple root store are
+// distributed, to the store. It reports whether any certificate was added.
+func (s *RootStore) AppendCertsFromPEM(pemCerts []byte) (ok bool) {
+	for len(pemCerts) > 0 {
+		var block *pem.Block
+		block, pemCerts = pem.Decode(pemCerts)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" || len(block.Headers) != 0 {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			continue
+		}
+		s.AddCert(cert)
+		ok = true
+	}
+	return ok
+}
+
+// AppendCertsFromNSSCertdata adds the certificates of a certdata.txt file of
+// Mozilla NSS which are trusted to issue server certificates to the store,
+// with their CKA_NSS_SERVER_DISTRUST_AFTER dates.
+func (s *RootStore) AppendCertsFromNSSCertdata(certdata []byte) error {
+	objects, err := parseNSSCertdata(certdata)
+	if err != nil {
+		return err
+	}
+
+	trusted := make(map[string]bool)
+	for _, object := range objects {
+		if object["CKA_CLASS"] == "CKO_NSS_TRUST" && object["CKA_TRUST_SERVER_AUTH"] == "CKT_NSS_TRUSTED_DELEGATOR" {
+			trusted[object["CKA_CERT_SHA1_HASH"]] = true
+		}
+	}
+	for _, object := range objects {
+		if object["CKA_CLASS"] != "CKO_CERTIFICATE" {
+			continue
+		}
+		der := []byte(object["CKA_VALUE"])
+		if hash := sha1.Sum(der); !trusted[string(hash[:])] {
+			continue
+		}
+		cert, err := x509.ParseCertificate(der)
+		if err != nil {
+			return fmt.Errorf("tls: invalid certificate %s in certdata: %w", object["CKA_LABEL"], err)
+		}
+		s.AddCert(cert)
+		if distrustAfter := object["CKA_NSS_SERVER_DISTRUST_AFTER"]; distrustAfter != "CK_FALSE" && distrustAfter != "" {
+			t, err := time.Parse("060102150405Z", distrustAfter)
+			if err != nil {
+				return fmt.Errorf("tls: invalid distrust date of %s in certdata: %w", object["CKA_LABEL"], err)
+			}
+			s.SetDistrustAfter(cert, t)
+		}
+	}
+	return nil
+}
+
+// parseNSSCertdata parses the objects of a certdata.txt file, as maps of
+// their attributes to their values: the value of MULTILINE_OCTAL attributes is
+// decoded, the quotes of UTF8 ones are removed, and others are kept as is.
+func parseNSSCertdata(certdata []byte) ([]map[string]string, error) {
+	var objects []map[string]string
+	scanner := bufio.NewScanner(bytes.NewReader(certdata))
+	line := 0
+	for scanner.Scan() {
+		line++
+		fields := strings.SplitN(strings.TrimSpace(scanner.Text()), " ", 3)
+		if fields[0] == "" || strings.HasPrefix(fields[0], "#") || fields[0] == "BEGINDATA" {
+			continue
+		}
+		if len(fields) < 2 {
+			return nil, fmt.Errorf("tls: invalid certdata line %d", line)
+		}
+		name, typ, value := fields[0], fields[1], ""
+		if len(fields) == 3 {
+			value = fields[2]
+		}
+		switch typ {
+		case "MULTILINE_OCTAL":
+			var b []byte
+			for {
+				if !scanner.Scan() {
+					return nil, fmt.Errorf("tls: unterminated certdata value at line %d", line)
+				}
+				line++
+				octal := strings.TrimSpace(scanner.Text())
+				if octal == "END" {
+					break
+				}
+				for _, o := range strings.Split(octal, `\`)[1:] {
+					n, err := strconv.ParseUint(o, 8, 8)
+					if err != nil {
+						return nil, fmt.Errorf("tls: invalid certdata value at line %d", line)
+					}
+					b = append(b, byte(n))
+				}
+			}
+			value = string(b)
+		case "UTF8":
+			if unquoted, err := strconv.Unquote(value); err == nil {
+				value = unquoted
+			}
+		}
+		if name == "CKA_CLASS" {
+			objects = append(objects, make(map[string]string))
+		}
+		if len(objects) == 0 {
+			return nil, fmt.Errorf("tls: certdata attribute outside of an object at line %d", line)
+		}
+		objects[len(objects)-1][name] = value
+	}
+	return objects, scanner.Err()
+}
+
+var (
+	rootStoresMu sync.RWMutex
+	rootStores   = make(map[string]*RootStore)
+)
+
+// BindRootStore makes UConns of all the versions of the client of id, such as
+// HelloChrome_Auto, verify server certificates with store rather than the
+// system roots, when their Config sets neither RootStore nor RootCAs. A nil
+// store removes the binding.
+func BindRootStore(id ClientHelloID, store *RootStore) {
+	rootStoresMu.Lock()
+	defer rootStoresMu.Unlock()
+	if store == nil {
+		delete(rootStores, id.Client)
+	} else {
+		rootStores[id.Client] = store
+	}
+}
+
+// boundRootStore returns the root store bound to the client of id, or nil.
+func boundRootStore(id ClientHelloID) *RootStore {
+	rootStoresMu.RLock()
+	defer rootStoresMu.RUnlock()
+	return rootStores[id.Client]
+}
+
+// verifyChains verifies leaf with opts, with the roots of Config.RootStore or
+// of the root store bound to the ClientHelloID of a UConn, if any, rather
+// than Config.RootCAs, and drops the chains which the root store distrusts.
+func (c *Conn) verifyChains(leaf *x509.Certificate, opts x509.VerifyOptions) ([][]*x509.Certificate, error) {
+	store := c.config.RootStore
+	if store == nil && c.config.RootCAs == nil {
+		store = c.utls.rootStore
+	}
+	if store == nil {
+		return leaf.Verify(opts)
+	}
+
+	opts.Roots = store.pool
+	chains, err := leaf.Verify(opts)
+	if err != nil {
+		return nil, err
+	}
+	var trusted [][]*x509.Certificate
+	for _, chain := range chains {
+		root := chain[len(chain)-1]
+		if t, ok := store.DistrustAfter(root); ok && leaf.NotBefore.After(t) {
+			err = fmt.Errorf("tls: %s distrusts the certificates issued by %q after %v", store.Name, root.Subject, t)
+			continue
+		}
+		trusted = append(trusted, chain)
+	}
+	if len(trusted) == 0 {
+		return nil, err
+	}
+	return trusted, nil
+}
@@ -0,0 +1,170 @@
+// Copyright 2026 uTLS Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tls
+
+import (
+	"crypto/sha1"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// nssCertdataOctal encodes b as a MULTILINE_OCTAL certdata value.
+func nssCertdataOctal(b []byte) string {
+	var s strings.Builder
+	s.WriteString("MULTILINE_OCTAL\n")
+	for i, c := range b {
+		fmt.Fprintf(&s, `\%03o`, c)
+		if i%16 == 15 || i == len(b)-1 {
+			s.WriteString("\n")
+		}
+	}
+	s.WriteString("END\n")
+	return s.String()
+}
+
+// nssCertdataEntry returns the certificate and trust objects of cert.
+func nssCertdataEntry(cert *x509.Certificate, trust string, distrustAfter string) string {
+	hash := sha1.Sum(cert.Raw)
+	distrust := "CK_BBOOL CK_FALSE\n"
+	if distrustAfter != "" {
+		distrust = nssCertdataOctal([]byte(distrustAfter))
+	}
+	label := cert.Subject.CommonName
+	return `
+# Certificate "` + label + `"
+CKA_CLASS CK_OBJECT_CLASS CKO_CERTIFICATE
+CKA_TOKEN CK_BBOOL CK_TRUE
+CKA_LABEL UTF8 "` + label + `"
+CKA_VALUE ` + nssCertdataOctal(cert.Raw) + `CKA_NSS_SERVER_DISTRUST_AFTER ` + distrust + `
+# Trust for "` + label + `"
+CKA_CLASS CK_OBJECT_CLASS CKO_NSS_TRUST
+CKA_LABEL UTF8 "` + label + `"
+CKA_CERT_SHA1_HASH ` + nssCertdataOctal(hash[:]) + `CKA_TRUST_SERVER_AUTH CK_TRUST ` + trust + `
+CKA_TRUST_STEP_UP_APPROVED CK_BBOOL CK_FALSE
+`
+}
+
+func testRootStoreHandshake(t *testing.T, p *revocationTestPKI, clientConfig *Config, spec *ClientHelloSpec) error {
+	t.Helper()
+	serverConfig := testConfig.Clone()
+	serverConfig.Time = func() time.Time { return revocationTestTime }
+	serverConfig.Certificates = []Certificate{p.leafCert}
+
+	clientConfig.Time = func() time.Time { return revocationTestTime }
+	clientConfig.InsecureSkipVerify = false
+	clientConfig.ServerName = "example.golang"
+	_, _, err := testUtlsHandshake(t, clientConfig, serverConfig, spec)
+	return err
+}
+
+func TestRootStoreNSSCertdata(t *testing.T) {
+	trusted, distrusted, untrusted := newRevocationTestPKI(t), newRevocationTestPKI(t), newRevocationTestPKI(t)
+	certdata := "# This Source Code Form is subject to the terms of the Mozilla Public\nBEGINDATA\n" +
+		nssCertdataEntry(trusted.ca, "CKT_NSS_TRUSTED_DELEGATOR", "") +
+		nssCertdataEntry(distrusted.ca, "CKT_NSS_TRUSTED_DELEGATOR", revocationTestTime.Add(-2*time.Hour).UTC().Format("060102150405Z")) +
+		nssCertdataEntry(untrusted.ca, "CKT_NSS_MUST_VERIFY_TRUST", "")
+
+	store := NewRootStore("NSS")
+	if err := store.AppendCertsFromNSSCertdata([]byte(certdata)); err != nil {
+		t.Fatal(err)
+	}
+	if len(store.Roots()) != 2 {
+		t.Fatalf("got %d roots, want 2", len(store.Roots()))
+	}
+	if _, ok := store.DistrustAfter(trusted.ca); ok {
+		t.Error("a root without CKA_NSS_SERVER_DISTRUST_AFTER is distrusted")
+	}
+	if after, ok := store.DistrustAfter(distrusted.ca); !ok || !after.Equal(revocationTestTime.Add(-2*time.Hour)) {
+		t.Errorf("got distrust date %v, %v", after, ok)
+	}
+
+	tests := []struct {
+		name    string
+		pki     *revocationTestPKI
+		trusted bool
+	}{
+		{"Trusted", trusted, true},
+		{"DistrustedAfter", distrusted, false},
+		{"MustVerifyTrust", untrusted, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			clientConfig := testConfig.Clone()
+			clientConfig.RootStore = store
+			// RootStore takes precedence over RootCAs.
+			clientConfig.RootCAs = x509.NewCertPool()
+			clientConfig.RootCAs.AddCert(test.pki.ca)
+			err := testRootStoreHandshake(t, test.pki, clientConfig, nil)
+			if test.trusted && err != nil {
+				t.Errorf("a certificate of a trusted root was rejected: %v", err)
+			} else if !test.trusted && err == nil {
+				t.Error("a certificate of an untrusted root was accepted")
+			}
+		})
+	}
+}
+
+func TestRootStorePEM(t *testing.T) {
+	p, other := newRevocationTestPKI(t), newRevocationTestPKI(t)
+	store := NewRootStore("Apple")
+	pemCerts := append(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: p.ca.Raw}),
+		pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: other.ca.Raw})...)
+	if !store.AppendCertsFromPEM(pemCerts) || len(store.Roots()) != 2 {
+		t.Fatalf("got %d roots, want 2", len(store.Roots()))
+	}
+
+	clientConfig := testConfig.Clone()
+	clientConfig.RootStore = store
+	if err := testRootStoreHandshake(t, p, clientConfig, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	store.SetDistrustAfter(p.ca, revocationTestTime.Add(-2*time.Hour))
+	if err := testRootStoreHandshake(t, p, clientConfig, nil); err == nil || !strings.Contains(err.Error(), "Apple distrusts") {
+		t.Errorf("a certificate issued after the distrust date was accepted: %v", err)
+	}
+	store.SetDistrustAfter(p.ca, revocationTestTime)
+	if err := testRootStoreHandshake(t, p, clientConfig, nil); err != nil {
+		t.Errorf("a certificate issued before the distrust date was rejected: %v", err)
+	}
+}
+
+func TestBindRootStore(t *testing.T) {
+	p := newRevocationTestPKI(t)
+	store := NewRootStore("Custom")
+	store.AddCert(p.ca)
+	BindRootStore(HelloCustom, store)
+	t.Cleanup(func() { BindRootStore(HelloCustom, nil) })
+
+	// A spec makes testUtlsHandshake run a UConn with HelloCustom.
+	chrome, err := utlsIdToSpec(HelloChrome_Auto)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := testRootStoreHandshake(t, p, testConfig.Clone(), &chrome); err != nil {
+		t.Fatal(err)
+	}
+
+	// RootCAs takes precedence over the bound root store.
+	clientConfig := testConfig.Clone()
+	clientConfig.RootCAs = x509.NewCertPool()
+	if err := testRootStoreHandshake(t, p, clientConfig, &chrome); err == nil {
+		t.Error("the bound root store was used despite RootCAs")
+	}
+
+	// Conns don't use it.
+	if err := testRootStoreHandshake(t, p, testConfig.Clone(), nil); err == nil {
+		t.Error("a Conn used the bound root store")
+	}
+
+	BindRootStore(HelloCustom, nil)
+	if err := testRootStoreHandshake(t, p, testConfig.Clone(), &chrome); err == nil {
+		t.Error("the root store is still bound")
+	}
+}
@@ -0,0 +1,291 @@
+// Copyright 2026 uTLS Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tls
+
+import (
+	"bytes"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// SessionCachePolicy controls how a PolicyClientSessionCache stores and hands
+// out sessions, to mimic the ticket handling of browsers.
+type SessionCachePolicy struct {
+	// SingleUseTickets removes a TLS 1.3 ticket from the cache as soon as it
+	// is offered, so it is never sent twice, as recommended by RFC 8446,
+	// Appendix C.4 and done by Chrome. TLS 1.0–1.2 sessions are kept and may
+	// be resumed several times, like browsers do.
+	SingleUseTickets bool
+
+	// MaxTicketsPerHost is the number of sessions kept for a host. When it is
+	// exceeded, the oldest session is evicted. The most recently received
+	// session is offered first. If zero, one session per host is kept.
+	MaxTicketsPerHost int
+
+	// MaxLifetime caps the lifetime of sessions, counted from the moment the
+	// ticket was received. TLS 1.3 sessions also expire at the end of the
+	// lifetime announced by the server, whichever comes first. If zero, only
+	// the server lifetime is applied, and TLS 1.0–1.2 sessions don't expire.
+	MaxLifetime time.Duration
+
+	// Time returns the current time. If nil, time.Now is used.
+	Time func() time.Time
+}
+
+// PolicyClientSessionCache is a ClientSessionCache that applies a
+// SessionCachePolicy on top of another ClientSessionCache, which is used as
+// storage.
+//
+// Sessions may also be partitioned by ClientHelloID and network identity with
+// Partition, so that a ticket obtained with one fingerprint or through one
+// proxy is never offered under another one, which would link both identities.
+// A UConn of which the Config.ClientSessionCache is a PolicyClientSessionCache
+// uses the partition of its ClientHelloID, with an empty network identity.
+//
+// Put with a nil session removes the session last returned by Get on the same
+// view of the cache. Each connection uses its own view, but handshakes which
+// share the cache itself, or a view returned by Partition, share it too.
+type PolicyClientSessionCache struct {
+	mu     sync.Mutex
+	base   ClientSessionCache
+	policy SessionCachePolicy
+
+	// handedOut is the slot and session last returned by Get for each key,
+	// in the unpartitioned view of the cache.
+	handedOut map[string]handedOutSession
+}
+
+type handedOutSession struct {
+	slot    int
+	session *ClientSessionState
+}
+
+// NewPolicyClientSessionCache returns a PolicyClientSessionCache storing its
+// sessions in base. If base is nil, an LRU cache of default capacity is used.
+//
+// Each stored session takes one entry in base, so base should be able to
+// hold MaxTicketsPerHost entries per host.
+func NewPolicyClientSessionCache(base ClientSessionCache, policy SessionCachePolicy) *PolicyClientSessionCache {
+	if base == nil {
+		base = NewLRUClientSessionCache(0)
+	}
+	if policy.MaxTicketsPerHost < 1 {
+		policy.MaxTicketsPerHost = 1
+	}
+	return &PolicyClientSessionCache{
+		base:      base,
+		policy:    policy,
+		handedOut: make(map[string]handedOutSession),
+	}
+}
+
+// Partition returns a view of the cache in which all session keys are
+// qualified by the given ClientHelloID and network identity. networkID can be
+// any string that identifies the path to the server, e.g. the proxy URL or
+// the local address used. Views with the same parameters share sessions.
+func (c *PolicyClientSessionCache) Partition(clientHelloID ClientHelloID, networkID string) ClientSessionCache {
+	return c.view(lengthPrefixed(clientHelloID.Str()) + lengthPrefixed(networkID))
+}
+
+func (c *PolicyClientSessionCache) view(prefix string) *partitionedSessionCache {
+	return &partitionedSessionCache{
+		cache:     c,
+		prefix:    prefix,
+		handedOut: make(map[string]handedOutSession),
+	}
+}
+
+// Get returns the most recently received unexpired session for sessionKey,
+// in the unpartitioned view of the cache.
+func (c *PolicyClientSessionCache) Get(sessionKey string) (*ClientSessionState, bool) {
+	return c.get(unpartitioned+lengthPrefixed(sessionKey), c.handedOut)
+}
+
+// Peek is like Get, but it neither consumes single-use tickets nor hands out
+// the session, so that a later Put with a nil session doesn't remove it.
+func (c *PolicyClientSessionCache) Peek(sessionKey string) (*ClientSessionState, bool) {
+	return c.peek(unpartitioned + lengthPrefixed(sessionKey))
+}
+
+// Put adds a session for sessionKey, in the unpartitioned view of the cache.
+// If cs is nil, the session last returned by Get for sessionKey is removed,
+// such as the session of which the certificate expired, and the other
+// sessions for sessionKey are kept.
+func (c *PolicyClientSessionCache) Put(sessionKey string, cs *ClientSessionState) {
+	c.put(unpartitioned+lengthPrefixed(sessionKey), cs, c.handedOut)
+}
+
+// unpartitioned is the prefix of the keys of the unpartitioned view.
+var unpartitioned = lengthPrefixed("") + lengthPrefixed("")
+
+type partitionedSessionCache struct {
+	cache  *PolicyClientSessionCache
+	prefix string
+
+	// handedOut is the slot and session last returned by Get on this view
+	// for each key, guarded by cache.mu.
+	handedOut map[string]handedOutSession
+}
+
+func (p *partitionedSessionCache) Get(sessionKey string) (*ClientSessionState, bool) {
+	return p.cache.get(p.prefix+lengthPrefixed(sessionKey), p.handedOut)
+}
+
+func (p *partitionedSessionCache) Put(sessionKey string, cs *ClientSessionState) {
+	p.cache.put(p.prefix+lengthPrefixed(sessionKey), cs, p.handedOut)
+}
+
+// lengthPrefixed encodes s so that concatenations of encoded strings are
+// unambiguous.
+func lengthPrefixed(s string) string {
+	return strconv.Itoa(len(s)) + ":" + s
+}
+
+func slotKey(key string, slot int) string {
+	return key + "#" + strconv.Itoa(slot)
+}
+
+func (c *PolicyClientSessionCache) now() time.Time {
+	if c.policy.Time == nil {
+		return time.Now()
+	}
+	return c.policy.Time()
+}
+
+func (c *PolicyClientSessionCache) expired(cs *ClientSessionState, now time.Time) bool {
+	if cs == nil || cs.session == nil {
+		return true
+	}
+	if useBy := cs.expiry(); !useBy.IsZero() && !now.Before(useBy) {
+		return true
+	}
+	if c.policy.MaxLifetime > 0 {
+		createdAt := time.Unix(int64(cs.session.createdAt), 0)
+		if !now.Before(createdAt.Add(c.policy.MaxLifetime)) {
+			return true
+		}
+	}
+	return false
+}
+
+// peek returns the session that get would return, without changing the cache.
+func (c *PolicyClientSessionCache) peek(key string) (*ClientSessionState, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now()
+	var best *ClientSessionState
+	for slot := 0; slot < c.policy.MaxTicketsPerHost; slot++ {
+		cs, ok := c.base.Get(slotKey(key, slot))
+		if !ok || c.expired(cs, now) {
+			continue
+		}
+		if best == nil || cs.session.createdAt > best.session.createdAt {
+			best = cs
+		}
+	}
+	return best, best != nil
+}
+
+// get returns the most recent unexpired session for key, and records it in
+// handedOut, for put to remove it.
+func (c *PolicyClientSessionCache) get(key string, handedOut map[string]handedOutSession) (*ClientSessionState, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now()
+	best, bestSlot := (*ClientSessionState)(nil), -1
+	for slot := 0; slot < c.policy.MaxTicketsPerHost; slot++ {
+		cs, ok := c.base.Get(slotKey(key, slot))
+		if !ok || cs == nil {
+			continue
+		}
+		if c.expired(cs, now) {
+			c.base.Put(slotKey(key, slot), nil)
+			continue
+		}
+		if best == nil || cs.session.createdAt > best.session.createdAt {
+			best, bestSlot = cs, slot
+		}
+	}
+	if best == nil {
+		return nil, false
+	}
+	if c.policy.SingleUseTickets && best.session.version == VersionTLS13 {
+		c.base.Put(slotKey(key, bestSlot), nil)
+	}
+	handedOut[key] = handedOutSession{bestSlot, best}
+	return best, true
+}
+
+func (c *PolicyClientSessionCache) put(key string, cs *ClientSessionState, handedOut map[string]handedOutSession) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if cs == nil {
+		// Only remove the session handed out by get, if it wasn't replaced
+		// since, as the base cache may store copies of the sessions.
+		h, ok := handedOut[key]
+		delete(handedOut, key)
+		if !ok {
+			return
+		}
+		stored, ok := c.base.Get(slotKey(key, h.slot))
+		if ok && stored != nil && stored.session != nil &&
+			bytes.Equal(stored.session.secret, h.session.session.secret) {
+			c.base.Put(slotKey(key, h.slot), nil)
+		}
+		return
+	}
+
+	now := c.now()
+	if c.expired(cs, now) {
+		return
+	}
+
+	// Use a free slot if there is one, otherwise evict the oldest session.
+	target, oldest := -1, uint64(0)
+	for slot := 0; slot < c.policy.MaxTicketsPerHost; slot++ {
+		stored, ok := c.base.Get(slotKey(key, slot))
+		if !ok || c.expired(stored, now) {
+			target = slot
+			break
+		}
+		if target == -1 || stored.session.createdAt < oldest {
+			target, oldest = slot, stored.session.createdAt
+		}
+	}
+	c.base.Put(slotKey(key, target), cs)
+}
+
+// clientSessionCache returns Config.ClientSessionCache or, if it is a
+// PolicyClientSessionCache, the view of the connection, so that the sessions
+// handed out to other connections are never removed by a put of a nil session.
+// The view of a UConn is the partition of its ClientHelloID.
+func (c *Conn) clientSessionCache() ClientSessionCache {
+	cache, ok := c.config.ClientSessionCache.(*PolicyClientSessionCache)
+	if !ok {
+		return c.config.ClientSessionCache
+	}
+	if view := c.utls.sessionCacheView; view != nil && view.cache == cache {
+		return view
+	}
+	prefix := unpartitioned
+	if c.utls.sessionController != nil {
+		prefix = lengthPrefixed(c.utls.sessionController.uconnRef.ClientHelloID.Str()) + lengthPrefixed("")
+	}
+	c.utls.sessionCacheView = cache.view(prefix)
+	return c.utls.sessionCacheView
+}
+
+// peekClientSession returns the session the client session cache holds for
+// cacheKey, without consuming it.
+func (c *Conn) peekClientSession(cacheKey string) (*ClientSessionState, bool) {
+	if view, ok := c.clientSessionCache().(*partitionedSessionCache); ok {
+		return view.cache.peek(view.prefix + lengthPrefixed(cacheKey))
+	}
+	return c.config.ClientSessionCache.Get(cacheKey)
+}
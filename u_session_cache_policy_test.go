@@ -0,0 +1,306 @@
+// Copyright 2026 uTLS Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tls
+
+import (
+	"testing"
+	"time"
+)
+
+func testPolicySession(version uint16, ticket string, createdAt time.Time, lifetime time.Duration) *ClientSessionState {
+	cs := MakeClientSessionState([]byte(ticket), version, TLS_AES_128_GCM_SHA256, make([]byte, 32), nil, nil)
+	cs.SetCreatedAt(uint64(createdAt.Unix()))
+	if lifetime > 0 {
+		cs.SetUseBy(uint64(createdAt.Add(lifetime).Unix()))
+	}
+	return cs
+}
+
+func TestPolicyClientSessionCacheSingleUse(t *testing.T) {
+	now := time.Unix(1000, 0)
+	cache := NewPolicyClientSessionCache(nil, SessionCachePolicy{
+		SingleUseTickets:  true,
+		MaxTicketsPerHost: 2,
+		Time:              func() time.Time { return now },
+	})
+
+	cache.Put("host", testPolicySession(VersionTLS13, "old", now, time.Hour))
+	cache.Put("host", testPolicySession(VersionTLS13, "new", now.Add(time.Second), time.Hour))
+
+	for _, want := range []string{"new", "old"} {
+		cs, ok := cache.Get("host")
+		if !ok {
+			t.Fatalf("expected ticket %q, got none", want)
+		}
+		if got := string(cs.SessionTicket()); got != want {
+			t.Fatalf("got ticket %q, want %q", got, want)
+		}
+	}
+	if _, ok := cache.Get("host"); ok {
+		t.Fatal("single-use ticket was returned twice")
+	}
+
+	// TLS 1.2 sessions are reusable.
+	cache.Put("host", testPolicySession(VersionTLS12, "tls12", now, 0))
+	for i := 0; i < 2; i++ {
+		if _, ok := cache.Get("host"); !ok {
+			t.Fatal("TLS 1.2 session was not reusable")
+		}
+	}
+}
+
+func TestPolicyClientSessionCacheMaxTickets(t *testing.T) {
+	now := time.Unix(1000, 0)
+	cache := NewPolicyClientSessionCache(nil, SessionCachePolicy{
+		MaxTicketsPerHost: 2,
+		Time:              func() time.Time { return now },
+	})
+
+	cache.Put("host", testPolicySession(VersionTLS13, "1", now, time.Hour))
+	cache.Put("host", testPolicySession(VersionTLS13, "2", now.Add(1*time.Second), time.Hour))
+	cache.Put("host", testPolicySession(VersionTLS13, "3", now.Add(2*time.Second), time.Hour))
+
+	stored := map[string]bool{}
+	for slot := 0; slot < 3; slot++ {
+		if cs, ok := cache.base.Get(slotKey(lengthPrefixed("")+lengthPrefixed("")+lengthPrefixed("host"), slot)); ok && cs != nil {
+			stored[string(cs.SessionTicket())] = true
+		}
+	}
+	if len(stored) != 2 || stored["1"] {
+		t.Errorf("expected the oldest ticket to be evicted, stored: %v", stored)
+	}
+
+	// Put(nil) removes the sessions handed out by Get, one at a time.
+	for range 2 {
+		cache.Get("host")
+		cache.Put("host", nil)
+	}
+	if _, ok := cache.Get("host"); ok {
+		t.Error("Put(nil) did not remove the host sessions")
+	}
+}
+
+func TestPolicyClientSessionCacheLifetime(t *testing.T) {
+	now := time.Unix(1000, 0)
+	cache := NewPolicyClientSessionCache(nil, SessionCachePolicy{
+		MaxLifetime: time.Hour,
+		Time:        func() time.Time { return now },
+	})
+
+	cache.Put("short", testPolicySession(VersionTLS13, "short", now, 10*time.Minute))
+	cache.Put("long", testPolicySession(VersionTLS13, "long", now, 24*time.Hour))
+	cache.Put("tls12", testPolicySession(VersionTLS12, "tls12", now, 0))
+
+	now = now.Add(30 * time.Minute)
+	if _, ok := cache.Get("short"); ok {
+		t.Error("ticket was used past its server lifetime")
+	}
+	if _, ok := cache.Get("long"); !ok {
+		t.Error("ticket expired early")
+	}
+
+	now = now.Add(time.Hour)
+	if _, ok := cache.Get("long"); ok {
+		t.Error("ticket was used past MaxLifetime")
+	}
+	if _, ok := cache.Get("tls12"); ok {
+		t.Error("TLS 1.2 session was used past MaxLifetime")
+	}
+}
+
+func TestPolicyClientSessionCachePartition(t *testing.T) {
+	now := time.Unix(1000, 0)
+	cache := NewPolicyClientSessionCache(nil, SessionCachePolicy{
+		Time: func() time.Time { return now },
+	})
+
+	chrome := cache.Partition(HelloChrome_120, "proxy-a")
+	chrome.Put("host", testPolicySession(VersionTLS13, "chrome", now, time.Hour))
+
+	for _, other := range []ClientSessionCache{
+		cache,
+		cache.Partition(HelloFirefox_120, "proxy-a"),
+		cache.Partition(HelloChrome_120, "proxy-b"),
+	} {
+		if _, ok := other.Get("host"); ok {
+			t.Errorf("session leaked across partitions")
+		}
+	}
+	if _, ok := cache.Partition(HelloChrome_120, "proxy-a").Get("host"); !ok {
+		t.Error("session missing from its own partition")
+	}
+}
+
+func TestPolicyClientSessionCacheResumption(t *testing.T) {
+	serverConfig := testConfig.Clone()
+	clientConfig := testConfig.Clone()
+	clientConfig.ServerName = "example.golang"
+
+	cache := NewPolicyClientSessionCache(nil, SessionCachePolicy{
+		SingleUseTickets: true,
+		Time:             testConfig.Time,
+	})
+
+	clientConfig.ClientSessionCache = cache.Partition(HelloGolang, "direct")
+	if _, state, err := testHandshake(t, clientConfig, serverConfig); err != nil {
+		t.Fatal(err)
+	} else if state.DidResume {
+		t.Fatal("first handshake unexpectedly resumed")
+	}
+
+	clientConfig.ClientSessionCache = cache.Partition(HelloGolang, "via-proxy")
+	if _, state, err := testHandshake(t, clientConfig, serverConfig); err != nil {
+		t.Fatal(err)
+	} else if state.DidResume {
+		t.Fatal("resumed with a ticket from another network partition")
+	}
+
+	clientConfig.ClientSessionCache = cache.Partition(HelloGolang, "direct")
+	if _, state, err := testHandshake(t, clientConfig, serverConfig); err != nil {
+		t.Fatal(err)
+	} else if !state.DidResume {
+		t.Fatal("did not resume within the same partition")
+	}
+}
+
+func TestPolicyClientSessionCacheDelete(t *testing.T) {
+	now := time.Unix(1000, 0)
+	cache := NewPolicyClientSessionCache(nil, SessionCachePolicy{
+		MaxTicketsPerHost: 2,
+		Time:              func() time.Time { return now },
+	})
+	cache.Put("host", testPolicySession(VersionTLS13, "old", now, time.Hour))
+	cache.Put("host", testPolicySession(VersionTLS13, "new", now.Add(time.Second), time.Hour))
+
+	// Without a Get, there is nothing to remove.
+	cache.Put("host", nil)
+	if cs, ok := cache.Get("host"); !ok || string(cs.SessionTicket()) != "new" {
+		t.Fatal("a session was removed without being handed out")
+	}
+
+	// Only the session handed out by Get is removed.
+	cache.Put("host", nil)
+	if cs, ok := cache.Get("host"); !ok || string(cs.SessionTicket()) != "old" {
+		t.Fatalf("got %v, %v, want the other session", cs, ok)
+	}
+}
+
+func TestPolicyClientSessionCacheUConnPartition(t *testing.T) {
+	serverConfig := testConfig.Clone()
+	clientConfig := testConfig.Clone()
+	clientConfig.ServerName = "example.golang"
+	cache := NewPolicyClientSessionCache(nil, SessionCachePolicy{Time: testConfig.Time})
+	clientConfig.ClientSessionCache = cache
+
+	handshake := func(id ClientHelloID) bool {
+		t.Helper()
+		c, s := localPipe(t)
+		go func() {
+			server := Server(s, serverConfig)
+			server.Handshake()
+			server.Close()
+		}()
+		client := UClient(c, clientConfig, id, false, false)
+		defer client.Close()
+		if err := client.Handshake(); err != nil {
+			t.Fatal(err)
+		}
+		// Read the session ticket.
+		client.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+		client.Read(make([]byte, 1))
+		return client.ConnectionState().DidResume
+	}
+
+	if handshake(HelloChrome_Auto) {
+		t.Fatal("first handshake unexpectedly resumed")
+	}
+	if _, ok := cache.Partition(HelloChrome_Auto, "").Get("example.golang"); !ok {
+		t.Fatal("the session wasn't stored in the partition of the ClientHelloID")
+	}
+	if handshake(HelloFirefox_Auto) {
+		t.Error("resumed a session of another ClientHelloID")
+	}
+}
+
+func TestPolicyClientSessionCachePeek(t *testing.T) {
+	now := time.Unix(1000, 0)
+	cache := NewPolicyClientSessionCache(nil, SessionCachePolicy{
+		SingleUseTickets: true,
+		Time:             func() time.Time { return now },
+	})
+	cache.Put("host", testPolicySession(VersionTLS13, "ticket", now, time.Hour))
+
+	for i := 0; i < 2; i++ {
+		if cs, ok := cache.Peek("host"); !ok || string(cs.SessionTicket()) != "ticket" {
+			t.Fatalf("got %v, %v, want the ticket", cs, ok)
+		}
+	}
+	// Peek doesn't hand out the session either.
+	cache.Put("host", nil)
+	if _, ok := cache.Get("host"); !ok {
+		t.Fatal("the ticket was consumed by Peek")
+	}
+	if _, ok := cache.Peek("host"); ok {
+		t.Fatal("the ticket was not consumed by Get")
+	}
+}
+
+func TestPolicyClientSessionCacheConcurrentDelete(t *testing.T) {
+	now := time.Unix(1000, 0)
+	cache := NewPolicyClientSessionCache(nil, SessionCachePolicy{
+		MaxTicketsPerHost: 2,
+		Time:              func() time.Time { return now },
+	})
+
+	// Two handshakes to the same host, through their own views.
+	first := cache.Partition(HelloGolang, "")
+	second := cache.Partition(HelloGolang, "")
+	first.Put("host", testPolicySession(VersionTLS13, "old", now, time.Hour))
+	first.Put("host", testPolicySession(VersionTLS13, "new", now.Add(time.Second), time.Hour))
+	if cs, ok := first.Get("host"); !ok || string(cs.SessionTicket()) != "new" {
+		t.Fatal("the first handshake didn't get the newest session")
+	}
+	first.Put("host", testPolicySession(VersionTLS13, "newer", now.Add(2*time.Second), time.Hour))
+	if cs, ok := second.Get("host"); !ok || string(cs.SessionTicket()) != "newer" {
+		t.Fatal("the second handshake didn't get the newest session")
+	}
+
+	// The first handshake only removes the session it was handed.
+	first.Put("host", nil)
+	if cs, ok := second.Get("host"); !ok || string(cs.SessionTicket()) != "newer" {
+		t.Fatalf("got %v, %v, want the session of the second handshake", cs, ok)
+	}
+	second.Put("host", nil)
+	if _, ok := second.Get("host"); ok {
+		t.Fatal("the sessions handed out weren't removed")
+	}
+}
+
+func TestPolicyClientSessionCacheFakePSK(t *testing.T) {
+	now := time.Unix(1000, 0)
+	cache := NewPolicyClientSessionCache(nil, SessionCachePolicy{
+		SingleUseTickets: true,
+		Time:             func() time.Time { return now },
+	})
+	cache.Partition(HelloCustom, "").Put("example.golang", testPolicySession(VersionTLS13, "ticket", now, time.Hour))
+
+	config := testConfig.Clone()
+	config.ServerName = "example.golang"
+	config.ClientSessionCache = cache
+	uconn := UClient(nil, config, HelloCustom, false, false)
+	ext := &FakePreSharedKeyExtension{
+		Identities: []PskIdentity{{Label: []byte("identity")}},
+		Binders:    [][]byte{make([]byte, 32)},
+	}
+	if err := ext.writeToUConn(uconn); err != nil {
+		t.Fatal(err)
+	}
+	if len(uconn.HandshakeState.Hello.PskIdentities) != 1 {
+		t.Error("the fake pre_shared_key extension wasn't written")
+	}
+	if _, ok := cache.Partition(HelloCustom, "").Get("example.golang"); !ok {
+		t.Error("writing the fake pre_shared_key extension consumed the ticket")
+	}
+}
@@ -3,6 +3,7 @@ package tls
 import (
 	"errors"
 	"fmt"
+	"io"
 
 	"github.com/bogdanfinn/utls/internal/tls13"
 )
@@ -75,18 +76,23 @@ const shouldReturn shouldLoadSessionResult = 0
 const shouldSetTicket shouldLoadSessionResult = 1
 const shouldSetPsk shouldLoadSessionResult = 2
 const shouldLoad shouldLoadSessionResult = 3
+const shouldLoadSessionID shouldLoadSessionResult = 4
 
 // shouldLoadSession determines the appropriate action to take when it is time to load the session for the clientHello.
 // There are several possible scenarios:
 //   - If a session ticket is already initialized, typically via the `initSessionTicketExt()` function, the ticket should be set in the client hello.
 //   - If a pre-shared key (PSK) is already initialized, typically via the `overridePskExt()` function, the PSK should be set in the client hello.
-//   - If both the `sessionTicketExt` and `pskExtension` are nil, which might occur if the client hello spec does not include them, we should skip the loadSession().
+//   - If both the `sessionTicketExt` and `pskExtension` are nil, which might occur if the client hello spec does not include them, only a TLS 1.2 session cached by the server under its session ID can be resumed.
 //   - In all other cases, the function proceeds to load the session.
 func (s *sessionController) shouldLoadSession() shouldLoadSessionResult {
-	if s.sessionTicketExt == nil && s.pskExtension == nil || s.uconnRef.clientHelloBuildStatus != NotBuilt {
-		// No need to load session since we don't have the related extensions.
+	if s.uconnRef.clientHelloBuildStatus != NotBuilt {
 		return shouldReturn
 	}
+	if s.sessionTicketExt == nil && s.pskExtension == nil {
+		// Without the related extensions, a session can only be offered
+		// with its session ID.
+		return shouldLoadSessionID
+	}
 	if s.state == SessionTicketExtInitialized {
 		return shouldSetTicket
 	}
@@ -190,6 +196,7 @@ func (s *sessionController) setSessionTicketToUConn() {
 	uAssert(s.sessionTicketExt != nil && s.state == SessionTicketExtInitialized, "tls: setSessionTicketExt failed: invalid state")
 	s.uconnRef.HandshakeState.Session = s.sessionTicketExt.GetSession()
 	s.uconnRef.HandshakeState.Hello.SessionTicket = s.sessionTicketExt.GetTicket()
+	s.uconnRef.setSessionIDForSession(s.sessionTicketExt.GetSession(), s.sessionTicketExt.GetTicket())
 	s.state = SessionTicketExtAllSet
 }
 
@@ -223,9 +230,14 @@ func (s *sessionController) shouldUpdateBinders() bool {
 	return (s.state == PskExtInitialized || s.state == PskExtAllSet)
 }
 
-func (s *sessionController) updateBinders() {
+func (s *sessionController) updateBinders() error {
 	uAssert(s.shouldUpdateBinders(), "tls: updateBinders failed: shouldn't update binders")
-	s.pskExtension.PatchBuiltHello(s.uconnRef.HandshakeState.Hello)
+	err := s.pskExtension.PatchBuiltHello(s.uconnRef.HandshakeState.Hello)
+	if err == io.EOF {
+		// UtlsPreSharedKeyExtension reports success with io.EOF.
+		return nil
+	}
+	return err
 }
 
 func (s *sessionController) overrideExtension(extension Initializable, override func(), initializedState sessionControllerState) error {
@@ -0,0 +1,204 @@
+// Copyright 2026 uTLS Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tls
+
+import (
+	"container/list"
+	"errors"
+	"io"
+	"sync"
+)
+
+// Session ID resumption, RFC 5246, Section 7.3. Before session tickets, a
+// TLS 1.0–1.2 server cached the session under the session ID it sent in the
+// ServerHello, which the client offered in the ClientHello of the next
+// connection to resume it. Clients save such sessions in the
+// ClientSessionCache, as they do tickets, and servers look them up in
+// Config.ServerSessionCache when the client didn't send a valid ticket.
+//
+// Clients treat Config.SessionTicketsDisabled as disabling resumption
+// altogether, like crypto/tls and the session controller of UConn do, so
+// session IDs are neither saved nor offered when it is set.
+
+// ServerSessionCache is a cache of the TLS 1.0–1.2 sessions of a server, keyed
+// by session ID, used to resume them when clients offer the session ID.
+// Implementations should expect to be called concurrently from different
+// goroutines.
+type ServerSessionCache interface {
+	// Get searches for the session cached under the given session ID.
+	Get(sessionID string) (session *SessionState, ok bool)
+
+	// Put adds the session to the cache under the given session ID. If
+	// called with a nil *SessionState, it should remove the cache entry.
+	Put(sessionID string, session *SessionState)
+}
+
+// lruServerSessionCache is a ServerSessionCache implementation that uses an
+// LRU caching strategy.
+type lruServerSessionCache struct {
+	sync.Mutex
+
+	m        map[string]*list.Element
+	q        *list.List
+	capacity int
+}
+
+type lruServerSessionCacheEntry struct {
+	sessionID string
+	state     *SessionState
+}
+
+// NewLRUServerSessionCache returns a [ServerSessionCache] with the given
+// capacity that uses an LRU strategy. If capacity is < 1, a default capacity
+// is used instead.
+func NewLRUServerSessionCache(capacity int) ServerSessionCache {
+	const defaultServerSessionCacheCapacity = 1024
+
+	if capacity < 1 {
+		capacity = defaultServerSessionCacheCapacity
+	}
+	return &lruServerSessionCache{
+		m:        make(map[string]*list.Element),
+		q:        list.New(),
+		capacity: capacity,
+	}
+}
+
+// Put adds the provided (sessionID, ss) pair to the cache. If ss is nil, the
+// entry corresponding to sessionID is removed from the cache instead.
+func (c *lruServerSessionCache) Put(sessionID string, ss *SessionState) {
+	c.Lock()
+	defer c.Unlock()
+
+	if elem, ok := c.m[sessionID]; ok {
+		if ss == nil {
+			c.q.Remove(elem)
+			delete(c.m, sessionID)
+		} else {
+			elem.Value.(*lruServerSessionCacheEntry).state = ss
+			c.q.MoveToFront(elem)
+		}
+		return
+	}
+	if ss == nil {
+		return
+	}
+
+	if c.q.Len() < c.capacity {
+		entry := &lruServerSessionCacheEntry{sessionID, ss}
+		c.m[sessionID] = c.q.PushFront(entry)
+		return
+	}
+
+	elem := c.q.Back()
+	entry := elem.Value.(*lruServerSessionCacheEntry)
+	delete(c.m, entry.sessionID)
+	entry.sessionID = sessionID
+	entry.state = ss
+	c.q.MoveToFront(elem)
+	c.m[sessionID] = elem
+}
+
+// Get returns the [SessionState] value associated with a given session ID.
+// It returns (nil, false) if no value is found.
+func (c *lruServerSessionCache) Get(sessionID string) (*SessionState, bool) {
+	c.Lock()
+	defer c.Unlock()
+
+	if elem, ok := c.m[sessionID]; ok {
+		c.q.MoveToFront(elem)
+		return elem.Value.(*lruServerSessionCacheEntry).state, true
+	}
+	return nil, false
+}
+
+// hasSessionIDSession reports whether the client session cache holds a
+// TLS 1.0–1.2 session without a ticket for the connection, which is resumed
+// with its session ID, without handing it out.
+func (c *Conn) hasSessionIDSession() bool {
+	cacheKey := c.clientSessionCacheKey()
+	if cacheKey == "" {
+		return false
+	}
+	cs, ok := c.peekClientSession(cacheKey)
+	return ok && cs != nil && cs.session != nil &&
+		cs.session.version != VersionTLS13 && cs.session.ticket == nil
+}
+
+// sessionIDToSave returns the session ID of a full handshake in which the
+// server didn't issue a ticket, under which it might have cached the session.
+// Like tickets, session IDs aren't saved if Config.SessionTicketsDisabled is
+// set.
+func (hs *clientHandshakeState) sessionIDToSave() []byte {
+	c := hs.c
+
+	if c.config.SessionTicketsDisabled || c.config.ClientSessionCache == nil {
+		return nil
+	}
+	if hs.ticket != nil || c.didResume || len(hs.serverHello.sessionId) == 0 {
+		return nil
+	}
+	return hs.serverHello.sessionId
+}
+
+// sessionStateFromCache returns the session cached under the session ID of
+// the ClientHello, or nil.
+func (hs *serverHandshakeState) sessionStateFromCache() *SessionState {
+	c := hs.c
+
+	if c.config.ServerSessionCache == nil || len(hs.clientHello.sessionId) == 0 {
+		return nil
+	}
+	ss, ok := c.config.ServerSessionCache.Get(string(hs.clientHello.sessionId))
+	if !ok || ss == nil || ss.isClient {
+		return nil
+	}
+	return ss
+}
+
+// newSessionID sets a random session ID in the ServerHello of a full
+// handshake, for the session to be cached under.
+func (hs *serverHandshakeState) newSessionID() error {
+	c := hs.c
+
+	if c.config.ServerSessionCache == nil {
+		return nil
+	}
+	hs.hello.sessionId = make([]byte, 32)
+	if _, err := io.ReadFull(c.config.rand(), hs.hello.sessionId); err != nil {
+		c.sendAlert(alertInternalError)
+		return errors.New("tls: short read from Rand: " + err.Error())
+	}
+	return nil
+}
+
+// cacheSessionState caches the session of a completed full handshake under
+// the session ID of the ServerHello.
+func (hs *serverHandshakeState) cacheSessionState() {
+	c := hs.c
+
+	if c.config.ServerSessionCache == nil || len(hs.hello.sessionId) == 0 {
+		return
+	}
+	state := c.sessionState()
+	state.secret = hs.masterSecret
+	c.config.ServerSessionCache.Put(string(hs.hello.sessionId), state)
+}
+
+// setSessionIDForSession sets the session ID of the ClientHello for a TLS 1.2
+// session offered by the UConn: the one the server cached the session under,
+// or the one derived from the ticket by ClientHelloSpec.GetSessionID.
+func (uconn *UConn) setSessionIDForSession(session *SessionState, ticket []byte) {
+	if session == nil || session.version > VersionTLS12 || uconn.quic != nil {
+		return
+	}
+	switch {
+	case len(ticket) == 0 && session.sessionID != nil:
+		uconn.HandshakeState.Hello.SessionId = session.sessionID
+	case len(ticket) > 0 && uconn.getSessionID != nil:
+		sessionID := uconn.getSessionID(ticket)
+		uconn.HandshakeState.Hello.SessionId = sessionID[:]
+	}
+}
@@ -0,0 +1,261 @@
+// Copyright 2026 uTLS Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tls
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"testing"
+)
+
+func testSessionIDSpec() *ClientHelloSpec {
+	return &ClientHelloSpec{
+		TLSVersMax:   VersionTLS12,
+		TLSVersMin:   VersionTLS12,
+		CipherSuites: []uint16{TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256},
+		Extensions: []TLSExtension{
+			&SupportedCurvesExtension{Curves: []CurveID{X25519}},
+			&SupportedPointsExtension{SupportedPoints: []uint8{0}}, // uncompressed
+			&SignatureAlgorithmsExtension{SupportedSignatureAlgorithms: []SignatureScheme{PSSWithSHA256, PKCS1WithSHA256}},
+			&SessionTicketExtension{},
+		},
+		GetSessionID: sha256.Sum256,
+	}
+}
+
+// testSessionIDHandshake runs a handshake between a UConn using spec and a
+// server, and returns the UConn.
+func testSessionIDHandshake(t *testing.T, clientConfig, serverConfig *Config, spec *ClientHelloSpec) (*UConn, ConnectionState) {
+	t.Helper()
+	c, s := localPipe(t)
+	serverChan := make(chan ConnectionState, 1)
+	go func() {
+		server := Server(s, serverConfig)
+		defer server.Close()
+		if err := server.Handshake(); err != nil {
+			t.Errorf("server: %v", err)
+		}
+		serverChan <- server.ConnectionState()
+	}()
+	client := UClient(c, clientConfig, HelloCustom, false, false)
+	defer client.Close()
+	if err := client.ApplyPreset(spec); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Handshake(); err != nil {
+		t.Fatalf("client: %v", err)
+	}
+	return client, <-serverChan
+}
+
+func TestSessionIDResumption(t *testing.T) {
+	serverConfig := testConfig.Clone()
+	serverConfig.MaxVersion = VersionTLS12
+	serverConfig.SessionTicketsDisabled = true
+	serverConfig.ServerSessionCache = NewLRUServerSessionCache(0)
+	// testConfig.Rand would make every session ID the same.
+	serverConfig.Rand = rand.Reader
+
+	t.Run("Client", func(t *testing.T) {
+		clientConfig := testConfig.Clone()
+		clientConfig.ServerName = "example.golang"
+		clientConfig.ClientSessionCache = NewLRUClientSessionCache(0)
+
+		_, state, err := testHandshake(t, clientConfig, serverConfig)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if state.DidResume {
+			t.Fatal("first handshake unexpectedly resumed")
+		}
+		cs, ok := clientConfig.ClientSessionCache.Get("example.golang")
+		if !ok || len(cs.session.sessionID) != 32 || cs.session.ticket != nil {
+			t.Fatal("the session ID wasn't saved")
+		}
+
+		serverState, state, err := testHandshake(t, clientConfig, serverConfig)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !state.DidResume || !serverState.DidResume {
+			t.Fatal("handshake did not resume with the session ID")
+		}
+	})
+
+	t.Run("UConn", func(t *testing.T) {
+		clientConfig := testConfig.Clone()
+		clientConfig.ServerName = "example.golang"
+		clientConfig.ClientSessionCache = NewLRUClientSessionCache(0)
+
+		client, _ := testSessionIDHandshake(t, clientConfig.Clone(), serverConfig, testSessionIDSpec())
+		if client.ConnectionState().DidResume {
+			t.Fatal("first handshake unexpectedly resumed")
+		}
+		cs, _ := clientConfig.ClientSessionCache.Get("example.golang")
+
+		client, serverState := testSessionIDHandshake(t, clientConfig.Clone(), serverConfig, testSessionIDSpec())
+		if !client.ConnectionState().DidResume || !serverState.DidResume {
+			t.Fatal("handshake did not resume with the session ID")
+		}
+		if !bytes.Equal(client.HandshakeState.Hello.SessionId, cs.session.sessionID) {
+			t.Error("the cached session ID wasn't offered")
+		}
+		if len(client.HandshakeState.Hello.SessionTicket) != 0 {
+			t.Error("a session ticket was offered")
+		}
+	})
+
+	t.Run("NoTicketExtension", func(t *testing.T) {
+		clientConfig := testConfig.Clone()
+		clientConfig.ServerName = "example.golang"
+		clientConfig.ClientSessionCache = NewLRUClientSessionCache(0)
+		spec := testSessionIDSpec()
+		spec.Extensions = spec.Extensions[:len(spec.Extensions)-1]
+
+		testSessionIDHandshake(t, clientConfig.Clone(), serverConfig, spec)
+		spec = testSessionIDSpec()
+		spec.Extensions = spec.Extensions[:len(spec.Extensions)-1]
+		client, serverState := testSessionIDHandshake(t, clientConfig.Clone(), serverConfig, spec)
+		if !client.ConnectionState().DidResume || !serverState.DidResume {
+			t.Fatal("handshake did not resume with the session ID")
+		}
+	})
+
+	t.Run("Unknown", func(t *testing.T) {
+		clientConfig := testConfig.Clone()
+		clientConfig.ServerName = "example.golang"
+		clientConfig.ClientSessionCache = NewLRUClientSessionCache(0)
+		if _, _, err := testHandshake(t, clientConfig, serverConfig); err != nil {
+			t.Fatal(err)
+		}
+
+		// A server which lost its cache falls back to a full handshake.
+		otherConfig := serverConfig.Clone()
+		otherConfig.ServerSessionCache = NewLRUServerSessionCache(0)
+		_, state, err := testHandshake(t, clientConfig, otherConfig)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if state.DidResume {
+			t.Fatal("resumed a session unknown to the server")
+		}
+	})
+
+	t.Run("ClientDisabled", func(t *testing.T) {
+		clientConfig := testConfig.Clone()
+		clientConfig.ServerName = "example.golang"
+		clientConfig.ClientSessionCache = NewLRUClientSessionCache(0)
+		clientConfig.SessionTicketsDisabled = true
+		if _, _, err := testHandshake(t, clientConfig, serverConfig); err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := clientConfig.ClientSessionCache.Get("example.golang"); ok {
+			t.Fatal("a session was saved with SessionTicketsDisabled")
+		}
+	})
+
+	t.Run("SingleUseTickets", func(t *testing.T) {
+		// Without the session_ticket and pre_shared_key extensions, a TLS 1.3
+		// ticket can't be offered, so it must stay in the cache.
+		cert, err := x509.ParseCertificate(testRSACertificate)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cache := NewPolicyClientSessionCache(nil, SessionCachePolicy{SingleUseTickets: true, Time: testConfig.Time})
+		cs := MakeClientSessionState([]byte("ticket"), VersionTLS13, TLS_AES_128_GCM_SHA256, make([]byte, 32), []*x509.Certificate{cert}, nil)
+		cs.SetUseBy(3600)
+		cache.Partition(HelloCustom, "").Put("example.golang", cs)
+
+		clientConfig := testConfig.Clone()
+		clientConfig.ServerName = "example.golang"
+		clientConfig.ClientSessionCache = cache
+		spec := testSessionIDSpec()
+		spec.Extensions = spec.Extensions[:len(spec.Extensions)-1]
+		client := UClient(nil, clientConfig, HelloCustom, false, false)
+		if err := client.ApplyPreset(spec); err != nil {
+			t.Fatal(err)
+		}
+		if err := client.BuildHandshakeState(); err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := cache.Partition(HelloCustom, "").Get("example.golang"); !ok {
+			t.Fatal("the ticket was consumed without being offered")
+		}
+	})
+}
+
+func TestSessionIDFromTicket(t *testing.T) {
+	serverConfig := testConfig.Clone()
+	serverConfig.MaxVersion = VersionTLS12
+	clientConfig := testConfig.Clone()
+	clientConfig.ServerName = "example.golang"
+	clientConfig.ClientSessionCache = NewLRUClientSessionCache(0)
+
+	testSessionIDHandshake(t, clientConfig.Clone(), serverConfig, testSessionIDSpec())
+	cs, ok := clientConfig.ClientSessionCache.Get("example.golang")
+	if !ok || cs.session.ticket == nil || cs.session.sessionID != nil {
+		t.Fatal("the session ticket wasn't saved")
+	}
+
+	client, _ := testSessionIDHandshake(t, clientConfig.Clone(), serverConfig, testSessionIDSpec())
+	if !client.ConnectionState().DidResume {
+		t.Fatal("handshake did not resume with the ticket")
+	}
+	if want := sha256.Sum256(cs.session.ticket); !bytes.Equal(client.HandshakeState.Hello.SessionId, want[:]) {
+		t.Error("the session ID wasn't derived from the ticket with GetSessionID")
+	}
+}
+
+func TestClientSessionStateSessionID(t *testing.T) {
+	serverConfig := testConfig.Clone()
+	serverConfig.MaxVersion = VersionTLS12
+	serverConfig.SessionTicketsDisabled = true
+	serverConfig.ServerSessionCache = NewLRUServerSessionCache(0)
+	clientConfig := testConfig.Clone()
+	cache := &capturingSessionCache{}
+	clientConfig.ClientSessionCache = cache
+	clientConfig.ServerName = "example.golang"
+	if _, _, err := testHandshake(t, clientConfig, serverConfig); err != nil {
+		t.Fatal(err)
+	}
+	cs := cache.last
+	if cs == nil || len(cs.session.sessionID) != 32 {
+		t.Fatal("no session ID was stored")
+	}
+
+	b, err := cs.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := ParseClientSessionState(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(parsed.session.sessionID, cs.session.sessionID) {
+		t.Errorf("got session ID %x, want %x", parsed.session.sessionID, cs.session.sessionID)
+	}
+
+	// The format 1 encoding, which predates session IDs, is still parsed.
+	cs.session.sessionID = nil
+	b, err = cs.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	offset := 2 + 2 + 2 + 8 + 8 + 4 + 1 + len(cs.session.secret) + 2 + len(cs.session.ticket)
+	if b[offset] != 0 {
+		t.Fatal("unexpected encoding")
+	}
+	v1 := append([]byte{0, 1}, b[2:offset]...)
+	v1 = append(v1, b[offset+1:]...)
+	parsed, err = ParseClientSessionState(v1)
+	if err != nil {
+		t.Fatalf("failed to parse the format 1 encoding: %v", err)
+	}
+	if parsed.session.sessionID != nil || !bytes.Equal(parsed.session.secret, cs.session.secret) {
+		t.Error("format 1 encoding parsed incorrectly")
+	}
+}
@@ -0,0 +1,107 @@
+// Copyright 2026 uTLS Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tls
+
+import (
+	"crypto"
+	"crypto/cipher"
+	"errors"
+	"hash"
+	"slices"
+)
+
+// tls13Hash is the hash of a TLS 1.3 cipher suite. It is a crypto.Hash, or
+// hashSM3 for the ShangMi suites, as SM3 has no crypto.Hash value.
+type tls13Hash crypto.Hash
+
+// hashSM3 is SM3, out of the range of crypto.Hash values.
+const hashSM3 tls13Hash = 0x100
+
+func (h tls13Hash) New() hash.Hash {
+	if h == hashSM3 {
+		return newSM3()
+	}
+	return crypto.Hash(h).New()
+}
+
+func (h tls13Hash) Size() int {
+	if h == hashSM3 {
+		return sm3Size
+	}
+	return crypto.Hash(h).Size()
+}
+
+// shangMiCipherSuitesTLS13 are the ShangMi suites of RFC 8998. They are kept
+// apart from cipherSuitesTLS13, which other packages access by linkname.
+var shangMiCipherSuitesTLS13 = []*cipherSuiteTLS13{
+	{TLS_SM4_GCM_SM3, 16, aeadSM4GCMTLS13, hashSM3},
+	{TLS_SM4_CCM_SM3, 16, aeadSM4CCMTLS13, hashSM3},
+}
+
+func aeadSM4GCMTLS13(key, nonceMask []byte) aead {
+	return aeadSM4TLS13(key, nonceMask, cipher.NewGCM)
+}
+
+func aeadSM4CCMTLS13(key, nonceMask []byte) aead {
+	return aeadSM4TLS13(key, nonceMask, newCCM)
+}
+
+func aeadSM4TLS13(key, nonceMask []byte, mode func(cipher.Block) (cipher.AEAD, error)) aead {
+	if len(nonceMask) != aeadNonceLength {
+		panic("tls: internal error: wrong nonce length")
+	}
+	block, err := newSM4Cipher(key)
+	if err != nil {
+		panic(err)
+	}
+	aead, err := mode(block)
+	if err != nil {
+		panic(err)
+	}
+
+	ret := &xorNonceAEAD{aead: aead}
+	copy(ret.nonceMask[:], nonceMask)
+	return ret
+}
+
+func isShangMiCipherSuite(id uint16) bool {
+	return id == TLS_SM4_GCM_SM3 || id == TLS_SM4_CCM_SM3
+}
+
+// errShangMiDisabled is returned when a ShangMi algorithm is about to be used
+// without Config.InsecureShangMi, such as a suite of a ClientHelloSpec selected
+// by the server.
+var errShangMiDisabled = errors.New("tls: the ShangMi algorithms are disabled, see Config.InsecureShangMi")
+
+// shangMiEnabled reports whether c enables the ShangMi algorithms, whose
+// implementation is not constant time.
+func (c *Config) shangMiEnabled() bool {
+	return c != nil && c.InsecureShangMi
+}
+
+// checkShangMiSignature returns errShangMiDisabled if sigAlg is SM2WithSM3
+// and c doesn't enable the ShangMi algorithms.
+func (c *Config) checkShangMiSignature(sigAlg SignatureScheme) error {
+	if sigAlg == SM2WithSM3 && !c.shangMiEnabled() {
+		return errShangMiDisabled
+	}
+	return nil
+}
+
+// shangMiCipherSuites returns the ShangMi suites listed in c.CipherSuites,
+// which the Go client advertises and the Go server prefers in TLS 1.3, if c
+// enables them.
+func (c *Config) shangMiCipherSuites() []uint16 {
+	if !c.shangMiEnabled() {
+		return nil
+	}
+	var ids []uint16
+	for _, id := range c.CipherSuites {
+		if isShangMiCipherSuite(id) && !slices.Contains(ids, id) {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
@@ -0,0 +1,415 @@
+// Copyright 2026 uTLS Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tls
+
+import (
+	"crypto"
+	"crypto/elliptic"
+	"crypto/subtle"
+	"crypto/x509"
+	"encoding/asn1"
+	"errors"
+	"io"
+	"math/big"
+	"sync"
+
+	"golang.org/x/crypto/cryptobyte"
+	cryptobyte_asn1 "golang.org/x/crypto/cryptobyte/asn1"
+)
+
+// The SM2 elliptic curve, GB/T 32918.5-2017, its signature scheme, GB/T
+// 32918.2-2016, and their use in TLS 1.3 by RFC 8998: the curveSM2 key
+// exchange group and the sm2sig_sm3 signature scheme.
+//
+// The arithmetic uses the generic, variable time, implementation of
+// crypto/elliptic, as crypto/ecdh and crypto/ecdsa don't support the curve.
+// Signing and key exchange may thus leak private keys and nonces through
+// timing side channels, which is why they require Config.InsecureShangMi.
+
+var sm2P256 = sync.OnceValue(func() *elliptic.CurveParams {
+	hex := func(s string) *big.Int {
+		n, _ := new(big.Int).SetString(s, 16)
+		return n
+	}
+	// The curve has a = p - 3, as assumed by CurveParams.
+	return &elliptic.CurveParams{
+		Name:    "SM2-P-256",
+		BitSize: 256,
+		P:       hex("FFFFFFFEFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFF00000000FFFFFFFFFFFFFFFF"),
+		N:       hex("FFFFFFFEFFFFFFFFFFFFFFFFFFFFFFFF7203DF6B21C6052B53BBF40939D54123"),
+		B:       hex("28E9FA9E9D9F5E344D5A9E4BCF6509A7F39789F515AB8F92DDBCBD414D940E93"),
+		Gx:      hex("32C4AE2C1F1981195F9904466A39C9948FE30BBFF2660BE1715A4589334C74C7"),
+		Gy:      hex("BC3736A2F4F6779C59BDCEE36B692153D0A9877CC62A474002DF32E52139F0A0"),
+	}
+})
+
+var (
+	oidPublicKeyEC = asn1.ObjectIdentifier{1, 2, 840, 10045, 2, 1}
+	oidSM2         = asn1.ObjectIdentifier{1, 2, 156, 10197, 1, 301}
+)
+
+// sm2SignatureID is the distinguishing identifier mixed into SM2 signatures
+// in TLS 1.3, see RFC 8998, Section 3.2.1.
+const sm2SignatureID = "TLSv1.3+GM+Cipher+Suite"
+
+const sm2PointSize = 1 + 2*32
+
+type sm2PublicKey struct {
+	x, y *big.Int
+}
+
+// Equal reports whether pub and x have the same value.
+func (pub *sm2PublicKey) Equal(x crypto.PublicKey) bool {
+	xx, ok := x.(*sm2PublicKey)
+	return ok && pub.x.Cmp(xx.x) == 0 && pub.y.Cmp(xx.y) == 0
+}
+
+func (pub *sm2PublicKey) bytes() []byte {
+	return elliptic.Marshal(sm2P256(), pub.x, pub.y)
+}
+
+// parseSM2PublicKey parses an uncompressed point of the SM2 curve.
+func parseSM2PublicKey(point []byte) (*sm2PublicKey, error) {
+	curve := sm2P256()
+	if len(point) != sm2PointSize || point[0] != 4 {
+		return nil, errors.New("tls: invalid SM2 public key")
+	}
+	x := new(big.Int).SetBytes(point[1:33])
+	y := new(big.Int).SetBytes(point[33:])
+	if x.Cmp(curve.P) >= 0 || y.Cmp(curve.P) >= 0 || !curve.IsOnCurve(x, y) {
+		return nil, errors.New("tls: invalid SM2 public key")
+	}
+	return &sm2PublicKey{x: x, y: y}, nil
+}
+
+// sm2PrivateKey is an SM2 private key, which signs with sm2sig_sm3.
+type sm2PrivateKey struct {
+	sm2PublicKey
+	d *big.Int
+}
+
+func newSM2PrivateKey(d *big.Int) (*sm2PrivateKey, error) {
+	curve := sm2P256()
+	// d+1 must be invertible, so d is at most n-2.
+	if d.Sign() <= 0 || d.Cmp(new(big.Int).Sub(curve.N, big.NewInt(1))) >= 0 {
+		return nil, errors.New("tls: invalid SM2 private key")
+	}
+	x, y := curve.ScalarBaseMult(d.FillBytes(make([]byte, 32)))
+	return &sm2PrivateKey{sm2PublicKey: sm2PublicKey{x: x, y: y}, d: d}, nil
+}
+
+// generateSM2Key returns a new private key, with a scalar in [1, n-2].
+func generateSM2Key(rand io.Reader) (*sm2PrivateKey, error) {
+	d, err := randSM2Scalar(rand, 2)
+	if err != nil {
+		return nil, err
+	}
+	return newSM2PrivateKey(d)
+}
+
+// randSM2Scalar returns a random scalar in [1, n-1-skip]. It reduces 64 more
+// bits than the size of n, making the bias negligible, rather than rejecting
+// out of range values, which would loop forever on a constant reader.
+func randSM2Scalar(rand io.Reader, skip int64) (*big.Int, error) {
+	b := make([]byte, 32+8)
+	if _, err := io.ReadFull(rand, b); err != nil {
+		return nil, err
+	}
+	k := new(big.Int).SetBytes(b)
+	k.Mod(k, new(big.Int).Sub(sm2P256().N, big.NewInt(1+skip)))
+	return k.Add(k, big.NewInt(1)), nil
+}
+
+func (priv *sm2PrivateKey) Public() crypto.PublicKey {
+	return &priv.sm2PublicKey
+}
+
+// Sign signs msg, which is not hashed beforehand, with sm2sig_sm3. opts must
+// not specify a hash, as the message is hashed with SM3 together with the
+// public key and sm2SignatureID.
+func (priv *sm2PrivateKey) Sign(rand io.Reader, msg []byte, opts crypto.SignerOpts) ([]byte, error) {
+	if opts.HashFunc() != 0 {
+		return nil, errors.New("tls: SM2 signatures are computed over the whole message")
+	}
+	curve := sm2P256()
+	n := curve.N
+	e := sm2Digest(&priv.sm2PublicKey, msg)
+	dPlus1Inv := new(big.Int).ModInverse(new(big.Int).Add(priv.d, big.NewInt(1)), n)
+	for {
+		k, err := randSM2Scalar(rand, 0)
+		if err != nil {
+			return nil, err
+		}
+		x1, _ := curve.ScalarBaseMult(k.FillBytes(make([]byte, 32)))
+		r := new(big.Int).Add(e, x1)
+		r.Mod(r, n)
+		if r.Sign() == 0 || new(big.Int).Add(r, k).Cmp(n) == 0 {
+			continue
+		}
+		s := new(big.Int).Mul(r, priv.d)
+		s.Sub(k, s)
+		s.Mul(s, dPlus1Inv)
+		s.Mod(s, n)
+		if s.Sign() == 0 {
+			continue
+		}
+		var b cryptobyte.Builder
+		b.AddASN1(cryptobyte_asn1.SEQUENCE, func(b *cryptobyte.Builder) {
+			b.AddASN1BigInt(r)
+			b.AddASN1BigInt(s)
+		})
+		return b.Bytes()
+	}
+}
+
+// sm2Verify reports whether sig is a valid sm2sig_sm3 signature of msg.
+func sm2Verify(pub *sm2PublicKey, msg, sig []byte) bool {
+	curve := sm2P256()
+	n := curve.N
+	r, s := new(big.Int), new(big.Int)
+	input := cryptobyte.String(sig)
+	var inner cryptobyte.String
+	if !input.ReadASN1(&inner, cryptobyte_asn1.SEQUENCE) || !input.Empty() ||
+		!inner.ReadASN1Integer(r) || !inner.ReadASN1Integer(s) || !inner.Empty() {
+		return false
+	}
+	if r.Sign() <= 0 || r.Cmp(n) >= 0 || s.Sign() <= 0 || s.Cmp(n) >= 0 {
+		return false
+	}
+	t := new(big.Int).Add(r, s)
+	t.Mod(t, n)
+	if t.Sign() == 0 {
+		return false
+	}
+	x1, y1 := curve.ScalarBaseMult(s.FillBytes(make([]byte, 32)))
+	x2, y2 := curve.ScalarMult(pub.x, pub.y, t.FillBytes(make([]byte, 32)))
+	x, _ := curve.Add(x1, y1, x2, y2)
+	v := new(big.Int).Add(sm2Digest(pub, msg), x)
+	v.Mod(v, n)
+	return subtle.ConstantTimeCompare(v.Bytes(), r.Bytes()) == 1
+}
+
+// sm2Digest returns e = SM3(Z || msg), where Z hashes sm2SignatureID, the
+// curve parameters and the public key.
+func sm2Digest(pub *sm2PublicKey, msg []byte) *big.Int {
+	curve := sm2P256()
+	h := newSM3()
+	h.Write([]byte{byte(len(sm2SignatureID) * 8 >> 8), byte(len(sm2SignatureID) * 8)})
+	h.Write([]byte(sm2SignatureID))
+	a := new(big.Int).Sub(curve.P, big.NewInt(3))
+	for _, v := range []*big.Int{a, curve.B, curve.Gx, curve.Gy, pub.x, pub.y} {
+		h.Write(v.FillBytes(make([]byte, 32)))
+	}
+	z := h.Sum(nil)
+	h.Reset()
+	h.Write(z)
+	h.Write(msg)
+	return new(big.Int).SetBytes(h.Sum(nil))
+}
+
+// sm2KeyShare holds the client secret of a TLS 1.3 key share for CurveSM2.
+type sm2KeyShare struct {
+	priv *sm2PrivateKey
+}
+
+// generateSM2KeyShare returns a new client secret for CurveSM2 and the
+// matching key share, an uncompressed point, see RFC 8998, Section 3.1.
+func generateSM2KeyShare(rand io.Reader) (*sm2KeyShare, []byte, error) {
+	priv, err := generateSM2Key(rand)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &sm2KeyShare{priv: priv}, priv.bytes(), nil
+}
+
+func (k *sm2KeyShare) curveID() CurveID {
+	return CurveSM2
+}
+
+// sharedKey returns the x coordinate of the shared point.
+func (k *sm2KeyShare) sharedKey(serverShare []byte) ([]byte, error) {
+	peer, err := parseSM2PublicKey(serverShare)
+	if err != nil {
+		return nil, errors.New("tls: invalid server SM2 key share")
+	}
+	x, _ := sm2P256().ScalarMult(peer.x, peer.y, k.priv.d.FillBytes(make([]byte, 32)))
+	return x.FillBytes(make([]byte, 32)), nil
+}
+
+// sm2ServerShare returns the server key share and the shared secret for a
+// CurveSM2 client key share.
+func sm2ServerShare(rand io.Reader, clientShare []byte) (serverShare, sharedKey []byte, err error) {
+	key, serverShare, err := generateSM2KeyShare(rand)
+	if err != nil {
+		return nil, nil, err
+	}
+	sharedKey, err = key.sharedKey(clientShare)
+	if err != nil {
+		return nil, nil, errors.New("tls: invalid SM2 client key share")
+	}
+	return serverShare, sharedKey, nil
+}
+
+// readSM2AlgorithmIdentifier reads an AlgorithmIdentifier and reports
+// whether it is the one of SM2 keys: id-ecPublicKey with the SM2 curve, or
+// the SM2 OID itself.
+func readSM2AlgorithmIdentifier(input *cryptobyte.String) bool {
+	var ai cryptobyte.String
+	var oid, curve asn1.ObjectIdentifier
+	if !input.ReadASN1(&ai, cryptobyte_asn1.SEQUENCE) || !ai.ReadASN1ObjectIdentifier(&oid) {
+		return false
+	}
+	if oid.Equal(oidSM2) {
+		return true
+	}
+	return oid.Equal(oidPublicKeyEC) && ai.ReadASN1ObjectIdentifier(&curve) && curve.Equal(oidSM2)
+}
+
+// sm2PublicKeyFromSPKI returns the SM2 key of a SubjectPublicKeyInfo, or
+// false if it doesn't hold one.
+func sm2PublicKeyFromSPKI(spki []byte) (*sm2PublicKey, bool, error) {
+	input := cryptobyte.String(spki)
+	var inner cryptobyte.String
+	var point asn1.BitString
+	if !input.ReadASN1(&inner, cryptobyte_asn1.SEQUENCE) || !readSM2AlgorithmIdentifier(&inner) {
+		return nil, false, nil
+	}
+	if !inner.ReadASN1BitString(&point) || point.BitLength%8 != 0 {
+		return nil, true, errors.New("tls: invalid SM2 public key")
+	}
+	pub, err := parseSM2PublicKey(point.Bytes)
+	return pub, true, err
+}
+
+// parseSM2PrivateKey parses an SM2 private key in PKCS #8 or SEC 1 form.
+func parseSM2PrivateKey(der []byte) (*sm2PrivateKey, error) {
+	input := cryptobyte.String(der)
+	var seq cryptobyte.String
+	if !input.ReadASN1(&seq, cryptobyte_asn1.SEQUENCE) || !input.Empty() {
+		return nil, errors.New("tls: invalid SM2 private key")
+	}
+	var version int
+	if !seq.ReadASN1Integer(&version) {
+		return nil, errors.New("tls: invalid SM2 private key")
+	}
+	sm2Curve := false
+	if version == 0 {
+		// PKCS #8: the AlgorithmIdentifier, and the SEC 1 key.
+		var sec1 cryptobyte.String
+		if !readSM2AlgorithmIdentifier(&seq) || !seq.ReadASN1(&sec1, cryptobyte_asn1.OCTET_STRING) {
+			return nil, errors.New("tls: not an SM2 private key")
+		}
+		seq = nil
+		if !sec1.ReadASN1(&seq, cryptobyte_asn1.SEQUENCE) || !seq.ReadASN1Integer(&version) {
+			return nil, errors.New("tls: invalid SM2 private key")
+		}
+		sm2Curve = true
+	}
+	var d cryptobyte.String
+	if version != 1 || !seq.ReadASN1(&d, cryptobyte_asn1.OCTET_STRING) {
+		return nil, errors.New("tls: invalid SM2 private key")
+	}
+	var params cryptobyte.String
+	var hasParams bool
+	if !seq.ReadOptionalASN1(&params, &hasParams, cryptobyte_asn1.Tag(0).Constructed().ContextSpecific()) {
+		return nil, errors.New("tls: invalid SM2 private key")
+	}
+	if hasParams {
+		var curve asn1.ObjectIdentifier
+		if !params.ReadASN1ObjectIdentifier(&curve) || !curve.Equal(oidSM2) {
+			return nil, errors.New("tls: not an SM2 private key")
+		}
+		sm2Curve = true
+	}
+	if !sm2Curve {
+		return nil, errors.New("tls: not an SM2 private key")
+	}
+	return newSM2PrivateKey(new(big.Int).SetBytes(d))
+}
+
+// parseCertificate is x509.ParseCertificate, extended to certificates for
+// SM2 keys, whose curve crypto/x509 rejects. Their PublicKeyAlgorithm is
+// x509.UnknownPublicKeyAlgorithm, and certificatePublicKey returns their key.
+//
+// crypto/x509 can't verify SM2 signatures either, so chains issued by SM2
+// authorities have to be verified by Config.VerifyPeerCertificate.
+func parseCertificate(der []byte) (*x509.Certificate, error) {
+	cert, err := x509.ParseCertificate(der)
+	if err == nil {
+		return cert, nil
+	}
+
+	// Parse a copy of the certificate with an SM2 key under an algorithm
+	// crypto/x509 doesn't know, and restore the original encodings.
+	input := cryptobyte.String(der)
+	var certificate, tbs, tbsElement cryptobyte.String
+	if !input.ReadASN1(&certificate, cryptobyte_asn1.SEQUENCE) {
+		return nil, err
+	}
+	signature := certificate
+	if !signature.ReadASN1Element(&tbsElement, cryptobyte_asn1.SEQUENCE) {
+		return nil, err
+	}
+	tbs = tbsElement
+	var fields cryptobyte.String
+	if !tbs.ReadASN1(&fields, cryptobyte_asn1.SEQUENCE) {
+		return nil, err
+	}
+	var prefix []byte
+	if fields.PeekASN1Tag(cryptobyte_asn1.Tag(0).Constructed().ContextSpecific()) {
+		var version cryptobyte.String
+		fields.ReadASN1Element(&version, cryptobyte_asn1.Tag(0).Constructed().ContextSpecific())
+		prefix = append(prefix, version...)
+	}
+	// serialNumber, signature, issuer, validity, subject
+	for range 5 {
+		var field cryptobyte.String
+		var tag cryptobyte_asn1.Tag
+		if !fields.ReadAnyASN1Element(&field, &tag) {
+			return nil, err
+		}
+		prefix = append(prefix, field...)
+	}
+	var spki cryptobyte.String
+	if !fields.ReadASN1Element(&spki, cryptobyte_asn1.SEQUENCE) {
+		return nil, err
+	}
+	if _, ok, _ := sm2PublicKeyFromSPKI(spki); !ok {
+		return nil, err
+	}
+	spkiContents := cryptobyte.String(spki)
+	var point asn1.BitString
+	if !spkiContents.ReadASN1(&spkiContents, cryptobyte_asn1.SEQUENCE) ||
+		!spkiContents.SkipASN1(cryptobyte_asn1.SEQUENCE) || !spkiContents.ReadASN1BitString(&point) {
+		return nil, err
+	}
+
+	var b cryptobyte.Builder
+	b.AddASN1(cryptobyte_asn1.SEQUENCE, func(b *cryptobyte.Builder) {
+		b.AddASN1(cryptobyte_asn1.SEQUENCE, func(b *cryptobyte.Builder) {
+			b.AddBytes(prefix)
+			b.AddASN1(cryptobyte_asn1.SEQUENCE, func(b *cryptobyte.Builder) {
+				b.AddASN1(cryptobyte_asn1.SEQUENCE, func(b *cryptobyte.Builder) {
+					b.AddASN1ObjectIdentifier(oidSM2)
+				})
+				b.AddASN1BitString(point.Bytes)
+			})
+			b.AddBytes(fields)
+		})
+		b.AddBytes(signature)
+	})
+	patched, bErr := b.Bytes()
+	if bErr != nil {
+		return nil, err
+	}
+	cert, pErr := x509.ParseCertificate(patched)
+	if pErr != nil {
+		return nil, err
+	}
+	cert.Raw = der
+	cert.RawTBSCertificate = tbsElement
+	cert.RawSubjectPublicKeyInfo = spki
+	return cert, nil
+}
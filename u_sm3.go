@@ -0,0 +1,168 @@
+// Copyright 2026 uTLS Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tls
+
+import (
+	"errors"
+	"hash"
+	"math/bits"
+
+	"github.com/bogdanfinn/utls/internal/byteorder"
+)
+
+// The SM3 hash function, GB/T 32905-2016, used by the ShangMi suites of
+// RFC 8998. It has no crypto.Hash value.
+
+const (
+	sm3Size      = 32
+	sm3BlockSize = 64
+
+	sm3Magic         = "sm3\x01"
+	sm3MarshaledSize = len(sm3Magic) + 8*4 + sm3BlockSize + 8
+)
+
+var sm3IV = [8]uint32{0x7380166f, 0x4914b2b9, 0x172442d7, 0xda8a0600, 0xa96f30bc, 0x163138aa, 0xe38dee4d, 0xb0fb0e4e}
+
+type sm3Digest struct {
+	h   [8]uint32
+	x   [sm3BlockSize]byte
+	nx  int
+	len uint64
+}
+
+func newSM3() hash.Hash {
+	d := new(sm3Digest)
+	d.Reset()
+	return d
+}
+
+func (d *sm3Digest) Reset() {
+	d.h = sm3IV
+	d.nx = 0
+	d.len = 0
+}
+
+func (d *sm3Digest) Size() int      { return sm3Size }
+func (d *sm3Digest) BlockSize() int { return sm3BlockSize }
+
+func (d *sm3Digest) Write(p []byte) (int, error) {
+	n := len(p)
+	d.len += uint64(n)
+	if d.nx > 0 {
+		c := copy(d.x[d.nx:], p)
+		d.nx += c
+		if d.nx == sm3BlockSize {
+			sm3Block(&d.h, d.x[:])
+			d.nx = 0
+		}
+		p = p[c:]
+	}
+	for len(p) >= sm3BlockSize {
+		sm3Block(&d.h, p[:sm3BlockSize])
+		p = p[sm3BlockSize:]
+	}
+	if len(p) > 0 {
+		d.nx = copy(d.x[:], p)
+	}
+	return n, nil
+}
+
+func (d *sm3Digest) Sum(in []byte) []byte {
+	// Make a copy of d so that the caller can keep writing and summing.
+	d0 := *d
+	length := d0.len
+	var tmp [sm3BlockSize + 8]byte
+	tmp[0] = 0x80
+	padLen := 55 - int(length%64)
+	if padLen < 0 {
+		padLen += 64
+	}
+	byteorder.BEPutUint64(tmp[1+padLen:], length<<3)
+	d0.Write(tmp[:1+padLen+8])
+	if d0.nx != 0 {
+		panic("tls: internal error: SM3 padding")
+	}
+	for _, v := range d0.h {
+		in = byteorder.BEAppendUint32(in, v)
+	}
+	return in
+}
+
+// MarshalBinary and UnmarshalBinary allow cloneHash to copy the state of a
+// transcript hash.
+func (d *sm3Digest) MarshalBinary() ([]byte, error) {
+	b := make([]byte, 0, sm3MarshaledSize)
+	b = append(b, sm3Magic...)
+	for _, v := range d.h {
+		b = byteorder.BEAppendUint32(b, v)
+	}
+	b = append(b, d.x[:d.nx]...)
+	b = append(b, make([]byte, sm3BlockSize-d.nx)...)
+	return byteorder.BEAppendUint64(b, d.len), nil
+}
+
+func (d *sm3Digest) UnmarshalBinary(b []byte) error {
+	if len(b) != sm3MarshaledSize || string(b[:len(sm3Magic)]) != sm3Magic {
+		return errors.New("tls: invalid SM3 hash state")
+	}
+	b = b[len(sm3Magic):]
+	for i := range d.h {
+		d.h[i] = byteorder.BEUint32(b)
+		b = b[4:]
+	}
+	copy(d.x[:], b[:sm3BlockSize])
+	d.len = byteorder.BEUint64(b[sm3BlockSize:])
+	d.nx = int(d.len % sm3BlockSize)
+	return nil
+}
+
+func sm3Block(h *[8]uint32, p []byte) {
+	var w [68]uint32
+	for i := 0; i < 16; i++ {
+		w[i] = byteorder.BEUint32(p[4*i:])
+	}
+	for i := 16; i < 68; i++ {
+		x := w[i-16] ^ w[i-9] ^ bits.RotateLeft32(w[i-3], 15)
+		w[i] = x ^ bits.RotateLeft32(x, 15) ^ bits.RotateLeft32(x, 23) ^
+			bits.RotateLeft32(w[i-13], 7) ^ w[i-6]
+	}
+
+	a, b, c, d, e, f, g, hh := h[0], h[1], h[2], h[3], h[4], h[5], h[6], h[7]
+	for j := 0; j < 64; j++ {
+		t := uint32(0x79cc4519)
+		if j >= 16 {
+			t = 0x7a879d8a
+		}
+		a12 := bits.RotateLeft32(a, 12)
+		ss1 := bits.RotateLeft32(a12+e+bits.RotateLeft32(t, j%32), 7)
+		ss2 := ss1 ^ a12
+		var ff, gg uint32
+		if j < 16 {
+			ff = a ^ b ^ c
+			gg = e ^ f ^ g
+		} else {
+			ff = (a & b) | (a & c) | (b & c)
+			gg = (e & f) | (^e & g)
+		}
+		tt1 := ff + d + ss2 + (w[j] ^ w[j+4])
+		tt2 := gg + hh + ss1 + w[j]
+		d = c
+		c = bits.RotateLeft32(b, 9)
+		b = a
+		a = tt1
+		hh = g
+		g = bits.RotateLeft32(f, 19)
+		f = e
+		e = tt2 ^ bits.RotateLeft32(tt2, 9) ^ bits.RotateLeft32(tt2, 17)
+	}
+	h[0] ^= a
+	h[1] ^= b
+	h[2] ^= c
+	h[3] ^= d
+	h[4] ^= e
+	h[5] ^= f
+	h[6] ^= g
+	h[7] ^= hh
+}
@@ -0,0 +1,229 @@
+// Copyright 2026 uTLS Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tls
+
+import (
+	"crypto/cipher"
+	"crypto/subtle"
+	"errors"
+	"math/bits"
+
+	"github.com/bogdanfinn/utls/internal/byteorder"
+)
+
+// The SM4 block cipher, GB/T 32907-2016, and the CCM mode of RFC 3610, used
+// by the ShangMi suites of RFC 8998.
+//
+// The S-box is a table indexed by key-dependent values, so the cipher is not
+// constant time and may leak keys through cache timing side channels, which
+// is why the suites require Config.InsecureShangMi.
+
+const sm4BlockSize = 16
+
+var sm4Sbox = [256]byte{
+	0xd6, 0x90, 0xe9, 0xfe, 0xcc, 0xe1, 0x3d, 0xb7, 0x16, 0xb6, 0x14, 0xc2, 0x28, 0xfb, 0x2c, 0x05,
+	0x2b, 0x67, 0x9a, 0x76, 0x2a, 0xbe, 0x04, 0xc3, 0xaa, 0x44, 0x13, 0x26, 0x49, 0x86, 0x06, 0x99,
+	0x9c, 0x42, 0x50, 0xf4, 0x91, 0xef, 0x98, 0x7a, 0x33, 0x54, 0x0b, 0x43, 0xed, 0xcf, 0xac, 0x62,
+	0xe4, 0xb3, 0x1c, 0xa9, 0xc9, 0x08, 0xe8, 0x95, 0x80, 0xdf, 0x94, 0xfa, 0x75, 0x8f, 0x3f, 0xa6,
+	0x47, 0x07, 0xa7, 0xfc, 0xf3, 0x73, 0x17, 0xba, 0x83, 0x59, 0x3c, 0x19, 0xe6, 0x85, 0x4f, 0xa8,
+	0x68, 0x6b, 0x81, 0xb2, 0x71, 0x64, 0xda, 0x8b, 0xf8, 0xeb, 0x0f, 0x4b, 0x70, 0x56, 0x9d, 0x35,
+	0x1e, 0x24, 0x0e, 0x5e, 0x63, 0x58, 0xd1, 0xa2, 0x25, 0x22, 0x7c, 0x3b, 0x01, 0x21, 0x78, 0x87,
+	0xd4, 0x00, 0x46, 0x57, 0x9f, 0xd3, 0x27, 0x52, 0x4c, 0x36, 0x02, 0xe7, 0xa0, 0xc4, 0xc8, 0x9e,
+	0xea, 0xbf, 0x8a, 0xd2, 0x40, 0xc7, 0x38, 0xb5, 0xa3, 0xf7, 0xf2, 0xce, 0xf9, 0x61, 0x15, 0xa1,
+	0xe0, 0xae, 0x5d, 0xa4, 0x9b, 0x34, 0x1a, 0x55, 0xad, 0x93, 0x32, 0x30, 0xf5, 0x8c, 0xb1, 0xe3,
+	0x1d, 0xf6, 0xe2, 0x2e, 0x82, 0x66, 0xca, 0x60, 0xc0, 0x29, 0x23, 0xab, 0x0d, 0x53, 0x4e, 0x6f,
+	0xd5, 0xdb, 0x37, 0x45, 0xde, 0xfd, 0x8e, 0x2f, 0x03, 0xff, 0x6a, 0x72, 0x6d, 0x6c, 0x5b, 0x51,
+	0x8d, 0x1b, 0xaf, 0x92, 0xbb, 0xdd, 0xbc, 0x7f, 0x11, 0xd9, 0x5c, 0x41, 0x1f, 0x10, 0x5a, 0xd8,
+	0x0a, 0xc1, 0x31, 0x88, 0xa5, 0xcd, 0x7b, 0xbd, 0x2d, 0x74, 0xd0, 0x12, 0xb8, 0xe5, 0xb4, 0xb0,
+	0x89, 0x69, 0x97, 0x4a, 0x0c, 0x96, 0x77, 0x7e, 0x65, 0xb9, 0xf1, 0x09, 0xc5, 0x6e, 0xc6, 0x84,
+	0x18, 0xf0, 0x7d, 0xec, 0x3a, 0xdc, 0x4d, 0x20, 0x79, 0xee, 0x5f, 0x3e, 0xd7, 0xcb, 0x39, 0x48,
+}
+
+var sm4FK = [4]uint32{0xa3b1bac6, 0x56aa3350, 0x677d9197, 0xb27022dc}
+
+// sm4CK are the round constants, whose byte j of word i is (4i+j)*7 mod 256.
+var sm4CK = func() (ck [32]uint32) {
+	for i := range ck {
+		for j := 0; j < 4; j++ {
+			ck[i] = ck[i]<<8 | uint32(byte((4*i+j)*7))
+		}
+	}
+	return ck
+}()
+
+func sm4Tau(x uint32) uint32 {
+	return uint32(sm4Sbox[x>>24])<<24 | uint32(sm4Sbox[x>>16&0xff])<<16 |
+		uint32(sm4Sbox[x>>8&0xff])<<8 | uint32(sm4Sbox[x&0xff])
+}
+
+type sm4Cipher struct {
+	rk [32]uint32
+}
+
+func newSM4Cipher(key []byte) (cipher.Block, error) {
+	if len(key) != 16 {
+		return nil, errors.New("tls: invalid SM4 key size")
+	}
+	c := new(sm4Cipher)
+	var k [4]uint32
+	for i := range k {
+		k[i] = byteorder.BEUint32(key[4*i:]) ^ sm4FK[i]
+	}
+	for i := range c.rk {
+		t := sm4Tau(k[1] ^ k[2] ^ k[3] ^ sm4CK[i])
+		c.rk[i] = k[0] ^ t ^ bits.RotateLeft32(t, 13) ^ bits.RotateLeft32(t, 23)
+		k[0], k[1], k[2], k[3] = k[1], k[2], k[3], c.rk[i]
+	}
+	return c, nil
+}
+
+func (c *sm4Cipher) BlockSize() int { return sm4BlockSize }
+
+func (c *sm4Cipher) crypt(dst, src []byte, decrypt bool) {
+	if len(src) < sm4BlockSize || len(dst) < sm4BlockSize {
+		panic("tls: SM4 input not full block")
+	}
+	var x [4]uint32
+	for i := range x {
+		x[i] = byteorder.BEUint32(src[4*i:])
+	}
+	for i := 0; i < 32; i++ {
+		rk := c.rk[i]
+		if decrypt {
+			rk = c.rk[31-i]
+		}
+		t := sm4Tau(x[1] ^ x[2] ^ x[3] ^ rk)
+		t = x[0] ^ t ^ bits.RotateLeft32(t, 2) ^ bits.RotateLeft32(t, 10) ^
+			bits.RotateLeft32(t, 18) ^ bits.RotateLeft32(t, 24)
+		x[0], x[1], x[2], x[3] = x[1], x[2], x[3], t
+	}
+	for i := range x {
+		byteorder.BEPutUint32(dst[4*i:], x[3-i])
+	}
+}
+
+func (c *sm4Cipher) Encrypt(dst, src []byte) { c.crypt(dst, src, false) }
+func (c *sm4Cipher) Decrypt(dst, src []byte) { c.crypt(dst, src, true) }
+
+const (
+	ccmNonceSize = 12
+	ccmTagSize   = 16
+)
+
+// ccm implements CCM, RFC 3610, with 12 byte nonces and 16 byte tags, as
+// used by TLS 1.3, see RFC 8446, Section 5.3.
+type ccm struct {
+	b cipher.Block
+}
+
+func newCCM(b cipher.Block) (cipher.AEAD, error) {
+	if b.BlockSize() != 16 {
+		return nil, errors.New("tls: CCM requires a 128-bit block cipher")
+	}
+	return &ccm{b: b}, nil
+}
+
+func (c *ccm) NonceSize() int { return ccmNonceSize }
+func (c *ccm) Overhead() int  { return ccmTagSize }
+
+// The length field of the CCM blocks is 15 - ccmNonceSize bytes long.
+const ccmLengthSize = 15 - ccmNonceSize
+
+func (c *ccm) mac(nonce, plaintext, additionalData []byte) [16]byte {
+	var b0 [16]byte
+	b0[0] = byte((ccmTagSize-2)/2<<3 | (ccmLengthSize - 1))
+	if len(additionalData) > 0 {
+		b0[0] |= 0x40
+	}
+	copy(b0[1:], nonce)
+	n := uint64(len(plaintext))
+	for i := 15; i > ccmNonceSize; i-- {
+		b0[i] = byte(n)
+		n >>= 8
+	}
+
+	var x [16]byte
+	c.b.Encrypt(x[:], b0[:])
+	block := func(p []byte) {
+		for len(p) > 0 {
+			n := subtle.XORBytes(x[:], x[:], p)
+			c.b.Encrypt(x[:], x[:])
+			p = p[n:]
+		}
+	}
+	if len(additionalData) > 0 {
+		// Additional data shorter than 0xff00 bytes, which is always the
+		// case for TLS, has a two byte length prefix.
+		var first [16]byte
+		byteorder.BEPutUint16(first[:], uint16(len(additionalData)))
+		n := copy(first[2:], additionalData)
+		block(first[:])
+		rest := additionalData[n:]
+		full := len(rest) &^ 15
+		block(rest[:full])
+		if len(rest) > full {
+			var last [16]byte
+			copy(last[:], rest[full:])
+			block(last[:])
+		}
+	}
+	full := len(plaintext) &^ 15
+	block(plaintext[:full])
+	if len(plaintext) > full {
+		var last [16]byte
+		copy(last[:], plaintext[full:])
+		block(last[:])
+	}
+	return x
+}
+
+// ctr applies the CCM keystream starting at counter 1 to src, and returns
+// the first keystream block, used to encrypt the tag.
+func (c *ccm) ctr(dst, src, nonce []byte) [16]byte {
+	var ctr [16]byte
+	ctr[0] = ccmLengthSize - 1
+	copy(ctr[1:], nonce)
+	var s0 [16]byte
+	c.b.Encrypt(s0[:], ctr[:])
+	ctr[15] = 1
+	cipher.NewCTR(c.b, ctr[:]).XORKeyStream(dst, src)
+	return s0
+}
+
+func (c *ccm) Seal(dst, nonce, plaintext, additionalData []byte) []byte {
+	if len(nonce) != ccmNonceSize {
+		panic("tls: incorrect CCM nonce length")
+	}
+	if uint64(len(plaintext)) >= 1<<(8*ccmLengthSize) {
+		panic("tls: CCM plaintext too large")
+	}
+	tag := c.mac(nonce, plaintext, additionalData)
+	ret, out := sliceForAppend(dst, len(plaintext)+ccmTagSize)
+	s0 := c.ctr(out, plaintext, nonce)
+	subtle.XORBytes(out[len(plaintext):], tag[:], s0[:])
+	return ret
+}
+
+var errCCMOpen = errors.New("tls: CCM message authentication failed")
+
+func (c *ccm) Open(dst, nonce, ciphertext, additionalData []byte) ([]byte, error) {
+	if len(nonce) != ccmNonceSize {
+		panic("tls: incorrect CCM nonce length")
+	}
+	if len(ciphertext) < ccmTagSize {
+		return nil, errCCMOpen
+	}
+	tagged := ciphertext[len(ciphertext)-ccmTagSize:]
+	ciphertext = ciphertext[:len(ciphertext)-ccmTagSize]
+	ret, out := sliceForAppend(dst, len(ciphertext))
+	s0 := c.ctr(out, ciphertext, nonce)
+	tag := c.mac(nonce, out, additionalData)
+	subtle.XORBytes(tag[:], tag[:], s0[:])
+	if subtle.ConstantTimeCompare(tag[:], tagged) != 1 {
+		clear(out)
+		return nil, errCCMOpen
+	}
+	return ret, nil
+}
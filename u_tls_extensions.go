@@ -39,6 +39,10 @@ func ExtensionFromID(id uint16) TLSExtension {
 		return &UtlsPaddingExtension{}
 	case ExtensionExtendedMasterSecret:
 		return &ExtendedMasterSecretExtension{}
+	case extensionEncryptThenMAC:
+		return &EncryptThenMACExtension{}
+	case utlsExtensionPostHandshakeAuth:
+		return &PostHandshakeAuthExtension{}
 	case fakeExtensionTokenBinding:
 		return &FakeTokenBindingExtension{}
 	case utlsExtensionCompressCertificate:
@@ -966,6 +970,75 @@ func (e *ExtendedMasterSecretExtension) Write(_ []byte) (int, error) {
 	return 0, nil
 }
 
+// EncryptThenMACExtension implements encrypt_then_mac (22), see RFC 7366.
+//
+// If the server echoes it and selects a CBC cipher suite, records are
+// encrypted before being MACed.
+type EncryptThenMACExtension struct {
+}
+
+func (e *EncryptThenMACExtension) writeToUConn(uc *UConn) error {
+	uc.HandshakeState.Hello.EncryptThenMAC = true
+	return nil
+}
+
+func (e *EncryptThenMACExtension) Len() int {
+	return 4
+}
+
+func (e *EncryptThenMACExtension) Read(b []byte) (int, error) {
+	if len(b) < e.Len() {
+		return 0, io.ErrShortBuffer
+	}
+	b[0] = byte(extensionEncryptThenMAC >> 8)
+	b[1] = byte(extensionEncryptThenMAC)
+	// The length is 0
+	return e.Len(), io.EOF
+}
+
+func (e *EncryptThenMACExtension) UnmarshalJSON(_ []byte) error {
+	return nil // no-op
+}
+
+func (e *EncryptThenMACExtension) Write(_ []byte) (int, error) {
+	return 0, nil
+}
+
+// PostHandshakeAuthExtension implements post_handshake_auth (49), see RFC 8446,
+// Section 4.2.6.
+//
+// It lets a TLS 1.3 server request a client certificate after the handshake,
+// which is answered with Config.Certificates or Config.GetClientCertificate.
+type PostHandshakeAuthExtension struct {
+}
+
+func (e *PostHandshakeAuthExtension) writeToUConn(uc *UConn) error {
+	uc.HandshakeState.Hello.PostHandshakeAuth = true
+	return nil
+}
+
+func (e *PostHandshakeAuthExtension) Len() int {
+	return 4
+}
+
+func (e *PostHandshakeAuthExtension) Read(b []byte) (int, error) {
+	if len(b) < e.Len() {
+		return 0, io.ErrShortBuffer
+	}
+	b[0] = byte(utlsExtensionPostHandshakeAuth >> 8)
+	b[1] = byte(utlsExtensionPostHandshakeAuth)
+	// The length is 0
+	return e.Len(), io.EOF
+}
+
+func (e *PostHandshakeAuthExtension) UnmarshalJSON(_ []byte) error {
+	return nil // no-op
+}
+
+func (e *PostHandshakeAuthExtension) Write(_ []byte) (int, error) {
+	return 0, nil
+}
+
 // GREASE stinks with dead parrots, have to be super careful, and, if possible, not include GREASE
 // https://github.com/google/boringssl/blob/1c68fa2350936ca5897a66b430ebaf333a0e43f5/ssl/internal.h
 const (
@@ -1608,11 +1681,15 @@ func (e *CookieExtension) UnmarshalJSON(data []byte) error {
 
 // NPNExtension implements next_protocol_negotiation (Not IANA assigned)
 type NPNExtension struct {
+	// NextProtos, if not empty, replaces Config.NextProtos, from which the
+	// protocol is selected if the server offers NPN.
 	NextProtos []string
 }
 
 func (e *NPNExtension) writeToUConn(uc *UConn) error {
-	uc.config.NextProtos = e.NextProtos
+	if len(e.NextProtos) > 0 {
+		uc.config.NextProtos = e.NextProtos
+	}
 	uc.HandshakeState.Hello.NextProtoNeg = true
 	return nil
 }